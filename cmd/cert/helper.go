@@ -1,7 +1,7 @@
 package main
 
 import (
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -13,8 +13,8 @@ import (
 	"github.com/shurco/gosign/pkg/security/cert"
 )
 
-func generatePrivateKey(path string) (*cert.PrivateKey, error) {
-	p, err := cert.GetPrivateKey()
+func generatePrivateKey(path string, keyType cert.KeyType) (*cert.PrivateKey, error) {
+	p, err := cert.GeneratePrivateKey(keyType)
 	if err != nil {
 		return &cert.PrivateKey{}, err
 	}
@@ -33,7 +33,7 @@ func isExist(path string) bool {
 	return !errors.Is(err, os.ErrNotExist)
 }
 
-func getKeyIdentifier(publicKey *ecdsa.PublicKey) ([]byte, error) {
+func getKeyIdentifier(publicKey crypto.PublicKey) ([]byte, error) {
 	b, err := x509.MarshalPKIXPublicKey(publicKey)
 	if err != nil {
 		return nil, err
@@ -43,8 +43,8 @@ func getKeyIdentifier(publicKey *ecdsa.PublicKey) ([]byte, error) {
 	return ki[:], nil
 }
 
-func generateCA(pkey *ecdsa.PrivateKey) (*cert.Result, error) {
-	ski, err := getKeyIdentifier(&pkey.PublicKey)
+func generateCA(pkey crypto.Signer) (*cert.Result, error) {
+	ski, err := getKeyIdentifier(pkey.Public())
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +70,7 @@ func generateCA(pkey *ecdsa.PrivateKey) (*cert.Result, error) {
 	return caCert, store(caCert.String(), caPath)
 }
 
-func generateCRL(pkey *ecdsa.PrivateKey, caCert *x509.Certificate) error {
+func generateCRL(pkey crypto.Signer, caCert *x509.Certificate) error {
 	nextUpdate := time.Now().AddDate(20, 0, 0)
 	crl, _, err := cert.CreateCRL(pkey, caCert, nil, nextUpdate)
 	if err != nil {
@@ -80,7 +80,7 @@ func generateCRL(pkey *ecdsa.PrivateKey, caCert *x509.Certificate) error {
 	return store(crl.String(), caCRLPath)
 }
 
-func generateIntermediateCert(pkey *ecdsa.PrivateKey) error {
+func generateIntermediateCert(pkey crypto.Signer) error {
 	parentKey, err := getCAPrivateKey(caKeyPath)
 	if err != nil {
 		return err
@@ -118,7 +118,7 @@ func generateIntermediateCert(pkey *ecdsa.PrivateKey) error {
 	return err
 }
 
-func getCAPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+func getCAPrivateKey(path string) (crypto.Signer, error) {
 	f, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -141,9 +141,9 @@ func getCACert(path string) (*x509.Certificate, error) {
 	return cert.ParseCertificate(f)
 }
 
-func generateCert(pkey *ecdsa.PrivateKey) (err error) {
+func generateCert(pkey crypto.Signer) (err error) {
 	var parent *x509.Certificate
-	var parentKey *ecdsa.PrivateKey
+	var parentKey crypto.Signer
 	var aki, ski []byte
 
 	if parentKey, err = getCAPrivateKey(caInterKeyPath); err != nil {
@@ -168,11 +168,11 @@ func generateCert(pkey *ecdsa.PrivateKey) (err error) {
 		}
 	}
 
-	if aki, err = getKeyIdentifier(&parentKey.PublicKey); err != nil {
+	if aki, err = getKeyIdentifier(parentKey.Public()); err != nil {
 		return err
 	}
 
-	if ski, err = getKeyIdentifier(&pkey.PublicKey); err != nil {
+	if ski, err = getKeyIdentifier(pkey.Public()); err != nil {
 		return err
 	}
 
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+
+	"github.com/shurco/gosign/pkg/security/cert"
 )
 
 var (
@@ -16,8 +19,16 @@ var (
 )
 
 func main() {
+	keyTypeName := flag.String("key", string(cert.KeyTypeECDSAP256), "key type: ecdsa-p256, ecdsa-p384, rsa-2048, rsa-3072, rsa-4096 or ed25519")
+	flag.Parse()
+
+	keyType, err := cert.ParseKeyType(*keyTypeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// CA cert
-	pKey, err := generatePrivateKey(caKeyPath)
+	pKey, err := generatePrivateKey(caKeyPath, keyType)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -35,7 +46,7 @@ func main() {
 	fmt.Println("CRL file generated", caCRLPath)
 
 	// CA Intermed cert
-	ipKey, err := generatePrivateKey(caInterKeyPath)
+	ipKey, err := generatePrivateKey(caInterKeyPath, keyType)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -47,7 +58,7 @@ func main() {
 	fmt.Println("CA Intermed certificate file generated")
 
 	// user cert
-	upKey, err := generatePrivateKey(uKeyPath)
+	upKey, err := generatePrivateKey(uKeyPath, keyType)
 	if err != nil {
 		log.Fatal(err)
 	}
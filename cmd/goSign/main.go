@@ -24,6 +24,12 @@ func main() {
 	switch command {
 	case "serve":
 		handleServe()
+	case "storage":
+		handleStorage(os.Args[2:])
+	case "ca":
+		handleCA(os.Args[2:])
+	case "secrets":
+		handleSecrets(os.Args[2:])
 	case "version", "-v", "--version":
 		fmt.Printf("goSign %s (%s) from %s\n", version, gitCommit, buildDate)
 		os.Exit(0)
@@ -43,6 +49,14 @@ func printUsage() {
 	fmt.Println("  gosign <command> [flags]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  serve     Start the web server")
+	fmt.Println("  storage   Storage maintenance: rewrap (re-wrap document keys with the current key),")
+	fmt.Println("            gc [--dry-run] [--grace 168h] (remove unreferenced blobs and files),")
+	fmt.Println("            migrate --from local --to s3 [--verify] (copy documents to another provider),")
+	fmt.Println("            migrate --org <id> --s3-bucket <bucket> [--verify] (move an organization's bucket)")
+	fmt.Println("  ca        Internal CA: init --cert ca-cert.pem --key ca-key.pem [--key-type ecdsa-p256]")
+	fmt.Println("            (create the CA certificate and key all nodes are started with)")
+	fmt.Println("  secrets   Stored secrets: rewrap (re-encrypt signing keys and bucket credentials")
+	fmt.Println("            from GOSIGN_PREVIOUS_ENCRYPTION_KEY to GOSIGN_ENCRYPTION_KEY)")
 	fmt.Println("  version   Show version information")
 	fmt.Println("  help      Show this help message")
 }
@@ -52,3 +66,24 @@ func handleServe() {
 		os.Exit(1)
 	}
 }
+
+func handleStorage(args []string) {
+	if err := app.Storage(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func handleCA(args []string) {
+	if err := app.CA(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func handleSecrets(args []string) {
+	if err := app.Secrets(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
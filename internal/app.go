@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,9 +14,9 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/gofiber/fiber/v3/middleware/static"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/shurco/gosign/internal/assets"
 	"github.com/shurco/gosign/internal/config"
@@ -25,15 +26,26 @@ import (
 	"github.com/shurco/gosign/internal/queries"
 	"github.com/shurco/gosign/internal/routes"
 	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/internal/services/ca"
 	"github.com/shurco/gosign/internal/services/submission"
+	"github.com/shurco/gosign/internal/services/tsa"
 	"github.com/shurco/gosign/internal/trust"
+	"github.com/shurco/gosign/internal/worker"
+	"github.com/shurco/gosign/internal/worker/tasks"
 	"github.com/shurco/gosign/pkg/appdir"
 	"github.com/shurco/gosign/pkg/geolocation"
 	"github.com/shurco/gosign/pkg/logging"
 	"github.com/shurco/gosign/pkg/notification"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/security/cert"
+	"github.com/shurco/gosign/pkg/security/keystore"
+	"github.com/shurco/gosign/pkg/security/kms"
+	"github.com/shurco/gosign/pkg/security/secret"
+	"github.com/shurco/gosign/pkg/storage"
 	"github.com/shurco/gosign/pkg/storage/postgres"
 	"github.com/shurco/gosign/pkg/storage/redis"
 	"github.com/shurco/gosign/pkg/utils"
+	"github.com/shurco/gosign/pkg/webhook"
 )
 
 func New() error {
@@ -108,12 +120,25 @@ func New() error {
 		submissionRepo: submissionRepo,
 	}
 
-	submissionService := submission.NewService(submissionRepo, nil, nil)
+	// Webhook events go through a delivery outbox; a job retries failed deliveries with backoff
+	webhookDeliveries := services.NewWebhookDeliveryService(queries.NewWebhookDeliveryRepository(pool), webhook.NewDispatcher(0, 30*time.Second))
+	// Lifecycle events of submissions and templates are all emitted, with their payloads, by webhookEvents
+	webhookEvents := services.NewWebhookEvents(queries.NewWebhookEventRepository(pool), webhookDeliveries)
 
-	// update trust certs
+	// update trust certs; a list that can't be fetched keeps its previous anchors
+	if len(cfg.TrustSources) > 0 {
+		if trust.Sources, err = trust.ParseSources(cfg.TrustSources); err != nil {
+			log.Err(err).Msg("GOSIGN_TRUST_SOURCES")
+			return err
+		}
+		for _, src := range trust.Sources {
+			if src.Insecure {
+				log.Warn().Str("list", src.List).Msg("Trust list signatures are not verified (insecure), do not use in production")
+			}
+		}
+	}
 	if err = trust.Update(); err != nil {
-		log.Err(err).Send()
-		return err
+		log.Err(err).Msg("Failed to update trust lists")
 	}
 
 	// Download GeoLite2 database if needed (after Adobe certificates update)
@@ -134,23 +159,151 @@ func New() error {
 
 	middleware.Fiber(app, log, cfg)
 	routes.SiteRoutes(app)
-	app.Use("/drive/pages", static.New(appdir.LcPages()))
-	app.Use("/drive/signed", static.New(appdir.LcSigned()))
-	app.Use("/drive/uploads", static.New(appdir.LcUploads()))
+
+	// Blob storage for every document file (pages, previews, originals, completed PDFs, certificates)
+	blobStorage, err := initStorage(settingQueries)
+	if err != nil {
+		log.Err(err).Msg("Failed to initialize storage")
+		return err
+	}
+	// Files served by the application (/drive) are only downloaded through expiring signed URLs,
+	// which is also how the local storage gives URLs
+	urlKey := sha256.Sum256([]byte("storage-url:" + cfg.EncryptionKey))
+	storageURLs := storage.NewURLSigner(urlKey[:], cfg.PublicURL+"/drive/files")
+	if local, ok := blobStorage.(*storage.LocalStorage); ok {
+		local.URLs = storageURLs
+	}
+	templateQueries.PageURL = public.PageURLs(storageURLs, cfg.PublicURL+"/drive/pages")
+	// Envelope encryption of documents at rest, in the global storage and in organization buckets
+	encryptStorage, err := initStorageEncryption(cfg)
+	if err != nil {
+		log.Err(err).Msg("GOSIGN_STORAGE_KEYRING")
+		return err
+	}
+	if encryptStorage != nil {
+		blobStorage = encryptStorage(blobStorage)
+	}
 
 	// Initialize webhook repository
 	webhookRepo := &simpleWebhookRepository{}
 
-	notificationService := initNotificationService(settingQueries)
+	// Scheduled notifications (reminders) are kept in the notification table
+	notificationService := initNotificationService(settingQueries, queries.NewNotificationRepository(stdlib.OpenDBFromPool(pool)))
+
+	// Each invited signing group gets the reminders its template asks for
+	submissionService := submission.NewService(submissionRepo, notificationService, webhookEvents)
+	reminders := services.NewReminderService(notificationService, submissionRepo, templateQueries)
+	submissionService.Reminders = reminders
+
+	// Signing certificate store; the platform seal key serves scopes without a default certificate.
+	keyCipher, err := secret.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	// Keys of external certificates stay on a PKCS#11 token or behind a remote signing service;
+	// each organization may only use the services and token objects configured for it.
+	var keyBackends *keystore.Policies
+	if cfg.KeyBackends != "" {
+		if keyBackends, err = keystore.LoadPolicies(cfg.KeyBackends); err != nil {
+			log.Err(err).Msg("GOSIGN_KEY_BACKENDS")
+			return err
+		}
+		if cfg.PKCS11Module != "" {
+			pkcs11 := &keystore.PKCS11Backend{Module: cfg.PKCS11Module, Pin: cfg.PKCS11Pin}
+			defer pkcs11.Close()
+			keyBackends.PKCS11 = pkcs11
+		}
+	}
+	certificateService := services.NewCertificateService(
+		queries.NewCertificateRepository(pool),
+		keyCipher,
+		&services.FileSealingKeyProvider{Dir: filepath.Join(appdir.Base(), "seal")},
+		keyBackends,
+	)
 
-	// Completed document builder (filesystem-backed cache).
+	// Internal CA for short-lived signer certificates; CRL and OCSP are published under PublicURL.
+	// All nodes share its certificate and key, so they publish the same CRL and OCSP answers.
+	caCert, caKey, err := loadCA(cfg)
+	if err != nil {
+		log.Err(err).Msg("GOSIGN_CA_CERT, GOSIGN_CA_KEY")
+		return err
+	}
+	authority, err := ca.New(queries.NewIssuedCertificateRepository(pool), ca.Config{
+		Certificate:   caCert,
+		Key:           caKey,
+		BaseURL:       cfg.PublicURL,
+		SignerKeyType: cert.KeyType(cfg.CASignerKeyType),
+	})
+	if err != nil {
+		log.Err(err).Msg("internal CA")
+		return err
+	}
+	// Embed OCSP/CRL data for our own certificates in-process at signing time
+	sign.RegisterLocalRevocationSource(authority)
+
+	// Built-in RFC 3161 TSA, enabled by a timestamping certificate from the store.
+	// The signer calls it in-process so sealed documents are timestamped without network access.
+	var (
+		tsaHandler *public.TSAHandler
+		timestamps sign.TimestampFunction
+	)
+	if cfg.TSACertificateID != "" {
+		tsaServer := tsa.New(certificateService, tsa.Config{CertificateID: cfg.TSACertificateID})
+		tsaHandler = public.NewTSAHandler(tsaServer)
+		timestamps = tsaServer.Timestamp
+	}
+
+	// Documents of organizations with their own storage (data residency) go to it
+	orgStorage := services.NewOrganizationStorageService(queries.NewOrganizationStorageRepository(pool), keyCipher)
+	storages := storage.NewRegistry(blobStorage, orgStorage.Config)
+	storages.Decorate = encryptStorage
+
+	// Template pages are stored content addressed; a daily job removes what is no longer referenced
+	blobs := services.NewBlobService(queries.NewStorageBlobRepository(pool), storages, orgStorage)
+	jobs := worker.NewWorker(0)
+	defer jobs.Stop()
+	if err := jobs.AddJob(worker.Job{
+		Name:     "storage-gc",
+		Schedule: "0 0 * * *",
+		Task:     tasks.NewStorageGCTask(blobs, services.DefaultBlobGracePeriod, false),
+	}); err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	if err := jobs.AddJob(worker.Job{
+		Name:     "reminders",
+		Schedule: "*/5 * * * *",
+		Task:     tasks.NewRemindersTask(reminders),
+	}); err != nil {
+		log.Err(err).Send()
+		return err
+	}
+	if err := jobs.AddJob(worker.Job{
+		Name:     "webhook-deliveries",
+		Schedule: "*/1 * * * *",
+		Task:     worker.NewWebhookTask(webhookDeliveries),
+	}); err != nil {
+		log.Err(err).Send()
+		return err
+	}
+
+	// Completed document builder (cached in the blob storage).
+	documentHashes := queries.NewDocumentHashRepository(pool)
 	completedDoc := &services.CompletedDocumentBuilder{
 		Pool:            pool,
 		TemplateQueries: templateQueries,
-		PagesDir:        appdir.LcPages(),
-		SignedDir:       appdir.LcSigned(),
+		Storages:        storages,
 		AssetsDir:       assetPaths.Dir,
+		SealingKeys:     certificateService,
+		SubmitterKeys:   authority,
+		Timestamps:      timestamps,
+		DocumentHashes:  documentHashes,
+		Blobs:           blobs,
 	}
+	// Payloads of completed submissions carry download URLs signed at each delivery attempt
+	webhookDeliveries.Documents = completedDoc
+	jobs.Start()
 
 	// Initialize geolocation service (best-effort; works without database)
 	geolocationDBPath := os.Getenv("GEOLITE2_DB_PATH")
@@ -179,9 +332,9 @@ func New() error {
 	apiHandlers := &routes.APIHandlers{
 		Submissions:    api.NewSubmissionHandler(submissionRepoImpl, submissionService),
 		Submitters:     nil, // TODO: initialize with repository and service
-		SigningLinks:   api.NewSigningLinkHandler(pool, templateQueries, completedDoc),
-		Templates:      api.NewTemplateHandler(templateRepo, templateQueries),
-		Webhooks:       api.NewWebhookHandler(webhookRepo),
+		SigningLinks:   api.NewSigningLinkHandler(pool, templateQueries, completedDoc, webhookEvents),
+		Templates:      api.NewTemplateHandler(templateRepo, templateQueries, blobs, webhookEvents),
+		Webhooks:       api.NewWebhookHandler(webhookRepo, webhookDeliveries, userQueries),
 		Settings:       api.NewSettingsHandler(notificationService, accountQueries, userQueries, geolocationSvc, settingQueries),
 		APIKeys:        api.NewAPIKeyHandler(apiKeyService),
 		Stats:          api.NewStatsHandler(pool),
@@ -193,7 +346,17 @@ func New() error {
 		I18n:           api.NewI18nHandler(userQueries, accountQueries),
 		Branding:       api.NewBrandingHandler(accountQueries, userQueries, nil), // TODO: initialize with storage
 		EmailTemplates: api.NewEmailTemplateHandler(emailTemplateQueries, userQueries),
-		PublicSigning:  public.NewPublicSigningHandler(pool, templateQueries, userQueries, notificationService, completedDoc, geolocationSvc),
+		Certificates:   api.NewCertificateHandler(certificateService, userQueries, organizationQueries),
+		CA:             api.NewCAHandler(authority, userQueries, organizationQueries),
+		PublicCA:       public.NewCAHandler(authority),
+		PublicTSA:      tsaHandler,
+		PublicSigning:  public.NewPublicSigningHandler(pool, templateQueries, userQueries, notificationService, completedDoc, geolocationSvc, webhookEvents, submissionService),
+		Sign:           public.NewSignHandler(certificateService, userQueries, timestamps, storages, storageURLs),
+		VerifyReport:   public.NewVerifyReportHandler(public.TrustAnchors(authority), userQueries, assetPaths.Dir),
+		DocumentHash:   public.NewDocumentHashHandler(documentHashes),
+		Drive:          public.NewDriveHandler(storages, blobs.LocateFile, storageURLs),
+		OrgStorage:     api.NewOrganizationStorageHandler(orgStorage, organizationQueries, userQueries),
+		Trust:          api.NewTrustHandler(&queries.DB.TrustQueries, userQueries, organizationQueries),
 	}
 
 	routes.ApiRoutes(app, apiHandlers)
@@ -257,8 +420,8 @@ func createDirs() error {
 	return nil
 }
 
-func initNotificationService(settingQueries *queries.SettingQueries) *notification.Service {
-	svc := notification.NewService(nil)
+func initNotificationService(settingQueries *queries.SettingQueries, repo notification.Repository) *notification.Service {
+	svc := notification.NewService(repo)
 	ctx := context.Background()
 
 	if smtpMap, err := settingQueries.GetGlobalSetting(ctx, "smtp"); err == nil && utils.GetStringFromMap(smtpMap, "provider", "") == "smtp" {
@@ -291,6 +454,51 @@ func initNotificationService(settingQueries *queries.SettingQueries) *notificati
 	return svc
 }
 
+// initStorage creates the blob storage from the global "storage" setting;
+// without one documents are kept in the local data directory
+func initStorage(settingQueries *queries.SettingQueries) (storage.BlobStorage, error) {
+	ctx := context.Background()
+	cfg := storage.Config{Provider: "local"}
+
+	if storageMap, err := settingQueries.GetGlobalSetting(ctx, "storage"); err == nil && utils.GetStringFromMap(storageMap, "provider", "") == "s3" {
+		cfg = s3StorageConfig(storageMap)
+	}
+
+	return storage.NewStorage(ctx, cfg)
+}
+
+// s3StorageConfig returns the S3 configuration of the "storage" global setting
+func s3StorageConfig(storageMap map[string]any) storage.Config {
+	return storage.Config{
+		Provider: "s3",
+		Bucket:   utils.GetStringFromMap(storageMap, "bucket", ""),
+		Region:   utils.GetStringFromMap(storageMap, "region", ""),
+		Endpoint: utils.GetStringFromMap(storageMap, "endpoint", ""),
+		Options: map[string]string{
+			"access_key_id":     utils.GetStringFromMap(storageMap, "access_key_id", ""),
+			"secret_access_key": utils.GetStringFromMap(storageMap, "secret_access_key", ""),
+		},
+	}
+}
+
+// initStorageEncryption returns the decorator encrypting stored documents with data keys
+// wrapped by the keyring of GOSIGN_STORAGE_KEYRING, or nil when encryption is disabled.
+// Documents stored before encryption was enabled stay readable.
+func initStorageEncryption(cfg *config.Config) (func(storage.BlobStorage) storage.BlobStorage, error) {
+	if cfg.StorageKeyring == "" {
+		return nil, nil
+	}
+	keyring, err := kms.LoadKeyring(cfg.StorageKeyring)
+	if err != nil {
+		return nil, err
+	}
+	return func(s storage.BlobStorage) storage.BlobStorage {
+		encrypted := storage.NewEncryptedStorage(s, keyring)
+		encrypted.AllowPlaintext = true
+		return encrypted
+	}, nil
+}
+
 // scheduleGeoLite2Updates mirrors the Adobe trust-list updater loop:
 // a frequent tick (12h) + a "staleness" check so downloads happen ~2x/week.
 func scheduleGeoLite2Updates(pool *pgxpool.Pool, log *logging.Logger, geoSvc *geolocation.Service) {
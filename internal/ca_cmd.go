@@ -0,0 +1,109 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shurco/gosign/internal/config"
+	"github.com/shurco/gosign/internal/services/ca"
+	"github.com/shurco/gosign/pkg/appdir"
+	"github.com/shurco/gosign/pkg/security/cert"
+)
+
+// CA runs an internal CA command: gosign ca <command>
+func CA(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gosign ca init --cert ca-cert.pem --key ca-key.pem [--key-type ecdsa-p256] [--name \"goSign Internal CA\"]")
+	}
+	switch args[0] {
+	case "init":
+		return caInit(args[1:])
+	default:
+		return fmt.Errorf("unknown ca command: %s", args[0])
+	}
+}
+
+// caInit generates the CA certificate and key files every node is then started with
+func caInit(args []string) error {
+	fs := flag.NewFlagSet("ca init", flag.ContinueOnError)
+	certPath := fs.String("cert", "", "file to write the CA certificate to")
+	keyPath := fs.String("key", "", "file to write the CA key to")
+	keyTypeName := fs.String("key-type", string(cert.KeyTypeECDSAP256), "key type: ecdsa-p256, ecdsa-p384, rsa-2048, rsa-3072 or rsa-4096")
+	name := fs.String("name", "", "CA common name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *certPath == "" || *keyPath == "" {
+		return errors.New("--cert and --key are required")
+	}
+	keyType, err := cert.ParseKeyType(*keyTypeName)
+	if err != nil {
+		return err
+	}
+
+	certPEM, keyPEM, err := ca.Generate(*name, keyType)
+	if err != nil {
+		return err
+	}
+	if err := writeCA(*certPath, *keyPath, certPEM, keyPEM); err != nil {
+		return err
+	}
+	fmt.Printf("CA certificate written to %s, key to %s\n", *certPath, *keyPath)
+	return nil
+}
+
+// loadCA reads the CA certificate and key configured for all nodes. A development
+// instance without them uses a CA generated in its data directory instead.
+func loadCA(cfg *config.Config) (certPEM, keyPEM []byte, err error) {
+	certPath, keyPath := cfg.CACert, cfg.CAKey
+	if certPath == "" || keyPath == "" {
+		if !cfg.DevMode {
+			return nil, nil, ca.ErrNotConfigured
+		}
+		dir := filepath.Join(appdir.Base(), "ca")
+		certPath, keyPath = filepath.Join(dir, "ca-cert.pem"), filepath.Join(dir, "ca-key.pem")
+		if _, err := os.Stat(keyPath); errors.Is(err, os.ErrNotExist) {
+			if certPEM, keyPEM, err = ca.Generate("", ""); err != nil {
+				return nil, nil, err
+			}
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, nil, err
+			}
+			return certPEM, keyPEM, writeCA(certPath, keyPath, certPEM, keyPEM)
+		}
+	}
+
+	if certPEM, err = os.ReadFile(certPath); err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	if keyPEM, err = os.ReadFile(keyPath); err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	return certPEM, keyPEM, nil
+}
+
+// writeCA writes new CA files; existing ones are never overwritten
+func writeCA(certPath, keyPath string, certPEM, keyPEM []byte) error {
+	if err := writeNewFile(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write CA key: %w", err)
+	}
+	if err := writeNewFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	return nil
+}
+
+func writeNewFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -58,8 +58,30 @@ func TestLoadRequiresJWTSecret(t *testing.T) {
 	}
 }
 
+func TestLoadRequiresEncryptionKey(t *testing.T) {
+	t.Setenv(envPrefix+"JWT_SECRET", "test-secret")
+	t.Setenv(envPrefix+"DEV_MODE", "true")
+
+	t.Setenv(envPrefix+"ENCRYPTION_KEY", "")
+	if err := Load(); err == nil {
+		t.Fatal("Load() must fail when ENCRYPTION_KEY is empty")
+	}
+	t.Setenv(envPrefix+"ENCRYPTION_KEY", "test-secret")
+	if err := Load(); err == nil {
+		t.Fatal("Load() must fail when ENCRYPTION_KEY is the JWT secret")
+	}
+	t.Setenv(envPrefix+"ENCRYPTION_KEY", "test-encryption-key")
+	if err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Data().EncryptionKey != "test-encryption-key" {
+		t.Errorf("EncryptionKey = %q", Data().EncryptionKey)
+	}
+}
+
 func TestLoadAppliesDevCORSOriginsWhenDevMode(t *testing.T) {
 	t.Setenv(envPrefix+"JWT_SECRET", "test-secret")
+	t.Setenv(envPrefix+"ENCRYPTION_KEY", "test-encryption-key")
 	t.Setenv(envPrefix+"DEV_MODE", "true")
 	t.Setenv(envPrefix+"CORS_ALLOWED_ORIGINS", "")
 
@@ -78,6 +100,7 @@ func TestLoadAppliesDevCORSOriginsWhenDevMode(t *testing.T) {
 
 func TestLoadRespectsExplicitCORSOrigins(t *testing.T) {
 	t.Setenv(envPrefix+"JWT_SECRET", "test-secret")
+	t.Setenv(envPrefix+"ENCRYPTION_KEY", "test-encryption-key")
 	t.Setenv(envPrefix+"DEV_MODE", "true")
 	t.Setenv(envPrefix+"CORS_ALLOWED_ORIGINS", "https://example.com, https://foo.bar")
 
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services/ca"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// CAHandler handles certificates issued by the internal CA
+type CAHandler struct {
+	authority           *ca.Authority
+	userQueries         *queries.UserQueries
+	organizationQueries *queries.OrganizationQueries
+}
+
+// NewCAHandler creates new internal CA handler
+func NewCAHandler(authority *ca.Authority, userQueries *queries.UserQueries, organizationQueries *queries.OrganizationQueries) *CAHandler {
+	return &CAHandler{authority: authority, userQueries: userQueries, organizationQueries: organizationQueries}
+}
+
+// RegisterRoutes registers internal CA routes; only organization owners and
+// admins issue and revoke certificates
+func (h *CAHandler) RegisterRoutes(router fiber.Router) {
+	admin := RequireScopeAdmin(h.userQueries, h.organizationQueries)
+	router.Get("/certificates", h.List)
+	router.Post("/certificates", admin, h.Issue)
+	router.Get("/certificates/:serial", h.Get)
+	router.Post("/certificates/:serial/revoke", admin, h.Revoke)
+}
+
+// IssueCertificateRequest represents certificate issuance request
+type IssueCertificateRequest struct {
+	CSR        string `json:"csr" validate:"required"` // PEM encoded PKCS#10; only its public key is used
+	ValidHours int    `json:"valid_hours,omitempty"`
+}
+
+// RevokeCertificateRequest represents certificate revocation request
+type RevokeCertificateRequest struct {
+	Reason int `json:"reason"` // RFC 5280 CRLReason code
+}
+
+func (h *CAHandler) scope(c fiber.Ctx) (ca.Scope, error) {
+	orgID, accountID, err := ResolveOwnerScope(c, h.userQueries)
+	return ca.Scope{OrganizationID: orgID, AccountID: accountID}, err
+}
+
+// List lists issued certificates
+// @Summary List issued certificates
+// @Description Get certificates issued by the internal CA to the current organization (or account)
+// @Tags ca
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/ca/certificates [get]
+func (h *CAHandler) List(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	certs, err := h.authority.List(c.Context(), scope)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to list certificates", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificates retrieved successfully", certs)
+}
+
+// Issue issues a short-lived certificate for a CSR
+// @Summary Issue certificate
+// @Description Issue a short-lived signing certificate from the internal CA for the public key of a PKCS#10 request; the certificate names the authenticated user, the CSR subject is ignored
+// @Tags ca
+// @Accept json
+// @Produce json
+// @Param request body IssueCertificateRequest true "Issuance request"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/ca/certificates [post]
+func (h *CAHandler) Issue(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	var req IssueCertificateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+	}
+	if req.ValidHours < 0 {
+		return webutil.Response(c, fiber.StatusBadRequest, "valid_hours must be positive", nil)
+	}
+
+	// The certificate names the authenticated user, whatever the CSR claims
+	userID, err := GetUserID(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+	user, err := h.userQueries.GetUserByID(c.Context(), userID)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to get user", nil)
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Email
+	}
+
+	issued, err := h.authority.IssueFromCSR(c.Context(), []byte(req.CSR), name, user.Email, time.Duration(req.ValidHours)*time.Hour, scope)
+	if err != nil {
+		if errors.Is(err, ca.ErrInvalidCSR) || errors.Is(err, ca.ErrInvalidValidity) {
+			return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to issue certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusCreated, "Certificate issued successfully", issued.Record)
+}
+
+// Get returns an issued certificate
+// @Summary Get issued certificate
+// @Description Get a certificate issued by the internal CA by its hex serial number
+// @Tags ca
+// @Produce json
+// @Param serial path string true "Serial number (hex)"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/ca/certificates/{serial} [get]
+func (h *CAHandler) Get(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	crt, err := h.authority.Get(c.Context(), c.Params("serial"))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to get certificate", nil)
+	}
+	if crt == nil || !scope.Owns(crt) {
+		return webutil.Response(c, fiber.StatusNotFound, "Certificate not found", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificate retrieved successfully", crt)
+}
+
+// Revoke revokes an issued certificate
+// @Summary Revoke issued certificate
+// @Description Revoke a certificate issued by the internal CA; it is listed in the next published CRL
+// @Tags ca
+// @Accept json
+// @Produce json
+// @Param serial path string true "Serial number (hex)"
+// @Param request body RevokeCertificateRequest false "Revocation reason"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/ca/certificates/{serial}/revoke [post]
+func (h *CAHandler) Revoke(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	var req RevokeCertificateRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+		}
+	}
+	// 7 is unassigned in RFC 5280
+	if req.Reason < 0 || req.Reason > 10 || req.Reason == 7 {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid revocation reason", nil)
+	}
+
+	if err := h.authority.Revoke(c.Context(), scope, c.Params("serial"), req.Reason); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Certificate not found or already revoked", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to revoke certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificate revoked successfully", nil)
+}
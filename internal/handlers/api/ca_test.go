@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services/ca"
+	"github.com/shurco/gosign/internal/testutil"
+)
+
+func TestCAHandler(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	caCert, caKey, err := ca.Generate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authority, err := ca.New(queries.NewIssuedCertificateRepository(pool), ca.Config{
+		Certificate: caCert,
+		Key:         caKey,
+		BaseURL:     "http://localhost:8088",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewCAHandler(authority, queries.NewUserQueries(pool), queries.NewOrganizationQueries(pool))
+
+	newApp := func(user testutil.FixtureUser) *fiber.App {
+		app := fiber.New()
+		app.Use(testutil.AuthMiddleware(user))
+		h.RegisterRoutes(app.Group("/ca"))
+		return app
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "API Signer"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+
+	t.Run("issue invalid csr returns 400", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/ca/certificates", bytes.NewReader([]byte(`{"csr":"nope"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := newApp(testutil.User1).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("viewer cannot issue", func(t *testing.T) {
+		viewer := testutil.User2
+		viewer.OrganizationID = newTestOrganization(t, pool, map[string]models.OrganizationRole{
+			testutil.User1.AccountID: models.OrganizationRoleOwner,
+			testutil.User2.AccountID: models.OrganizationRoleViewer,
+		})
+
+		body, _ := json.Marshal(IssueCertificateRequest{CSR: csr})
+		req := httptest.NewRequest(http.MethodPost, "/ca/certificates", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := newApp(viewer).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+		}
+	})
+
+	t.Run("issue, get, revoke", func(t *testing.T) {
+		app := newApp(testutil.User1)
+
+		body, _ := json.Marshal(IssueCertificateRequest{CSR: csr, ValidHours: 2})
+		req := httptest.NewRequest(http.MethodPost, "/ca/certificates", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("issue status = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		var issued map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		serial, _ := issued["data"].(map[string]any)["serial_number"].(string)
+		if serial == "" {
+			t.Fatalf("missing serial_number: %v", issued)
+		}
+		// The subject comes from the account, not from the CSR
+		if subject, _ := issued["data"].(map[string]any)["subject"].(string); strings.Contains(subject, "API Signer") {
+			t.Fatalf("subject taken from the CSR: %s", subject)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/ca/certificates/"+serial, nil)
+		resp, err = newApp(testutil.User2).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("foreign get status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/ca/certificates/"+serial+"/revoke", bytes.NewReader([]byte(`{"reason":7}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("invalid reason status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/ca/certificates/"+serial+"/revoke", bytes.NewReader([]byte(`{"reason":1}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("revoke status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+
+		crlDER, err := authority.CRL(t.Context())
+		if err != nil {
+			t.Fatalf("CRL: %v", err)
+		}
+		crl, err := x509.ParseRevocationList(crlDER)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Text(16) != serial {
+			t.Fatalf("CRL does not list revoked serial %s", serial)
+		}
+
+		// Revoking twice reports not found
+		req = httptest.NewRequest(http.MethodPost, "/ca/certificates/"+serial+"/revoke", nil)
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("second revoke status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,370 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/security/cert"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// maxCertificateUploadSize limits each uploaded key/certificate file
+const maxCertificateUploadSize = 1 << 20
+
+// CertificateHandler handles signing certificate store operations
+type CertificateHandler struct {
+	service             *services.CertificateService
+	userQueries         *queries.UserQueries
+	organizationQueries *queries.OrganizationQueries
+}
+
+// NewCertificateHandler creates new signing certificate handler
+func NewCertificateHandler(service *services.CertificateService, userQueries *queries.UserQueries, organizationQueries *queries.OrganizationQueries) *CertificateHandler {
+	return &CertificateHandler{service: service, userQueries: userQueries, organizationQueries: organizationQueries}
+}
+
+// RegisterRoutes registers signing certificate routes; only organization owners
+// and admins change the keys, the default one seals completed submissions
+func (h *CertificateHandler) RegisterRoutes(router fiber.Router) {
+	admin := RequireScopeAdmin(h.userQueries, h.organizationQueries)
+	router.Get("/", h.List)
+	router.Post("/", admin, h.Upload)
+	router.Post("/generate", admin, h.Generate)
+	router.Post("/external", admin, h.ImportExternal)
+	router.Get("/:id", h.Get)
+	router.Put("/:id/default", admin, h.SetDefault)
+	router.Delete("/:id", admin, h.Delete)
+}
+
+// GenerateCertificateRequest represents self-signed certificate generation request
+type GenerateCertificateRequest struct {
+	Name         string `json:"name"`
+	CommonName   string `json:"common_name" validate:"required"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	ValidDays    int    `json:"valid_days,omitempty"`
+	IsDefault    bool   `json:"is_default"`
+	Usage        string `json:"usage,omitempty"`    // signing (default) or timestamping
+	KeyType      string `json:"key_type,omitempty"` // ecdsa-p256 (default), ecdsa-p384, rsa-2048, rsa-3072, rsa-4096 or ed25519
+	RSAPSS       bool   `json:"rsa_pss,omitempty"`  // sign with RSASSA-PSS (RSA keys only)
+}
+
+// ExternalCertificateRequest represents a certificate whose key stays in a key backend
+type ExternalCertificateRequest struct {
+	Name        string `json:"name"`
+	KeyURI      string `json:"key_uri" validate:"required"`     // pkcs11:token=...;object=... or https://signer/keys/...
+	Certificate string `json:"certificate" validate:"required"` // PEM, the key's certificate first, then its issuer chain
+	IsDefault   bool   `json:"is_default"`
+}
+
+// scope resolves the certificate owner: the current organization, else the user's account
+func (h *CertificateHandler) scope(c fiber.Ctx) (services.CertificateScope, error) {
+	orgID, accountID, err := ResolveOwnerScope(c, h.userQueries)
+	return services.CertificateScope{OrganizationID: orgID, AccountID: accountID}, err
+}
+
+// List lists signing certificates
+// @Summary List signing certificates
+// @Description Get all signing certificates of the current organization (or account)
+// @Tags certificates
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates [get]
+func (h *CertificateHandler) List(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	certs, err := h.service.List(c.Context(), scope)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to list certificates", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificates retrieved successfully", certs)
+}
+
+// Get returns a signing certificate
+// @Summary Get signing certificate
+// @Description Get a signing certificate by ID (the private key is never returned)
+// @Tags certificates
+// @Produce json
+// @Param id path string true "Certificate ID"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates/{id} [get]
+func (h *CertificateHandler) Get(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	crt, err := h.service.Get(c.Context(), scope, c.Params("id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Certificate not found", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to get certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificate retrieved successfully", crt)
+}
+
+// Upload imports a signing certificate with its private key
+// @Summary Upload signing certificate
+// @Description Import a PKCS#12 file (pkcs12 + password) or PEM files (certificate + private_key, optional chain)
+// @Tags certificates
+// @Accept multipart/form-data
+// @Produce json
+// @Param name formData string false "Display name"
+// @Param default formData bool false "Make it the default signing certificate"
+// @Param pkcs12 formData file false "PKCS#12 (.p12/.pfx) file"
+// @Param password formData string false "PKCS#12 password"
+// @Param certificate formData file false "PEM certificate"
+// @Param private_key formData file false "PEM private key"
+// @Param chain formData file false "PEM issuer chain"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates [post]
+func (h *CertificateHandler) Upload(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	var bundle *cert.Bundle
+	if fh, err := c.FormFile("pkcs12"); err == nil {
+		data, err := readFormFile(fh)
+		if err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		}
+		if bundle, err = cert.ParsePKCS12(data, c.FormValue("password")); err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, "Invalid PKCS#12 file: "+err.Error(), nil)
+		}
+	} else {
+		certFile, certErr := c.FormFile("certificate")
+		keyFile, keyErr := c.FormFile("private_key")
+		if certErr != nil || keyErr != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, "Provide pkcs12 or certificate and private_key files", nil)
+		}
+		certPEM, err := readFormFile(certFile)
+		if err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		}
+		keyPEM, err := readFormFile(keyFile)
+		if err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		}
+		var chainPEM []byte
+		if chainFile, err := c.FormFile("chain"); err == nil {
+			if chainPEM, err = readFormFile(chainFile); err != nil {
+				return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+			}
+		}
+		if bundle, err = cert.ParsePEMBundle(certPEM, keyPEM, chainPEM); err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, "Invalid certificate: "+err.Error(), nil)
+		}
+	}
+
+	crt, err := h.service.Import(c.Context(), scope, c.FormValue("name"), bundle, c.FormValue("default") == "true")
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to store certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusCreated, "Certificate uploaded successfully", crt)
+}
+
+// Generate creates a self-signed signing certificate
+// @Summary Generate signing certificate
+// @Description Generate a self-signed signing (or TSA timestamping) certificate with an ECDSA, RSA or Ed25519 key and store it
+// @Tags certificates
+// @Accept json
+// @Produce json
+// @Param request body GenerateCertificateRequest true "Certificate details"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates/generate [post]
+func (h *CertificateHandler) Generate(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	var req GenerateCertificateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+	}
+	if req.CommonName == "" {
+		return webutil.Response(c, fiber.StatusBadRequest, "common_name is required", nil)
+	}
+	usage := models.CertificateUsage(req.Usage)
+	switch usage {
+	case "":
+		usage = models.CertificateUsageSigning
+	case models.CertificateUsageSigning, models.CertificateUsageTimestamping:
+	default:
+		return webutil.Response(c, fiber.StatusBadRequest, "usage must be signing or timestamping", nil)
+	}
+
+	keyType, err := cert.ParseKeyType(req.KeyType)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "key_type must be ecdsa-p256, ecdsa-p384, rsa-2048, rsa-3072, rsa-4096 or ed25519", nil)
+	}
+
+	crt, err := h.service.Generate(c.Context(), scope, services.GenerateCertificateRequest{
+		Name:         req.Name,
+		CommonName:   req.CommonName,
+		Organization: req.Organization,
+		Email:        req.Email,
+		ValidDays:    req.ValidDays,
+		IsDefault:    req.IsDefault,
+		Usage:        usage,
+		KeyType:      keyType,
+		RSAPSS:       req.RSAPSS,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrPSSRequiresRSA) {
+			return webutil.Response(c, fiber.StatusBadRequest, "rsa_pss requires an RSA key_type", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to generate certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusCreated, "Certificate generated successfully", crt)
+}
+
+// ImportExternal adds a certificate whose private key stays on a PKCS#11 token or remote signing service
+// @Summary Add external signing certificate
+// @Description Store a certificate whose key is addressed by a PKCS#11 URI (RFC 7512) or a remote signing service URL. The key is probed with a test signature.
+// @Tags certificates
+// @Accept json
+// @Produce json
+// @Param request body ExternalCertificateRequest true "Certificate and key URI"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates/external [post]
+func (h *CertificateHandler) ImportExternal(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	var req ExternalCertificateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+	}
+	if req.KeyURI == "" || req.Certificate == "" {
+		return webutil.Response(c, fiber.StatusBadRequest, "key_uri and certificate are required", nil)
+	}
+	certs, err := cert.ParseCertificates([]byte(req.Certificate))
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid certificate: "+err.Error(), nil)
+	}
+
+	crt, err := h.service.ImportExternal(c.Context(), scope, req.Name, req.KeyURI, certs, req.IsDefault)
+	if err != nil {
+		if errors.Is(err, services.ErrExternalKey) {
+			return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to store certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusCreated, "Certificate added successfully", crt)
+}
+
+// SetDefault makes a certificate the default for signing
+// @Summary Set default signing certificate
+// @Description Use this certificate when signing documents of the organization (or account)
+// @Tags certificates
+// @Produce json
+// @Param id path string true "Certificate ID"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates/{id}/default [put]
+func (h *CertificateHandler) SetDefault(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	if err := h.service.SetDefault(c.Context(), scope, c.Params("id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Certificate not found", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to set default certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Default certificate updated successfully", nil)
+}
+
+// Delete removes a signing certificate
+// @Summary Delete signing certificate
+// @Description Delete a signing certificate and its private key
+// @Tags certificates
+// @Produce json
+// @Param id path string true "Certificate ID"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/certificates/{id} [delete]
+func (h *CertificateHandler) Delete(c fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	if err := h.service.Delete(c.Context(), scope, c.Params("id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Certificate not found", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to delete certificate", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Certificate deleted successfully", nil)
+}
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	return readUpload(fh, maxCertificateUploadSize)
+}
+
+func readUpload(fh *multipart.FileHeader, limit int64) ([]byte, error) {
+	if fh.Size > limit {
+		return nil, errors.New(fh.Filename + " is too large")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
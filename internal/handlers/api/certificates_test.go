@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/internal/testutil"
+	"github.com/shurco/gosign/pkg/security/cert"
+	"github.com/shurco/gosign/pkg/security/secret"
+)
+
+func TestCertificateHandler(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	cipher, err := secret.NewCipher("test-encryption-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := services.NewCertificateService(queries.NewCertificateRepository(pool), cipher, nil, nil)
+	h := NewCertificateHandler(service, queries.NewUserQueries(pool), queries.NewOrganizationQueries(pool))
+
+	newApp := func(user testutil.FixtureUser) *fiber.App {
+		app := fiber.New()
+		app.Use(testutil.AuthMiddleware(user))
+		h.RegisterRoutes(app.Group("/certificates"))
+		return app
+	}
+
+	decodeData := func(t *testing.T, resp *http.Response) map[string]any {
+		t.Helper()
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		data, _ := body["data"].(map[string]any)
+		return data
+	}
+
+	t.Run("generate requires common name", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/certificates/generate", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := newApp(testutil.User1).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("generate, upload, set default, delete", func(t *testing.T) {
+		app := newApp(testutil.User1)
+
+		req := httptest.NewRequest(http.MethodPost, "/certificates/generate", bytes.NewReader([]byte(`{"common_name":"User One Signing"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("generate status = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		generated := decodeData(t, resp)
+		if generated["is_default"] != true {
+			t.Fatalf("first certificate should be default: %v", generated)
+		}
+		if _, ok := generated["private_key"]; ok {
+			t.Fatal("private key must not be exposed")
+		}
+
+		// Upload a PEM pair
+		pkey, _ := cert.GetPrivateKey()
+		res, err := (&cert.Certificate{
+			Subject:   pkix.Name{CommonName: "Uploaded"},
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(time.Hour),
+		}).GetCertificate(pkey.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		_ = w.WriteField("name", "Uploaded")
+		_ = w.WriteField("default", "true")
+		fw, _ := w.CreateFormFile("certificate", "cert.pem")
+		_, _ = fw.Write([]byte(res.String()))
+		fw, _ = w.CreateFormFile("private_key", "key.pem")
+		_, _ = fw.Write([]byte(pkey.String()))
+		_ = w.Close()
+
+		req = httptest.NewRequest(http.MethodPost, "/certificates", &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("upload status = %d, want %d", resp.StatusCode, http.StatusCreated)
+		}
+		uploaded := decodeData(t, resp)
+
+		key, err := service.Resolve(t.Context(), services.CertificateScope{AccountID: testutil.User1.AccountID})
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		if !bytes.Equal(key.Certificate.Raw, res.ByteCert) {
+			t.Fatal("uploaded certificate should be the default")
+		}
+
+		req = httptest.NewRequest(http.MethodPut, "/certificates/"+generated["id"].(string)+"/default", nil)
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("set default status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+
+		// Other accounts cannot see the certificate
+		req = httptest.NewRequest(http.MethodGet, "/certificates/"+uploaded["id"].(string), nil)
+		resp, err = newApp(testutil.User2).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("foreign get status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+
+		req = httptest.NewRequest(http.MethodDelete, "/certificates/"+uploaded["id"].(string), nil)
+		resp, err = app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("delete status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("only organization owners and admins change keys", func(t *testing.T) {
+		orgID := newTestOrganization(t, pool, map[string]models.OrganizationRole{
+			testutil.User1.AccountID: models.OrganizationRoleOwner,
+			testutil.User2.AccountID: models.OrganizationRoleViewer,
+		})
+		owner, viewer := testutil.User1, testutil.User2
+		owner.OrganizationID, viewer.OrganizationID = orgID, orgID
+
+		generate := func(user testutil.FixtureUser) int {
+			req := httptest.NewRequest(http.MethodPost, "/certificates/generate", bytes.NewReader([]byte(`{"common_name":"Org Seal"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := newApp(user).Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			return resp.StatusCode
+		}
+		if status := generate(viewer); status != http.StatusForbidden {
+			t.Fatalf("viewer generate status = %d, want %d", status, http.StatusForbidden)
+		}
+		if status := generate(owner); status != http.StatusCreated {
+			t.Fatalf("owner generate status = %d, want %d", status, http.StatusCreated)
+		}
+
+		resp, err := newApp(viewer).Test(httptest.NewRequest(http.MethodGet, "/certificates", nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("viewer list status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("upload without files returns 400", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		_ = w.WriteField("name", "empty")
+		_ = w.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/certificates", &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		resp, err := newApp(testutil.User1).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+// newTestOrganization creates an organization with members, keyed by account ID
+func newTestOrganization(t *testing.T, pool *pgxpool.Pool, members map[string]models.OrganizationRole) string {
+	t.Helper()
+	orgQ := queries.NewOrganizationQueries(pool)
+	org := &models.Organization{ID: uuid.New().String(), Name: "Test Org"}
+	for accountID, role := range members {
+		if role == models.OrganizationRoleOwner {
+			org.OwnerID = accountID
+		}
+	}
+	if err := orgQ.CreateOrganization(t.Context(), org); err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	for accountID, role := range members {
+		member := &models.OrganizationMember{ID: uuid.New().String(), OrganizationID: org.ID, UserID: accountID, Role: role}
+		if err := orgQ.AddOrganizationMember(t.Context(), member); err != nil {
+			t.Fatalf("AddOrganizationMember: %v", err)
+		}
+	}
+	return org.ID
+}
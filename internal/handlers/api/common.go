@@ -1,11 +1,16 @@
 package api
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
 
+	"github.com/shurco/gosign/internal/middleware"
+	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/utils/webutil"
 )
 
 // GetUserID extracts user ID from request context
@@ -75,6 +80,64 @@ func ResolveAccountID(c fiber.Ctx, uq *queries.UserQueries) (string, error) {
 	return accountID, nil
 }
 
+// ResolveOwnerScope returns the owner of scoped resources such as signing certificates:
+// the current organization or, outside an organization context, the caller's account.
+func ResolveOwnerScope(c fiber.Ctx, uq *queries.UserQueries) (organizationID, accountID string, err error) {
+	if orgID := GetOrganizationIDFromLocals(c); orgID != "" {
+		return orgID, "", nil
+	}
+	if auth := middleware.GetAuthContext(c); auth != nil && auth.AccountID != "" {
+		return "", auth.AccountID, nil
+	}
+	accountID, err = ResolveAccountID(c, uq)
+	if err != nil {
+		return "", "", err
+	}
+	return "", accountID, nil
+}
+
+// RequireScopeAdmin guards routes that change resources of the owner scope (see
+// ResolveOwnerScope): in an organization only its owners and admins pass, outside
+// one the caller manages the resources of their own account.
+func RequireScopeAdmin(uq *queries.UserQueries, oq *queries.OrganizationQueries) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		orgID, accountID, err := ResolveOwnerScope(c, uq)
+		if err != nil {
+			return scopeErrorResponse(c, err)
+		}
+		if orgID == "" {
+			return c.Next()
+		}
+
+		if auth := middleware.GetAuthContext(c); auth != nil && auth.AccountID != "" {
+			accountID = auth.AccountID
+		} else if accountID, err = ResolveAccountID(c, uq); err != nil {
+			return scopeErrorResponse(c, err)
+		}
+		member, err := oq.GetOrganizationMember(c.Context(), orgID, accountID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to check organization membership")
+			return webutil.Response(c, fiber.StatusInternalServerError, "Failed to check permissions", nil)
+		}
+		if member == nil {
+			return webutil.Response(c, fiber.StatusForbidden, "Access denied", nil)
+		}
+		if member.Role != models.OrganizationRoleAdmin && member.Role != models.OrganizationRoleOwner {
+			return webutil.Response(c, fiber.StatusForbidden, "Insufficient permissions", nil)
+		}
+		return c.Next()
+	}
+}
+
+// scopeErrorResponse writes the response for a failed ResolveOwnerScope
+func scopeErrorResponse(c fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return webutil.Response(c, fe.Code, fe.Message, nil)
+	}
+	return webutil.Response(c, fiber.StatusUnauthorized, "Unauthorized", nil)
+}
+
 // GetOrganizationIDFromLocals extracts organization ID from fiber.Ctx locals.
 // Returns empty string if not set. This is the canonical way to get org context.
 func GetOrganizationIDFromLocals(c fiber.Ctx) string {
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// OrganizationStorageHandler manages the blob storage of an organization (data residency)
+type OrganizationStorageHandler struct {
+	storages            *services.OrganizationStorageService
+	organizationQueries *queries.OrganizationQueries
+	userQueries         *queries.UserQueries
+}
+
+// NewOrganizationStorageHandler creates a new organization storage handler
+func NewOrganizationStorageHandler(storages *services.OrganizationStorageService, organizationQueries *queries.OrganizationQueries, userQueries *queries.UserQueries) *OrganizationStorageHandler {
+	return &OrganizationStorageHandler{
+		storages:            storages,
+		organizationQueries: organizationQueries,
+		userQueries:         userQueries,
+	}
+}
+
+// OrganizationStorageResponse is the storage of an organization without its secret
+type OrganizationStorageResponse struct {
+	Default            bool                        `json:"default"` // documents go to the global storage
+	Storage            *models.OrganizationStorage `json:"storage,omitempty"`
+	SecretAccessKeySet bool                        `json:"secret_access_key_set"`
+}
+
+// GetStorage returns the storage of an organization
+// @Summary Get organization storage
+// @Description Get the bucket holding the documents of the organization's templates and submissions (admins and owners)
+// @Tags organizations
+// @Produce json
+// @Param organization_id path string true "Organization ID"
+// @Success 200 {object} OrganizationStorageResponse
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /api/v1/organizations/{organization_id}/storage [get]
+func (h *OrganizationStorageHandler) GetStorage(c fiber.Ctx) error {
+	orgID, err := h.authorize(c)
+	if err != nil {
+		return err
+	}
+
+	st, err := h.storages.Get(c.Context(), orgID)
+	if err != nil {
+		log.Error().Err(err).Str("organization_id", orgID).Msg("Failed to load organization storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to load storage", nil)
+	}
+	return webutil.Response(c, fiber.StatusOK, "Organization storage", storageResponse(st))
+}
+
+// UpdateStorage sets the storage of an organization
+// @Summary Set organization storage
+// @Description Keep the documents of the organization's templates and submissions in an S3 bucket (e.g. an EU region). The bucket is checked by writing and removing a test file. Documents are not moved, so the bucket can't change while the organization has templates (409); credentials and region can. An empty secret_access_key keeps the stored one
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param organization_id path string true "Organization ID"
+// @Param request body services.OrganizationStorageRequest true "Storage settings"
+// @Success 200 {object} OrganizationStorageResponse
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /api/v1/organizations/{organization_id}/storage [put]
+func (h *OrganizationStorageHandler) UpdateStorage(c fiber.Ctx) error {
+	orgID, err := h.authorize(c)
+	if err != nil {
+		return err
+	}
+
+	var req services.OrganizationStorageRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+	}
+
+	st, err := h.storages.Set(c.Context(), orgID, req)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedStorageProvider), errors.Is(err, services.ErrStorageBucketRequired):
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, services.ErrStorageInUse):
+		return webutil.Response(c, fiber.StatusConflict, err.Error(), nil)
+	case errors.Is(err, services.ErrStorageUnreachable):
+		return webutil.Response(c, fiber.StatusBadRequest, "Storage is not reachable with these settings", map[string]any{"error": err.Error()})
+	case err != nil:
+		log.Error().Err(err).Str("organization_id", orgID).Msg("Failed to save organization storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to save storage", nil)
+	}
+
+	log.Info().Str("organization_id", orgID).Str("bucket", st.Bucket).Str("region", st.Region).Msg("Organization storage updated")
+	return webutil.Response(c, fiber.StatusOK, "Organization storage updated", storageResponse(st))
+}
+
+// DeleteStorage sends the documents of an organization back to the global storage
+// @Summary Remove organization storage
+// @Description New documents of the organization go to the global storage again. Documents in the bucket are not moved, so this is refused while the organization has templates (409)
+// @Tags organizations
+// @Produce json
+// @Param organization_id path string true "Organization ID"
+// @Success 200 {object} OrganizationStorageResponse
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /api/v1/organizations/{organization_id}/storage [delete]
+func (h *OrganizationStorageHandler) DeleteStorage(c fiber.Ctx) error {
+	orgID, err := h.authorize(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.storages.Delete(c.Context(), orgID); errors.Is(err, services.ErrStorageInUse) {
+		return webutil.Response(c, fiber.StatusConflict, err.Error(), nil)
+	} else if err != nil {
+		log.Error().Err(err).Str("organization_id", orgID).Msg("Failed to remove organization storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to remove storage", nil)
+	}
+	return webutil.Response(c, fiber.StatusOK, "Organization storage removed", storageResponse(nil))
+}
+
+// authorize returns the organization of the request when the caller is one of its admins or owners
+func (h *OrganizationStorageHandler) authorize(c fiber.Ctx) (string, error) {
+	orgID := c.Params("organization_id")
+	if orgID == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Organization ID is required")
+	}
+
+	accountID, err := ResolveAccountID(c, h.userQueries)
+	if err != nil {
+		return "", err
+	}
+
+	member, err := h.organizationQueries.GetOrganizationMember(c.Context(), orgID, accountID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check organization membership")
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to check permissions")
+	}
+	if member == nil {
+		return "", fiber.NewError(fiber.StatusForbidden, "Access denied")
+	}
+	if member.Role != models.OrganizationRoleAdmin && member.Role != models.OrganizationRoleOwner {
+		return "", fiber.NewError(fiber.StatusForbidden, "Insufficient permissions")
+	}
+	return orgID, nil
+}
+
+func storageResponse(st *models.OrganizationStorage) OrganizationStorageResponse {
+	if st == nil {
+		return OrganizationStorageResponse{Default: true}
+	}
+	return OrganizationStorageResponse{Storage: st, SecretAccessKeySet: len(st.SecretAccessKey) > 0}
+}
+
+// RegisterRoutes registers organization storage routes
+func (h *OrganizationStorageHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/:organization_id/storage", h.GetStorage)
+	router.Put("/:organization_id/storage", h.UpdateStorage)
+	router.Delete("/:organization_id/storage", h.DeleteStorage)
+}
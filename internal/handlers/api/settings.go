@@ -84,7 +84,7 @@ func (h *SettingsHandler) Get(c fiber.Ctx) error {
 				}
 			}
 
-			// Storage settings (local path is fixed ./lc_uploads, not exposed)
+			// Storage settings (local storage is the data directory, not exposed)
 			if storageMap, ok := globalSettings["storage"]; ok {
 				safSettings["storage"] = map[string]any{
 					"provider": utils.GetStringFromMap(storageMap, "provider", ""),
@@ -433,7 +433,8 @@ func (h *SettingsHandler) UpdateStorage(c fiber.Ctx) error {
 		currentSettings = make(map[string]any)
 	}
 
-	// Update settings (local storage uses fixed path ./lc_uploads, base_path not stored)
+	// Update settings (local storage is the data directory, base_path not stored);
+	// documents go to the new storage after a restart
 	currentSettings["provider"] = req.Provider
 	if req.Bucket != "" {
 		currentSettings["bucket"] = req.Bucket
@@ -8,9 +8,12 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
 
+	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/queries"
 	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/security/asic"
 	"github.com/shurco/gosign/pkg/utils/webutil"
 )
 
@@ -21,13 +24,15 @@ type SigningLinkHandler struct {
 	pool            *pgxpool.Pool
 	templateQueries *queries.TemplateQueries
 	completedDoc    *services.CompletedDocumentBuilder
+	webhookEvents   *services.WebhookEvents
 }
 
-func NewSigningLinkHandler(pool *pgxpool.Pool, templateQueries *queries.TemplateQueries, completedDoc *services.CompletedDocumentBuilder) *SigningLinkHandler {
+func NewSigningLinkHandler(pool *pgxpool.Pool, templateQueries *queries.TemplateQueries, completedDoc *services.CompletedDocumentBuilder, webhookEvents *services.WebhookEvents) *SigningLinkHandler {
 	return &SigningLinkHandler{
 		pool:            pool,
 		templateQueries: templateQueries,
 		completedDoc:    completedDoc,
+		webhookEvents:   webhookEvents,
 	}
 }
 
@@ -35,6 +40,11 @@ type SubmitterInput struct {
 	Name  string `json:"name,omitempty"`
 	Email string `json:"email,omitempty"`
 	Phone string `json:"phone,omitempty"`
+
+	// Optional signing order overriding the template's; set for all submitters or none.
+	// In sequential mode submitters sharing an order sign in parallel, and each order
+	// must complete before the next one signs.
+	Order *int `json:"order,omitempty"`
 }
 
 type CreateSigningLinkRequest struct {
@@ -46,6 +56,10 @@ type CreateSigningLinkRequest struct {
 	// SigningMode: "sequential" or "parallel". Default "sequential".
 	SigningMode string `json:"signing_mode,omitempty"`
 
+	// SignatureMode: "seal" or "incremental". Default "seal".
+	// In "incremental" mode every submitter adds its own signature to the document.
+	SignatureMode string `json:"signature_mode,omitempty"`
+
 	// Optional locale for the submission (used by i18n).
 	Locale string `json:"locale,omitempty"`
 }
@@ -134,6 +148,20 @@ func (h *SigningLinkHandler) Create(c fiber.Ctx) error {
 			nil,
 		)
 	}
+	orders, err := signingOrders(req.Submitters, tpl.Submitters)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid signing order")
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid signing order", nil)
+	}
+
+	signatureMode := models.SignatureMode(req.SignatureMode)
+	switch signatureMode {
+	case "":
+		signatureMode = models.SignatureModeSeal
+	case models.SignatureModeSeal, models.SignatureModeIncremental:
+	default:
+		return webutil.Response(c, fiber.StatusBadRequest, "signature_mode must be seal or incremental", nil)
+	}
 
 	tx, err := h.pool.Begin(ctx)
 	if err != nil {
@@ -150,7 +178,7 @@ func (h *SigningLinkHandler) Create(c fiber.Ctx) error {
 	if signingMode != "sequential" && signingMode != "parallel" {
 		signingMode = "sequential"
 	}
-	preferencesJSON := fmt.Sprintf(`{"signing_mode": %q}`, signingMode)
+	preferencesJSON := fmt.Sprintf(`{"signing_mode": %q, "signature_mode": %q}`, signingMode, signatureMode)
 
 	// Note: DB schema uses created_by_user_id and requires slug/source/submitters_order.
 	// Signing mode is stored in preferences for use by sequential/parallel flows.
@@ -176,7 +204,7 @@ func (h *SigningLinkHandler) Create(c fiber.Ctx) error {
 
 		meta := map[string]any{
 			"template_submitter_id": tpl.Submitters[i].ID,
-			"order":                 i,
+			"order":                 orders[i],
 		}
 		metaJSON, _ := json.Marshal(meta)
 
@@ -205,6 +233,14 @@ func (h *SigningLinkHandler) Create(c fiber.Ctx) error {
 	if err := tx.Commit(ctx); err != nil {
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to create signing link", nil)
 	}
+	h.webhookEvents.Submission(ctx, models.EventSubmissionCreated, submissionID, "")
+
+	// Keep the original document and its hash (best-effort; completion renders it otherwise).
+	if h.completedDoc != nil {
+		if _, err := h.completedDoc.EnsureOriginalPDF(ctx, submissionID); err != nil {
+			log.Warn().Err(err).Str("submission_id", submissionID).Msg("failed to store original document")
+		}
+	}
 
 	resp := CreateSigningLinkResponse{
 		SubmissionID: submissionID,
@@ -214,6 +250,31 @@ func (h *SigningLinkHandler) Create(c fiber.Ctx) error {
 	return webutil.Response(c, fiber.StatusCreated, "signing_link_created", resp)
 }
 
+// signingOrders returns the signing order of each submitter of a signing link: the orders of
+// the request when given, else those defined by the template submitters
+func signingOrders(submitters []SubmitterInput, templateSubmitters []models.Submitter) ([]int, error) {
+	if submitters[0].Order == nil {
+		for _, s := range submitters {
+			if s.Order != nil {
+				return nil, fmt.Errorf("order must be set for all submitters or none")
+			}
+		}
+		return models.SigningOrders(templateSubmitters), nil
+	}
+
+	orders := make([]int, len(submitters))
+	for i, s := range submitters {
+		if s.Order == nil {
+			return nil, fmt.Errorf("order must be set for all submitters or none")
+		}
+		if *s.Order < 0 {
+			return nil, fmt.Errorf("invalid order of submitter %d: must not be negative", i)
+		}
+		orders[i] = *s.Order
+	}
+	return orders, nil
+}
+
 // List returns submissions created via direct-link flow, including signer status and links.
 // @Summary List direct-link signings
 // @Description Returns direct-link submissions created by the current user, including per-submitters' status and generated signing links.
@@ -276,6 +337,7 @@ func (h *SigningLinkHandler) List(c fiber.Ctx) error {
 					'phone', COALESCE(s.phone, ''),
 					'slug', s.slug,
 					'status', COALESCE(s.status, 'pending'),
+					'order', s.metadata->'order',
 					'completed_at', CASE WHEN s.completed_at IS NULL THEN NULL ELSE s.completed_at::text END
 				)
 				ORDER BY s.created_at ASC
@@ -424,6 +486,7 @@ func (h *SigningLinkHandler) Get(c fiber.Ctx) error {
 					'phone', COALESCE(s.phone, ''),
 					'slug', s.slug,
 					'status', COALESCE(s.status, 'pending'),
+					'order', s.metadata->'order',
 					'created_at', s.created_at::text,
 					'opened_at', CASE WHEN s.opened_at IS NULL THEN NULL ELSE s.opened_at::text END,
 					'opened_ip', host(opened_event.ip),
@@ -539,17 +602,62 @@ func (h *SigningLinkHandler) Get(c fiber.Ctx) error {
 // @Failure 404 {object} map[string]any
 // @Router /api/v1/signing-links/{submission_id}/document [get]
 func (h *SigningLinkHandler) DownloadCompletedDocument(c fiber.Ctx) error {
-	userID, err := GetUserID(c)
+	submissionID, ready, err := h.completedSubmission(c)
+	if !ready {
+		return err
+	}
+
+	doc, err := h.completedDoc.EnsureCompletedPDF(c.Context(), submissionID)
 	if err != nil {
+		log.Error().Err(err).Str("submission_id", submissionID).Msg("Failed to build completed document")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to build completed document", nil)
+	}
+
+	return webutil.Attachment(c, doc, fmt.Sprintf("submission_%s.pdf", submissionID), "application/pdf")
+}
+
+// DownloadASiC downloads a completed submission as a signed ASiC-E container.
+// Only the creator of the submission can download it.
+//
+// @Summary Download completed submission as ASiC-E
+// @Description Downloads an ASiC-E container (ETSI EN 319 162-1) of a completed direct-link submission: the completed PDF, the certificate PDF, the files uploaded by the signers and a JSON evidence record, listed in META-INF/ASiCManifest.xml and covered by a CAdES signature made with the sealing key. Only the submission creator can download it and the submission must be fully completed.
+// @Tags signing-links
+// @Produce application/vnd.etsi.asic-e+zip
+// @Param submission_id path string true "Submission ID"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Router /api/v1/signing-links/{submission_id}/asic [get]
+func (h *SigningLinkHandler) DownloadASiC(c fiber.Ctx) error {
+	submissionID, ready, err := h.completedSubmission(c)
+	if !ready {
 		return err
 	}
 
-	submissionID := c.Params("submission_id")
+	container, err := h.completedDoc.BuildASiCE(c.Context(), submissionID)
+	if err != nil {
+		log.Error().Err(err).Str("submission_id", submissionID).Msg("Failed to build ASiC-E container")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to build ASiC-E container", nil)
+	}
+
+	return webutil.Attachment(c, container, fmt.Sprintf("submission_%s.asice", submissionID), asic.MimeTypeASiCE)
+}
+
+// completedSubmission checks that the submission in the path belongs to the caller and is
+// fully completed. When it isn't ready the response has been sent and err is its result.
+func (h *SigningLinkHandler) completedSubmission(c fiber.Ctx) (submissionID string, ready bool, err error) {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return "", false, err
+	}
+
+	submissionID = c.Params("submission_id")
 	if submissionID == "" {
-		return webutil.Response(c, fiber.StatusBadRequest, "submission_id is required", nil)
+		return "", false, webutil.Response(c, fiber.StatusBadRequest, "submission_id is required", nil)
 	}
 	if h.completedDoc == nil {
-		return webutil.Response(c, fiber.StatusInternalServerError, "Document builder not configured", nil)
+		return "", false, webutil.Response(c, fiber.StatusInternalServerError, "Document builder not configured", nil)
 	}
 
 	// Ensure ownership.
@@ -563,15 +671,15 @@ func (h *SigningLinkHandler) DownloadCompletedDocument(c fiber.Ctx) error {
 		)
 	`, submissionID, userID).Scan(&ok)
 	if err != nil || !ok {
-		return webutil.Response(c, fiber.StatusNotFound, "Submission not found", nil)
+		return "", false, webutil.Response(c, fiber.StatusNotFound, "Submission not found", nil)
 	}
 
 	isDone, err := h.completedDoc.IsSubmissionFullyCompleted(c.Context(), submissionID)
 	if err != nil {
-		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to check completion", nil)
+		return "", false, webutil.Response(c, fiber.StatusInternalServerError, "Failed to check completion", nil)
 	}
 	if !isDone {
-		return webutil.Response(c, fiber.StatusConflict, "Submission not completed yet", nil)
+		return "", false, webutil.Response(c, fiber.StatusConflict, "Submission not completed yet", nil)
 	}
 
 	// Ensure we have an absolute base URL stored for QR codes in the certificate.
@@ -584,12 +692,7 @@ func (h *SigningLinkHandler) DownloadCompletedDocument(c fiber.Ctx) error {
 		  AND COALESCE(preferences->>'public_base_url', '') = ''
 	`, submissionID, baseURL)
 
-	path, err := h.completedDoc.EnsureCompletedPDF(c.Context(), submissionID)
-	if err != nil {
-		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to build completed document", map[string]any{"error": err.Error()})
-	}
-
-	return c.Download(path, fmt.Sprintf("submission_%s.pdf", submissionID))
+	return submissionID, true, nil
 }
 
 // querySubmitterEvents fetches event rows for a given submission and event type.
@@ -659,7 +762,8 @@ func parseAndValidateJSON(c fiber.Ctx, v any) error {
 		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
 	}
 	if err := webutil.ValidateStruct(v); err != nil {
-		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		log.Debug().Err(err).Msg("Invalid request body")
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
 	}
 	return nil
 }
@@ -4,17 +4,19 @@ import (
 	"bytes"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/shurco/gosign/internal/middleware"
+	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/testutil"
 )
 
 func TestSigningLinkHandler_AuthValidationAndSimpleDBFlow(t *testing.T) {
 	pool := testutil.NewTestDB(t)
-	hWithDB := NewSigningLinkHandler(pool, nil, nil)
-	hNoDB := NewSigningLinkHandler(nil, nil, nil)
+	hWithDB := NewSigningLinkHandler(pool, nil, nil, nil)
+	hNoDB := NewSigningLinkHandler(nil, nil, nil, nil)
 
 	tests := []struct {
 		name         string
@@ -109,3 +111,71 @@ func TestSigningLinkHandler_AuthValidationAndSimpleDBFlow(t *testing.T) {
 		})
 	}
 }
+
+func TestSigningOrders(t *testing.T) {
+	order := func(o int) *int { return &o }
+	template := func(orders ...int) []models.Submitter {
+		var submitters []models.Submitter
+		for _, o := range orders {
+			submitters = append(submitters, models.Submitter{Order: o})
+		}
+		return submitters
+	}
+
+	tests := []struct {
+		name       string
+		submitters []SubmitterInput
+		template   []models.Submitter
+		want       []int
+		wantErr    bool
+	}{
+		{
+			name:       "template without groups signs in list order",
+			submitters: make([]SubmitterInput, 3),
+			template:   template(0, 0, 0),
+			want:       []int{0, 1, 2},
+		},
+		{
+			name:       "template groups",
+			submitters: make([]SubmitterInput, 5),
+			template:   template(0, 0, 1, 2, 2),
+			want:       []int{0, 0, 1, 2, 2},
+		},
+		{
+			name:       "request orders override the template",
+			submitters: []SubmitterInput{{Order: order(1)}, {Order: order(0)}, {Order: order(1)}},
+			template:   template(0, 1, 2),
+			want:       []int{1, 0, 1},
+		},
+		{
+			name:       "orders set for some submitters only",
+			submitters: []SubmitterInput{{}, {Order: order(0)}},
+			template:   template(0, 1),
+			wantErr:    true,
+		},
+		{
+			name:       "negative order",
+			submitters: []SubmitterInput{{Order: order(0)}, {Order: order(-1)}},
+			template:   template(0, 1),
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := signingOrders(tc.submitters, tc.template)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
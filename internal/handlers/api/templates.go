@@ -11,6 +11,7 @@ import (
 	"image/jpeg"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -22,9 +23,9 @@ import (
 
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
 	"github.com/shurco/gosign/internal/services/field"
 	"github.com/shurco/gosign/internal/services/formula"
-	"github.com/shurco/gosign/pkg/appdir"
 	"github.com/shurco/gosign/pkg/pdf"
 	"github.com/shurco/gosign/pkg/utils/webutil"
 	"github.com/signintech/gopdf"
@@ -34,13 +35,18 @@ import (
 type TemplateHandler struct {
 	*ResourceHandler[models.Template] // embed generic CRUD
 	templateQueries                   *queries.TemplateQueries
+	blobs                             *services.BlobService
+	webhookEvents                     *services.WebhookEvents
 }
 
-// NewTemplateHandler creates new handler
-func NewTemplateHandler(repo ResourceRepository[models.Template], templateQueries *queries.TemplateQueries) *TemplateHandler {
+// NewTemplateHandler creates new handler; page PDFs and previews are stored as blobs in the
+// storage of the organization of the template
+func NewTemplateHandler(repo ResourceRepository[models.Template], templateQueries *queries.TemplateQueries, blobs *services.BlobService, webhookEvents *services.WebhookEvents) *TemplateHandler {
 	return &TemplateHandler{
 		ResourceHandler: NewResourceHandler("template", repo),
 		templateQueries: templateQueries,
+		blobs:           blobs,
+		webhookEvents:   webhookEvents,
 	}
 }
 
@@ -99,6 +105,7 @@ func (h *TemplateHandler) CreateEmptyTemplate(c fiber.Ctx) error {
 		log.Error().Err(err).Msg("Failed to create empty template")
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to create template", nil)
 	}
+	h.webhookEvents.Template(c.Context(), models.EventTemplateCreated, template.ID)
 
 	return webutil.Response(c, fiber.StatusCreated, "template", template)
 }
@@ -150,6 +157,7 @@ func (h *TemplateHandler) Clone(c fiber.Ctx) error {
 		log.Error().Err(err).Msg("Failed to clone template")
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to clone template", nil)
 	}
+	h.webhookEvents.Template(c.Context(), models.EventTemplateCreated, cloned.ID)
 
 	return webutil.Response(c, fiber.StatusCreated, "template", cloned)
 }
@@ -247,6 +255,7 @@ func (h *TemplateHandler) AttachFileToTemplate(c fiber.Ctx) error {
 		log.Error().Err(err).Str("template_id", templateID).Msg("Failed to load updated template after attach")
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to load updated template", nil)
 	}
+	h.webhookEvents.Template(c.Context(), models.EventTemplateUpdated, templateID)
 
 	return webutil.Response(c, fiber.StatusOK, "template", updated)
 }
@@ -328,6 +337,7 @@ func (h *TemplateHandler) CreateFromType(c fiber.Ctx) error {
 			})
 		}
 	}
+	h.webhookEvents.Template(c.Context(), models.EventTemplateCreated, template.ID)
 
 	return webutil.Response(c, fiber.StatusCreated, "template", template)
 }
@@ -430,12 +440,12 @@ func (h *TemplateHandler) processPDF(ctx context.Context, name, description stri
 	return template, nil
 }
 
-// savePDFToStorage splits a PDF into individual pages and saves each page to the lc_pages directory.
-// For each page, it creates:
-// - lc_pages/{attachment_id}/0.pdf - the PDF page file
-// - lc_pages/{attachment_id}/0.jpg - the full preview image
-// - lc_pages/{attachment_id}/p/0.jpg - the thumbnail preview image
-// It also creates storage_attachment and storage_blob records in the database.
+// savePDFToStorage splits a PDF into individual pages and stores each page as blobs.
+// For each page, it creates (served under /drive/pages):
+// - {attachment_id}/0.pdf - the PDF page file
+// - {attachment_id}/0.jpg - the full preview image
+// - {attachment_id}/p/0.jpg - the thumbnail preview image
+// Identical files are stored once; storage_attachment rows reference their storage_blob.
 func (h *TemplateHandler) savePDFToStorage(ctx context.Context, templateID, name string, fileData []byte, organizationID string) error {
 	return h.savePDFToStorageWithBaseSchema(ctx, templateID, name, fileData, organizationID, nil)
 }
@@ -464,7 +474,7 @@ func (h *TemplateHandler) savePDFToStorageWithBaseSchema(
 	return nil
 }
 
-// storePDFPagesToStorage splits the PDF into pages, writes them to lc_pages, creates storage records,
+// storePDFPagesToStorage splits the PDF into pages, stores their files as blobs, creates storage records,
 // and returns schema items for the newly-added pages (does NOT update template.schema).
 func (h *TemplateHandler) storePDFPagesToStorage(ctx context.Context, templateID, name string, fileData []byte, organizationID string) ([]models.Schema, error) {
 	// Save PDF to temporary location
@@ -475,10 +485,10 @@ func (h *TemplateHandler) storePDFPagesToStorage(ctx context.Context, templateID
 		return nil, fmt.Errorf("failed to write temp file: %w", err)
 	}
 
-	// Ensure lc_pages directory exists (next to executable, same as app.go)
-	lcPagesDir := appdir.LcPages()
-	if err := os.MkdirAll(lcPagesDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create lc_pages directory: %w", err)
+	// Pages are kept in the storage of the organization owning the template
+	templateOrgID, err := h.templateQueries.TemplateOrganizationID(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
 	}
 
 	var schema []models.Schema
@@ -530,25 +540,19 @@ func (h *TemplateHandler) storePDFPagesToStorage(ctx context.Context, templateID
 	// Process each extracted page
 	for pageNum := 1; pageNum <= pageCount; pageNum++ {
 		attachmentID := uuid.New().String()
-		pageDir := filepath.Join(lcPagesDir, attachmentID)
-		if err := os.MkdirAll(pageDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create page directory: %w", err)
-		}
 
 		// Use extracted page PDF file
 		extractedPagePath := filepath.Join(tmpPagesDir, fmt.Sprintf("page_%d.pdf", pageNum))
 
-		// Copy extracted page to lc_pages/{attachment_id}/0.pdf
+		// Store extracted page as {attachment_id}/0.pdf
 		pageData, err := os.ReadFile(extractedPagePath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read extracted page: %w", err)
 		}
 
-		pagePDFPath := filepath.Join(pageDir, "0.pdf")
-		if err := os.WriteFile(pagePDFPath, pageData, 0644); err != nil {
-			absPath, _ := filepath.Abs(pagePDFPath)
-			log.Error().Err(err).Str("path", pagePDFPath).Str("abs_path", absPath).Msg("Failed to save page PDF")
-			return nil, fmt.Errorf("failed to save page PDF to %s: %w", pagePDFPath, err)
+		if _, err := h.storePageFile(ctx, templateOrgID, attachmentID, "0.pdf", pageData, "application/pdf", nil); err != nil {
+			log.Error().Err(err).Str("attachment_id", attachmentID).Msg("Failed to save page PDF")
+			return nil, fmt.Errorf("failed to save page PDF of page %d: %w", pageNum, err)
 		}
 
 		// Generate preview image for this page from extracted PDF
@@ -562,23 +566,18 @@ func (h *TemplateHandler) storePDFPagesToStorage(ctx context.Context, templateID
 
 		if previewData, err := os.ReadFile(previewImagePath); err == nil {
 			// Save full preview as 0.jpg
-			previewPath := filepath.Join(pageDir, "0.jpg")
-			if err := os.WriteFile(previewPath, previewData, 0644); err == nil {
-				// Create storage_blob for preview
-				previewBlobID = uuid.New().String()
-				previewMetadata := map[string]any{"width": 1400, "height": 1980, "analyzed": true, "identified": true}
-				if err := h.templateQueries.CreateStorageBlob(ctx, previewBlobID, "0.jpg", "image/jpeg", int64(len(previewData)), previewMetadata); err != nil {
-					log.Warn().Err(err).Int("page", pageNum).Msg("Failed to create preview blob")
-				}
+			previewMetadata := map[string]any{"width": 1400, "height": 1980, "analyzed": true, "identified": true}
+			if previewBlob, err := h.storePageFile(ctx, templateOrgID, attachmentID, "0.jpg", previewData, "image/jpeg", previewMetadata); err == nil {
+				previewBlobID = previewBlob.ID
 
 				// Create small preview in p/ folder (thumbnail)
-				pDir := filepath.Join(pageDir, "p")
-				if err := os.MkdirAll(pDir, 0755); err == nil {
-					thumbnailPath := filepath.Join(pDir, "0.jpg")
-					if thumbnailData, err := createThumbnail(previewData); err == nil {
-						_ = os.WriteFile(thumbnailPath, thumbnailData, 0644)
+				if thumbnailData, err := createThumbnail(previewData); err == nil {
+					if _, err := h.storePageFile(ctx, templateOrgID, attachmentID, "p/0.jpg", thumbnailData, "image/jpeg", nil); err != nil {
+						log.Warn().Err(err).Int("page", pageNum).Msg("Failed to save thumbnail")
 					}
 				}
+			} else {
+				log.Warn().Err(err).Int("page", pageNum).Msg("Failed to save preview")
 			}
 		}
 
@@ -599,6 +598,19 @@ func (h *TemplateHandler) storePDFPagesToStorage(ctx context.Context, templateID
 	return schema, nil
 }
 
+// storePageFile stores a file of a template page (0.pdf, 0.jpg or p/0.jpg) as a blob and
+// attaches it to the page, so /drive/pages/{token}/{attachment_id}/{name} serves it
+func (h *TemplateHandler) storePageFile(ctx context.Context, organizationID, attachmentID, name string, data []byte, contentType string, metadata map[string]any) (*models.StorageBlob, error) {
+	blob, err := h.blobs.Put(ctx, organizationID, data, path.Base(name), contentType, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.templateQueries.CreateStorageAttachment(ctx, uuid.New().String(), blob.ID, "Page", attachmentID, name, "disk"); err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", name, err)
+	}
+	return blob, nil
+}
+
 // generatePagePreview generates a preview image from a PDF page using pdftoppm.
 // It renders the first page of the PDF at 150 DPI and saves it as a JPEG image.
 // The output image is saved to the specified outputPath.
@@ -1321,6 +1333,12 @@ func (h *TemplateHandler) UpdateTemplate(c fiber.Ctx) error {
 			if err := json.Unmarshal(b, &submitters); err != nil {
 				return webutil.Response(c, fiber.StatusBadRequest, "Invalid submitters", nil)
 			}
+			// Submitters sharing an order sign in parallel (see models.SigningOrders)
+			for _, s := range submitters {
+				if s.Order < 0 {
+					return webutil.Response(c, fiber.StatusBadRequest, "Invalid submitters: order must not be negative", nil)
+				}
+			}
 			patch.Submitters = &submitters
 		}
 	}
@@ -1329,6 +1347,7 @@ func (h *TemplateHandler) UpdateTemplate(c fiber.Ctx) error {
 		log.Error().Err(err).Str("template_id", templateID).Msg("Failed to update template")
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to update template", nil)
 	}
+	h.webhookEvents.Template(c.Context(), models.EventTemplateUpdated, templateID)
 
 	return webutil.Response(c, fiber.StatusOK, "template", map[string]any{"id": templateID})
 }
@@ -13,7 +13,7 @@ import (
 )
 
 func TestTemplateHandler_ValidationAndAuth(t *testing.T) {
-	h := NewTemplateHandler(newMemRepo[models.Template](), nil)
+	h := NewTemplateHandler(newMemRepo[models.Template](), nil, nil, nil)
 
 	tests := []struct {
 		name         string
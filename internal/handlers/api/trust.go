@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/trust"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// maxTrustListUploadSize bounds uploaded trust lists; national ETSI lists are
+// a few megabytes
+const maxTrustListUploadSize = 16 << 20
+
+// defaultCustomTrustList names uploaded anchors when no list is given
+const defaultCustomTrustList = "custom"
+
+// TrustHandler manages the custom trust anchors of an organization (or account).
+// They are trusted in addition to the platform trust lists when the owner
+// verifies documents.
+type TrustHandler struct {
+	trust               *queries.TrustQueries
+	userQueries         *queries.UserQueries
+	organizationQueries *queries.OrganizationQueries
+}
+
+// NewTrustHandler creates new trust anchor handler
+func NewTrustHandler(trustQueries *queries.TrustQueries, userQueries *queries.UserQueries, organizationQueries *queries.OrganizationQueries) *TrustHandler {
+	return &TrustHandler{trust: trustQueries, userQueries: userQueries, organizationQueries: organizationQueries}
+}
+
+// RegisterRoutes registers trust anchor routes; only organization owners and
+// admins change the anchors
+func (h *TrustHandler) RegisterRoutes(router fiber.Router) {
+	admin := RequireScopeAdmin(h.userQueries, h.organizationQueries)
+	router.Get("/anchors", h.List)
+	router.Post("/anchors", admin, h.Import)
+	router.Delete("/anchors/:id", admin, h.Delete)
+}
+
+// List lists custom trust anchors
+// @Summary List trust anchors
+// @Description Get the custom trust anchors of the current organization (or account)
+// @Tags trust
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/trust/anchors [get]
+func (h *TrustHandler) List(c fiber.Ctx) error {
+	orgID, accountID, err := ResolveOwnerScope(c, h.userQueries)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	anchors, err := h.trust.ListTrustAnchors(c.Context(), orgID, accountID)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to list trust anchors", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Trust anchors retrieved successfully", anchors)
+}
+
+// Import adds custom trust anchors
+// @Summary Import trust anchors
+// @Description Import the roots of a PEM (or DER) certificate bundle or the active services of an ETSI TS 119 612 trusted list. Anchors already in the list are skipped
+// @Tags trust
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "PEM bundle or ETSI trusted list (XML)"
+// @Param list formData string false "List name (default custom)"
+// @Success 201 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/trust/anchors [post]
+func (h *TrustHandler) Import(c fiber.Ctx) error {
+	orgID, accountID, err := ResolveOwnerScope(c, h.userQueries)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Provide a file", nil)
+	}
+	data, err := readUpload(fh, maxTrustListUploadSize)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	list := strings.TrimSpace(c.FormValue("list"))
+	if list == "" {
+		list = defaultCustomTrustList
+	}
+	if len(list) > 64 {
+		return webutil.Response(c, fiber.StatusBadRequest, "List name is too long", nil)
+	}
+
+	anchors, err := trust.Parse(list, fh.Filename, data)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid trust list: "+err.Error(), nil)
+	}
+	if len(anchors) == 0 {
+		return webutil.Response(c, fiber.StatusBadRequest, "The file has no trust anchors", nil)
+	}
+
+	added, err := h.trust.AddTrustAnchors(c.Context(), orgID, accountID, trust.Records(anchors))
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to store trust anchors", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusCreated, "Trust anchors imported successfully", added)
+}
+
+// Delete removes a custom trust anchor
+// @Summary Delete trust anchor
+// @Description Delete a custom trust anchor of the current organization (or account)
+// @Tags trust
+// @Produce json
+// @Param id path string true "Trust anchor ID"
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/v1/trust/anchors/{id} [delete]
+func (h *TrustHandler) Delete(c fiber.Ctx) error {
+	orgID, accountID, err := ResolveOwnerScope(c, h.userQueries)
+	if err != nil {
+		return scopeErrorResponse(c, err)
+	}
+
+	if err := h.trust.DeleteTrustAnchor(c.Context(), c.Params("id"), orgID, accountID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Trust anchor not found", nil)
+		}
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to delete trust anchor", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Trust anchor deleted successfully", nil)
+}
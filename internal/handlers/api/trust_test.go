@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/testutil"
+	"github.com/shurco/gosign/internal/trust"
+)
+
+func TestTrustHandler(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	h := NewTrustHandler(&queries.TrustQueries{Pool: pool}, queries.NewUserQueries(pool), queries.NewOrganizationQueries(pool))
+
+	newApp := func(user testutil.FixtureUser) *fiber.App {
+		app := fiber.New()
+		app.Use(testutil.AuthMiddleware(user))
+		h.RegisterRoutes(app.Group("/trust"))
+		return app
+	}
+
+	roots, err := os.ReadFile(filepath.Join("..", "..", "trust", "testdata", "roots.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(t *testing.T, user testutil.FixtureUser, data []byte) (int, []map[string]any) {
+		t.Helper()
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		fw, _ := w.CreateFormFile("file", "roots.pem")
+		fw.Write(data)
+		w.WriteField("list", "partners")
+		w.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/trust/anchors", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		resp, err := newApp(user).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		var out struct {
+			Data []map[string]any `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out.Data
+	}
+
+	list := func(t *testing.T, user testutil.FixtureUser) []map[string]any {
+		t.Helper()
+		resp, err := newApp(user).Test(httptest.NewRequest(http.MethodGet, "/trust/anchors", nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		var out struct {
+			Data []map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out.Data
+	}
+
+	t.Run("invalid file returns 400", func(t *testing.T) {
+		if status, _ := upload(t, testutil.User1, []byte("not a certificate")); status != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("viewer cannot import", func(t *testing.T) {
+		viewer := testutil.User2
+		viewer.OrganizationID = newTestOrganization(t, pool, map[string]models.OrganizationRole{
+			testutil.User1.AccountID: models.OrganizationRoleOwner,
+			testutil.User2.AccountID: models.OrganizationRoleViewer,
+		})
+		if status, _ := upload(t, viewer, roots); status != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d", status, http.StatusForbidden)
+		}
+	})
+
+	t.Run("import, lookup, delete", func(t *testing.T) {
+		status, added := upload(t, testutil.User1, roots)
+		if status != http.StatusCreated || len(added) != 2 {
+			t.Fatalf("import = %d with %d anchors, want %d with 2", status, len(added), http.StatusCreated)
+		}
+		if added[0]["list"] != "partners" || added[0]["location"] != "roots.pem" {
+			t.Fatalf("anchor = %v", added[0])
+		}
+
+		// Importing again adds nothing
+		if status, again := upload(t, testutil.User1, roots); status != http.StatusCreated || len(again) != 0 {
+			t.Fatalf("second import = %d with %d anchors", status, len(again))
+		}
+
+		if got := len(list(t, testutil.User1)); got != 2 {
+			t.Fatalf("owner sees %d anchors, want 2", got)
+		}
+		if got := len(list(t, testutil.User2)); got != 0 {
+			t.Fatalf("other account sees %d anchors, want 0", got)
+		}
+
+		// The anchors only apply to their owner
+		anchors, err := trust.Parse("partners", "roots.pem", roots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyID := anchors[0].Certificate.SubjectKeyId
+		q := &queries.TrustQueries{Pool: pool}
+		if rows, err := q.TrustAnchorsBySKI(t.Context(), trust.Records(anchors)[0].SKI, "", testutil.User1.AccountID); err != nil || len(rows) != 1 {
+			t.Fatalf("owner lookup of %x = %d rows, %v", keyID, len(rows), err)
+		}
+		if rows, err := q.TrustAnchorsBySKI(t.Context(), trust.Records(anchors)[0].SKI, "", testutil.User2.AccountID); err != nil || len(rows) != 0 {
+			t.Fatalf("foreign lookup = %d rows, %v", len(rows), err)
+		}
+
+		id, _ := added[0]["id"].(string)
+		resp, err := newApp(testutil.User2).Test(httptest.NewRequest(http.MethodDelete, "/trust/anchors/"+id, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("foreign delete status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+
+		resp, err = newApp(testutil.User1).Test(httptest.NewRequest(http.MethodDelete, "/trust/anchors/"+id, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("delete status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if got := len(list(t, testutil.User1)); got != 1 {
+			t.Fatalf("%d anchors left, want 1", got)
+		}
+	})
+}
@@ -1,26 +1,237 @@
 package api
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 
+	"github.com/shurco/gosign/internal/middleware"
 	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/utils/webutil"
 )
 
+// WebhookDeliveries lists and redelivers the deliveries of the webhooks of an account and
+// rotates the secrets they are signed with
+type WebhookDeliveries interface {
+	Deliveries(ctx context.Context, accountID, webhookID, status string, limit, offset int) ([]*models.WebhookDelivery, int, error)
+	Delivery(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error)
+	RotateSecret(ctx context.Context, accountID, webhookID string, keepPrevious time.Duration) (string, bool, error)
+}
+
 // WebhookHandler handles requests to webhooks
 type WebhookHandler struct {
 	*ResourceHandler[models.Webhook] // embed generic CRUD
+	deliveries                       WebhookDeliveries
+	userQueries                      *queries.UserQueries
 }
 
 // NewWebhookHandler creates new handler
-func NewWebhookHandler(repo ResourceRepository[models.Webhook]) *WebhookHandler {
+func NewWebhookHandler(repo ResourceRepository[models.Webhook], deliveries WebhookDeliveries, userQueries *queries.UserQueries) *WebhookHandler {
 	return &WebhookHandler{
 		ResourceHandler: NewResourceHandler("webhook", repo),
+		deliveries:      deliveries,
+		userQueries:     userQueries,
 	}
 }
 
-// RegisterRoutes registers all routes for webhooks
-// Using only generic CRUD - no specific logic needed
+// ListDeliveries returns the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description Returns the deliveries of a webhook, newest first: state (pending, delivered, dead), attempts, next attempt, and the response status, body excerpt and latency of the last attempt
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param status query string false "pending, delivered or dead"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c fiber.Ctx) error {
+	accountID, webhookID, err := h.webhookScope(c)
+	if err != nil {
+		return err
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
+	page = max(page, 1)
+	pageSize = min(max(pageSize, 1), 100)
+
+	status := c.Query("status")
+	switch models.WebhookDeliveryStatus(status) {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		return webutil.Response(c, fiber.StatusBadRequest, "Invalid status", nil)
+	}
+
+	items, total, err := h.deliveries.Deliveries(c.Context(), accountID, webhookID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Error().Err(err).Str("webhook_id", webhookID).Msg("Failed to list webhook deliveries")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to retrieve deliveries", nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "webhook deliveries", map[string]any{
+		"items":       items,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+		"total_pages": (total + pageSize - 1) / pageSize,
+	})
+}
+
+// GetDelivery returns a delivery of a webhook with its payload
+// @Summary Get webhook delivery
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id} [get]
+func (h *WebhookHandler) GetDelivery(c fiber.Ctx) error {
+	accountID, webhookID, err := h.webhookScope(c)
+	if err != nil {
+		return err
+	}
+	deliveryID := c.Params("delivery_id")
+	if uuid.Validate(deliveryID) != nil {
+		return webutil.Response(c, fiber.StatusNotFound, "Delivery not found", nil)
+	}
+
+	delivery, err := h.deliveries.Delivery(c.Context(), accountID, webhookID, deliveryID)
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", deliveryID).Msg("Failed to get webhook delivery")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to retrieve delivery", nil)
+	}
+	if delivery == nil {
+		return webutil.Response(c, fiber.StatusNotFound, "Delivery not found", nil)
+	}
+	return webutil.Response(c, fiber.StatusOK, "webhook delivery", delivery)
+}
+
+// Redeliver queues the event of a delivery again
+// @Summary Redeliver webhook event
+// @Description Queues the event of a delivery again, whatever its state (dead deliveries included), as a new delivery with the same event ID; it is attempted right away and retried like any other
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c fiber.Ctx) error {
+	accountID, webhookID, err := h.webhookScope(c)
+	if err != nil {
+		return err
+	}
+	deliveryID := c.Params("delivery_id")
+	if uuid.Validate(deliveryID) != nil {
+		return webutil.Response(c, fiber.StatusNotFound, "Delivery not found", nil)
+	}
+
+	delivery, err := h.deliveries.Redeliver(c.Context(), accountID, webhookID, deliveryID)
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", deliveryID).Msg("Failed to redeliver webhook event")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to redeliver", nil)
+	}
+	if delivery == nil {
+		return webutil.Response(c, fiber.StatusNotFound, "Delivery not found", nil)
+	}
+
+	log.Info().Str("webhook_id", webhookID).Str("delivery_id", deliveryID).Str("redelivery_id", delivery.ID).Msg("Webhook event redelivered")
+	return webutil.Response(c, fiber.StatusAccepted, "Webhook event queued", delivery)
+}
+
+// RotateSecretRequest sets how long the replaced secret keeps signing deliveries
+type RotateSecretRequest struct {
+	KeepPreviousHours *int `json:"keep_previous_hours"` // 24 when unset, 0 revokes it at once, at most 168
+}
+
+// RotateSecret gives a webhook a new signing secret
+// @Summary Rotate webhook secret
+// @Description Generates a new signing secret (whsec_…). Until keep_previous_hours have passed, deliveries carry a signature with the replaced secret next to the new one in webhook-signature, so the endpoint can be switched without rejecting deliveries
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param request body RotateSecretRequest false "Rotation"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /api/v1/webhooks/{id}/secret/rotate [post]
+func (h *WebhookHandler) RotateSecret(c fiber.Ctx) error {
+	accountID, webhookID, err := h.webhookScope(c)
+	if err != nil {
+		return err
+	}
+
+	var req RotateSecretRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return webutil.Response(c, fiber.StatusBadRequest, "Invalid request body", nil)
+		}
+	}
+	keepHours := 24
+	if req.KeepPreviousHours != nil {
+		keepHours = *req.KeepPreviousHours
+	}
+	if keepHours < 0 || keepHours > 168 {
+		return webutil.Response(c, fiber.StatusBadRequest, "keep_previous_hours must be between 0 and 168", nil)
+	}
+	keepPrevious := time.Duration(keepHours) * time.Hour
+
+	secret, ok, err := h.deliveries.RotateSecret(c.Context(), accountID, webhookID, keepPrevious)
+	if err != nil {
+		log.Error().Err(err).Str("webhook_id", webhookID).Msg("Failed to rotate webhook secret")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to rotate secret", nil)
+	}
+	if !ok {
+		return webutil.Response(c, fiber.StatusNotFound, "Webhook not found", nil)
+	}
+
+	log.Info().Str("webhook_id", webhookID).Int("keep_previous_hours", keepHours).Msg("Webhook secret rotated")
+	response := map[string]any{"secret": secret}
+	if keepPrevious > 0 {
+		response["previous_expires_at"] = time.Now().Add(keepPrevious).UTC()
+	}
+	return webutil.Response(c, fiber.StatusOK, "Webhook secret rotated", response)
+}
+
+// webhookScope returns the caller's account and the webhook of the request
+func (h *WebhookHandler) webhookScope(c fiber.Ctx) (string, string, error) {
+	if h.deliveries == nil {
+		return "", "", fiber.NewError(fiber.StatusServiceUnavailable, "Webhook deliveries are not available")
+	}
+	webhookID := c.Params("id")
+	if uuid.Validate(webhookID) != nil {
+		return "", "", fiber.NewError(fiber.StatusNotFound, "Webhook not found")
+	}
+
+	if auth := middleware.GetAuthContext(c); auth != nil && auth.AccountID != "" {
+		return auth.AccountID, webhookID, nil
+	}
+	accountID, err := ResolveAccountID(c, h.userQueries)
+	if err != nil {
+		return "", "", err
+	}
+	return accountID, webhookID, nil
+}
+
+// RegisterRoutes registers all routes for webhooks: the generic CRUD, the delivery log and secret rotation
 func (h *WebhookHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/:id/deliveries", h.ListDeliveries)
+	router.Get("/:id/deliveries/:delivery_id", h.GetDelivery)
+	router.Post("/:id/deliveries/:delivery_id/redeliver", h.Redeliver)
+	router.Post("/:id/secret/rotate", h.RotateSecret)
 	h.ResourceHandler.RegisterRoutes(router)
 }
-
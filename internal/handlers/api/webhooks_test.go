@@ -2,12 +2,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 	"github.com/shurco/gosign/internal/middleware"
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/testutil"
@@ -15,7 +19,7 @@ import (
 
 func TestWebhookHandler_CRUDViaResourceHandler(t *testing.T) {
 	repo := newMemRepo[models.Webhook]()
-	h := NewWebhookHandler(repo)
+	h := NewWebhookHandler(repo, nil, nil)
 
 	tests := []struct {
 		name       string
@@ -111,3 +115,146 @@ func TestWebhookHandler_CRUDViaResourceHandler(t *testing.T) {
 	}
 }
 
+type fakeWebhookDeliveries struct {
+	items     []*models.WebhookDelivery
+	rotations []time.Duration
+}
+
+func (f *fakeWebhookDeliveries) Deliveries(_ context.Context, accountID, webhookID, status string, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	var items []*models.WebhookDelivery
+	for _, d := range f.items {
+		if accountID == testutil.User1.AccountID && d.WebhookID == webhookID && (status == "" || string(d.Status) == status) {
+			items = append(items, d)
+		}
+	}
+	return items, len(items), nil
+}
+
+func (f *fakeWebhookDeliveries) Delivery(_ context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	for _, d := range f.items {
+		if accountID == testutil.User1.AccountID && d.WebhookID == webhookID && d.ID == id {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeWebhookDeliveries) Redeliver(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	d, err := f.Delivery(ctx, accountID, webhookID, id)
+	if d == nil || err != nil {
+		return nil, err
+	}
+	redelivery := *d
+	redelivery.ID, redelivery.Status, redelivery.Attempts, redelivery.RedeliveryOf = uuid.NewString(), models.WebhookDeliveryPending, 0, d.ID
+	f.items = append(f.items, &redelivery)
+	return &redelivery, nil
+}
+
+func (f *fakeWebhookDeliveries) RotateSecret(_ context.Context, accountID, webhookID string, keepPrevious time.Duration) (string, bool, error) {
+	if accountID != testutil.User1.AccountID {
+		return "", false, nil
+	}
+	f.rotations = append(f.rotations, keepPrevious)
+	return "whsec_new", true, nil
+}
+
+func TestWebhookHandler_RotateSecret(t *testing.T) {
+	deliveries := &fakeWebhookDeliveries{}
+	h := NewWebhookHandler(newMemRepo[models.Webhook](), deliveries, nil)
+	path := "/webhooks/" + uuid.NewString() + "/secret/rotate"
+
+	tests := []struct {
+		name       string
+		user       testutil.FixtureUser
+		body       string
+		wantStatus int
+		wantKeep   time.Duration
+	}{
+		{"default overlap", testutil.User1, "", http.StatusOK, 24 * time.Hour},
+		{"revoke at once", testutil.User1, `{"keep_previous_hours":0}`, http.StatusOK, 0},
+		{"overlap too long", testutil.User1, `{"keep_previous_hours":1000}`, http.StatusBadRequest, -1},
+		{"another account", testutil.User2, "", http.StatusNotFound, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries.rotations = nil
+			app := fiber.New()
+			app.Use(testutil.AuthMiddleware(tt.user))
+			h.RegisterRoutes(app.Group("/webhooks"))
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantKeep >= 0 && (len(deliveries.rotations) != 1 || deliveries.rotations[0] != tt.wantKeep) {
+				t.Fatalf("rotations = %v, want one keeping the previous secret %v", deliveries.rotations, tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_Deliveries(t *testing.T) {
+	webhookID := uuid.NewString()
+	dead := &models.WebhookDelivery{ID: uuid.NewString(), WebhookID: webhookID, EventType: "submission.completed", Status: models.WebhookDeliveryDead, Attempts: 12}
+	delivered := &models.WebhookDelivery{ID: uuid.NewString(), WebhookID: webhookID, EventType: "submission.created", Status: models.WebhookDeliveryDelivered, Attempts: 1}
+	deliveries := &fakeWebhookDeliveries{items: []*models.WebhookDelivery{dead, delivered}}
+	h := NewWebhookHandler(newMemRepo[models.Webhook](), deliveries, nil)
+
+	tests := []struct {
+		name       string
+		user       testutil.FixtureUser
+		method     string
+		path       string
+		wantStatus int
+		wantItems  int
+	}{
+		{"list", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries", http.StatusOK, 2},
+		{"list by status", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries?status=dead", http.StatusOK, 1},
+		{"list invalid status", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries?status=lost", http.StatusBadRequest, 0},
+		{"list of another account", testutil.User2, http.MethodGet, "/webhooks/" + webhookID + "/deliveries", http.StatusOK, 0},
+		{"list invalid webhook", testutil.User1, http.MethodGet, "/webhooks/bad-id/deliveries", http.StatusNotFound, 0},
+		{"get", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries/" + dead.ID, http.StatusOK, 0},
+		{"get of another account", testutil.User2, http.MethodGet, "/webhooks/" + webhookID + "/deliveries/" + dead.ID, http.StatusNotFound, 0},
+		{"get invalid delivery", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries/bad-id", http.StatusNotFound, 0},
+		{"redeliver of another account", testutil.User2, http.MethodPost, "/webhooks/" + webhookID + "/deliveries/" + dead.ID + "/redeliver", http.StatusNotFound, 0},
+		{"redeliver", testutil.User1, http.MethodPost, "/webhooks/" + webhookID + "/deliveries/" + dead.ID + "/redeliver", http.StatusAccepted, 0},
+		{"list after redelivery", testutil.User1, http.MethodGet, "/webhooks/" + webhookID + "/deliveries?status=pending", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(testutil.AuthMiddleware(tt.user))
+			h.RegisterRoutes(app.Group("/webhooks"))
+
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.StatusCode != http.StatusOK || strings.Contains(tt.path, "/deliveries/") {
+				return // not a list
+			}
+
+			var body struct {
+				Data struct {
+					Items []models.WebhookDelivery `json:"items"`
+					Total int                      `json:"total"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if len(body.Data.Items) != tt.wantItems || body.Data.Total != tt.wantItems {
+				t.Fatalf("items = %d (total %d), want %d", len(body.Data.Items), body.Data.Total, tt.wantItems)
+			}
+		})
+	}
+}
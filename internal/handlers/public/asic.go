@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/trust"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+	"github.com/shurco/gosign/pkg/security/asic"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// VerifyASiC verifies an ASiC-E container
+// @Summary Verify ASiC-E container
+// @Description Verify the CAdES signature of an ASiC-E container (ETSI EN 319 162-1) and the digests of the files listed in its manifest, e.g. a completed submission exported from /api/v1/signing-links/{submission_id}/asic. Files missing from the container or not matching the manifest make the result TOTAL-FAILED; files the manifest doesn't cover are listed as unsigned. Authenticated requests (/api/v1/verify/asic) also trust the custom anchors of the caller's organization or account
+// @Tags verify
+// @Accept multipart/form-data
+// @Produce json
+// @Param container formData file true "ASiC-E container (.asice)"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Router /verify/asic [post]
+// @Router /api/v1/verify/asic [post]
+func (h *VerifyReportHandler) VerifyASiC(c fiber.Ctx) error {
+	containerHeader, err := c.FormFile("container")
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+	container, err := readFormFile(containerHeader)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	var anchors verify.TrustFunc
+	if h.trust != nil {
+		orgID, accountID := callerScope(c, h.userQueries)
+		anchors = h.trust(c.Context(), trust.Scope{OrganizationID: orgID, AccountID: accountID})
+	}
+
+	result, err := asic.Verify(container, anchors)
+	if err != nil {
+		if errors.Is(err, asic.ErrNotContainer) {
+			return webutil.Response(c, fiber.StatusBadRequest, "File is not an ASiC-E container", nil)
+		}
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	return webutil.Response(c, fiber.StatusOK, "Verification report", result)
+}
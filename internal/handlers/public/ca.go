@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/url"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/services/ca"
+	"github.com/shurco/gosign/pkg/logging"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// CAHandler publishes the internal CA certificate, CRL and OCSP responder (no authentication)
+type CAHandler struct {
+	authority *ca.Authority
+}
+
+// NewCAHandler creates new public CA handler
+func NewCAHandler(authority *ca.Authority) *CAHandler {
+	return &CAHandler{authority: authority}
+}
+
+// RegisterRoutes registers public CA routes; paths match the URLs embedded in issued certificates
+func (h *CAHandler) RegisterRoutes(app fiber.Router) {
+	app.Get(ca.CRLPath, h.CRL)
+	app.Get(ca.CertificatePath, h.Certificate)
+	app.Post(ca.OCSPPath, h.OCSP)
+	app.Get(ca.OCSPPath+"/*", h.OCSP)
+}
+
+// CRL returns the current certificate revocation list
+// @Summary Internal CA CRL
+// @Description DER encoded certificate revocation list of the internal CA
+// @Tags ca
+// @Produce application/pkix-crl
+// @Success 200 {file} binary
+// @Failure 500 {object} map[string]any
+// @Router /ca/crl [get]
+func (h *CAHandler) CRL(c fiber.Ctx) error {
+	crl, err := h.authority.CRL(c.Context())
+	if err != nil {
+		logging.Log.Err(err).Msg("failed to build CRL")
+		return webutil.Response(c, fiber.StatusInternalServerError, "CRL not available", nil)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pkix-crl")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Send(crl)
+}
+
+// Certificate returns the internal CA certificate
+// @Summary Internal CA certificate
+// @Description DER encoded certificate of the internal CA
+// @Tags ca
+// @Produce application/pkix-cert
+// @Success 200 {file} binary
+// @Router /ca/cert [get]
+func (h *CAHandler) Certificate(c fiber.Ctx) error {
+	crt := h.authority.Certificate()
+
+	c.Set(fiber.HeaderContentType, "application/pkix-cert")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(crt.Raw)
+}
+
+// OCSP answers RFC 6960 OCSP requests, sent as POST body or base64 encoded in the GET path
+// @Summary Internal CA OCSP responder
+// @Description Answers OCSP requests for certificates issued by the internal CA
+// @Tags ca
+// @Accept application/ocsp-request
+// @Produce application/ocsp-response
+// @Success 200 {file} binary
+// @Router /ca/ocsp [post]
+func (h *CAHandler) OCSP(c fiber.Ctx) error {
+	raw := c.Body()
+	if c.Method() == fiber.MethodGet {
+		encoded, err := url.PathUnescape(c.Params("*"))
+		if err == nil {
+			raw, err = base64.StdEncoding.DecodeString(encoded)
+		}
+		if err != nil {
+			raw = nil
+		}
+	}
+
+	resp, err := h.authority.Respond(c.Context(), raw)
+	if err != nil {
+		logging.Log.Err(err).Msg("failed to answer OCSP request")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/ocsp-response")
+	return c.Send(resp)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/logging"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// DocumentHashHandler looks copies of documents up by their SHA-256
+type DocumentHashHandler struct {
+	hashes *queries.DocumentHashRepository
+}
+
+// NewDocumentHashHandler creates a document hash handler
+func NewDocumentHashHandler(hashes *queries.DocumentHashRepository) *DocumentHashHandler {
+	return &DocumentHashHandler{hashes: hashes}
+}
+
+// DocumentHashResult tells whether a document is the completed document, or the signature
+// certificate, of a completed submission. Signer names are masked: anyone holding a copy
+// may look it up, so they only confirm names the verifier already knows.
+type DocumentHashResult struct {
+	SHA256       string     `json:"sha256"`
+	Found        bool       `json:"found"`
+	SubmissionID string     `json:"submission_id,omitempty"`
+	Kind         string     `json:"kind,omitempty"`   // completed or certificate
+	Status       string     `json:"status,omitempty"` // completed or archived
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	Signers      []string   `json:"signers,omitempty"` // masked, e.g. "J*** D***"
+}
+
+// LookupHash looks a document up by its SHA-256
+// @Summary Look up a document by hash
+// @Description Report whether a SHA-256 belongs to the completed document or the signature certificate of a completed submission, with the submission, its completion time, status and masked signer names. Field values are never disclosed
+// @Tags verify
+// @Produce json
+// @Param sha256 query string true "SHA-256 of the document, hex"
+// @Success 200 {object} DocumentHashResult
+// @Failure 400 {object} map[string]any
+// @Router /verify/hash [get]
+func (h *DocumentHashHandler) LookupHash(c fiber.Ctx) error {
+	digest := strings.ToLower(strings.TrimSpace(c.Query("sha256")))
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return webutil.Response(c, fiber.StatusBadRequest, "sha256 must be 64 hex characters", nil)
+	}
+	return h.lookup(c, digest)
+}
+
+// VerifyDocumentHash looks an uploaded document up by its SHA-256
+// @Summary Look up a document file
+// @Description Hash an uploaded file and report whether it is a document produced for a submission, like GET /verify/hash. The file is not stored
+// @Tags verify
+// @Accept multipart/form-data
+// @Produce json
+// @Param document formData file true "Document to look up"
+// @Success 200 {object} DocumentHashResult
+// @Failure 400 {object} map[string]any
+// @Router /verify/hash [post]
+func (h *DocumentHashHandler) VerifyDocumentHash(c fiber.Ctx) error {
+	fileHeader, err := c.FormFile("document")
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+	return h.lookup(c, hex.EncodeToString(hash.Sum(nil)))
+}
+
+func (h *DocumentHashHandler) lookup(c fiber.Ctx, digest string) error {
+	match, err := h.hashes.Lookup(c.Context(), digest)
+	if err != nil {
+		logging.Log.Err(err).Msg("failed to look up document hash")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
+	}
+
+	result := DocumentHashResult{SHA256: digest}
+	if match != nil {
+		result.Found = true
+		result.SubmissionID = match.SubmissionID
+		result.Kind = string(match.Kind)
+		result.Status = match.Status
+		result.CompletedAt = &match.CompletedAt
+		for _, name := range match.Signers {
+			result.Signers = append(result.Signers, maskName(name))
+		}
+	}
+	return webutil.Response(c, fiber.StatusOK, "Document hash", result)
+}
+
+// maskName keeps the first letter of every word of a name.
+// Example: "Jane van Doe" -> "J*** v*** D***"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r, _ := utf8.DecodeRuneInString(w)
+		words[i] = string(r) + "***"
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/testutil"
+)
+
+func TestDocumentHashHandler(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	ctx := context.Background()
+	hashes := queries.NewDocumentHashRepository(pool)
+
+	app := fiber.New()
+	h := NewDocumentHashHandler(hashes)
+	app.Get("/verify/hash", h.LookupHash)
+	app.Post("/verify/hash", h.VerifyDocumentHash)
+
+	decode := func(t *testing.T, resp *http.Response) DocumentHashResult {
+		t.Helper()
+		var out struct {
+			Data DocumentHashResult `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Data
+	}
+	lookup := func(t *testing.T, data []byte) DocumentHashResult {
+		t.Helper()
+		sum := sha256.Sum256(data)
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/verify/hash?sha256="+hex.EncodeToString(sum[:]), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		return decode(t, resp)
+	}
+
+	// A completed submission, signed by Signer 1 and then Signer 2
+	completedID, slugs := newSigningSubmission(t, pool, models.SigningModeSequential, 0, 1)
+	for i, slug := range slugs {
+		_, err := pool.Exec(ctx, `
+			UPDATE submitter SET status = 'completed', completed_at = NOW() - make_interval(mins => $2)
+			WHERE slug = $1
+		`, slug, len(slugs)-i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A submission still waiting for its second signer
+	pendingID, _ := newSigningSubmission(t, pool, models.SigningModeSequential, 0, 1)
+
+	original := []byte("%PDF-1.7 original")
+	completed := []byte("%PDF-1.7 completed")
+	certificate := []byte("%PDF-1.7 certificate")
+	pending := []byte("%PDF-1.7 pending")
+	for _, r := range []struct {
+		submissionID string
+		kind         models.DocumentKind
+		data         []byte
+	}{
+		{completedID, models.DocumentKindOriginal, original},
+		{completedID, models.DocumentKindCompleted, completed},
+		{completedID, models.DocumentKindCompleted, completed}, // recorded twice
+		{completedID, models.DocumentKindCertificate, certificate},
+		{pendingID, models.DocumentKindCompleted, pending},
+	} {
+		if err := hashes.Record(ctx, r.submissionID, r.kind, r.data); err != nil {
+			t.Fatalf("Record(%s): %v", r.kind, err)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		sum := sha256.Sum256(completed)
+		var count int
+		var size int64
+		err := pool.QueryRow(ctx, `
+			SELECT count(*), max(size) FROM document_hash WHERE submission_id = $1 AND kind = 'completed' AND sha256 = $2
+		`, completedID, hex.EncodeToString(sum[:])).Scan(&count, &size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 || size != int64(len(completed)) {
+			t.Errorf("count = %d, size = %d; want 1, %d", count, size, len(completed))
+		}
+	})
+
+	t.Run("completed document", func(t *testing.T) {
+		got := lookup(t, completed)
+		if !got.Found || got.SubmissionID != completedID || got.Kind != "completed" || got.Status != "completed" || got.CompletedAt == nil {
+			t.Fatalf("result = %+v", got)
+		}
+		if want := []string{"S*** 1***", "S*** 2***"}; !reflect.DeepEqual(got.Signers, want) {
+			t.Errorf("signers = %q, want %q", got.Signers, want)
+		}
+	})
+
+	t.Run("certificate", func(t *testing.T) {
+		if got := lookup(t, certificate); !got.Found || got.Kind != "certificate" {
+			t.Errorf("result = %+v", got)
+		}
+	})
+
+	t.Run("only completed documents of completed submissions", func(t *testing.T) {
+		for name, data := range map[string][]byte{"original": original, "pending": pending, "unknown": []byte("unknown")} {
+			if got := lookup(t, data); got.Found || got.SubmissionID != "" || got.Signers != nil {
+				t.Errorf("%s: result = %+v", name, got)
+			}
+		}
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		fw, _ := w.CreateFormFile("document", "completed.pdf")
+		fw.Write(completed)
+		w.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/verify/hash", &body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decode(t, resp); !got.Found || got.SubmissionID != completedID {
+			t.Errorf("result = %+v", got)
+		}
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/verify/hash?sha256=abc", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+		}
+	})
+}
+
+func TestMaskName(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"Jane":             "J***",
+		"Jane van Doe":     "J*** v*** D***",
+		"  Łukasz  Nowak ": "Ł*** N***",
+	}
+	for name, want := range tests {
+		if got := maskName(name); got != want {
+			t.Errorf("maskName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/pkg/logging"
+	"github.com/shurco/gosign/pkg/storage"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// pageURLExpiration is how long the page URLs of a template fetched by a client stay valid
+const pageURLExpiration = 12 * time.Hour
+
+// DriveHandler serves files from the blob storage under /drive through expiring signed
+// URLs, so every node serves them whatever node wrote them: page images and page PDFs
+// under /drive/pages/{token}, any other file under /drive/files. Files of an organization
+// with its own storage are read (and decrypted) from it.
+type DriveHandler struct {
+	storages *storage.Registry
+	locate   func(ctx context.Context, key string) (string, string, error)
+	urls     *storage.URLSigner
+}
+
+// NewDriveHandler creates a drive handler; locate returns the organization whose storage
+// holds a key ("" for the default storage) and the key the file is stored at (template
+// pages are stored as content-addressed blobs), and may be nil. urls verifies the signed
+// URLs.
+func NewDriveHandler(storages *storage.Registry, locate func(ctx context.Context, key string) (string, string, error), urls *storage.URLSigner) *DriveHandler {
+	return &DriveHandler{storages: storages, locate: locate, urls: urls}
+}
+
+// RegisterRoutes registers the /drive routes
+func (h *DriveHandler) RegisterRoutes(app fiber.Router) {
+	app.Get("/drive/pages/:token/*", h.ServePage)
+	app.Get("/drive/files/*", h.ServeSigned)
+}
+
+// PageURLs returns a function giving the URL the pages of a document (attachment) are
+// served under, base/{token}, for clients to append {attachment_id}/{file} to
+func PageURLs(urls *storage.URLSigner, base string) func(attachmentID string) string {
+	base = strings.TrimRight(base, "/")
+	return func(attachmentID string) string {
+		return base + "/" + urls.Token(storage.Key(storage.PrefixPages, attachmentID), pageURLExpiration)
+	}
+}
+
+// ServeSigned streams a file named by a signed download URL
+// @Summary Download a file by signed URL
+// @Description Download a file through an expiring URL returned by the API (the counterpart of S3 presigned URLs)
+// @Tags drive
+// @Produce octet-stream
+// @Param key path string true "Storage key"
+// @Param expires query int true "Expiry (Unix time)"
+// @Param disposition query string false "Content-Disposition of the response"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /drive/files/{key} [get]
+func (h *DriveHandler) ServeSigned(c fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil || key == "" {
+		return webutil.Response(c, fiber.StatusNotFound, "Not found", nil)
+	}
+
+	disposition := c.Query("disposition")
+	switch err := h.urls.Verify(key, c.Query("expires"), disposition, c.Query("signature")); {
+	case errors.Is(err, storage.ErrURLExpired):
+		return webutil.Response(c, fiber.StatusForbidden, "Link expired", nil)
+	case err != nil:
+		return webutil.Response(c, fiber.StatusForbidden, "Invalid link", nil)
+	}
+
+	if disposition != "" {
+		c.Set(fiber.HeaderContentDisposition, disposition)
+	}
+	return h.send(c, key)
+}
+
+// ServePage streams a page image or page PDF of a document through a page URL
+// @Summary Download a document page
+// @Description Download a page image (0.jpg, p/0.jpg) or page PDF (0.pdf) of a document through the expiring URL given as the url of the document in templates
+// @Tags drive
+// @Produce octet-stream
+// @Param token path string true "Page URL token"
+// @Param file path string true "{attachment_id}/{file}"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]any
+// @Failure 404 {object} map[string]any
+// @Router /drive/pages/{token}/{file} [get]
+func (h *DriveHandler) ServePage(c fiber.Ctx) error {
+	name := c.Params("*")
+	attachmentID, file, ok := strings.Cut(name, "/")
+	if !ok || attachmentID == "" || file == "" || name != path.Clean(name) || strings.HasPrefix(file, "../") || file == ".." {
+		return webutil.Response(c, fiber.StatusNotFound, "Not found", nil)
+	}
+
+	switch err := h.urls.VerifyToken(storage.Key(storage.PrefixPages, attachmentID), c.Params("token")); {
+	case errors.Is(err, storage.ErrURLExpired):
+		return webutil.Response(c, fiber.StatusForbidden, "Link expired", nil)
+	case err != nil:
+		return webutil.Response(c, fiber.StatusForbidden, "Invalid link", nil)
+	}
+	return h.send(c, storage.Key(storage.PrefixPages, name))
+}
+
+// send streams the file served under key from the storage holding it
+func (h *DriveHandler) send(c fiber.Ctx, key string) error {
+	store, storedKey, err := h.storageFor(c.Context(), key)
+	if err != nil {
+		logging.Log.Err(err).Str("key", key).Msg("failed to resolve storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to read file", nil)
+	}
+
+	r, err := store.Download(c.Context(), storedKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return webutil.Response(c, fiber.StatusNotFound, "Not found", nil)
+	}
+	if err != nil {
+		logging.Log.Err(err).Str("key", key).Msg("failed to read file from storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to read file", nil)
+	}
+
+	c.Set(fiber.HeaderContentType, contentTypeOf(key))
+	return c.SendStream(r)
+}
+
+// contentTypeOf returns the content type of a file by its extension
+func contentTypeOf(name string) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return fiber.MIMEOctetStream
+}
+
+// storageFor returns the storage holding a file served under key and the key it is stored at
+func (h *DriveHandler) storageFor(ctx context.Context, key string) (storage.BlobStorage, string, error) {
+	if h.locate == nil {
+		return h.storages.Default(), key, nil
+	}
+	organizationID, storedKey, err := h.locate(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := h.storages.ForOrganization(ctx, organizationID)
+	return store, storedKey, err
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/pkg/storage"
+)
+
+func TestDriveHandler_ServeSigned(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.URLs = storage.NewURLSigner([]byte("secret"), "/drive/files")
+	key := storage.Key(storage.PrefixSigned, "contract.pdf")
+	if err := storage.Put(ctx, local, key, []byte("%PDF-1.7"), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	NewDriveHandler(storage.NewRegistry(local, nil), nil, local.URLs).RegisterRoutes(app)
+
+	signed, err := storage.DownloadURL(ctx, local, key, time.Minute, `attachment; filename="contract.pdf"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, signed, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "%PDF-1.7" {
+		t.Fatalf("GET signed URL = %d %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get(fiber.HeaderContentDisposition); got != `attachment; filename="contract.pdf"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderContentType); got != "application/pdf" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	u, _ := url.Parse(signed)
+	q := u.Query()
+	q.Set("disposition", "inline")
+	tampered := u.Path + "?" + q.Encode()
+	for name, target := range map[string]string{
+		"unsigned":          "/drive/files/" + key,
+		"other disposition": tampered,
+		"other key":         "/drive/files/lc_signed/other.pdf?" + u.RawQuery,
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want %d", name, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+}
+
+func TestDriveHandler_ServePage(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{storage.Key(storage.PrefixPages, "att-1", "p", "0.jpg"), storage.Key(storage.PrefixSigned, "contract.pdf")} {
+		if err := storage.Put(ctx, local, key, []byte("data"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	urls := storage.NewURLSigner([]byte("secret"), "/drive/files")
+	app := fiber.New()
+	NewDriveHandler(storage.NewRegistry(local, nil), nil, urls).RegisterRoutes(app)
+	base := PageURLs(urls, "/drive/pages/")("att-1")
+
+	for target, want := range map[string]int{
+		base + "/att-1/p/0.jpg":                      http.StatusOK,
+		base + "/att-2/p/0.jpg":                      http.StatusForbidden,
+		"/drive/pages/0.x/att-1/p/0.jpg":             http.StatusForbidden,
+		base + "/att-1/../att-2/p/0.jpg":             http.StatusNotFound,
+		"/drive/pages/att-1/p/0.jpg":                 http.StatusForbidden,
+		"/drive/signed/contract.pdf":                 http.StatusNotFound,
+		"/drive/uploads/contract.pdf":                http.StatusNotFound,
+		"/drive/files/" + storage.PrefixSigned + "/": http.StatusForbidden,
+	} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", target, resp.StatusCode, want)
+		}
+	}
+}
@@ -3,26 +3,92 @@ package handlers
 import (
 	"crypto"
 	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"io"
+	"mime"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
-	"github.com/shurco/gosign/pkg/appdir"
+	"github.com/shurco/gosign/internal/middleware"
 	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/logging"
 	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/storage"
 	"github.com/shurco/gosign/pkg/utils"
 	"github.com/shurco/gosign/pkg/utils/webutil"
 )
 
-// SignPDF is ...
-func SignPDF(c fiber.Ctx) error {
+// SignHandler signs uploaded PDFs with a key from the certificate store
+type SignHandler struct {
+	keys        *services.CertificateService
+	userQueries *queries.UserQueries
+	timestamps  sign.TimestampFunction
+	storages    *storage.Registry
+	urls        *storage.URLSigner
+}
+
+// signedFileURLExpiration is how long the download URLs of uploaded and signed files stay valid
+const signedFileURLExpiration = time.Hour
+
+// NewSignHandler creates new sign handler.
+// timestamps is the built-in TSA; when nil signatures aren't timestamped.
+// Uploaded and signed PDFs are kept in the storage of the organization of the caller
+// and downloaded through URLs signed with urls.
+func NewSignHandler(keys *services.CertificateService, userQueries *queries.UserQueries, timestamps sign.TimestampFunction, storages *storage.Registry, urls *storage.URLSigner) *SignHandler {
+	return &SignHandler{keys: keys, userQueries: userQueries, timestamps: timestamps, storages: storages, urls: urls}
+}
+
+// scope returns the certificate scope of the caller, empty for anonymous callers
+func (h *SignHandler) scope(c fiber.Ctx) services.CertificateScope {
+	orgID, accountID := callerScope(c, h.userQueries)
+	return services.CertificateScope{OrganizationID: orgID, AccountID: accountID}
+}
+
+// callerScope returns the organization of the caller or, without one, the
+// account; both are empty for anonymous requests
+func callerScope(c fiber.Ctx, userQueries *queries.UserQueries) (organizationID, accountID string) {
+	if orgID, ok := c.Locals("organization_id").(string); ok && orgID != "" {
+		return orgID, ""
+	}
+	auth := middleware.GetAuthContext(c)
+	if auth == nil {
+		return "", ""
+	}
+	if auth.AccountID != "" {
+		return "", auth.AccountID
+	}
+	if userQueries != nil {
+		if accountID, err := userQueries.GetUserAccountID(c.Context(), auth.UserID); err == nil {
+			return "", accountID
+		}
+	}
+	return "", ""
+}
+
+// SignPDF signs an uploaded PDF with the default certificate of the caller
+// @Summary Sign PDF
+// @Description Sign a PDF with the organization (or account) default certificate. The platform seal key is never used: callers without a default certificate get 403. The uploaded and signed files are downloaded through the returned url and url_signed, valid for an hour
+// @Tags sign
+// @Accept multipart/form-data
+// @Produce json
+// @Param document formData file true "PDF document"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 401 {object} map[string]any
+// @Failure 403 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sign [post]
+// @Router /api/v1/sign [post]
+func (h *SignHandler) SignPDF(c fiber.Ctx) error {
 	response := &models.Sign{}
 
 	fileHeader, err := c.FormFile("document")
@@ -30,121 +96,104 @@ func SignPDF(c fiber.Ctx) error {
 		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
 	}
 
-	if fileHeader.Header["Content-Type"][0] != "application/pdf" {
+	contentType := strings.ToLower(strings.TrimSpace(fileHeader.Header.Get("Content-Type")))
+	if !strings.HasPrefix(contentType, "application/pdf") {
 		response.Error = "File format not supported"
-		return webutil.Response(c, fiber.StatusOK, "Verify", response)
+		return webutil.Response(c, fiber.StatusOK, "Sign", response)
 	}
 
-	file, err := fileHeader.Open()
+	// Files of an organization go to its storage, in a directory named after it so that
+	// /drive finds them there
+	scope := h.scope(c)
+	if scope.OrganizationID == "" && scope.AccountID == "" {
+		return webutil.Response(c, fiber.StatusUnauthorized, "Authentication required", nil)
+	}
+	store, err := h.storages.ForOrganization(c.Context(), scope.OrganizationID)
 	if err != nil {
-		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+		logging.Log.Err(err).Msg("failed to resolve storage")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
+	}
+	dir := ""
+	if scope.OrganizationID != "" {
+		dir = scope.OrganizationID + "/"
 	}
-	defer file.Close()
 
-	fileNameUUID := uuid.New().String()
-	fileNameSignedUUID := uuid.New().String()
 	fileExt := utils.ExtName(fileHeader.Filename)
-	response.FileName = fmt.Sprintf("%s.%s", fileNameUUID, fileExt)
-	response.FileNameSigned = fmt.Sprintf("%s.%s", fileNameSignedUUID, fileExt)
-	filePath := filepath.Join(appdir.LcUploads(), response.FileName)
+	response.FileName = fmt.Sprintf("%s%s.%s", dir, uuid.New().String(), fileExt)
+	response.FileNameSigned = fmt.Sprintf("%s%s.%s", dir, uuid.New().String(), fileExt)
 
-	if err := c.SaveFile(fileHeader, filePath); err != nil {
-		return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
+	file, err := fileHeader.Open()
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
 	}
-
-	// sign process
-	certificate := "../cert/ca/user-cert.pem"
-	privateKey := "../cert/ca/user-key.pem"
-
-	chainCACrt := "../cert/ca/ca-cert.pem"
-	chainCrt := "../cert/ca/ca-intermediate-cert.pem"
-
-	certificateData, err := os.ReadFile(certificate)
+	document, err := io.ReadAll(file)
+	file.Close()
 	if err != nil {
-		log.Fatal(err)
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
 	}
-	certificateDataBlock, _ := pem.Decode(certificateData)
-	if certificateDataBlock == nil {
-		log.Fatal(errors.New("failed to parse PEM block containing the certificate"))
+	if err := storage.Put(c.Context(), store, storage.Key(storage.PrefixUploads, response.FileName), document, "application/pdf"); err != nil {
+		logging.Log.Err(err).Msg("failed to store uploaded PDF")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
 	}
 
-	cert, err := x509.ParseCertificate(certificateDataBlock.Bytes)
+	signData, err := h.signData(c)
 	if err != nil {
-		log.Fatal(err)
+		return signKeyError(c, err)
+	}
+	signData.Signature = sign.SignDataSignature{
+		Info: sign.SignDataSignatureInfo{
+			Name:   signData.Certificate.Subject.CommonName,
+			Reason: "Signed by " + signData.Certificate.Subject.CommonName,
+			Date:   time.Now().Local(),
+		},
+		CertType:   sign.CertificationSignature,
+		DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
 	}
 
-	keyData, err := os.ReadFile(privateKey)
+	signed, err := sign.SignBytes(document, signData)
 	if err != nil {
-		log.Fatal(err)
+		logging.Log.Err(err).Msg("failed to sign PDF")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to sign document", nil)
 	}
-	keyDataBlock, _ := pem.Decode(keyData)
-	if keyDataBlock == nil {
-		log.Fatal(errors.New("failed to parse PEM block containing the private key"))
+	if err := storage.Put(c.Context(), store, storage.Key(storage.PrefixSigned, response.FileNameSigned), signed, "application/pdf"); err != nil {
+		logging.Log.Err(err).Msg("failed to store signed PDF")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
 	}
 
-	pkey, err := x509.ParseECPrivateKey(keyDataBlock.Bytes)
+	response.URL = h.urls.Sign(storage.Key(storage.PrefixUploads, response.FileName), signedFileURLExpiration, "")
+	response.URLSigned = h.urls.Sign(storage.Key(storage.PrefixSigned, response.FileNameSigned), signedFileURLExpiration,
+		mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(response.FileNameSigned)}))
+	return webutil.Response(c, fiber.StatusOK, "Sign", response)
+}
+
+// signData returns the signature options for the default certificate of the
+// caller, timestamped by the built-in TSA when it is configured
+func (h *SignHandler) signData(c fiber.Ctx) (sign.SignData, error) {
+	key, err := h.keys.Default(c.Context(), h.scope(c))
 	if err != nil {
-		log.Fatal(err)
+		return sign.SignData{}, err
 	}
 
-	certificateChains := make([][]*x509.Certificate, 0)
-
-	if chainCACrt != "" && chainCrt != "" {
-		chainCAData, err := os.ReadFile(chainCACrt)
-		if err != nil {
-			log.Fatal(err)
-		}
-		certificateCAPool := x509.NewCertPool()
-		if ok := certificateCAPool.AppendCertsFromPEM(chainCAData); !ok {
-			log.Fatalf("failed to parse root certificate")
-		}
-
-		chainData, err := os.ReadFile(chainCrt)
-		if err != nil {
-			log.Fatal(err)
-		}
-		certificatePool := x509.NewCertPool()
-		if ok := certificatePool.AppendCertsFromPEM(chainData); !ok {
-			log.Fatalf("failed to parse root certificate")
-		}
-
-		certificateChains, err = cert.Verify(x509.VerifyOptions{
-			Roots:         certificateCAPool,
-			Intermediates: certificatePool,
-			CurrentTime:   cert.NotBefore,
-			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-		})
-		if err != nil {
-			log.Fatal(err)
-		}
+	var certificateChains [][]*x509.Certificate
+	if len(key.Chain) > 0 {
+		certificateChains = append(certificateChains, append([]*x509.Certificate{key.Certificate}, key.Chain...))
 	}
 
-	err = sign.SignFile(
-		filepath.Join(appdir.LcUploads(), response.FileName),
-		filepath.Join(appdir.LcSigned(), response.FileNameSigned),
-		sign.SignData{
-			Signature: sign.SignDataSignature{
-				Info: sign.SignDataSignatureInfo{
-					Name:   "User Name",
-					Reason: "Signed by ...",
-					Date:   time.Now().Local(),
-				},
-				CertType:   sign.CertificationSignature,
-				DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
-			},
-			Signer:            pkey,
-			DigestAlgorithm:   crypto.SHA256,
-			Certificate:       cert,
-			CertificateChains: certificateChains,
-			TSA: sign.TSA{
-				URL: "http://timestamp.digicert.com",
-			},
-		})
-	if err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Signed PDF written to " + response.FileNameSigned)
-	}
+	return sign.SignData{
+		Signer:            key.Signer,
+		RSAPSS:            key.RSAPSS,
+		DigestAlgorithm:   crypto.SHA256,
+		Certificate:       key.Certificate,
+		CertificateChains: certificateChains,
+		TSA:               sign.TSA{Local: h.timestamps},
+	}, nil
+}
 
-	return webutil.Response(c, fiber.StatusOK, "Sign", response)
+// signKeyError answers a request whose signing key couldn't be resolved
+func signKeyError(c fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrNoSigningCertificate) {
+		return webutil.Response(c, fiber.StatusForbidden, "No default signing certificate configured", nil)
+	}
+	logging.Log.Err(err).Msg("failed to resolve signing key")
+	return webutil.Response(c, fiber.StatusInternalServerError, "Signing certificate not available", nil)
 }
@@ -3,20 +3,25 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/queries"
 	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/internal/services/field"
+	"github.com/shurco/gosign/internal/services/submission"
 	"github.com/shurco/gosign/pkg/geolocation"
 	"github.com/shurco/gosign/pkg/notification"
+	"github.com/shurco/gosign/pkg/storage"
 	"github.com/shurco/gosign/pkg/utils/webutil"
 )
 
@@ -29,6 +34,9 @@ type PublicSigningHandler struct {
 	notificationSvc  *notification.Service
 	completedDoc     *services.CompletedDocumentBuilder
 	geolocationSvc   *geolocation.Service
+	webhookEvents    *services.WebhookEvents
+	submissions      *submission.Service
+	submitters       *queries.SubmissionRepository
 }
 
 func NewPublicSigningHandler(
@@ -38,6 +46,8 @@ func NewPublicSigningHandler(
 	notificationSvc *notification.Service,
 	completedDoc *services.CompletedDocumentBuilder,
 	geolocationSvc *geolocation.Service,
+	webhookEvents *services.WebhookEvents,
+	submissions *submission.Service,
 ) *PublicSigningHandler {
 	return &PublicSigningHandler{
 		pool:            pool,
@@ -46,9 +56,41 @@ func NewPublicSigningHandler(
 		notificationSvc:  notificationSvc,
 		completedDoc:     completedDoc,
 		geolocationSvc:   geolocationSvc,
+		webhookEvents:    webhookEvents,
+		submissions:      submissions,
+		submitters:       queries.NewSubmissionRepository(pool),
 	}
 }
 
+// signingTurn reports whether the submitter of slug may open and complete the document
+// now: in sequential mode later signing groups wait for the earlier ones (see
+// models.SigningTurn). Submitters who completed or declined may still view it.
+func (h *PublicSigningHandler) signingTurn(ctx context.Context, slug string) (bool, error) {
+	if h.submissions == nil {
+		return true, nil
+	}
+	submitter, err := h.submitters.GetSubmitterBySlug(ctx, slug)
+	if err != nil {
+		return false, err
+	}
+	if submitter.Status == models.SubmitterStatusCompleted || submitter.Status == models.SubmitterStatusDeclined {
+		return true, nil
+	}
+	return h.submissions.SigningTurn(ctx, submitter.ID)
+}
+
+// signingTurnResponse answers a request signingTurn didn't allow
+func signingTurnResponse(c fiber.Ctx, slug string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("slug", slug).Msg("Failed to check signing order")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to check signing order", nil)
+	}
+	return webutil.Response(c, fiber.StatusConflict, "Waiting for earlier signers", nil)
+}
+
 type getBySlugResponse struct {
 	Template            *models.Template  `json:"template"`
 	Submitter           *models.Submitter `json:"submitter"`
@@ -227,23 +269,40 @@ func (h *PublicSigningHandler) Open(c fiber.Ctx) error {
 		return webutil.Response(c, fiber.StatusNotFound, "Not found", nil)
 	}
 
+	if ok, err := h.signingTurn(c.Context(), slug); !ok {
+		return signingTurnResponse(c, slug, err)
+	}
+
 	// Also record an event for the submission dashboard (best-effort).
 	clientIP := getClientIP(c)
-	_, _ = h.pool.Exec(c.Context(), `
-		WITH upd AS (
+	var submissionID, submitterID string
+	var firstOpen bool
+	err := h.pool.QueryRow(c.Context(), `
+		WITH prev AS (
+			SELECT id, opened_at
+			FROM submitter
+			WHERE slug = $1
+		), upd AS (
 			UPDATE submitter
-			SET opened_at = COALESCE(opened_at, NOW()),
-			    status = CASE WHEN status = 'pending' THEN 'opened' ELSE status END,
+			SET opened_at = COALESCE(submitter.opened_at, NOW()),
+			    status = CASE WHEN submitter.status = 'pending' THEN 'opened' ELSE submitter.status END,
 			    updated_at = NOW(),
-			    ip = COALESCE(ip, $2::inet)
-			WHERE slug = $1
-			RETURNING id, submission_id
+			    ip = COALESCE(submitter.ip, $2::inet)
+			FROM prev
+			WHERE submitter.id = prev.id
+			RETURNING submitter.id, submitter.submission_id, prev.opened_at IS NULL AS first_open
+		), ev AS (
+			INSERT INTO event (id, type, resource_type, resource_id, metadata_json, ip, created_at)
+			SELECT gen_random_uuid(), 'submitter.opened', 'submission', submission_id,
+			       jsonb_build_object('submitter_id', id), $2::inet, NOW()
+			FROM upd
 		)
-		INSERT INTO event (id, type, resource_type, resource_id, metadata_json, ip, created_at)
-		SELECT gen_random_uuid(), 'submitter.opened', 'submission', submission_id,
-		       jsonb_build_object('submitter_id', id), $2::inet, NOW()
+		SELECT submission_id::text, id::text, first_open
 		FROM upd
-	`, slug, clientIP)
+	`, slug, clientIP).Scan(&submissionID, &submitterID, &firstOpen)
+	if err == nil && firstOpen {
+		h.webhookEvents.Submission(c.Context(), models.EventSubmitterOpened, submissionID, submitterID)
+	}
 
 	return webutil.Response(c, fiber.StatusOK, "opened", map[string]any{"slug": slug})
 }
@@ -337,6 +396,8 @@ func (h *PublicSigningHandler) UpdateSubmitter(c fiber.Ctx) error {
 		}
 		if err := h.notificationSvc.Send(n); err != nil {
 			log.Warn().Err(err).Str("email", req.Email).Msg("Failed to send confirmation email")
+		} else {
+			h.webhookEvents.Submission(ctx, models.EventSubmitterSent, submissionID, submitterID)
 		}
 	}
 
@@ -345,7 +406,7 @@ func (h *PublicSigningHandler) UpdateSubmitter(c fiber.Ctx) error {
 
 // Complete stores field values and marks submitter completed.
 // @Summary Complete signing
-// @Description Stores the submitter fields, marks the submitter as completed, and triggers finalization of the completed document (best-effort).
+// @Description Enforces the template field rules (required fields, validation, conditions, formulas), stores the resulting fields, marks the submitter as completed, and triggers finalization of the completed document (best-effort). Rejected fields are listed in data.errors. In incremental signature mode the submitter's values are stamped on the document and signed with the completion; when signing fails nothing is completed (500).
 // @Tags public-signing
 // @Accept json
 // @Produce json
@@ -353,6 +414,7 @@ func (h *PublicSigningHandler) UpdateSubmitter(c fiber.Ctx) error {
 // @Param body body completeRequest true "Fields payload"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} map[string]any
+// @Failure 500 {object} map[string]any
 // @Router /public/sign/{slug}/complete [post]
 func (h *PublicSigningHandler) Complete(c fiber.Ctx) error {
 	slug := c.Params("slug")
@@ -364,6 +426,28 @@ func (h *PublicSigningHandler) Complete(c fiber.Ctx) error {
 	if err := parseAndValidate(c, &req); err != nil {
 		return err
 	}
+	if ok, err := h.signingTurn(c.Context(), slug); !ok {
+		return signingTurnResponse(c, slug, err)
+	}
+
+	// Enforce the template field rules; the signing UI is not trusted with them.
+	fields, templateSubmitterID, err := h.templateFields(c.Context(), slug)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+	}
+	stored, err := h.otherSubmittersFields(c.Context(), slug)
+	if err != nil {
+		log.Error().Err(err).Str("slug", slug).Msg("Failed to load fields of other submitters")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to complete signing", nil)
+	}
+	values, err := field.Evaluate(fields, templateSubmitterID, req.Fields, stored)
+	if err != nil {
+		var fieldErrs field.Errors
+		if errors.As(err, &fieldErrs) {
+			return webutil.Response(c, fiber.StatusBadRequest, "Invalid fields", map[string]any{"errors": fieldErrs})
+		}
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
 
 	// Mark as completed and return ids (single statement).
 	clientIP := getClientIP(c)
@@ -393,7 +477,7 @@ func (h *PublicSigningHandler) Complete(c fiber.Ctx) error {
 	
 	// Build metadata with fields and location
 	metadataUpdates := map[string]any{
-		"fields": req.Fields,
+		"fields": values,
 	}
 	if locationData != nil {
 		metadataUpdates["location"] = locationData
@@ -403,9 +487,64 @@ func (h *PublicSigningHandler) Complete(c fiber.Ctx) error {
 		return webutil.Response(c, fiber.StatusBadRequest, "Invalid fields payload", nil)
 	}
 	
-	var submissionID string
-	var submitterID string
-	err = h.pool.QueryRow(c.Context(), `
+	// Incremental signature mode: the submitter's own signature is appended as part of
+	// the completion, which keeps signatures in signing order; a completion whose
+	// document can't be signed is rolled back.
+	var submissionID, submitterID string
+	mode := models.SignatureModeSeal
+	if h.completedDoc != nil {
+		if err := h.pool.QueryRow(c.Context(), `SELECT submission_id, id FROM submitter WHERE slug = $1`, slug).Scan(&submissionID, &submitterID); err != nil {
+			return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+		}
+		if mode, err = h.completedDoc.SignatureMode(c.Context(), submissionID); err != nil {
+			log.Error().Err(err).Str("submission_id", submissionID).Msg("Failed to get signature mode")
+			return webutil.Response(c, fiber.StatusInternalServerError, "Failed to complete signing", nil)
+		}
+	}
+	if mode == models.SignatureModeIncremental {
+		err = h.completedDoc.AppendSubmitterSignature(c.Context(), submissionID, submitterID, func(ctx context.Context, tx pgx.Tx) error {
+			_, _, err := completeSubmitter(ctx, tx, slug, metadataJSON, clientIP)
+			return err
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("submission_id", submissionID).Str("submitter_id", submitterID).Msg("Failed to append submitter signature")
+			return webutil.Response(c, fiber.StatusInternalServerError, "Failed to sign the document", nil)
+		}
+	} else {
+		submissionID, submitterID, err = completeSubmitter(c.Context(), h.pool, slug, metadataJSON, clientIP)
+		if err != nil {
+			return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+		}
+	}
+	h.webhookEvents.Submission(c.Context(), models.EventSubmitterCompleted, submissionID, submitterID)
+
+	// Best-effort finalization (generate completed PDF + auto-send links).
+	// Uses a DB idempotency flag in submission.preferences, so concurrent completions won't double-send.
+	baseURL := fmt.Sprintf("%s://%s", c.Protocol(), c.Get("Host"))
+	ctxAsync, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	go func() {
+		defer cancel()
+		// Invite the next signing group once this one is complete
+		if h.submissions != nil {
+			if err := h.submissions.Advance(ctxAsync, submitterID); err != nil {
+				log.Error().Err(err).Str("submitter_id", submitterID).Msg("Failed to invite the next signing group")
+			}
+		}
+		h.finalizeIfCompleted(ctxAsync, submissionID, baseURL)
+	}()
+
+	return webutil.Response(c, fiber.StatusOK, "completed", map[string]any{"slug": slug})
+}
+
+// completeSubmitter stores the fields of a submitter and marks them completed (single
+// statement); pgx.ErrNoRows when there is no such submitter or it is completed already
+func completeSubmitter(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, slug string, metadataJSON []byte, clientIP string) (submissionID, submitterID string, err error) {
+	err = db.QueryRow(ctx, `
 		WITH upd AS (
 			UPDATE submitter
 			SET metadata = COALESCE(metadata, '{}'::jsonb) || $2::jsonb,
@@ -426,20 +565,72 @@ func (h *PublicSigningHandler) Complete(c fiber.Ctx) error {
 		FROM upd
 		LIMIT 1
 	`, slug, string(metadataJSON), clientIP).Scan(&submissionID, &submitterID)
-	if err != nil || submissionID == "" || submitterID == "" {
-		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+	if err == nil && (submissionID == "" || submitterID == "") {
+		err = pgx.ErrNoRows
 	}
+	return submissionID, submitterID, err
+}
 
-	// Best-effort finalization (generate completed PDF + auto-send links).
-	// Uses a DB idempotency flag in submission.preferences, so concurrent completions won't double-send.
-	baseURL := fmt.Sprintf("%s://%s", c.Protocol(), c.Get("Host"))
-	ctxAsync, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	go func() {
-		defer cancel()
-		h.finalizeIfCompleted(ctxAsync, submissionID, baseURL)
-	}()
+// templateFields returns the template fields of the submitter's submission and
+// the template submitter whose fields the submitter fills; an empty ID means
+// all fields (legacy single-signer flows).
+func (h *PublicSigningHandler) templateFields(ctx context.Context, slug string) ([]models.Field, string, error) {
+	var templateID, metaJSON string
+	err := h.pool.QueryRow(ctx, `
+		SELECT sub.template_id, COALESCE(s.metadata, '{}'::jsonb)::text
+		FROM submitter s
+		JOIN submission sub ON sub.id = s.submission_id
+		WHERE s.slug = $1
+		LIMIT 1
+	`, slug).Scan(&templateID, &metaJSON)
+	if err != nil {
+		return nil, "", err
+	}
 
-	return webutil.Response(c, fiber.StatusOK, "completed", map[string]any{"slug": slug})
+	tpl, err := h.templateQueries.Template(ctx, templateID)
+	if err != nil {
+		return nil, "", err
+	}
+	if tpl == nil {
+		return nil, "", fmt.Errorf("template %s not found", templateID)
+	}
+
+	var meta map[string]any
+	_ = json.Unmarshal([]byte(metaJSON), &meta)
+	templateSubmitterID, _ := meta["template_submitter_id"].(string)
+	return tpl.Fields, templateSubmitterID, nil
+}
+
+// otherSubmittersFields returns the field values stored by the other submitters
+// of the submitter's submission, which its conditions and formulas may refer to.
+func (h *PublicSigningHandler) otherSubmittersFields(ctx context.Context, slug string) (map[string]any, error) {
+	rows, err := h.pool.Query(ctx, `
+		SELECT COALESCE(other.metadata->'fields', '{}'::jsonb)::text
+		FROM submitter s
+		JOIN submitter other ON other.submission_id = s.submission_id AND other.id <> s.id
+		WHERE s.slug = $1
+		ORDER BY other.completed_at NULLS LAST, other.created_at
+	`, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]any{}
+	for rows.Next() {
+		var fieldsJSON string
+		if err := rows.Scan(&fieldsJSON); err != nil {
+			return nil, err
+		}
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(fieldsJSON), &fields); err != nil {
+			continue
+		}
+		for k, v := range fields {
+			stored[k] = v
+		}
+	}
+	return stored, rows.Err()
 }
 
 type declineRequest struct {
@@ -467,7 +658,8 @@ func (h *PublicSigningHandler) Decline(c fiber.Ctx) error {
 	_ = c.Bind().JSON(&req) // optional
 
 	clientIP := getClientIP(c)
-	tag, err := h.pool.Exec(c.Context(), `
+	var submissionID, submitterID string
+	err := h.pool.QueryRow(c.Context(), `
 		WITH upd AS (
 			UPDATE submitter
 			SET metadata = COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('decline_reason', NULLIF($2, '')),
@@ -482,13 +674,15 @@ func (h *PublicSigningHandler) Decline(c fiber.Ctx) error {
 		SELECT gen_random_uuid(), 'submitter.declined', 'submission', submission_id,
 		       jsonb_build_object('submitter_id', id, 'reason', NULLIF($2, '')), $3::inet, NOW()
 		FROM upd
-	`, slug, req.Reason, clientIP)
+		RETURNING resource_id::text, metadata_json->>'submitter_id'
+	`, slug, req.Reason, clientIP).Scan(&submissionID, &submitterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+	}
 	if err != nil {
 		return webutil.Response(c, fiber.StatusInternalServerError, fmt.Sprintf("Failed to decline: %v", err), nil)
 	}
-	if tag.RowsAffected() == 0 {
-		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
-	}
+	h.webhookEvents.Submission(c.Context(), models.EventSubmitterDeclined, submissionID, submitterID)
 
 	return webutil.Response(c, fiber.StatusOK, "declined", map[string]any{"slug": slug})
 }
@@ -533,12 +727,12 @@ func (h *PublicSigningHandler) GetCompletedDocument(c fiber.Ctx) error {
 		  AND COALESCE(preferences->>'public_base_url', '') = ''
 	`, submissionID, baseURL)
 
-	path, err := h.completedDoc.EnsureCompletedPDF(c.Context(), submissionID)
+	doc, err := h.completedDoc.EnsureCompletedPDF(c.Context(), submissionID)
 	if err != nil {
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to build completed document", map[string]any{"error": err.Error()})
 	}
 
-	return c.Download(path, fmt.Sprintf("submission_%s.pdf", submissionID))
+	return webutil.Attachment(c, doc, fmt.Sprintf("submission_%s.pdf", submissionID), "application/pdf")
 }
 
 // GetCertificate returns the certificate PDF only when the whole submission is completed.
@@ -581,12 +775,70 @@ func (h *PublicSigningHandler) GetCertificate(c fiber.Ctx) error {
 		  AND COALESCE(preferences->>'public_base_url', '') = ''
 	`, submissionID, baseURL)
 
-	path, err := h.completedDoc.EnsureCertificatePDF(c.Context(), submissionID)
+	doc, err := h.completedDoc.EnsureCertificatePDF(c.Context(), submissionID)
 	if err != nil {
 		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to build certificate", map[string]any{"error": err.Error()})
 	}
 
-	return c.Download(path, fmt.Sprintf("submission_%s_certificate.pdf", submissionID))
+	return webutil.Attachment(c, doc, fmt.Sprintf("submission_%s_certificate.pdf", submissionID), "application/pdf")
+}
+
+// GetSignedDocument returns the document carrying one signature per submitter.
+// Only available for completed submissions in incremental signature mode.
+// @Summary Download per-submitter signed document
+// @Description Returns the PDF with an incremental signature of every submitter, in signing order.
+// @Tags public-signing
+// @Produce application/pdf
+// @Param slug path string true "Submitter slug"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]any
+// @Failure 409 {object} map[string]any
+// @Router /public/sign/{slug}/signed-document [get]
+func (h *PublicSigningHandler) GetSignedDocument(c fiber.Ctx) error {
+	slug := c.Params("slug")
+	if slug == "" {
+		return webutil.Response(c, fiber.StatusNotFound, "Not found", nil)
+	}
+	if h.completedDoc == nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Document builder not configured", nil)
+	}
+
+	var submissionID string
+	err := h.pool.QueryRow(c.Context(), `
+		SELECT submission_id
+		FROM submitter
+		WHERE slug = $1
+		LIMIT 1
+	`, slug).Scan(&submissionID)
+	if err != nil || submissionID == "" {
+		return webutil.Response(c, fiber.StatusNotFound, "Submitter not found", nil)
+	}
+
+	mode, err := h.completedDoc.SignatureMode(c.Context(), submissionID)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to load submission", nil)
+	}
+	if mode != models.SignatureModeIncremental {
+		return webutil.Response(c, fiber.StatusNotFound, "Submission has no per-submitter signatures", nil)
+	}
+
+	ok, err := h.completedDoc.IsSubmissionFullyCompleted(c.Context(), submissionID)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to check completion", nil)
+	}
+	if !ok {
+		return webutil.Response(c, fiber.StatusConflict, "Submission not completed yet", nil)
+	}
+
+	doc, err := h.completedDoc.SignedPDF(c.Context(), submissionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return webutil.Response(c, fiber.StatusNotFound, "Signed document not found", nil)
+	}
+	if err != nil {
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to load signed document", nil)
+	}
+
+	return webutil.Attachment(c, doc, fmt.Sprintf("submission_%s_signed.pdf", submissionID), "application/pdf")
 }
 
 func (h *PublicSigningHandler) RegisterRoutes(router fiber.Router) {
@@ -596,6 +848,7 @@ func (h *PublicSigningHandler) RegisterRoutes(router fiber.Router) {
 	router.Post("/sign/:slug/complete", h.Complete)
 	router.Get("/sign/:slug/document", h.GetCompletedDocument)
 	router.Get("/sign/:slug/certificate", h.GetCertificate)
+	router.Get("/sign/:slug/signed-document", h.GetSignedDocument)
 	router.Post("/sign/:slug/decline", h.Decline)
 }
 
@@ -634,6 +887,7 @@ func (h *PublicSigningHandler) finalizeIfCompleted(ctx context.Context, submissi
 
 	// Ensure the completed PDF exists (cached).
 	_, _ = h.completedDoc.EnsureCompletedPDF(ctx, submissionID)
+	h.webhookEvents.Submission(ctx, models.EventSubmissionCompleted, submissionID, "")
 
 	// Send notifications (best-effort) to all submitters with provided contact info.
 	rows, err := h.pool.Query(ctx, `
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services/submission"
+	"github.com/shurco/gosign/internal/testutil"
+)
+
+const signingTestTemplateID = "00c95859-98ef-42cd-a801-2023b75a9431"
+
+// newSigningSubmission stores a submission with one submitter per order and returns
+// the submission ID and the submitter slugs
+func newSigningSubmission(t *testing.T, pool *pgxpool.Pool, mode models.SigningMode, orders ...int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	submissionID := uuid.NewString()
+	_, err := pool.Exec(ctx, `
+		INSERT INTO submission (id, template_id, slug, source, submitters_order, preferences)
+		VALUES ($1, $2, $3, 'direct_link', '0', jsonb_build_object('signing_mode', $4::text))
+	`, submissionID, signingTestTemplateID, uuid.NewString(), string(mode))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slugs := make([]string, len(orders))
+	for i, order := range orders {
+		slugs[i] = uuid.NewString()
+		// An unknown template submitter owns no template fields, so completing needs none
+		_, err := pool.Exec(ctx, `
+			INSERT INTO submitter (id, submission_id, name, slug, metadata)
+			VALUES ($1, $2, $3, $4, jsonb_build_object('template_submitter_id', $5::text, 'order', $6::int))
+		`, uuid.NewString(), submissionID, fmt.Sprintf("Signer %d", i+1), slugs[i], uuid.NewString(), order)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return submissionID, slugs
+}
+
+func TestPublicSigningHandler_SigningOrder(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	repo := queries.NewSubmissionRepository(pool)
+	h := NewPublicSigningHandler(pool, &queries.TemplateQueries{Pool: pool}, nil, nil, nil, nil, nil, submission.NewService(repo, nil, nil))
+
+	app := fiber.New()
+	h.RegisterRoutes(app.Group("/public"))
+
+	post := func(t *testing.T, slug, action string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/public/sign/"+slug+"/"+action, strings.NewReader(`{"fields":{}}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		submissionID, slugs := newSigningSubmission(t, pool, models.SigningModeSequential, 0, 0, 1)
+
+		submitters, err := repo.GetSubmitters(context.Background(), submissionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(submitters) != 3 || submitters[0].Order != 0 || submitters[1].Order != 0 || submitters[2].Order != 1 {
+			t.Fatalf("submitters = %+v", submitters)
+		}
+
+		if got := post(t, slugs[2], "open"); got != fiber.StatusConflict {
+			t.Errorf("open of the later group = %d, want %d", got, fiber.StatusConflict)
+		}
+		if got := post(t, slugs[2], "complete"); got != fiber.StatusConflict {
+			t.Errorf("complete of the later group = %d, want %d", got, fiber.StatusConflict)
+		}
+
+		if got := post(t, slugs[0], "open"); got != fiber.StatusOK {
+			t.Errorf("open of the active group = %d, want %d", got, fiber.StatusOK)
+		}
+		if got := post(t, slugs[0], "complete"); got != fiber.StatusOK {
+			t.Errorf("complete of the active group = %d, want %d", got, fiber.StatusOK)
+		}
+		if got := post(t, slugs[2], "open"); got != fiber.StatusConflict {
+			t.Errorf("open before the group completed = %d, want %d", got, fiber.StatusConflict)
+		}
+
+		if got := post(t, slugs[1], "complete"); got != fiber.StatusOK {
+			t.Errorf("complete of the active group = %d, want %d", got, fiber.StatusOK)
+		}
+		if got := post(t, slugs[2], "open"); got != fiber.StatusOK {
+			t.Errorf("open of the next group = %d, want %d", got, fiber.StatusOK)
+		}
+		// Completed submitters may still open their document
+		if got := post(t, slugs[0], "open"); got != fiber.StatusOK {
+			t.Errorf("open after completion = %d, want %d", got, fiber.StatusOK)
+		}
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		_, slugs := newSigningSubmission(t, pool, models.SigningModeParallel, 0, 1)
+		if got := post(t, slugs[1], "open"); got != fiber.StatusOK {
+			t.Errorf("open = %d, want %d", got, fiber.StatusOK)
+		}
+		if got := post(t, slugs[1], "complete"); got != fiber.StatusOK {
+			t.Errorf("complete = %d, want %d", got, fiber.StatusOK)
+		}
+	})
+
+	t.Run("unknown submitter", func(t *testing.T) {
+		if got := post(t, uuid.NewString(), "open"); got != fiber.StatusNotFound {
+			t.Errorf("open = %d, want %d", got, fiber.StatusNotFound)
+		}
+	})
+}
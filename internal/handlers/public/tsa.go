@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/middleware"
+	"github.com/shurco/gosign/internal/services/tsa"
+	"github.com/shurco/gosign/pkg/logging"
+)
+
+// maxTimestampRequestSize bounds RFC 3161 requests; real ones are a few hundred bytes
+const maxTimestampRequestSize = 16 << 10
+
+// TSAHandler exposes the built-in RFC 3161 timestamp authority (no authentication)
+type TSAHandler struct {
+	server *tsa.Server
+}
+
+// NewTSAHandler creates new public TSA handler
+func NewTSAHandler(server *tsa.Server) *TSAHandler {
+	return &TSAHandler{server: server}
+}
+
+// RegisterRoutes registers public TSA routes, rate limited per client IP
+func (h *TSAHandler) RegisterRoutes(app fiber.Router) {
+	app.Post(tsa.Path, middleware.APIRateLimiter(), h.Timestamp)
+}
+
+// Timestamp answers an RFC 3161 timestamp request
+// @Summary Built-in timestamp authority
+// @Description Answers RFC 3161 timestamp requests, signed with the configured timestamping certificate
+// @Tags tsa
+// @Accept application/timestamp-query
+// @Produce application/timestamp-reply
+// @Success 200 {file} binary
+// @Failure 413 {string} string
+// @Router /tsa [post]
+func (h *TSAHandler) Timestamp(c fiber.Ctx) error {
+	body := c.Body()
+	if len(body) > maxTimestampRequestSize {
+		return c.SendStatus(fiber.StatusRequestEntityTooLarge)
+	}
+
+	resp, err := h.server.Respond(c.Context(), body)
+	if err != nil {
+		logging.Log.Err(err).Msg("failed to answer timestamp request")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/timestamp-reply")
+	return c.Send(resp)
+}
@@ -1,18 +1,14 @@
 package handlers
 
 import (
-	"context"
-	"encoding/hex"
-	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/jackc/pgx/v5"
 
 	"github.com/shurco/gosign/internal/models"
-	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/trust"
 	"github.com/shurco/gosign/pkg/appdir"
 	"github.com/shurco/gosign/pkg/pdf/verify"
 	"github.com/shurco/gosign/pkg/utils/webutil"
@@ -93,18 +89,21 @@ func VerifyPDF(c fiber.Ctx) error {
 		// check in trusted base
 		if !value.TrustedIssuer {
 			for _, cert := range value.Certificates {
-				aki := cert.Certificate.AuthorityKeyId
-				trustCert, err := queries.DB.CheckAKI(context.Background(), strings.ToUpper(hex.EncodeToString(aki[:])))
-				if err != nil && err != pgx.ErrNoRows {
+				anchors, err := trust.Lookup(c.Context(), cert.Certificate.AuthorityKeyId, trust.Scope{})
+				if err != nil {
 					return webutil.Response(c, fiber.StatusInternalServerError, "Internal server error", nil)
 				}
-				fmt.Print(trustCert)
-				if trustCert != nil && trustCert.List != "" {
+				for _, anchor := range anchors {
+					if anchor.Certificate != nil && cert.Certificate.CheckSignatureFrom(anchor.Certificate) != nil {
+						continue
+					}
 					signer.TrustedIssuer = models.TrustedIssuer{
-						Valid: true,
-						List:  trustCert.List,
-						Name:  trustCert.Name,
+						Valid:    true,
+						List:     anchor.List,
+						Name:     anchor.Name,
+						Location: anchor.Location,
 					}
+					break
 				}
 			}
 		}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services/ca"
+	"github.com/shurco/gosign/internal/trust"
+	"github.com/shurco/gosign/pkg/logging"
+	"github.com/shurco/gosign/pkg/pdf"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+	"github.com/shurco/gosign/pkg/utils/webutil"
+)
+
+// TrustSourceInternalCA is the trust anchor source of the internal CA
+const TrustSourceInternalCA = "internal-ca"
+
+// VerifyReportHandler builds detailed validation reports of signed PDFs
+type VerifyReportHandler struct {
+	trust       TrustResolver
+	userQueries *queries.UserQueries
+	assetsDir   string
+}
+
+// TrustResolver returns the trust anchor lookup for a caller
+type TrustResolver func(ctx context.Context, scope trust.Scope) verify.TrustFunc
+
+// NewVerifyReportHandler creates new verification report handler.
+// assetsDir holds the fonts of the PDF report.
+func NewVerifyReportHandler(resolver TrustResolver, userQueries *queries.UserQueries, assetsDir string) *VerifyReportHandler {
+	return &VerifyReportHandler{trust: resolver, userQueries: userQueries, assetsDir: assetsDir}
+}
+
+// TrustAnchors resolves trust anchors from the internal CA, the platform trust
+// lists and the custom anchors of the caller's organization or account
+func TrustAnchors(authority *ca.Authority) TrustResolver {
+	return func(ctx context.Context, scope trust.Scope) verify.TrustFunc {
+		return func(keyID []byte) []*verify.TrustAnchor {
+			var anchors []*verify.TrustAnchor
+			if authority != nil {
+				if root := authority.Certificate(); bytes.Equal(root.SubjectKeyId, keyID) {
+					anchors = append(anchors, &verify.TrustAnchor{Source: TrustSourceInternalCA, Name: root.Subject.CommonName, Certificate: root})
+				}
+			}
+			found, err := trust.Lookup(ctx, keyID, scope)
+			if err != nil {
+				logging.Log.Err(err).Msg("failed to look up trust anchors")
+			}
+			for _, a := range found {
+				anchors = append(anchors, &verify.TrustAnchor{Source: a.List, Location: a.Location, Name: a.Name, Certificate: a.Certificate})
+			}
+			return anchors
+		}
+	}
+}
+
+// Report validates an uploaded PDF and returns its validation report
+// @Summary PDF validation report
+// @Description Per-signature validation report in the style of ETSI EN 319 102-1: indication and sub-indication, covered byte ranges, modifications after signing and the trust anchor (and trust list) of each chain element. Authenticated requests (/api/v1/verify/report) also trust the custom anchors of the caller's organization or account. format=pdf returns the report as a PDF document
+// @Tags verify
+// @Accept multipart/form-data
+// @Produce json,application/pdf
+// @Param document formData file true "PDF document"
+// @Param format query string false "json (default) or pdf"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]any
+// @Failure 500 {object} map[string]any
+// @Router /verify/report [post]
+// @Router /api/v1/verify/report [post]
+func (h *VerifyReportHandler) Report(c fiber.Ctx) error {
+	fileHeader, err := c.FormFile("document")
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(fileHeader.Header.Get("Content-Type")))
+	if !strings.HasPrefix(contentType, "application/pdf") {
+		return webutil.Response(c, fiber.StatusBadRequest, "File format not supported", nil)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	var anchors verify.TrustFunc
+	if h.trust != nil {
+		orgID, accountID := callerScope(c, h.userQueries)
+		anchors = h.trust(c.Context(), trust.Scope{OrganizationID: orgID, AccountID: accountID})
+	}
+
+	report, err := verify.NewReport(bytes.NewReader(data), int64(len(data)), anchors)
+	if err != nil {
+		return webutil.Response(c, fiber.StatusBadRequest, err.Error(), nil)
+	}
+
+	if c.Query("format") != "pdf" {
+		return webutil.Response(c, fiber.StatusOK, "Verification report", report)
+	}
+
+	doc, err := pdf.RenderVerificationReportPDF(report, h.assetsDir)
+	if err != nil {
+		logging.Log.Err(err).Msg("failed to render verification report")
+		return webutil.Response(c, fiber.StatusInternalServerError, "Failed to render report", nil)
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="verification-report.pdf"`)
+	return c.Send(doc)
+}
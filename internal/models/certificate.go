@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// CertificateSource describes how a signing certificate was added to the store
+type CertificateSource string
+
+const (
+	CertificateSourceUploaded  CertificateSource = "uploaded"
+	CertificateSourceGenerated CertificateSource = "generated"
+	CertificateSourceExternal  CertificateSource = "external" // key on a PKCS#11 token or remote signing service
+)
+
+// CertificateUsage describes what a stored certificate is used for
+type CertificateUsage string
+
+const (
+	CertificateUsageSigning      CertificateUsage = "signing"
+	CertificateUsageTimestamping CertificateUsage = "timestamping" // RFC 3161 TSA, never a scope default
+)
+
+// SigningCertificate is a certificate + private key pair used to sign documents.
+// Either OrganizationID or AccountID is set, depending on the owning scope.
+type SigningCertificate struct {
+	ID             string            `json:"id" db:"id"`
+	OrganizationID *string           `json:"organization_id,omitempty" db:"organization_id"`
+	AccountID      *string           `json:"account_id,omitempty" db:"account_id"`
+	Name           string            `json:"name" db:"name"`
+	Subject        string            `json:"subject" db:"subject"`
+	Issuer         string            `json:"issuer" db:"issuer"`
+	SerialNumber   string            `json:"serial_number" db:"serial_number"`
+	KeyAlgorithm   string            `json:"key_algorithm" db:"key_algorithm"`
+	NotBefore      time.Time         `json:"not_before" db:"not_before"`
+	NotAfter       time.Time         `json:"not_after" db:"not_after"`
+	CertificatePEM string            `json:"certificate" db:"certificate"`
+	ChainPEM       string            `json:"chain,omitempty" db:"chain"`
+	PrivateKey     []byte            `json:"-" db:"private_key"`             // encrypted PKCS#8, never exported
+	KeyURI         string            `json:"key_uri,omitempty" db:"key_uri"` // external key, e.g. pkcs11:token=gosign;object=seal
+	Source         CertificateSource `json:"source" db:"source"`
+	Usage          CertificateUsage  `json:"usage" db:"usage"`
+	IsDefault      bool              `json:"is_default" db:"is_default"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// DocumentKind is the kind of a document produced for a submission
+type DocumentKind string
+
+// Documents whose SHA-256 is recorded
+const (
+	DocumentKindOriginal    DocumentKind = "original"    // template rendered before any signer filled it
+	DocumentKindCompleted   DocumentKind = "completed"   // sealed PDF with the signature certificate
+	DocumentKindCertificate DocumentKind = "certificate" // signature certificate alone
+)
+
+// DocumentHash is the SHA-256 of a document produced for a submission
+type DocumentHash struct {
+	ID           string       `json:"id" db:"id"`
+	SubmissionID string       `json:"submission_id" db:"submission_id"`
+	Kind         DocumentKind `json:"kind" db:"kind"`
+	SHA256       string       `json:"sha256" db:"sha256"` // lowercase hex
+	Size         int64        `json:"size" db:"size"`
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+}
+
+// DocumentMatch tells a verifier that a document is the completed document, or the
+// signature certificate, of a completed submission. It never carries field values.
+type DocumentMatch struct {
+	SubmissionID string       `json:"submission_id"`
+	Kind         DocumentKind `json:"kind"`
+	Status       string       `json:"status"` // completed, or archived once the submission was archived
+	CompletedAt  time.Time    `json:"completed_at"`
+	Signers      []string     `json:"signers"` // in the order they completed
+}
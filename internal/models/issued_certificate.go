@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IssuedCertificate is a certificate issued by the instance's internal CA.
+// Ownership follows the signing certificate scope: organization, or account outside one.
+type IssuedCertificate struct {
+	ID               string     `json:"id" db:"id"`
+	SerialNumber     string     `json:"serial_number" db:"serial_number"` // lowercase hex
+	Subject          string     `json:"subject" db:"subject"`
+	OrganizationID   *string    `json:"organization_id,omitempty" db:"organization_id"`
+	AccountID        *string    `json:"account_id,omitempty" db:"account_id"`
+	SubmitterID      *string    `json:"submitter_id,omitempty" db:"submitter_id"`
+	CertificatePEM   string     `json:"certificate" db:"certificate"`
+	NotBefore        time.Time  `json:"not_before" db:"not_before"`
+	NotAfter         time.Time  `json:"not_after" db:"not_after"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevocationReason int        `json:"revocation_reason,omitempty" db:"revocation_reason"` // RFC 5280 CRLReason
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Revoked reports whether the certificate has been revoked
+func (c *IssuedCertificate) Revoked() bool {
+	return c.RevokedAt != nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// OrganizationStorage is the blob storage holding the documents of an organization,
+// the per-organization counterpart of StorageSettings. Organizations without one
+// use the global storage.
+type OrganizationStorage struct {
+	OrganizationID  string    `json:"organization_id"`
+	Provider        string    `json:"provider"` // s3
+	Bucket          string    `json:"bucket"`
+	Region          string    `json:"region,omitempty"`
+	Endpoint        string    `json:"endpoint,omitempty"` // S3-compatible services, e.g. http://minio:9000
+	AccessKeyID     string    `json:"access_key_id,omitempty"`
+	SecretAccessKey []byte    `json:"-"` // encrypted
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Location identifies the bucket the storage writes to: documents written with one
+// location can't be read through another.
+func (s *OrganizationStorage) Location() string {
+	return s.Provider + ":" + s.Endpoint + "/" + s.Bucket
+}
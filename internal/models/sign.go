@@ -5,4 +5,6 @@ type Sign struct {
 	Error          string `json:"error,omitempty"`
 	FileName       string `json:"file_name,omitempty"`
 	FileNameSigned string `json:"file_name_signed,omitempty"`
+	URL            string `json:"url,omitempty"`        // expiring download URL of the uploaded file
+	URLSigned      string `json:"url_signed,omitempty"` // expiring download URL of the signed file
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StorageBlob is a file of the blob storage shared by the attachments with the same
+// content. Content-addressed blobs are stored once per storage under Key, derived from
+// their SHA-256 Checksum; blobs created before have neither.
+type StorageBlob struct {
+	ID             string         `json:"id"`
+	Filename       string         `json:"filename"`
+	ContentType    string         `json:"content_type"`
+	Metadata       map[string]any `json:"metadata"`
+	ByteSize       int64          `json:"byte_size"`
+	Checksum       string         `json:"checksum,omitempty"`        // SHA-256, hex
+	Key            string         `json:"key,omitempty"`             // storage key, lc_blobs/…
+	OrganizationID string         `json:"organization_id,omitempty"` // whose storage holds the file, "" for the global storage
+	Storage        string         `json:"storage,omitempty"`         // Location of the storage of the organization that holds the file
+	ReferenceCount int            `json:"reference_count"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
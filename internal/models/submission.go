@@ -10,6 +10,17 @@ const (
 	SigningModeParallel   SigningMode = "parallel"
 )
 
+// SignatureMode selects how the completed document is digitally signed
+type SignatureMode string
+
+const (
+	// SignatureModeSeal seals the completed render once with the platform key
+	SignatureModeSeal SignatureMode = "seal"
+	// SignatureModeIncremental appends one signature per submitter, as an
+	// incremental update with the submitter's issued certificate, in signing order
+	SignatureModeIncremental SignatureMode = "incremental"
+)
+
 // SubmissionStatus represents submission status
 type SubmissionStatus string
 
@@ -38,3 +49,44 @@ type Submission struct {
 	UpdatedAt   time.Time        `json:"updated_at"`
 }
 
+// ActiveSigningOrder returns the order of the signing group to sign now: the lowest order
+// that has a submitter who hasn't completed. Submitters sharing an order form a parallel
+// group, which must complete as a whole before the next order is invited. ok is false
+// once every submitter completed, and as soon as one declined: a decline ends the
+// signing, so no later group is invited.
+func ActiveSigningOrder(submitters []*Submitter) (order int, ok bool) {
+	for _, s := range submitters {
+		if s.Status == SubmitterStatusDeclined {
+			return 0, false
+		}
+	}
+	for _, s := range submitters {
+		if s.Status == SubmitterStatusCompleted {
+			continue
+		}
+		if !ok || s.Order < order {
+			order, ok = s.Order, true
+		}
+	}
+	return order, ok
+}
+
+// SigningTurn reports whether the submitter submitterID of submitters may open and
+// complete the document now. In parallel mode every submitter may; in sequential mode
+// only the submitters of the active signing group (see ActiveSigningOrder) may.
+func SigningTurn(mode SigningMode, submitters []*Submitter, submitterID string) bool {
+	if mode == SigningModeParallel {
+		return true
+	}
+	order, ok := ActiveSigningOrder(submitters)
+	if !ok {
+		return false
+	}
+	for _, s := range submitters {
+		if s.ID == submitterID {
+			return s.Order == order
+		}
+	}
+	return false
+}
+
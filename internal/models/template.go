@@ -80,7 +80,7 @@ type Submitter struct {
 	Slug          string           `json:"slug"` // unique signing link
 	Status        SubmitterStatus  `json:"status"`
 	SubmissionID  string           `json:"submission_id"`
-	Order         int              `json:"order"` // signing order for sequential mode
+	Order         int              `json:"order"` // signing order for sequential mode; a shared order signs in parallel
 	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
 	DeclinedAt    *time.Time       `json:"declined_at,omitempty"`
 	SentAt        *time.Time       `json:"sent_at,omitempty"`
@@ -90,6 +90,25 @@ type Submitter struct {
 	UpdatedAt     time.Time        `json:"updated_at"`
 }
 
+// SigningOrders returns the signing order of each template submitter. Submitters sharing an
+// order sign in parallel, e.g. orders [0, 0, 1, 2, 2] for two buyers, then the seller, then
+// two witnesses. Templates whose submitters all share one order define no groups: their
+// submitters sign one after another, in list order.
+func SigningOrders(submitters []Submitter) []int {
+	orders := make([]int, len(submitters))
+	grouped := false
+	for i, s := range submitters {
+		orders[i] = s.Order
+		grouped = grouped || s.Order != submitters[0].Order
+	}
+	if !grouped {
+		for i := range orders {
+			orders[i] = i
+		}
+	}
+	return orders
+}
+
 // FieldType represents field type in template
 type FieldType string
 
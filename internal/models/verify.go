@@ -30,9 +30,10 @@ type Signer struct {
 
 // TrustedIssuer is ...
 type TrustedIssuer struct {
-	Valid bool   `json:"valid"`
-	List  string `json:"list"`
-	Name  string `json:"name"`
+	Valid    bool   `json:"valid"`
+	List     string `json:"list"`
+	Name     string `json:"name"`
+	Location string `json:"location,omitempty"` // where the trust list was read from
 }
 
 // TimeStamp is ...
@@ -46,16 +47,17 @@ type CertSubject struct {
 	CommonName   string `json:"common_name"`
 }
 
-// TrustList is ...
-type TrustCerts struct {
-	Certs []TrustCert `json:"trust_cert"`
-}
-
-// TrustCert is ...
+// TrustCert is a trust anchor. Anchors without an organization or account come
+// from the platform trust lists.
 type TrustCert struct {
-	List      string     `json:"list"`
-	Name      string     `json:"name"`
-	AKI       string     `json:"aki"`
-	SKI       string     `json:"ski"`
-	CreatedAt *time.Time `json:"created,omitempty"`
+	ID             string     `json:"id,omitempty"`
+	OrganizationID string     `json:"organization_id,omitempty"`
+	AccountID      string     `json:"account_id,omitempty"`
+	List           string     `json:"list"`
+	Name           string     `json:"name"`
+	AKI            string     `json:"aki"` // SHA-1 of the certificate
+	SKI            string     `json:"ski"`
+	Location       string     `json:"location,omitempty"` // where the list was read from
+	Certificate    []byte     `json:"-"`                  // DER, empty for anchors imported before certificates were kept
+	CreatedAt      *time.Time `json:"created,omitempty"`
 }
@@ -8,7 +8,10 @@ type Webhook struct {
 	AccountID       string    `json:"account_id"`
 	URL             string    `json:"url"`
 	Events          []string  `json:"events"` // ["submission.created", "submission.completed", etc.]
+	TemplateIDs     []string  `json:"template_ids"`   // only events of these templates; all when empty
+	IncludeValues   bool      `json:"include_values"` // payloads carry the values submitters filled
 	Secret          string    `json:"secret"`
+	PreviousSecrets []WebhookSecret `json:"previous_secrets,omitempty"` // still signing during a rotation
 	Enabled         bool      `json:"enabled"`
 	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
 	FailureCount    int       `json:"failure_count"`
@@ -16,10 +19,29 @@ type Webhook struct {
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// WebhookSecret is a rotated secret of a webhook, still used to sign deliveries until it expires
+type WebhookSecret struct {
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SigningSecrets returns the secrets deliveries are signed with at now: the current one and
+// the rotated ones that haven't expired
+func (w *Webhook) SigningSecrets(now time.Time) []string {
+	secrets := []string{w.Secret}
+	for _, s := range w.PreviousSecrets {
+		if now.Before(s.ExpiresAt) {
+			secrets = append(secrets, s.Secret)
+		}
+	}
+	return secrets
+}
+
 // WebhookEvent represents event for webhook
 type WebhookEvent struct {
-	Type      string                 `json:"type"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]any `json:"data"`
+	Version   string    `json:"version"` // WebhookPayloadVersion
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"` // *WebhookSubmissionData or *WebhookTemplateData
 }
 
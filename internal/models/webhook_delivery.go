@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookDeliveryStatus is the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // waiting for its next attempt
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered" // the endpoint answered 2xx
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // no attempt left
+)
+
+// WebhookDelivery is an event queued for delivery to a webhook, with the outcome of its
+// last attempt
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhook_id"`
+	EventID        string                `json:"event_id"` // the same for redeliveries of the event
+	EventType      string                `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	ResponseStatus int                   `json:"response_status,omitempty"` // 0 when no response was received
+	ResponseBody   string                `json:"response_body,omitempty"`   // excerpt
+	LatencyMS      int64                 `json:"latency_ms,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	RedeliveryOf   string                `json:"redelivery_of,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+
+	Webhook *Webhook `json:"-"` // endpoint of a delivery claimed for an attempt
+}
@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// WebhookPayloadVersion is the version of the payloads of webhook events. It changes when a
+// field is removed or changes meaning, not when one is added.
+const WebhookPayloadVersion = "v1"
+
+// WebhookSubmissionData is the data of submission.* and submitter.* events: the submission as
+// it is after the event and, for submitter events, the submitter the event is about
+type WebhookSubmissionData struct {
+	Submission *WebhookSubmission `json:"submission"`
+	Submitter  *WebhookSubmitter  `json:"submitter,omitempty"`
+}
+
+// WithoutValues returns a copy of the data without the values of the submitters, for
+// webhooks that haven't opted in to them
+func (d *WebhookSubmissionData) WithoutValues() *WebhookSubmissionData {
+	submission := *d.Submission
+	submission.Submitters = make([]*WebhookSubmitter, len(d.Submission.Submitters))
+	out := &WebhookSubmissionData{Submission: &submission}
+	for i, s := range d.Submission.Submitters {
+		submitter := *s
+		submitter.Values = nil
+		submission.Submitters[i] = &submitter
+		if s == d.Submitter {
+			out.Submitter = &submitter
+		}
+	}
+	if d.Submitter != nil && out.Submitter == nil {
+		submitter := *d.Submitter
+		submitter.Values = nil
+		out.Submitter = &submitter
+	}
+	return out
+}
+
+// WebhookSubmission is a submission in a webhook payload
+type WebhookSubmission struct {
+	ID          string              `json:"id"`
+	Status      string              `json:"status"` // pending, in_progress, completed or declined
+	Source      string              `json:"source"`
+	SigningMode SigningMode         `json:"signing_mode"`
+	Template    *WebhookTemplate    `json:"template"`
+	Submitters  []*WebhookSubmitter `json:"submitters"`
+	Documents   []*WebhookDocument  `json:"documents"` // the completed document and certificate, once completed
+	CreatedAt   time.Time           `json:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+}
+
+// WebhookSubmitter is a submitter in a webhook payload
+type WebhookSubmitter struct {
+	ID            string          `json:"id"`
+	Role          string          `json:"role,omitempty"` // name of the template submitter
+	Name          string          `json:"name"`
+	Email         string          `json:"email"`
+	Phone         string          `json:"phone"`
+	Status        SubmitterStatus `json:"status"`
+	Order         int             `json:"order"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+	OpenedAt      *time.Time      `json:"opened_at,omitempty"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	DeclinedAt    *time.Time      `json:"declined_at,omitempty"`
+	DeclineReason string          `json:"decline_reason,omitempty"`
+	Values        map[string]any  `json:"values,omitempty"` // by field ID, to webhooks with include_values only
+}
+
+// WebhookDocument is a document of a submission in a webhook payload. URL is a download
+// link signed when the delivery is attempted, valid until ExpiresAt; deliveries are stored
+// without it.
+type WebhookDocument struct {
+	Kind      DocumentKind `json:"kind"`
+	Filename  string       `json:"filename"`
+	URL       string       `json:"url,omitempty"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+// WebhookTemplateData is the data of template.* events
+type WebhookTemplateData struct {
+	Template *WebhookTemplate `json:"template"`
+}
+
+// WebhookTemplate is a template in a webhook payload
+type WebhookTemplate struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Category       string    `json:"category,omitempty"`
+	OrganizationID string    `json:"organization_id,omitempty"`
+	Submitters     []string  `json:"submitters,omitempty"` // names of the template submitters, in order
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,212 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// CertificateRepository implements signing certificate storage operations.
+//
+// Certificates are scoped to an organization, or to an account when the caller
+// is not in an organization context. Every scoped query takes both IDs and
+// picks the organization when it is set.
+type CertificateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewCertificateRepository creates new signing certificate repository
+func NewCertificateRepository(pool *pgxpool.Pool) *CertificateRepository {
+	return &CertificateRepository{pool: pool}
+}
+
+const certificateColumns = `
+	id, organization_id, account_id, name, subject, issuer, serial_number, key_algorithm,
+	not_before, not_after, certificate, chain, private_key, key_uri, source, usage, is_default, created_at, updated_at
+`
+
+// certificateScopeFilter matches rows of the organization ($1) or, without one, of the account ($2).
+const certificateScopeFilter = `
+	((NULLIF($1::text, '') IS NOT NULL AND organization_id = NULLIF($1::text, '')::uuid)
+	 OR (NULLIF($1::text, '') IS NULL AND organization_id IS NULL AND account_id = NULLIF($2::text, '')::uuid))
+`
+
+func scanCertificate(row pgx.Row) (*models.SigningCertificate, error) {
+	var c models.SigningCertificate
+	if err := row.Scan(
+		&c.ID,
+		&c.OrganizationID,
+		&c.AccountID,
+		&c.Name,
+		&c.Subject,
+		&c.Issuer,
+		&c.SerialNumber,
+		&c.KeyAlgorithm,
+		&c.NotBefore,
+		&c.NotAfter,
+		&c.CertificatePEM,
+		&c.ChainPEM,
+		&c.PrivateKey,
+		&c.KeyURI,
+		&c.Source,
+		&c.Usage,
+		&c.IsDefault,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func nullableID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// Create inserts a certificate into the scope and populates ID and timestamps.
+// When the certificate is marked as default, any previous default of the scope is cleared.
+func (r *CertificateRepository) Create(ctx context.Context, organizationID, accountID string, c *models.SigningCertificate) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if c.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE signing_certificate SET is_default = false WHERE is_default AND`+certificateScopeFilter, organizationID, accountID); err != nil {
+			return err
+		}
+	}
+
+	c.OrganizationID = nullableID(organizationID)
+	if organizationID == "" {
+		c.AccountID = nullableID(accountID)
+	}
+
+	const query = `
+		INSERT INTO signing_certificate (
+			organization_id, account_id, name, subject, issuer, serial_number, key_algorithm,
+			not_before, not_after, certificate, chain, private_key, key_uri, source, usage, is_default
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRow(ctx, query,
+		c.OrganizationID,
+		c.AccountID,
+		c.Name,
+		c.Subject,
+		c.Issuer,
+		c.SerialNumber,
+		c.KeyAlgorithm,
+		c.NotBefore,
+		c.NotAfter,
+		c.CertificatePEM,
+		c.ChainPEM,
+		c.PrivateKey,
+		c.KeyURI,
+		c.Source,
+		c.Usage,
+		c.IsDefault,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Get returns a certificate of the scope by ID; returns pgx.ErrNoRows if not found.
+func (r *CertificateRepository) Get(ctx context.Context, organizationID, accountID, id string) (*models.SigningCertificate, error) {
+	query := `SELECT` + certificateColumns + `FROM signing_certificate WHERE id = $3 AND` + certificateScopeFilter
+	return scanCertificate(r.pool.QueryRow(ctx, query, organizationID, accountID, id))
+}
+
+// GetByID returns a certificate by ID regardless of its scope; returns pgx.ErrNoRows if not found.
+// It is meant for platform services configured with a certificate ID, never for API callers.
+func (r *CertificateRepository) GetByID(ctx context.Context, id string) (*models.SigningCertificate, error) {
+	query := `SELECT` + certificateColumns + `FROM signing_certificate WHERE id = $1`
+	return scanCertificate(r.pool.QueryRow(ctx, query, id))
+}
+
+// GetDefault returns the default certificate of the scope; returns pgx.ErrNoRows if none is set.
+func (r *CertificateRepository) GetDefault(ctx context.Context, organizationID, accountID string) (*models.SigningCertificate, error) {
+	query := `SELECT` + certificateColumns + `FROM signing_certificate WHERE is_default AND` + certificateScopeFilter
+	return scanCertificate(r.pool.QueryRow(ctx, query, organizationID, accountID))
+}
+
+// List returns all certificates of the scope, default first, then newest first.
+func (r *CertificateRepository) List(ctx context.Context, organizationID, accountID string) ([]*models.SigningCertificate, error) {
+	query := `SELECT` + certificateColumns + `FROM signing_certificate WHERE` + certificateScopeFilter + `ORDER BY is_default DESC, created_at DESC`
+	rows, err := r.pool.Query(ctx, query, organizationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certs := []*models.SigningCertificate{}
+	for rows.Next() {
+		c, err := scanCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, rows.Err()
+}
+
+// SetDefault marks the certificate as the scope default; returns pgx.ErrNoRows if not found
+// or if it is not a signing certificate.
+func (r *CertificateRepository) SetDefault(ctx context.Context, organizationID, accountID, id string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `UPDATE signing_certificate SET is_default = false WHERE is_default AND`+certificateScopeFilter, organizationID, accountID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `UPDATE signing_certificate SET is_default = true WHERE id = $3 AND usage = 'signing' AND`+certificateScopeFilter, organizationID, accountID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete removes a certificate of the scope; returns pgx.ErrNoRows if not found.
+func (r *CertificateRepository) Delete(ctx context.Context, organizationID, accountID, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM signing_certificate WHERE id = $3 AND`+certificateScopeFilter, organizationID, accountID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// SubmissionScope returns the organization (from the template) and the creator's account of a submission.
+func (r *CertificateRepository) SubmissionScope(ctx context.Context, submissionID string) (string, string, error) {
+	const query = `
+		SELECT COALESCE(t.organization_id::text, ''), COALESCE(u.account_id::text, '')
+		FROM submission s
+		JOIN template t ON t.id = s.template_id
+		LEFT JOIN "user" u ON u.id = s.created_by_user_id
+		WHERE s.id = $1
+	`
+	var organizationID, accountID string
+	if err := r.pool.QueryRow(ctx, query, submissionID).Scan(&organizationID, &accountID); err != nil {
+		return "", "", err
+	}
+	return organizationID, accountID, nil
+}
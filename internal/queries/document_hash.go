@@ -0,0 +1,71 @@
+package queries
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// DocumentHashRepository records the SHA-256 of the documents produced for submissions
+// and looks copies of them up.
+type DocumentHashRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewDocumentHashRepository creates new document hash repository
+func NewDocumentHashRepository(pool *pgxpool.Pool) *DocumentHashRepository {
+	return &DocumentHashRepository{pool: pool}
+}
+
+// Record stores the SHA-256 of a document of a submission; recording the same document twice is a no-op
+func (r *DocumentHashRepository) Record(ctx context.Context, submissionID string, kind models.DocumentKind, data []byte) error {
+	sum := sha256.Sum256(data)
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO document_hash (submission_id, kind, sha256, size)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (submission_id, kind, sha256) DO NOTHING
+	`, submissionID, string(kind), hex.EncodeToString(sum[:]), len(data))
+	return err
+}
+
+// Lookup returns the completed submission whose completed document or signature certificate
+// has the lowercase hex SHA-256, the first recorded when there are several, or nil. Originals
+// are never matched: they are what every signer received before signing. Signers are listed
+// by full name; callers decide how much of them to disclose.
+func (r *DocumentHashRepository) Lookup(ctx context.Context, sha256Hex string) (*models.DocumentMatch, error) {
+	var kind string
+	m := &models.DocumentMatch{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT
+			dh.submission_id::text,
+			dh.kind,
+			CASE WHEN s.archived_at IS NULL THEN 'completed' ELSE 'archived' END,
+			c.completed_at,
+			ARRAY(SELECT COALESCE(sr.name, '') FROM submitter sr
+			      WHERE sr.submission_id = dh.submission_id
+			      ORDER BY sr.completed_at ASC)
+		FROM document_hash dh
+		JOIN submission s ON s.id = dh.submission_id
+		CROSS JOIN LATERAL (
+			SELECT max(sr.completed_at) AS completed_at, bool_and(sr.completed_at IS NOT NULL) AS completed
+			FROM submitter sr WHERE sr.submission_id = dh.submission_id
+		) c
+		WHERE dh.sha256 = $1 AND dh.kind IN ($2, $3) AND c.completed
+		ORDER BY dh.created_at ASC
+		LIMIT 1
+	`, sha256Hex, string(models.DocumentKindCompleted), string(models.DocumentKindCertificate)).Scan(&m.SubmissionID, &kind, &m.Status, &m.CompletedAt, &m.Signers)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Kind = models.DocumentKind(kind)
+	return m, nil
+}
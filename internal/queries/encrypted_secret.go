@@ -0,0 +1,77 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// encryptedSecretColumns are the columns holding secrets encrypted with the encryption key,
+// with the key column of their table
+var encryptedSecretColumns = []struct{ table, id, column string }{
+	{"signing_certificate", "id", "private_key"},
+	{"organization_storage", "organization_id", "secret_access_key"},
+}
+
+// EncryptedSecretRepository re-encrypts the secrets stored encrypted with the encryption
+// key: private keys of signing certificates and secret keys of organization buckets.
+type EncryptedSecretRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewEncryptedSecretRepository creates new encrypted secret repository
+func NewEncryptedSecretRepository(pool *pgxpool.Pool) *EncryptedSecretRepository {
+	return &EncryptedSecretRepository{pool: pool}
+}
+
+// Reencrypt passes every stored secret to reencrypt and stores what it returns, in a single
+// transaction; a nil result keeps the secret as it is. It returns how many secrets changed.
+func (r *EncryptedSecretRepository) Reencrypt(ctx context.Context, reencrypt func(ciphertext []byte) ([]byte, error)) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	changed := 0
+	for _, c := range encryptedSecretColumns {
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+			SELECT %[2]s::text, %[3]s FROM %[1]s
+			WHERE %[3]s IS NOT NULL AND length(%[3]s) > 0
+			FOR UPDATE
+		`, c.table, c.id, c.column))
+		if err != nil {
+			return 0, err
+		}
+		secrets := map[string][]byte{}
+		for rows.Next() {
+			var id string
+			var ciphertext []byte
+			if err := rows.Scan(&id, &ciphertext); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			secrets[id] = ciphertext
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		for id, ciphertext := range secrets {
+			out, err := reencrypt(ciphertext)
+			if err != nil {
+				return 0, fmt.Errorf("%s %s: %w", c.table, id, err)
+			}
+			if out == nil {
+				continue
+			}
+			if _, err := tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET %s = $2, updated_at = NOW() WHERE %s = $1`, c.table, c.column, c.id), id, out); err != nil {
+				return 0, err
+			}
+			changed++
+		}
+	}
+	return changed, tx.Commit(ctx)
+}
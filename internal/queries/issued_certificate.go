@@ -0,0 +1,120 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// IssuedCertificateRepository implements storage of certificates issued by the internal CA.
+// Scoped queries use the same organization/account rule as CertificateRepository.
+type IssuedCertificateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewIssuedCertificateRepository creates new issued certificate repository
+func NewIssuedCertificateRepository(pool *pgxpool.Pool) *IssuedCertificateRepository {
+	return &IssuedCertificateRepository{pool: pool}
+}
+
+const issuedCertificateColumns = `
+	id, serial_number, subject, organization_id, account_id, submitter_id, certificate,
+	not_before, not_after, revoked_at, revocation_reason, created_at
+`
+
+func scanIssuedCertificate(row pgx.Row) (*models.IssuedCertificate, error) {
+	var c models.IssuedCertificate
+	if err := row.Scan(
+		&c.ID,
+		&c.SerialNumber,
+		&c.Subject,
+		&c.OrganizationID,
+		&c.AccountID,
+		&c.SubmitterID,
+		&c.CertificatePEM,
+		&c.NotBefore,
+		&c.NotAfter,
+		&c.RevokedAt,
+		&c.RevocationReason,
+		&c.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Create records an issued certificate and populates ID and CreatedAt
+func (r *IssuedCertificateRepository) Create(ctx context.Context, c *models.IssuedCertificate) error {
+	const query = `
+		INSERT INTO issued_certificate (
+			serial_number, subject, organization_id, account_id, submitter_id, certificate, not_before, not_after
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+	return r.pool.QueryRow(ctx, query,
+		c.SerialNumber,
+		c.Subject,
+		c.OrganizationID,
+		c.AccountID,
+		c.SubmitterID,
+		c.CertificatePEM,
+		c.NotBefore,
+		c.NotAfter,
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+// GetBySerial returns a certificate by serial number regardless of scope; returns pgx.ErrNoRows if not found.
+func (r *IssuedCertificateRepository) GetBySerial(ctx context.Context, serial string) (*models.IssuedCertificate, error) {
+	query := `SELECT` + issuedCertificateColumns + `FROM issued_certificate WHERE serial_number = $1`
+	return scanIssuedCertificate(r.pool.QueryRow(ctx, query, serial))
+}
+
+// List returns certificates issued to the scope, newest first
+func (r *IssuedCertificateRepository) List(ctx context.Context, organizationID, accountID string) ([]*models.IssuedCertificate, error) {
+	query := `SELECT` + issuedCertificateColumns + `FROM issued_certificate WHERE` + certificateScopeFilter + `ORDER BY created_at DESC`
+	return r.list(ctx, query, organizationID, accountID)
+}
+
+// ListRevoked returns all revoked certificates, oldest revocation first
+func (r *IssuedCertificateRepository) ListRevoked(ctx context.Context) ([]*models.IssuedCertificate, error) {
+	query := `SELECT` + issuedCertificateColumns + `FROM issued_certificate WHERE revoked_at IS NOT NULL ORDER BY revoked_at`
+	return r.list(ctx, query)
+}
+
+func (r *IssuedCertificateRepository) list(ctx context.Context, query string, args ...any) ([]*models.IssuedCertificate, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certs := []*models.IssuedCertificate{}
+	for rows.Next() {
+		c, err := scanIssuedCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, rows.Err()
+}
+
+// Revoke marks a not yet revoked certificate of the scope as revoked; returns pgx.ErrNoRows if none matched.
+func (r *IssuedCertificateRepository) Revoke(ctx context.Context, organizationID, accountID, serial string, reason int, at time.Time) error {
+	query := `
+		UPDATE issued_certificate SET revoked_at = $4, revocation_reason = $5
+		WHERE serial_number = $3 AND revoked_at IS NULL AND` + certificateScopeFilter
+	tag, err := r.pool.Exec(ctx, query, organizationID, accountID, serial, at, reason)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
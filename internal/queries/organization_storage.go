@@ -0,0 +1,147 @@
+package queries
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// OrganizationStorageRepository keeps the blob storage configuration of organizations
+// and tells which organization owns a stored document.
+type OrganizationStorageRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOrganizationStorageRepository creates new organization storage repository
+func NewOrganizationStorageRepository(pool *pgxpool.Pool) *OrganizationStorageRepository {
+	return &OrganizationStorageRepository{pool: pool}
+}
+
+// Get returns the storage of an organization, or nil when it uses the global storage
+func (r *OrganizationStorageRepository) Get(ctx context.Context, organizationID string) (*models.OrganizationStorage, error) {
+	s := &models.OrganizationStorage{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT organization_id::text, provider, bucket, region, endpoint, access_key_id, secret_access_key, created_at, updated_at
+		FROM organization_storage
+		WHERE organization_id = $1
+	`, organizationID).Scan(&s.OrganizationID, &s.Provider, &s.Bucket, &s.Region, &s.Endpoint, &s.AccessKeyID, &s.SecretAccessKey, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+const upsertOrganizationStorage = `
+	INSERT INTO organization_storage (organization_id, provider, bucket, region, endpoint, access_key_id, secret_access_key)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (organization_id) DO UPDATE SET
+		provider = EXCLUDED.provider,
+		bucket = EXCLUDED.bucket,
+		region = EXCLUDED.region,
+		endpoint = EXCLUDED.endpoint,
+		access_key_id = EXCLUDED.access_key_id,
+		secret_access_key = EXCLUDED.secret_access_key,
+		updated_at = NOW()
+`
+
+// Upsert creates or replaces the storage of an organization
+func (r *OrganizationStorageRepository) Upsert(ctx context.Context, s *models.OrganizationStorage) error {
+	_, err := r.pool.Exec(ctx, upsertOrganizationStorage, s.OrganizationID, s.Provider, s.Bucket, s.Region, s.Endpoint, s.AccessKeyID, s.SecretAccessKey)
+	return err
+}
+
+// Move replaces the storage of an organization whose documents were copied to another bucket,
+// and moves its blobs recorded at the old location to the new one, in a single transaction
+func (r *OrganizationStorageRepository) Move(ctx context.Context, s *models.OrganizationStorage, oldLocation string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, upsertOrganizationStorage, s.OrganizationID, s.Provider, s.Bucket, s.Region, s.Endpoint, s.AccessKeyID, s.SecretAccessKey); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE storage_blob SET storage = $3
+		WHERE organization_id = $1 AND storage = $2
+	`, s.OrganizationID, oldLocation, s.Location()); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Delete removes the storage of an organization, which goes back to the global storage
+func (r *OrganizationStorageRepository) Delete(ctx context.Context, organizationID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM organization_storage WHERE organization_id = $1`, organizationID)
+	return err
+}
+
+// TemplateOrganization returns the organization of the template holding a page attachment,
+// "" when the template belongs to no organization or doesn't exist
+func (r *OrganizationStorageRepository) TemplateOrganization(ctx context.Context, attachmentID string) (string, error) {
+	var organizationID string
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(organization_id::text, '')
+		FROM template
+		WHERE schema @> jsonb_build_array(jsonb_build_object('attachment_id', $1::text))
+		LIMIT 1
+	`, attachmentID).Scan(&organizationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return organizationID, err
+}
+
+// SubmissionOrganization returns the organization of the template of a submission,
+// "" when the template belongs to no organization or the submission doesn't exist
+func (r *OrganizationStorageRepository) SubmissionOrganization(ctx context.Context, submissionID string) (string, error) {
+	var organizationID string
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(t.organization_id::text, '')
+		FROM submission s
+		JOIN template t ON t.id = s.template_id
+		WHERE s.id = $1
+	`, submissionID).Scan(&organizationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return organizationID, err
+}
+
+// HasDocuments reports whether an organization has templates, whose pages and submission
+// documents are in its storage, or blobs still in use there
+func (r *OrganizationStorageRepository) HasDocuments(ctx context.Context, organizationID string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM template WHERE organization_id = $1)
+			OR EXISTS (SELECT 1 FROM storage_blob WHERE organization_id = $1 AND reference_count > 0)
+	`, organizationID).Scan(&exists)
+	return exists, err
+}
+
+// OrganizationIDs returns the organizations with a storage of their own
+func (r *OrganizationStorageRepository) OrganizationIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT organization_id::text FROM organization_storage ORDER BY organization_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
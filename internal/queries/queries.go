@@ -26,7 +26,7 @@ func New(pool *pgxpool.Pool) error {
 	DB = &Base{
 		SystemQueries:        SystemQueries{pool},
 		TrustQueries:         TrustQueries{pool},
-		TemplateQueries:      TemplateQueries{pool},
+		TemplateQueries:      TemplateQueries{Pool: pool},
 		UserQueries:          UserQueries{pool},
 		EmailTemplateQueries: EmailTemplateQueries{pool},
 	}
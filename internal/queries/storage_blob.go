@@ -0,0 +1,243 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// StorageBlobRepository keeps content-addressed blobs and the attachments referencing them.
+// Reference counts are maintained by a trigger on storage_attachment.
+type StorageBlobRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewStorageBlobRepository creates new storage blob repository
+func NewStorageBlobRepository(pool *pgxpool.Pool) *StorageBlobRepository {
+	return &StorageBlobRepository{pool: pool}
+}
+
+const storageBlobColumns = `
+	id::text, filename, COALESCE(content_type, ''), metadata, byte_size, COALESCE(checksum, ''),
+	COALESCE(key, ''), COALESCE(organization_id::text, ''), storage, reference_count, created_at`
+
+func scanStorageBlob(row pgx.Row) (*models.StorageBlob, error) {
+	b := &models.StorageBlob{}
+	err := row.Scan(&b.ID, &b.Filename, &b.ContentType, &b.Metadata, &b.ByteSize, &b.Checksum,
+		&b.Key, &b.OrganizationID, &b.Storage, &b.ReferenceCount, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// FindByChecksum returns the blob with a SHA-256 in the storage of an organization ("" for the
+// global storage) at a location (see models.OrganizationStorage.Location), or nil. A blob found
+// without references gets a new grace period, so the garbage collector doesn't remove it
+// before it is attached.
+func (r *StorageBlobRepository) FindByChecksum(ctx context.Context, organizationID, location, checksum string) (*models.StorageBlob, error) {
+	b, err := scanStorageBlob(r.pool.QueryRow(ctx, `
+		UPDATE storage_blob
+		SET unreferenced_at = CASE WHEN reference_count = 0 THEN now() END
+		WHERE COALESCE(organization_id::text, '') = $1 AND storage = $2 AND checksum = $3
+		RETURNING`+storageBlobColumns, organizationID, location, checksum))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Create inserts a content-addressed blob and returns it; when a blob with the same checksum
+// was created meanwhile in the same storage, that one is returned
+func (r *StorageBlobRepository) Create(ctx context.Context, b *models.StorageBlob) (*models.StorageBlob, error) {
+	metadata, err := json.Marshal(b.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	var organizationID *string
+	if b.OrganizationID != "" {
+		organizationID = &b.OrganizationID
+	}
+	_, err = r.pool.Exec(ctx, `
+		INSERT INTO storage_blob (filename, content_type, metadata, byte_size, checksum, key, organization_id, storage, unreferenced_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		ON CONFLICT (COALESCE(organization_id::text, ''), storage, checksum) WHERE checksum IS NOT NULL DO NOTHING
+	`, b.Filename, b.ContentType, metadata, b.ByteSize, b.Checksum, b.Key, organizationID, b.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindByChecksum(ctx, b.OrganizationID, b.Storage, b.Checksum)
+}
+
+// PageFileKey returns the storage key of a file of a template page (0.pdf, 0.jpg, p/0.jpg),
+// "" when the page was stored before blobs were content addressed
+func (r *StorageBlobRepository) PageFileKey(ctx context.Context, attachmentID, name string) (string, error) {
+	var key string
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(b.key, '')
+		FROM storage_attachment a
+		JOIN storage_blob b ON b.id = a.blob_id
+		WHERE a.record_type = 'Page' AND a.record_id = $1 AND a.name = $2
+		LIMIT 1
+	`, attachmentID, name).Scan(&key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return key, err
+}
+
+// orphanedAttachments selects attachments created before $1 whose record is gone:
+// attachments of deleted templates and files of pages no template uses any more
+const orphanedAttachments = `
+	FROM storage_attachment a
+	WHERE a.created_at < $1
+	  AND (
+		(a.record_type = 'Template' AND NOT EXISTS (SELECT 1 FROM template t WHERE t.id = a.record_id))
+		OR (a.record_type = 'Page' AND NOT EXISTS (
+			SELECT 1 FROM template t
+			WHERE t.schema @> jsonb_build_array(jsonb_build_object('attachment_id', a.record_id::text))
+		))
+	  )`
+
+// OrphanedAttachments counts the attachments DeleteOrphanedAttachments would remove
+func (r *StorageBlobRepository) OrphanedAttachments(ctx context.Context, before time.Time) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT count(*)`+orphanedAttachments, before).Scan(&n)
+	return n, err
+}
+
+// DeleteOrphanedAttachments removes the attachments whose record is gone, releasing their blobs
+func (r *StorageBlobRepository) DeleteOrphanedAttachments(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM storage_attachment WHERE id IN (SELECT a.id`+orphanedAttachments+`)`, before)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Unreferenced returns the blobs without references since before
+func (r *StorageBlobRepository) Unreferenced(ctx context.Context, before time.Time) ([]*models.StorageBlob, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT`+storageBlobColumns+`
+		FROM storage_blob
+		WHERE reference_count = 0 AND COALESCE(unreferenced_at, created_at) < $1
+		ORDER BY created_at
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []*models.StorageBlob
+	for rows.Next() {
+		b, err := scanStorageBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
+// Delete removes a blob if it is still without references since before; false means it was kept
+func (r *StorageBlobRepository) Delete(ctx context.Context, id string, before time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM storage_blob
+		WHERE id = $1 AND reference_count = 0 AND COALESCE(unreferenced_at, created_at) < $2
+	`, id, before)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// BlobExists reports whether a blob is stored under key in the storage of an organization at a location
+func (r *StorageBlobRepository) BlobExists(ctx context.Context, organizationID, location, key string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM storage_blob
+			WHERE COALESCE(organization_id::text, '') = $1 AND storage = $2 AND key = $3
+		)
+	`, organizationID, location, key).Scan(&exists)
+	return exists, err
+}
+
+// RenameKey points the blobs of the storage of an organization at a location stored under
+// oldKey to newKey
+func (r *StorageBlobRepository) RenameKey(ctx context.Context, organizationID, location, oldKey, newKey string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE storage_blob SET key = $4
+		WHERE COALESCE(organization_id::text, '') = $1 AND storage = $2 AND key = $3
+	`, organizationID, location, oldKey, newKey)
+	return err
+}
+
+// Organizations returns the organizations ("" for none) with blobs in a storage: location
+// is "" for the global storage, as in RenameKey
+func (r *StorageBlobRepository) Organizations(ctx context.Context, location string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT DISTINCT COALESCE(organization_id::text, '') AS organization_id
+		FROM storage_blob
+		WHERE storage = $1
+		ORDER BY organization_id
+	`, location)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PageInUse reports whether a template uses the page with an attachment ID
+func (r *StorageBlobRepository) PageInUse(ctx context.Context, attachmentID string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM template
+			WHERE schema @> jsonb_build_array(jsonb_build_object('attachment_id', $1::text))
+		)
+	`, attachmentID).Scan(&exists)
+	return exists, err
+}
+
+// SubmissionExists reports whether a submission exists
+func (r *StorageBlobRepository) SubmissionExists(ctx context.Context, submissionID string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM submission WHERE id = $1)`, submissionID).Scan(&exists)
+	return exists, err
+}
+
+// TryLock takes the session lock of the garbage collector so a single node runs it;
+// ok is false when another node holds it. release must be called when ok.
+func (r *StorageBlobRepository) TryLock(ctx context.Context) (release func(), ok bool, err error) {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	const lock = `hashtextextended('storage_blob_gc', 0)`
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(`+lock+`)`).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return func() {
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock(`+lock+`)`)
+		conn.Release()
+	}, true, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/shurco/gosign/internal/models"
@@ -40,9 +41,22 @@ func (r *SubmissionRepository) CreateSubmission(ctx context.Context, submission
 	return nil
 }
 
-// GetSubmission is a stub implementation (not used in current flow)
+// GetSubmission returns a submission with its signing mode; pgx.ErrNoRows when there is none
 func (r *SubmissionRepository) GetSubmission(ctx context.Context, id string) (*models.Submission, error) {
-	return nil, nil
+	s := &models.Submission{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT id::text, template_id::text, COALESCE(created_by_user_id::text, ''), COALESCE(preferences->>'signing_mode', ''),
+		       COALESCE(locale, ''), (preferences->>'completed_at')::timestamptz, COALESCE(created_at, NOW()), COALESCE(updated_at, NOW())
+		FROM submission
+		WHERE id = $1
+	`, id).Scan(&s.ID, &s.TemplateID, &s.CreatedByID, &s.SigningMode, &s.Locale, &s.CompletedAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if s.SigningMode == "" {
+		s.SigningMode = models.SigningModeSequential
+	}
+	return s, nil
 }
 
 // UpdateSubmissionState is a stub implementation (not used in current flow)
@@ -55,22 +69,119 @@ func (r *SubmissionRepository) CreateSubmitter(ctx context.Context, submitter *m
 	return nil
 }
 
-// GetSubmitters is a stub implementation (not used in current flow)
+// submitterColumns are the columns scanSubmitters reads; the signing order is stored in
+// the metadata, submitters without one sign in the order they were added
+const submitterColumns = `
+		SELECT id::text, submission_id::text, COALESCE(name, ''), COALESCE(email, ''), COALESCE(phone, ''), slug,
+		       COALESCE(status, 'pending'), COALESCE(metadata, '{}'::jsonb), sented_at, opened_at, completed_at, declined_at,
+		       COALESCE(created_at, NOW()), COALESCE(updated_at, NOW()),
+		       CASE WHEN jsonb_typeof(metadata->'order') = 'number' THEN (metadata->>'order')::int
+		            ELSE (ROW_NUMBER() OVER (PARTITION BY submission_id ORDER BY created_at, id) - 1)::int END AS signing_order
+		FROM submitter`
+
+func scanSubmitters(rows pgx.Rows) ([]*models.Submitter, error) {
+	defer rows.Close()
+	var submitters []*models.Submitter
+	for rows.Next() {
+		var (
+			s        models.Submitter
+			metaJSON []byte
+		)
+		if err := rows.Scan(&s.ID, &s.SubmissionID, &s.Name, &s.Email, &s.Phone, &s.Slug, &s.Status, &metaJSON,
+			&s.SentAt, &s.OpenedAt, &s.CompletedAt, &s.DeclinedAt, &s.CreatedAt, &s.UpdatedAt, &s.Order); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metaJSON, &s.Metadata); err != nil {
+			return nil, err
+		}
+		submitters = append(submitters, &s)
+	}
+	return submitters, rows.Err()
+}
+
+// GetSubmitters returns the submitters of a submission in signing order
 func (r *SubmissionRepository) GetSubmitters(ctx context.Context, submissionID string) ([]*models.Submitter, error) {
-	return nil, nil
+	rows, err := r.pool.Query(ctx, `SELECT * FROM (`+submitterColumns+` WHERE submission_id = $1) s ORDER BY signing_order, created_at, id`, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSubmitters(rows)
 }
 
-// GetSubmittersByOrder is a stub implementation (not used in current flow)
+// GetSubmittersByOrder returns the submitters of a submission's signing group order
 func (r *SubmissionRepository) GetSubmittersByOrder(ctx context.Context, submissionID string, order int) ([]*models.Submitter, error) {
-	return nil, nil
+	submitters, err := r.GetSubmitters(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	group := make([]*models.Submitter, 0, len(submitters))
+	for _, s := range submitters {
+		if s.Order == order {
+			group = append(group, s)
+		}
+	}
+	return group, nil
 }
 
-// GetSubmitter is a stub implementation (not used in current flow)
+// GetSubmitter returns a submitter; pgx.ErrNoRows when there is none
 func (r *SubmissionRepository) GetSubmitter(ctx context.Context, id string) (*models.Submitter, error) {
-	return nil, nil
+	return r.submitterBy(ctx, "id", id)
+}
+
+// GetSubmitterBySlug returns the submitter of a signing link; pgx.ErrNoRows when there is none
+func (r *SubmissionRepository) GetSubmitterBySlug(ctx context.Context, slug string) (*models.Submitter, error) {
+	return r.submitterBy(ctx, "slug", slug)
+}
+
+// submitterBy returns the submitter whose column (id or slug) is value; its order
+// defaults to its position among the submitters of its submission
+func (r *SubmissionRepository) submitterBy(ctx context.Context, column, value string) (*models.Submitter, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT * FROM (`+submitterColumns+`
+			WHERE submission_id = (SELECT submission_id FROM submitter WHERE `+column+`::text = $1)
+		) s
+		WHERE s.`+column+` = $1
+	`, value)
+	if err != nil {
+		return nil, err
+	}
+	submitters, err := scanSubmitters(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(submitters) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return submitters[0], nil
 }
 
-// UpdateSubmitterStatus is a stub implementation (not used in current flow)
+// UpdateSubmitterStatus sets the status of a submitter, and the time it completed or declined
 func (r *SubmissionRepository) UpdateSubmitterStatus(ctx context.Context, id string, status models.SubmitterStatus) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE submitter
+		SET status = $2,
+		    completed_at = CASE WHEN $2 = 'completed' THEN COALESCE(completed_at, NOW()) ELSE completed_at END,
+		    declined_at = CASE WHEN $2 = 'declined' THEN COALESCE(declined_at, NOW()) ELSE declined_at END,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, id, string(status))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
 	return nil
 }
+
+// ClaimInvitation marks a pending submitter opened; false when it wasn't pending
+func (r *SubmissionRepository) ClaimInvitation(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE submitter SET status = 'opened', updated_at = NOW()
+		WHERE id = $1 AND COALESCE(status, 'pending') = 'pending'
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
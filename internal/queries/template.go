@@ -18,6 +18,9 @@ import (
 // TemplateQueries is ...
 type TemplateQueries struct {
 	*pgxpool.Pool
+
+	// PageURL, when set, gives the expiring URL the pages of a document are served under
+	PageURL func(attachmentID string) string
 }
 
 // Template is ...
@@ -34,6 +37,7 @@ func (q *TemplateQueries) Template(ctx context.Context, id string) (*models.Temp
 			"template"."submitters",
 			"template"."fields",
 			"template"."schema",
+			"template"."settings",
 			"template"."created_at",
 			"template"."updated_at"
 		FROM
@@ -50,6 +54,7 @@ func (q *TemplateQueries) Template(ctx context.Context, id string) (*models.Temp
 		&template.Submitters,
 		&template.Fields,
 		&template.Schema,
+		&template.Settings,
 		&template.CreatedAt,
 		&template.UpdatedAt,
 	)
@@ -113,8 +118,8 @@ func (q *TemplateQueries) Template(ctx context.Context, id string) (*models.Temp
 			return nil, err
 		}
 
-		if serviceName == "disk" {
-			document.URL = "http://localhost:8088/drive/pages"
+		if q.PageURL != nil {
+			document.URL = q.PageURL(document.ID)
 		}
 
 		if document.Metadata.Pdf.NumberOfPages == 0 {
@@ -227,6 +232,17 @@ func (q *TemplateQueries) CreateStorageAttachment(ctx context.Context, attachmen
 	return nil
 }
 
+// TemplateOrganizationID returns the organization of a template, "" when it has none
+func (q *TemplateQueries) TemplateOrganizationID(ctx context.Context, templateID string) (string, error) {
+	var organizationID string
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE("organization_id"::text, '')
+		FROM "template"
+		WHERE "id" = $1
+	`, templateID).Scan(&organizationID)
+	return organizationID, err
+}
+
 // UpdateTemplateSchema updates the schema field of a template
 func (q *TemplateQueries) UpdateTemplateSchema(ctx context.Context, templateID string, schema []models.Schema) error {
 	schemaJSON, err := json.Marshal(schema)
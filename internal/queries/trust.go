@@ -11,107 +11,192 @@ import (
 	"github.com/shurco/gosign/internal/models"
 )
 
-// TrustQueries is ...
+// TrustQueries stores trust anchors.
+//
+// Platform anchors (no organization or account) come from the configured trust
+// lists and are replaced list by list. Custom anchors belong to an organization
+// or, without one, to an account, the same way as signing certificates.
 type TrustQueries struct {
 	*pgxpool.Pool
 }
 
-// CheckAKI is ...
-func (q *TrustQueries) CheckAKI(ctx context.Context, aki string) (*models.TrustCert, error) {
-	if aki == "" {
-		return nil, nil
-	}
+const trustColumns = `
+	id, COALESCE(organization_id::text, ''), COALESCE(account_id::text, ''),
+	"list", "name", "aki", "ski", "location", COALESCE("certificate", ''::bytea), created_at
+`
 
-	trustCert := &models.TrustCert{}
-	query := `
-		SELECT
-			"list",
-			"name",
-			"aki",
-			"ski"
-		FROM
-			"trust_list"
-		WHERE
-			"ski" = $1
-		LIMIT
-			1
-	`
-	err := q.QueryRow(ctx, query,
-		aki,
-	).Scan(
-		&trustCert.List,
-		&trustCert.Name,
-		&trustCert.AKI,
-		&trustCert.SKI,
+// trustPlatformFilter matches the anchors of the platform trust lists
+const trustPlatformFilter = ` organization_id IS NULL AND account_id IS NULL `
+
+func scanTrustCert(row pgx.Row) (*models.TrustCert, error) {
+	var (
+		t       models.TrustCert
+		created sql.NullTime
 	)
+	if err := row.Scan(
+		&t.ID,
+		&t.OrganizationID,
+		&t.AccountID,
+		&t.List,
+		&t.Name,
+		&t.AKI,
+		&t.SKI,
+		&t.Location,
+		&t.Certificate,
+		&created,
+	); err != nil {
+		return nil, err
+	}
+	if created.Valid {
+		t.CreatedAt = &created.Time
+	}
+	return &t, nil
+}
+
+func collectTrustCerts(rows pgx.Rows) ([]models.TrustCert, error) {
+	defer rows.Close()
+	certs := []models.TrustCert{}
+	for rows.Next() {
+		t, err := scanTrustCert(rows)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, *t)
+	}
+	return certs, rows.Err()
+}
+
+// TrustAnchorsBySKI returns the anchors with the subject key identifier ski
+// (upper-case hex): platform anchors and the custom anchors of the scope.
+func (q *TrustQueries) TrustAnchorsBySKI(ctx context.Context, ski, organizationID, accountID string) ([]models.TrustCert, error) {
+	if ski == "" {
+		return nil, nil
+	}
+	query := `SELECT ` + trustColumns + ` FROM trust_list WHERE ski = $3 AND ((` + trustPlatformFilter + `) OR ` + certificateScopeFilter + `) ORDER BY created_at`
+	rows, err := q.Query(ctx, query, organizationID, accountID, ski)
 	if err != nil {
 		return nil, err
 	}
+	return collectTrustCerts(rows)
+}
 
-	return trustCert, nil
+// ListTrustAnchors returns the custom anchors of the scope
+func (q *TrustQueries) ListTrustAnchors(ctx context.Context, organizationID, accountID string) ([]models.TrustCert, error) {
+	query := `SELECT ` + trustColumns + ` FROM trust_list WHERE ` + certificateScopeFilter + ` ORDER BY "list", "name"`
+	rows, err := q.Query(ctx, query, organizationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return collectTrustCerts(rows)
 }
 
-// AddAdobeTL is ...
-func (q *TrustQueries) AddAdobeTL(ctx context.Context, trust models.TrustCerts) error {
-	var certsToInsert []models.TrustCert
+// AddTrustAnchors inserts custom anchors into the scope and populates their IDs.
+// Anchors already in the scope (same certificate and list) are skipped.
+func (q *TrustQueries) AddTrustAnchors(ctx context.Context, organizationID, accountID string, certs []models.TrustCert) ([]models.TrustCert, error) {
+	orgID, accID := nullableID(organizationID), (*string)(nil)
+	if organizationID == "" {
+		accID = nullableID(accountID)
+	}
 
-	for _, v := range trust.Certs {
-		var aki string
-		query := `
-			SELECT
-				"aki"
-			FROM
-				"trust_list"
-			WHERE
-				"aki" = $1
-			LIMIT
-				1
-		`
-		err := q.QueryRow(ctx, query, v.AKI).Scan(&aki)
-		if err != nil && err != pgx.ErrNoRows {
-			return err
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	added := []models.TrustCert{}
+	for _, t := range certs {
+		var exists bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM trust_list WHERE aki = $3 AND "list" = $4 AND `+certificateScopeFilter+`)`,
+			organizationID, accountID, t.AKI, t.List,
+		).Scan(&exists)
+		if err != nil {
+			return nil, err
 		}
-		if err == pgx.ErrNoRows {
-			certsToInsert = append(certsToInsert, v)
+		if exists {
+			continue
 		}
-	}
 
-	if len(certsToInsert) > 0 {
-		batch := &pgx.Batch{}
-		insertQuery := `
-			INSERT INTO
-				"trust_list" ("list", "name", "aki", "ski")
-			VALUES
-				($1, $2, $3, $4)
-		`
-		for _, cert := range certsToInsert {
-			batch.Queue(insertQuery, cert.List, cert.Name, cert.AKI, cert.SKI)
+		query := `
+			INSERT INTO trust_list (organization_id, account_id, "list", "name", "aki", "ski", "location", "certificate")
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING ` + trustColumns
+		row, err := scanTrustCert(tx.QueryRow(ctx, query, orgID, accID, t.List, t.Name, t.AKI, t.SKI, t.Location, t.Certificate))
+		if err != nil {
+			return nil, err
 		}
+		added = append(added, *row)
+	}
 
-		br := q.SendBatch(ctx, batch)
-		defer br.Close()
+	return added, tx.Commit(ctx)
+}
 
-		for range certsToInsert {
-			_, err := br.Exec()
-			if err != nil {
-				return err
-			}
-		}
+// DeleteTrustAnchor removes a custom anchor of the scope; pgx.ErrNoRows is
+// returned when there is none with the ID.
+func (q *TrustQueries) DeleteTrustAnchor(ctx context.Context, id, organizationID, accountID string) error {
+	tag, err := q.Exec(ctx, `DELETE FROM trust_list WHERE id::text = $3 AND `+certificateScopeFilter, organizationID, accountID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
 	}
-
 	return nil
 }
 
-// TimeUpdateAdobeTL is ...
-func (q *TrustQueries) TimeUpdateAdobeTL(ctx context.Context) (*time.Time, error) {
+// ReplaceTrustList replaces the platform anchors of list
+func (q *TrustQueries) ReplaceTrustList(ctx context.Context, list string, certs []models.TrustCert) error {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM trust_list WHERE "list" = $1 AND`+trustPlatformFilter, list); err != nil {
+		return err
+	}
+
+	if len(certs) == 0 {
+		return tx.Commit(ctx)
+	}
+
+	batch := &pgx.Batch{}
+	query := `
+		INSERT INTO
+			"trust_list" ("list", "name", "aki", "ski", "location", "certificate")
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+	`
+	for _, cert := range certs {
+		batch.Queue(query, list, cert.Name, cert.AKI, cert.SKI, cert.Location, cert.Certificate)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteTrustListsExcept removes the platform anchors of lists not in lists,
+// e.g. after a trust source was removed from the configuration
+func (q *TrustQueries) DeleteTrustListsExcept(ctx context.Context, lists []string) error {
+	_, err := q.Exec(ctx, `DELETE FROM trust_list WHERE NOT ("list" = ANY($1)) AND`+trustPlatformFilter, lists)
+	return err
+}
+
+// TrustListUpdatedAt returns when the platform list was last imported, or nil
+// when it never was
+func (q *TrustQueries) TrustListUpdatedAt(ctx context.Context, list string) (*time.Time, error) {
 	var date sql.NullTime
 	query := `
 		SELECT
-			MIN("created_at") AS "earliest_record"
+			MIN("created_at")
 		FROM
 			"trust_list"
-	`
-	if err := q.QueryRow(ctx, query).Scan(&date); err != nil {
+		WHERE
+			"list" = $1 AND` + trustPlatformFilter
+	if err := q.QueryRow(ctx, query, list).Scan(&date); err != nil {
 		return nil, err
 	}
 
@@ -121,25 +206,3 @@ func (q *TrustQueries) TimeUpdateAdobeTL(ctx context.Context) (*time.Time, error
 
 	return nil, nil
 }
-
-// DeleteAdobeTL is ...
-func (q *TrustQueries) DeleteAdobeTL(ctx context.Context, list string) error {
-	query := `
-		DELETE FROM "trust_list"
-		WHERE
-			"list" = $1
-	`
-	_, err := q.Exec(ctx, query, list)
-	return err
-}
-
-// ClearAdobeTL is ...
-func (q *TrustQueries) ClearAdobeTL(ctx context.Context) error {
-	query := `
-		DELETE FROM "trust_list"
-		WHERE
-			"list" != 'gosing'
-	`
-	_, err := q.Exec(ctx, query)
-	return err
-}
@@ -0,0 +1,223 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// WebhookDeliveryRepository keeps the outbox of webhook deliveries
+type WebhookDeliveryRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWebhookDeliveryRepository creates new webhook delivery repository
+func NewWebhookDeliveryRepository(pool *pgxpool.Pool) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{pool: pool}
+}
+
+const webhookDeliveryColumns = `
+	d.id::text, d.webhook_id::text, d.event_id::text, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, COALESCE(d.response_status, 0), COALESCE(d.response_body, ''),
+	COALESCE(d.latency_ms, 0), COALESCE(d.error, ''), COALESCE(d.redelivery_of::text, ''), d.delivered_at, d.created_at`
+
+func scanWebhookDelivery(row pgx.Row, dest ...any) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	err := row.Scan(append([]any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.ResponseBody,
+		&d.LatencyMS, &d.Error, &d.RedeliveryOf, &d.DeliveredAt, &d.CreatedAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Enqueue queues an event for every enabled webhook of an account subscribed to its type
+// (or to "*") and to its template (or to all templates), and returns the number of
+// deliveries queued. Webhooks with include_values get valuesPayload, the others payload.
+func (r *WebhookDeliveryRepository) Enqueue(ctx context.Context, accountID, eventID, eventType, templateID string, payload, valuesPayload []byte) (int, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO webhook_delivery (webhook_id, event_id, event_type, payload)
+		SELECT w.id, $2::uuid, $3::text, CASE WHEN w.include_values THEN $6::jsonb ELSE $5::jsonb END
+		FROM webhook w
+		WHERE w.account_id = $1 AND w.enabled
+		  AND (w.events @> jsonb_build_array($3::text) OR w.events @> '["*"]'::jsonb)
+		  AND ($4::text = '' OR w.template_ids = '[]'::jsonb OR w.template_ids @> jsonb_build_array($4::text))
+	`, accountID, eventID, eventType, templateID, payload, valuesPayload)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Claim returns up to limit deliveries due for an attempt, with their webhook, and pushes
+// their next attempt back by lease so other nodes don't attempt them meanwhile; a delivery
+// whose attempt is never recorded (e.g. the node stopped) is attempted again after lease.
+// Deliveries of disabled webhooks wait until the webhook is enabled again.
+func (r *WebhookDeliveryRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_delivery d
+			JOIN webhook w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND w.enabled
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_delivery d
+			SET next_attempt_at = now() + make_interval(secs => $2)
+			FROM due
+			WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT`+webhookDeliveryColumns+`, w.account_id::text, w.url, w.secret, w.previous_secrets
+		FROM claimed d
+		JOIN webhook w ON w.id = d.webhook_id
+		ORDER BY d.created_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		w := &models.Webhook{Enabled: true}
+		d, err := scanWebhookDelivery(rows, &w.AccountID, &w.URL, &w.Secret, &w.PreviousSecrets)
+		if err != nil {
+			return nil, err
+		}
+		w.ID = d.WebhookID
+		d.Webhook = w
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt saves the outcome of an attempt (status, attempts, next attempt and
+// response) and keeps the failure count of the webhook: reset on success, incremented
+// when the delivery is dead
+func (r *WebhookDeliveryRepository) RecordAttempt(ctx context.Context, d *models.WebhookDelivery) error {
+	var responseStatus *int
+	if d.ResponseStatus != 0 {
+		responseStatus = &d.ResponseStatus
+	}
+	_, err := r.pool.Exec(ctx, `
+		WITH attempt AS (
+			UPDATE webhook_delivery
+			SET status = $2::text, attempts = $3, next_attempt_at = $4, last_attempt_at = now(),
+			    response_status = $5, response_body = NULLIF($6, ''), latency_ms = $7, error = NULLIF($8, ''),
+			    delivered_at = CASE WHEN $2::text = 'delivered' THEN now() END
+			WHERE id = $1
+			RETURNING webhook_id, status
+		)
+		UPDATE webhook w
+		SET last_triggered_at = now(),
+		    failure_count = CASE attempt.status
+		        WHEN 'delivered' THEN 0
+		        WHEN 'dead' THEN COALESCE(w.failure_count, 0) + 1
+		        ELSE w.failure_count
+		    END
+		FROM attempt
+		WHERE w.id = attempt.webhook_id
+	`, d.ID, d.Status, d.Attempts, d.NextAttemptAt, responseStatus, d.ResponseBody, d.LatencyMS, d.Error)
+	return err
+}
+
+// List returns the deliveries of a webhook of an account, newest first, optionally with a
+// status, and their total
+func (r *WebhookDeliveryRepository) List(ctx context.Context, accountID, webhookID, status string, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	const filter = `
+		FROM webhook_delivery d
+		JOIN webhook w ON w.id = d.webhook_id
+		WHERE w.account_id = $1 AND d.webhook_id = $2 AND ($3::text = '' OR d.status = $3::text)`
+
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*)`+filter, accountID, webhookID, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT`+webhookDeliveryColumns+filter+`
+		ORDER BY d.created_at DESC
+		LIMIT $4 OFFSET $5
+	`, accountID, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// Get returns a delivery of a webhook of an account, or nil
+func (r *WebhookDeliveryRepository) Get(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.pool.QueryRow(ctx, `
+		SELECT`+webhookDeliveryColumns+`
+		FROM webhook_delivery d
+		JOIN webhook w ON w.id = d.webhook_id
+		WHERE w.account_id = $1 AND d.webhook_id = $2 AND d.id = $3
+	`, accountID, webhookID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// Redeliver queues the event of a delivery of a webhook of an account again and returns
+// the new delivery, or nil when there is no such delivery
+func (r *WebhookDeliveryRepository) Redeliver(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.pool.QueryRow(ctx, `
+		WITH redelivery AS (
+			INSERT INTO webhook_delivery (webhook_id, event_id, event_type, payload, redelivery_of)
+			SELECT d.webhook_id, d.event_id, d.event_type, d.payload, d.id
+			FROM webhook_delivery d
+			JOIN webhook w ON w.id = d.webhook_id
+			WHERE w.account_id = $1 AND d.webhook_id = $2 AND d.id = $3
+			RETURNING *
+		)
+		SELECT`+webhookDeliveryColumns+`
+		FROM redelivery d
+	`, accountID, webhookID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// RotateSecret replaces the signing secret of a webhook of an account. The replaced secret
+// keeps signing deliveries until expiresAt (not at all when it has passed), as do earlier
+// ones until their own expiry; expired secrets are dropped. false means there is no such webhook.
+func (r *WebhookDeliveryRepository) RotateSecret(ctx context.Context, accountID, webhookID, secret string, expiresAt time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE webhook
+		SET secret = $3,
+		    previous_secrets = COALESCE((
+		        SELECT jsonb_agg(s)
+		        FROM jsonb_array_elements(previous_secrets) s
+		        WHERE (s->>'expires_at')::timestamptz > now()
+		    ), '[]'::jsonb) || CASE
+		        WHEN $4::timestamptz > now() THEN jsonb_build_array(jsonb_build_object('secret', secret, 'expires_at', $4::timestamptz))
+		        ELSE '[]'::jsonb
+		    END,
+		    updated_at = now()
+		WHERE account_id = $1 AND id = $2
+	`, accountID, webhookID, secret, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,149 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+// WebhookEventRepository loads what the payloads of webhook events carry
+type WebhookEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWebhookEventRepository creates new webhook event repository
+func NewWebhookEventRepository(pool *pgxpool.Pool) *WebhookEventRepository {
+	return &WebhookEventRepository{pool: pool}
+}
+
+// templateAccount is the account whose webhooks receive the events of a template: the owner
+// of its organization, else the account of its folder
+const templateAccount = `COALESCE(o.owner_id::text, f.account_id::text, '')`
+
+const webhookTemplateColumns = `
+	t.id::text, t.name, COALESCE(t.category, ''), COALESCE(t.organization_id::text, ''), t.submitters,
+	COALESCE(t.created_at, now()), COALESCE(t.updated_at, now())`
+
+// templateSubmitter is a submitter of a template as stored in template.submitters
+type templateSubmitter struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func scanWebhookTemplate(row pgx.Row, dest ...any) (*models.WebhookTemplate, []templateSubmitter, error) {
+	t := &models.WebhookTemplate{}
+	var submittersJSON []byte
+	err := row.Scan(append(dest, &t.ID, &t.Name, &t.Category, &t.OrganizationID, &submittersJSON, &t.CreatedAt, &t.UpdatedAt)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var submitters []templateSubmitter
+	_ = json.Unmarshal(submittersJSON, &submitters) // {} for templates without submitters
+	for _, s := range submitters {
+		t.Submitters = append(t.Submitters, s.Name)
+	}
+	return t, submitters, nil
+}
+
+// Template returns a template and the account whose webhooks receive its events, or nil
+func (r *WebhookEventRepository) Template(ctx context.Context, templateID string) (string, *models.WebhookTemplate, error) {
+	var accountID string
+	t, _, err := scanWebhookTemplate(r.pool.QueryRow(ctx, `
+		SELECT `+templateAccount+`,`+webhookTemplateColumns+`
+		FROM template t
+		LEFT JOIN organization o ON o.id = t.organization_id
+		LEFT JOIN template_folder f ON f.id = t.folder_id
+		WHERE t.id = $1
+	`, templateID), &accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return accountID, t, nil
+}
+
+// Submission returns a submission with its template and submitters (their values
+// included) and the account whose webhooks receive its events: the account of its
+// creator, else that of its template. nil means there is no such submission.
+func (r *WebhookEventRepository) Submission(ctx context.Context, submissionID string) (string, *models.WebhookSubmission, error) {
+	var (
+		accountID string
+		s         = &models.WebhookSubmission{}
+	)
+	t, templateSubmitters, err := scanWebhookTemplate(r.pool.QueryRow(ctx, `
+		SELECT COALESCE(u.account_id::text, `+templateAccount+`),
+		       sub.id::text, COALESCE(sub.source, ''), COALESCE(sub.preferences->>'signing_mode', ''),
+		       COALESCE(sub.created_at, now()), (sub.preferences->>'completed_at')::timestamptz,`+webhookTemplateColumns+`
+		FROM submission sub
+		JOIN template t ON t.id = sub.template_id
+		LEFT JOIN "user" u ON u.id = sub.created_by_user_id
+		LEFT JOIN organization o ON o.id = t.organization_id
+		LEFT JOIN template_folder f ON f.id = t.folder_id
+		WHERE sub.id = $1
+	`, submissionID), &accountID, &s.ID, &s.Source, &s.SigningMode, &s.CreatedAt, &s.CompletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	s.Template = t
+	if s.SigningMode == "" {
+		s.SigningMode = models.SigningModeSequential
+	}
+
+	roles := make(map[string]string, len(templateSubmitters))
+	for _, ts := range templateSubmitters {
+		roles[ts.ID] = ts.Name
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id::text, COALESCE(name, ''), COALESCE(email, ''), COALESCE(phone, ''), COALESCE(status, 'pending'),
+		       COALESCE(metadata, '{}'::jsonb), sented_at, opened_at, completed_at, declined_at
+		FROM submitter
+		WHERE submission_id = $1
+		ORDER BY CASE WHEN jsonb_typeof(metadata->'order') = 'number' THEN (metadata->>'order')::numeric END NULLS LAST, created_at, id
+	`, submissionID)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	s.Submitters = []*models.WebhookSubmitter{}
+	for rows.Next() {
+		var (
+			submitter models.WebhookSubmitter
+			metaJSON  []byte
+		)
+		if err := rows.Scan(&submitter.ID, &submitter.Name, &submitter.Email, &submitter.Phone, &submitter.Status,
+			&metaJSON, &submitter.SentAt, &submitter.OpenedAt, &submitter.CompletedAt, &submitter.DeclinedAt); err != nil {
+			return "", nil, err
+		}
+		var meta struct {
+			TemplateSubmitterID string         `json:"template_submitter_id"`
+			Order               *int           `json:"order"`
+			Fields              map[string]any `json:"fields"`
+			DeclineReason       string         `json:"decline_reason"`
+		}
+		_ = json.Unmarshal(metaJSON, &meta)
+		submitter.Role = roles[meta.TemplateSubmitterID]
+		submitter.Order = len(s.Submitters)
+		if meta.Order != nil {
+			submitter.Order = *meta.Order
+		}
+		submitter.Values = meta.Fields
+		submitter.DeclineReason = meta.DeclineReason
+		s.Submitters = append(s.Submitters, &submitter)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	return accountID, s, nil
+}
@@ -26,7 +26,17 @@ type APIHandlers struct {
 	I18n            *api.I18nHandler
 	Branding        *api.BrandingHandler
 	EmailTemplates  *api.EmailTemplateHandler
+	Certificates    *api.CertificateHandler
+	CA              *api.CAHandler
+	PublicCA        *public.CAHandler
+	PublicTSA       *public.TSAHandler
 	PublicSigning   *public.PublicSigningHandler
+	Sign            *public.SignHandler
+	VerifyReport    *public.VerifyReportHandler
+	DocumentHash    *public.DocumentHashHandler
+	Drive           *public.DriveHandler
+	OrgStorage      *api.OrganizationStorageHandler
+	Trust           *api.TrustHandler
 }
 
 // ApiRoutes configures all API routes
@@ -64,9 +74,39 @@ func ApiRoutes(c *fiber.App, handlers *APIHandlers) {
 	// Public signing/verification (no authentication)
 	verify := c.Group("/verify")
 	verify.Post("/pdf", public.VerifyPDF)
+	if handlers.VerifyReport != nil {
+		verify.Post("/report", handlers.VerifyReport.Report)
+		verify.Post("/cms", handlers.VerifyReport.VerifyCMS)
+		verify.Post("/asic", handlers.VerifyReport.VerifyASiC)
+	}
+	// Look up copies of documents by SHA-256 (rate limited, the lookup hits the database)
+	if handlers.DocumentHash != nil {
+		hash := verify.Group("/hash", middleware.APIRateLimiter())
+		hash.Get("", handlers.DocumentHash.LookupHash)
+		hash.Post("", handlers.DocumentHash.VerifyDocumentHash)
+	}
+
+	// Signing with the caller's default certificate requires authentication
+	if handlers.Sign != nil {
+		sign := c.Group("/sign")
+		sign.Post("/", middleware.Protected(), middleware.APIRateLimiter(), handlers.Sign.SignPDF)
+		sign.Post("/cms", middleware.Protected(), middleware.APIRateLimiter(), handlers.Sign.SignCMS)
+	}
+
+	// Page images and signed files from the blob storage (no authentication)
+	if handlers.Drive != nil {
+		handlers.Drive.RegisterRoutes(c)
+	}
 
-	sign := c.Group("/sign")
-	sign.Post("/", public.SignPDF)
+	// Internal CA certificate and CRL (no authentication)
+	if handlers.PublicCA != nil {
+		handlers.PublicCA.RegisterRoutes(c)
+	}
+
+	// Built-in RFC 3161 timestamp authority (no authentication, only when configured)
+	if handlers.PublicTSA != nil {
+		handlers.PublicTSA.RegisterRoutes(c)
+	}
 
 	// Public signer-facing API (no authentication)
 	if handlers.PublicSigning != nil {
@@ -83,6 +123,19 @@ func ApiRoutes(c *fiber.App, handlers *APIHandlers) {
 		handlers.Invitations.RegisterRoutes(invitations)
 	}
 
+	// Authenticated signing with the caller's default certificate
+	if handlers.Sign != nil {
+		apiV1.Post("/sign", handlers.Sign.SignPDF)
+		apiV1.Post("/sign/cms", handlers.Sign.SignCMS)
+	}
+
+	// Authenticated validation report, also trusting the caller's custom anchors
+	if handlers.VerifyReport != nil {
+		apiV1.Post("/verify/report", handlers.VerifyReport.Report)
+		apiV1.Post("/verify/cms", handlers.VerifyReport.VerifyCMS)
+		apiV1.Post("/verify/asic", handlers.VerifyReport.VerifyASiC)
+	}
+
 	// Submissions API
 	if handlers.Submissions != nil {
 		submissions := apiV1.Group("/submissions")
@@ -94,6 +147,7 @@ func ApiRoutes(c *fiber.App, handlers *APIHandlers) {
 		signingLinks := apiV1.Group("/signing-links")
 		signingLinks.Get("/", handlers.SigningLinks.List)
 		signingLinks.Get("/:submission_id/document", handlers.SigningLinks.DownloadCompletedDocument)
+		signingLinks.Get("/:submission_id/asic", handlers.SigningLinks.DownloadASiC)
 		signingLinks.Get("/:submission_id", handlers.SigningLinks.Get)
 		signingLinks.Post("/", handlers.SigningLinks.Create)
 	}
@@ -120,6 +174,9 @@ func ApiRoutes(c *fiber.App, handlers *APIHandlers) {
 		if handlers.Members != nil {
 			handlers.Members.RegisterRoutes(organizations)
 		}
+		if handlers.OrgStorage != nil {
+			handlers.OrgStorage.RegisterRoutes(organizations)
+		}
 		
 		// Then register organization routes
 		handlers.Organizations.RegisterRoutes(organizations)
@@ -178,4 +235,22 @@ func ApiRoutes(c *fiber.App, handlers *APIHandlers) {
 		emailTemplates := apiV1.Group("/email-templates", middleware.StrictRateLimiter())
 		handlers.EmailTemplates.RegisterRoutes(emailTemplates)
 	}
+
+	// Signing certificates store (with stricter rate limiting)
+	if handlers.Certificates != nil {
+		certificates := apiV1.Group("/certificates", middleware.StrictRateLimiter())
+		handlers.Certificates.RegisterRoutes(certificates)
+	}
+
+	// Internal CA issuance and revocation (with stricter rate limiting)
+	if handlers.CA != nil {
+		caGroup := apiV1.Group("/ca", middleware.StrictRateLimiter())
+		handlers.CA.RegisterRoutes(caGroup)
+	}
+
+	// Custom trust anchors (with stricter rate limiting)
+	if handlers.Trust != nil {
+		trustGroup := apiV1.Group("/trust", middleware.StrictRateLimiter())
+		handlers.Trust.RegisterRoutes(trustGroup)
+	}
 }
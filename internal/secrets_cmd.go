@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/shurco/gosign/internal/config"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/appdir"
+	"github.com/shurco/gosign/pkg/security/secret"
+	"github.com/shurco/gosign/pkg/storage/postgres"
+)
+
+// Secrets runs a command on the secrets stored encrypted with GOSIGN_ENCRYPTION_KEY: gosign secrets <command>
+func Secrets(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: GOSIGN_PREVIOUS_ENCRYPTION_KEY=<key> gosign secrets rewrap")
+	}
+	switch args[0] {
+	case "rewrap":
+		return secretsRewrap(context.Background())
+	default:
+		return fmt.Errorf("unknown secrets command: %s", args[0])
+	}
+}
+
+// secretsRewrap re-encrypts the signing keys and bucket credentials encrypted with the
+// previous key (GOSIGN_PREVIOUS_ENCRYPTION_KEY; the JWT secret for installs from before
+// GOSIGN_ENCRYPTION_KEY was required) with GOSIGN_ENCRYPTION_KEY. Secrets encrypted with
+// the current key already are left alone, so the command can be run again; one encrypted
+// with neither key fails the whole run.
+func secretsRewrap(ctx context.Context) error {
+	previousKey := os.Getenv("GOSIGN_PREVIOUS_ENCRYPTION_KEY")
+	if previousKey == "" {
+		return errors.New("GOSIGN_PREVIOUS_ENCRYPTION_KEY is not set")
+	}
+	appdir.Init()
+	if err := config.Load(); err != nil {
+		return err
+	}
+	cfg := config.Data()
+
+	current, err := secret.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	previous, err := secret.NewCipher(previousKey)
+	if err != nil {
+		return err
+	}
+
+	pool, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	n, err := queries.NewEncryptedSecretRepository(pool).Reencrypt(ctx, func(ciphertext []byte) ([]byte, error) {
+		if _, err := current.Decrypt(ciphertext); err == nil {
+			return nil, nil
+		}
+		plaintext, err := previous.Decrypt(ciphertext)
+		if err != nil {
+			return nil, errors.New("encrypted with neither GOSIGN_ENCRYPTION_KEY nor GOSIGN_PREVIOUS_ENCRYPTION_KEY")
+		}
+		return current.Encrypt(plaintext)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d secrets re-encrypted\n", n)
+	return nil
+}
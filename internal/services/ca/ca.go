@@ -0,0 +1,397 @@
+// Package ca implements the instance's internal certificate authority: it issues
+// short-lived signer certificates, tracks revocations, publishes the CRL and
+// answers OCSP requests.
+package ca
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/security/cert"
+)
+
+// Public paths of the CA endpoints; BaseURL + path is embedded in issued certificates.
+const (
+	CRLPath         = "/ca/crl"
+	CertificatePath = "/ca/cert"
+	OCSPPath        = "/ca/ocsp"
+)
+
+var (
+	// ErrNotConfigured is returned when the CA certificate or key is missing.
+	ErrNotConfigured = errors.New("CA certificate and key not configured")
+	// ErrInvalidCSR is returned when a certificate signing request cannot be parsed or verified.
+	ErrInvalidCSR = errors.New("invalid certificate signing request")
+	// ErrInvalidValidity is returned when the requested lifetime exceeds the configured maximum.
+	ErrInvalidValidity = errors.New("requested validity exceeds maximum")
+	// ErrUnsupportedCAKeyType is returned for CA key types OCSP responses can't be signed with.
+	ErrUnsupportedCAKeyType = errors.New("unsupported CA key type")
+)
+
+// Repository defines interface for issued certificate storage operations
+type Repository interface {
+	Create(ctx context.Context, c *models.IssuedCertificate) error
+	GetBySerial(ctx context.Context, serial string) (*models.IssuedCertificate, error)
+	List(ctx context.Context, organizationID, accountID string) ([]*models.IssuedCertificate, error)
+	ListRevoked(ctx context.Context) ([]*models.IssuedCertificate, error)
+	Revoke(ctx context.Context, organizationID, accountID, serial string, reason int, at time.Time) error
+}
+
+// Config configures the authority. Every node of an instance must be given the
+// same CA certificate and key, so they issue under and publish one CRL.
+type Config struct {
+	Certificate   []byte        // PEM encoded CA certificate
+	Key           []byte        // PEM encoded CA private key; Ed25519 can't sign OCSP responses
+	BaseURL       string        // externally reachable URL prefix for CRL and CA certificate
+	Validity      time.Duration // default lifetime of issued certificates
+	MaxValidity   time.Duration // upper bound for requested lifetimes
+	CRLValidity   time.Duration // CRL nextUpdate distance
+	SignerKeyType cert.KeyType  // key type of generated signer keys, that of the CA key when empty
+}
+
+// Scope identifies the owner of an issued certificate
+type Scope struct {
+	OrganizationID string
+	AccountID      string
+}
+
+// Owns reports whether the issued certificate belongs to the scope
+func (s Scope) Owns(c *models.IssuedCertificate) bool {
+	if s.OrganizationID != "" {
+		return c.OrganizationID != nil && *c.OrganizationID == s.OrganizationID
+	}
+	return c.OrganizationID == nil && c.AccountID != nil && *c.AccountID == s.AccountID
+}
+
+// Request describes a certificate to issue for an existing public key
+type Request struct {
+	Subject        pkix.Name
+	EmailAddresses []string
+	PublicKey      crypto.PublicKey
+	Validity       time.Duration
+	Scope          Scope
+	SubmitterID    string
+}
+
+// Issued is the result of an issuance. PrivateKey is only set when the CA generated the key.
+type Issued struct {
+	Record      *models.IssuedCertificate
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	PrivateKey  crypto.Signer
+}
+
+// Authority is the internal certificate authority
+type Authority struct {
+	repo Repository
+	cfg  Config
+
+	key  crypto.Signer
+	cert *x509.Certificate
+
+	mu            sync.Mutex
+	crl           []byte
+	crlNextUpdate time.Time
+}
+
+// New creates new internal CA from the configured certificate and key
+func New(repo Repository, cfg Config) (*Authority, error) {
+	if len(cfg.Certificate) == 0 || len(cfg.Key) == 0 {
+		return nil, ErrNotConfigured
+	}
+	crt, err := cert.ParseCertificate(cfg.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	pkey, err := cert.ParsePrivateKey(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	if !crt.IsCA {
+		return nil, errors.New("CA certificate is not a CA")
+	}
+	if pub, ok := pkey.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(crt.PublicKey) {
+		return nil, errors.New("CA key does not match the CA certificate")
+	}
+	keyType, ok := keyTypeOf(pkey.Public())
+	if !ok || keyType == cert.KeyTypeEd25519 {
+		return nil, ErrUnsupportedCAKeyType
+	}
+
+	if cfg.Validity <= 0 {
+		cfg.Validity = 24 * time.Hour
+	}
+	if cfg.MaxValidity <= 0 {
+		cfg.MaxValidity = 7 * 24 * time.Hour
+	}
+	if cfg.CRLValidity <= 0 {
+		cfg.CRLValidity = 24 * time.Hour
+	}
+	if cfg.SignerKeyType == "" {
+		cfg.SignerKeyType = keyType
+	}
+	return &Authority{repo: repo, cfg: cfg, key: pkey, cert: crt}, nil
+}
+
+// Generate creates a self-signed CA certificate and key, both PEM encoded;
+// keyType is ECDSA P-256 when empty.
+func Generate(name string, keyType cert.KeyType) (certPEM, keyPEM []byte, err error) {
+	if keyType == cert.KeyTypeEd25519 {
+		return nil, nil, ErrUnsupportedCAKeyType
+	}
+	if name == "" {
+		name = "goSign Internal CA"
+	}
+	pkey, err := cert.GeneratePrivateKey(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	c := &cert.Certificate{
+		Subject:   pkix.Name{CommonName: name, Organization: []string{"goSign"}},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.AddDate(20, 0, 0),
+		IsCA:      true,
+		KeyUsage:  x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	res, err := c.GetCertificate(pkey.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []byte(res.String()), []byte(pkey.String()), nil
+}
+
+// keyTypeOf returns the key type of a public key
+func keyTypeOf(pub crypto.PublicKey) (cert.KeyType, bool) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return cert.KeyTypeECDSAP256, true
+		case elliptic.P384():
+			return cert.KeyTypeECDSAP384, true
+		}
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return cert.KeyTypeRSA2048, true
+		case 3072:
+			return cert.KeyTypeRSA3072, true
+		case 4096:
+			return cert.KeyTypeRSA4096, true
+		}
+	case ed25519.PublicKey:
+		return cert.KeyTypeEd25519, true
+	}
+	return "", false
+}
+
+// CRLURL returns the URL the CRL is published at
+func (a *Authority) CRLURL() string {
+	return a.cfg.BaseURL + CRLPath
+}
+
+// CertificateURL returns the URL the CA certificate is published at
+func (a *Authority) CertificateURL() string {
+	return a.cfg.BaseURL + CertificatePath
+}
+
+// OCSPURL returns the URL of the OCSP responder
+func (a *Authority) OCSPURL() string {
+	return a.cfg.BaseURL + OCSPPath
+}
+
+// Certificate returns the CA certificate
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.cert
+}
+
+// Issue signs a certificate for req.PublicKey and records it
+func (a *Authority) Issue(ctx context.Context, req Request) (*Issued, error) {
+	validity := req.Validity
+	if validity <= 0 {
+		validity = a.cfg.Validity
+	}
+	if validity > a.cfg.MaxValidity {
+		return nil, ErrInvalidValidity
+	}
+
+	caCert := a.cert
+
+	serial, err := cert.GetSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := (&cert.Certificate{
+		SerialNumber:          serial,
+		Subject:               req.Subject,
+		EmailAddresses:        req.EmailAddresses,
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtentedKeyUsage:      []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		CRLDistributionPoints: []string{a.CRLURL()},
+		IssuingCertificateURL: []string{a.CertificateURL()},
+		OCSPServer:            []string{a.OCSPURL()},
+	}).SetTemplate()
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, req.PublicKey, a.key)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.IssuedCertificate{
+		SerialNumber:   crt.SerialNumber.Text(16),
+		Subject:        crt.Subject.String(),
+		CertificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		NotBefore:      crt.NotBefore,
+		NotAfter:       crt.NotAfter,
+	}
+	if req.Scope.OrganizationID != "" {
+		record.OrganizationID = &req.Scope.OrganizationID
+	} else if req.Scope.AccountID != "" {
+		record.AccountID = &req.Scope.AccountID
+	}
+	if req.SubmitterID != "" {
+		record.SubmitterID = &req.SubmitterID
+	}
+	if err := a.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return &Issued{Record: record, Certificate: crt, Chain: []*x509.Certificate{caCert}}, nil
+}
+
+// IssueFromCSR issues a certificate for a PEM encoded PKCS#10 request to the
+// named account holder. Only the public key is taken from the CSR; its subject
+// and email addresses are ignored, so callers can't obtain certificates for
+// identities they don't hold.
+func (a *Authority) IssueFromCSR(ctx context.Context, csrPEM []byte, name, email string, validity time.Duration, scope Scope) (*Issued, error) {
+	csr, err := cert.ParseCSR(csrPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSR, err)
+	}
+
+	req := Request{
+		Subject:   pkix.Name{CommonName: name},
+		PublicKey: csr.PublicKey,
+		Validity:  validity,
+		Scope:     scope,
+	}
+	if email != "" {
+		req.EmailAddresses = []string{email}
+	}
+	return a.Issue(ctx, req)
+}
+
+// IssueSigner generates a fresh key pair for a signer and issues its certificate
+func (a *Authority) IssueSigner(ctx context.Context, name, email string, scope Scope, submitterID string) (*Issued, error) {
+	pkey, err := cert.GeneratePrivateKey(a.cfg.SignerKeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	req := Request{
+		Subject:     pkix.Name{CommonName: name},
+		PublicKey:   pkey.PrivateKey.Public(),
+		Scope:       scope,
+		SubmitterID: submitterID,
+	}
+	if email != "" {
+		req.EmailAddresses = []string{email}
+	}
+
+	issued, err := a.Issue(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	issued.PrivateKey = pkey.PrivateKey
+	return issued, nil
+}
+
+// List returns certificates issued to the scope
+func (a *Authority) List(ctx context.Context, scope Scope) ([]*models.IssuedCertificate, error) {
+	return a.repo.List(ctx, scope.OrganizationID, scope.AccountID)
+}
+
+// Get returns an issued certificate by hex serial number
+func (a *Authority) Get(ctx context.Context, serial string) (*models.IssuedCertificate, error) {
+	return a.repo.GetBySerial(ctx, serial)
+}
+
+// Revoke revokes a certificate of the scope and invalidates the cached CRL.
+// reason is an RFC 5280 CRLReason code.
+func (a *Authority) Revoke(ctx context.Context, scope Scope, serial string, reason int) error {
+	if err := a.repo.Revoke(ctx, scope.OrganizationID, scope.AccountID, serial, reason, time.Now()); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.crl = nil
+	a.mu.Unlock()
+	return nil
+}
+
+// CRL returns the current DER encoded CRL. It is regenerated after a revocation
+// and once half of its validity has passed.
+func (a *Authority) CRL(ctx context.Context) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.crl != nil && time.Until(a.crlNextUpdate) > a.cfg.CRLValidity/2 {
+		return a.crl, nil
+	}
+	revoked, err := a.repo.ListRevoked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &x509.RevocationList{}
+	for _, r := range revoked {
+		serial, ok := parseSerial(r.SerialNumber)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial number %q", r.SerialNumber)
+		}
+		list.RevokedCertificateEntries = append(list.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *r.RevokedAt,
+			ReasonCode:     r.RevocationReason,
+		})
+	}
+
+	nextUpdate := time.Now().Add(a.cfg.CRLValidity)
+	crl, _, err := cert.CreateCRL(a.key, a.cert, list, nextUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	a.crl, a.crlNextUpdate = crl.Byte, nextUpdate
+	return a.crl, nil
+}
+
+// parseSerial parses a lowercase hex serial number as stored in the repository
+func parseSerial(serial string) (*big.Int, bool) {
+	return new(big.Int).SetString(serial, 16)
+}
@@ -0,0 +1,351 @@
+package ca
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/security/cert"
+)
+
+type memRepo struct {
+	mu    sync.Mutex
+	certs []*models.IssuedCertificate
+}
+
+func (r *memRepo) Create(_ context.Context, c *models.IssuedCertificate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.ID = c.SerialNumber
+	c.CreatedAt = time.Now()
+	r.certs = append(r.certs, c)
+	return nil
+}
+
+func (r *memRepo) GetBySerial(_ context.Context, serial string) (*models.IssuedCertificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.certs {
+		if c.SerialNumber == serial {
+			return c, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *memRepo) List(_ context.Context, organizationID, accountID string) ([]*models.IssuedCertificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*models.IssuedCertificate
+	for _, c := range r.certs {
+		if (Scope{organizationID, accountID}).Owns(c) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *memRepo) ListRevoked(_ context.Context) ([]*models.IssuedCertificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*models.IssuedCertificate
+	for _, c := range r.certs {
+		if c.Revoked() {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *memRepo) Revoke(_ context.Context, organizationID, accountID, serial string, reason int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.certs {
+		if c.SerialNumber == serial && !c.Revoked() && (Scope{organizationID, accountID}).Owns(c) {
+			c.RevokedAt = &at
+			c.RevocationReason = reason
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+func newTestAuthority(t *testing.T) *Authority {
+	t.Helper()
+	return newAuthority(t, "", Config{BaseURL: "https://sign.example.com"})
+}
+
+// newAuthority creates an authority with a freshly generated CA of keyType
+func newAuthority(t *testing.T, keyType cert.KeyType, cfg Config) *Authority {
+	t.Helper()
+	certPEM, keyPEM, err := Generate("", keyType)
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	cfg.Certificate, cfg.Key = certPEM, keyPEM
+	a, err := New(&memRepo{}, cfg)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return a
+}
+
+func TestIssueSigner(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+	scope := Scope{OrganizationID: "org-1"}
+
+	issued, err := a.IssueSigner(ctx, "Jane Signer", "jane@example.com", scope, "")
+	if err != nil {
+		t.Fatalf("IssueSigner() error: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(issued.Chain[0])
+	if _, err := issued.Certificate.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}); err != nil {
+		t.Fatalf("issued certificate does not chain to CA: %v", err)
+	}
+
+	if got := issued.Certificate.CRLDistributionPoints; len(got) != 1 || got[0] != "https://sign.example.com/ca/crl" {
+		t.Fatalf("CRLDistributionPoints = %v", got)
+	}
+	if lifetime := issued.Certificate.NotAfter.Sub(time.Now()); lifetime > 25*time.Hour {
+		t.Fatalf("certificate should be short-lived, got %v", lifetime)
+	}
+	if !issued.PrivateKey.Public().(*ecdsa.PublicKey).Equal(issued.Certificate.PublicKey) {
+		t.Fatal("private key does not match certificate")
+	}
+
+	list, err := a.List(ctx, scope)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List() = %v, %v", list, err)
+	}
+}
+
+func TestIssueFromCSR(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:        pkix.Name{CommonName: "Chief Executive"},
+		EmailAddresses: []string{"ceo@example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+
+	issued, err := a.IssueFromCSR(ctx, csrPEM, "CSR Signer", "signer@example.com", time.Hour, Scope{AccountID: "acc-1"})
+	if err != nil {
+		t.Fatalf("IssueFromCSR() error: %v", err)
+	}
+	// The certificate names the account holder, not what the CSR claims
+	if issued.Certificate.Subject.CommonName != "CSR Signer" {
+		t.Fatalf("subject = %s", issued.Certificate.Subject)
+	}
+	if got := issued.Certificate.EmailAddresses; len(got) != 1 || got[0] != "signer@example.com" {
+		t.Fatalf("email addresses = %v", got)
+	}
+	if !key.PublicKey.Equal(issued.Certificate.PublicKey) {
+		t.Fatal("certificate is not for the CSR key")
+	}
+	if issued.PrivateKey != nil {
+		t.Fatal("CSR issuance must not return a private key")
+	}
+
+	if _, err := a.IssueFromCSR(ctx, []byte("garbage"), "CSR Signer", "", 0, Scope{AccountID: "acc-1"}); !errors.Is(err, ErrInvalidCSR) {
+		t.Fatalf("err = %v, want ErrInvalidCSR", err)
+	}
+	if _, err := a.IssueFromCSR(ctx, csrPEM, "CSR Signer", "", 30*24*time.Hour, Scope{AccountID: "acc-1"}); !errors.Is(err, ErrInvalidValidity) {
+		t.Fatalf("err = %v, want ErrInvalidValidity", err)
+	}
+}
+
+func TestAuthorityKeyTypes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		caKey, signerKey cert.KeyType
+	}{
+		{cert.KeyTypeRSA2048, ""},
+		{cert.KeyTypeECDSAP384, cert.KeyTypeEd25519},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.caKey), func(t *testing.T) {
+			a := newAuthority(t, tt.caKey, Config{SignerKeyType: tt.signerKey})
+
+			issued, err := a.IssueSigner(ctx, "Jane Signer", "", Scope{OrganizationID: "org-1"}, "")
+			if err != nil {
+				t.Fatalf("IssueSigner() error: %v", err)
+			}
+			caCert := a.Certificate()
+			if err := issued.Certificate.CheckSignatureFrom(caCert); err != nil {
+				t.Fatalf("certificate not issued by the CA: %v", err)
+			}
+			signerKey := tt.signerKey
+			if signerKey == "" {
+				signerKey = tt.caKey
+			}
+			want, err := cert.GeneratePrivateKey(signerKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cert.KeyAlgorithm(issued.PrivateKey.Public()); got != cert.KeyAlgorithm(want.PrivateKey.Public()) {
+				t.Fatalf("signer key %s, want %s", got, cert.KeyAlgorithm(want.PrivateKey.Public()))
+			}
+
+			// CSR with a key of another type
+			key, err := cert.GeneratePrivateKey(cert.KeyTypeECDSAP256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, err := (&cert.CertificateRequest{Subject: pkix.Name{CommonName: "CSR Signer"}}).GetCSR(key.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.IssueFromCSR(ctx, []byte(csr.String()), "Jane Signer", "", time.Hour, Scope{OrganizationID: "org-1"}); err != nil {
+				t.Fatalf("IssueFromCSR() error: %v", err)
+			}
+
+			crl, err := a.CRL(ctx)
+			if err != nil {
+				t.Fatalf("CRL() error: %v", err)
+			}
+			list, err := x509.ParseRevocationList(crl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := list.CheckSignatureFrom(caCert); err != nil {
+				t.Fatalf("CRL signature: %v", err)
+			}
+		})
+	}
+
+	if _, _, err := Generate("", cert.KeyTypeEd25519); !errors.Is(err, ErrUnsupportedCAKeyType) {
+		t.Fatalf("err = %v, want ErrUnsupportedCAKeyType", err)
+	}
+}
+
+func TestRevokeAndCRL(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+	scope := Scope{AccountID: "acc-1"}
+
+	issued, err := a.IssueSigner(ctx, "Revoked Signer", "", scope, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := a.IssueSigner(ctx, "Kept Signer", "", scope, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlDER, err := a.CRL(ctx)
+	if err != nil {
+		t.Fatalf("CRL() error: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crl.RevokedCertificateEntries) != 0 {
+		t.Fatalf("expected empty CRL, got %d entries", len(crl.RevokedCertificateEntries))
+	}
+
+	// Another scope cannot revoke the certificate
+	if err := a.Revoke(ctx, Scope{AccountID: "acc-2"}, issued.Record.SerialNumber, 1); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("foreign revoke err = %v, want pgx.ErrNoRows", err)
+	}
+	if err := a.Revoke(ctx, scope, issued.Record.SerialNumber, 1); err != nil {
+		t.Fatalf("Revoke() error: %v", err)
+	}
+
+	crlDER, err = a.CRL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err = x509.ParseRevocationList(crlDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(issued.Chain[0]); err != nil {
+		t.Fatalf("CRL signature: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("CRL entries = %d, want 1", len(crl.RevokedCertificateEntries))
+	}
+	entry := crl.RevokedCertificateEntries[0]
+	if entry.SerialNumber.Cmp(issued.Certificate.SerialNumber) != 0 {
+		t.Fatal("revoked serial missing from CRL")
+	}
+	if entry.SerialNumber.Cmp(kept.Certificate.SerialNumber) == 0 {
+		t.Fatal("unrevoked serial listed in CRL")
+	}
+	if entry.ReasonCode != 1 {
+		t.Fatalf("reason = %d, want 1", entry.ReasonCode)
+	}
+}
+
+func TestNew(t *testing.T) {
+	certPEM, keyPEM, err := Generate("Test CA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKeyPEM, err := Generate("Other CA", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := cert.GeneratePrivateKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := (&cert.Certificate{
+		Subject:   pkix.Name{CommonName: "Not a CA"},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}).GetCertificate(signer.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cert, key []byte
+		wantErr   error
+	}{
+		{"not configured", nil, nil, ErrNotConfigured},
+		{"missing key", certPEM, nil, ErrNotConfigured},
+		{"key of another CA", certPEM, otherKeyPEM, nil},
+		{"not a CA", []byte(leaf.String()), []byte(signer.String()), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&memRepo{}, Config{Certificate: tt.cert, Key: tt.key})
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("New() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	a, err := New(&memRepo{}, Config{Certificate: certPEM, Key: keyPEM})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if crt := a.Certificate(); !crt.IsCA || crt.Subject.CommonName != "Test CA" {
+		t.Fatalf("CA certificate = %s", crt.Subject)
+	}
+}
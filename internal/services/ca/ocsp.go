@@ -0,0 +1,124 @@
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspValidity is the nextUpdate distance of OCSP responses
+const ocspValidity = time.Hour
+
+// Respond answers a DER encoded RFC 6960 OCSP request for certificates of this CA.
+// Protocol level failures are returned as OCSP error responses; err is only set
+// for internal failures, together with an internalError response.
+func (a *Authority) Respond(ctx context.Context, raw []byte) ([]byte, error) {
+	req, err := ocsp.ParseRequest(raw)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+
+	caCert := a.cert
+	if !issuerMatches(req, caCert) {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	resp, err := a.response(ctx, caCert, req.SerialNumber.Text(16))
+	if err != nil {
+		return ocsp.InternalErrorErrorResponse, err
+	}
+	return resp, nil
+}
+
+// OCSPFor implements sign.LocalRevocationSource
+func (a *Authority) OCSPFor(crt, issuer *x509.Certificate) ([]byte, bool, error) {
+	caCert, ok := a.issued(crt)
+	if !ok {
+		return nil, false, nil
+	}
+	resp, err := a.response(context.Background(), caCert, crt.SerialNumber.Text(16))
+	return resp, true, err
+}
+
+// CRLFor implements sign.LocalRevocationSource
+func (a *Authority) CRLFor(crt *x509.Certificate) ([]byte, bool, error) {
+	if _, ok := a.issued(crt); !ok {
+		return nil, false, nil
+	}
+	crl, err := a.CRL(context.Background())
+	return crl, true, err
+}
+
+// issued reports whether crt was signed by this CA
+func (a *Authority) issued(crt *x509.Certificate) (*x509.Certificate, bool) {
+	caCert := a.cert
+	if !bytes.Equal(crt.RawIssuer, caCert.RawSubject) {
+		return nil, false
+	}
+	if crt.CheckSignatureFrom(caCert) != nil {
+		return nil, false
+	}
+	return caCert, true
+}
+
+func (a *Authority) response(ctx context.Context, caCert *x509.Certificate, serial string) ([]byte, error) {
+	now := time.Now()
+	tmpl := ocsp.Response{
+		Status:     ocsp.Unknown,
+		ThisUpdate: now,
+		NextUpdate: now.Add(ocspValidity),
+	}
+
+	record, err := a.repo.GetBySerial(ctx, serial)
+	switch {
+	case err == nil:
+		tmpl.Status = ocsp.Good
+		if record.Revoked() {
+			tmpl.Status = ocsp.Revoked
+			tmpl.RevokedAt = *record.RevokedAt
+			tmpl.RevocationReason = record.RevocationReason
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	var ok bool
+	if tmpl.SerialNumber, ok = parseSerial(serial); !ok {
+		return nil, errors.New("invalid serial number")
+	}
+
+	// The CA signs responses itself, so no responder certificate is embedded
+	return ocsp.CreateResponse(caCert, caCert, tmpl, a.key)
+}
+
+// issuerMatches compares the request's issuer hashes against the CA certificate
+func issuerMatches(req *ocsp.Request, caCert *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(caCert.RawSubject)
+	nameHash := h.Sum(nil)
+
+	return bytes.Equal(keyHash, req.IssuerKeyHash) && bytes.Equal(nameHash, req.IssuerNameHash)
+}
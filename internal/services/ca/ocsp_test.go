@@ -0,0 +1,125 @@
+package ca
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+)
+
+func TestRespond(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+	scope := Scope{AccountID: "acc-1"}
+
+	good, err := a.IssueSigner(ctx, "Good", "", scope, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := a.IssueSigner(ctx, "Revoked", "", scope, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Revoke(ctx, scope, revoked.Record.SerialNumber, ocsp.KeyCompromise); err != nil {
+		t.Fatal(err)
+	}
+	if got := good.Certificate.OCSPServer; len(got) != 1 || got[0] != "https://sign.example.com/ca/ocsp" {
+		t.Fatalf("OCSPServer = %v", got)
+	}
+
+	issuer := good.Chain[0]
+	query := func(t *testing.T, issued *Issued) *ocsp.Response {
+		t.Helper()
+		req, err := ocsp.CreateRequest(issued.Certificate, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := a.Respond(ctx, req)
+		if err != nil {
+			t.Fatalf("Respond() error: %v", err)
+		}
+		resp, err := ocsp.ParseResponseForCert(raw, issued.Certificate, issuer)
+		if err != nil {
+			t.Fatalf("ParseResponse: %v", err)
+		}
+		return resp
+	}
+
+	if resp := query(t, good); resp.Status != ocsp.Good {
+		t.Fatalf("status = %d, want Good", resp.Status)
+	}
+	resp := query(t, revoked)
+	if resp.Status != ocsp.Revoked || resp.RevocationReason != ocsp.KeyCompromise {
+		t.Fatalf("status = %d reason = %d, want Revoked/KeyCompromise", resp.Status, resp.RevocationReason)
+	}
+
+	t.Run("foreign issuer is unauthorized", func(t *testing.T) {
+		other := newTestAuthority(t)
+		foreign, err := other.IssueSigner(ctx, "Foreign", "", scope, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := ocsp.CreateRequest(foreign.Certificate, foreign.Chain[0], nil)
+		raw, err := a.Respond(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ocsp.ParseResponse(raw, nil); !isResponseError(err, ocsp.Unauthorized) {
+			t.Fatalf("err = %v, want unauthorized", err)
+		}
+	})
+
+	t.Run("malformed request", func(t *testing.T) {
+		raw, err := a.Respond(ctx, []byte("junk"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ocsp.ParseResponse(raw, nil); !isResponseError(err, ocsp.Malformed) {
+			t.Fatalf("err = %v, want malformed", err)
+		}
+	})
+}
+
+func TestLocalRevocationSource(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+
+	issued, err := a.IssueSigner(ctx, "Embedded", "", Scope{AccountID: "acc-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign.RegisterLocalRevocationSource(a)
+
+	// BaseURL is not reachable: both entries must come from the in-process source.
+	var info revocation.InfoArchival
+	if err := sign.DefaultEmbedRevocationStatusFunction(issued.Certificate, issued.Chain[0], &info); err != nil {
+		t.Fatalf("DefaultEmbedRevocationStatusFunction() error: %v", err)
+	}
+	if len(info.OCSP) != 1 || len(info.CRL) != 1 {
+		t.Fatalf("embedded OCSP=%d CRL=%d, want 1/1", len(info.OCSP), len(info.CRL))
+	}
+	resp, err := ocsp.ParseResponseForCert(info.OCSP[0].FullBytes, issued.Certificate, issued.Chain[0])
+	if err != nil || resp.Status != ocsp.Good {
+		t.Fatalf("embedded OCSP response = %v, %v", resp, err)
+	}
+
+	// Certificates from other issuers are not answered locally
+	other := newTestAuthority(t)
+	foreign, err := other.IssueSigner(ctx, "Foreign", "", Scope{AccountID: "acc-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := a.OCSPFor(foreign.Certificate, foreign.Chain[0]); ok {
+		t.Fatal("foreign certificate must not be answered")
+	}
+}
+
+func isResponseError(err error, status ocsp.ResponseStatus) bool {
+	var respErr ocsp.ResponseError
+	return errors.As(err, &respErr) && respErr.Status == status
+}
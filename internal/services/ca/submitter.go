@@ -0,0 +1,18 @@
+package ca
+
+import (
+	"context"
+
+	"github.com/shurco/gosign/internal/services"
+)
+
+// SubmitterKey implements services.SubmitterKeyProvider: every call issues a
+// fresh short-lived certificate, so the key is only used for one signature.
+func (a *Authority) SubmitterKey(ctx context.Context, submitter services.SubmitterIdentity) (*services.SealingKey, error) {
+	scope := Scope{OrganizationID: submitter.OrganizationID, AccountID: submitter.AccountID}
+	issued, err := a.IssueSigner(ctx, submitter.Name, submitter.Email, scope, submitter.SubmitterID)
+	if err != nil {
+		return nil, err
+	}
+	return &services.SealingKey{Signer: issued.PrivateKey, Certificate: issued.Certificate, Chain: issued.Chain}, nil
+}
@@ -0,0 +1,47 @@
+package ca
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shurco/gosign/internal/services"
+)
+
+func TestSubmitterKey(t *testing.T) {
+	a := newTestAuthority(t)
+	ctx := context.Background()
+
+	key, err := a.SubmitterKey(ctx, services.SubmitterIdentity{
+		SubmissionID:   "sub-1",
+		SubmitterID:    "submitter-1",
+		Name:           "Jane Signer",
+		Email:          "jane@example.com",
+		OrganizationID: "org-1",
+	})
+	if err != nil {
+		t.Fatalf("SubmitterKey() error: %v", err)
+	}
+	if key.Certificate.Subject.CommonName != "Jane Signer" || len(key.Chain) != 1 {
+		t.Fatalf("unexpected key: %s, chain %d", key.Certificate.Subject, len(key.Chain))
+	}
+	if err := key.Certificate.CheckSignatureFrom(key.Chain[0]); err != nil {
+		t.Fatalf("certificate not issued by the CA: %v", err)
+	}
+
+	list, err := a.List(ctx, Scope{OrganizationID: "org-1"})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List() = %v, %v", list, err)
+	}
+	if list[0].SubmitterID == nil || *list[0].SubmitterID != "submitter-1" {
+		t.Fatalf("issued certificate not linked to submitter: %v", list[0].SubmitterID)
+	}
+
+	// Every signature gets its own key
+	other, err := a.SubmitterKey(ctx, services.SubmitterIdentity{SubmitterID: "submitter-1", Name: "Jane Signer", OrganizationID: "org-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Certificate.SerialNumber.Cmp(key.Certificate.SerialNumber) == 0 {
+		t.Fatal("expected a new certificate per call")
+	}
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/security/cert"
+	"github.com/shurco/gosign/pkg/security/keystore"
+	"github.com/shurco/gosign/pkg/security/secret"
+)
+
+// CertificateRepository defines interface for signing certificate storage operations
+type CertificateRepository interface {
+	Create(ctx context.Context, organizationID, accountID string, c *models.SigningCertificate) error
+	Get(ctx context.Context, organizationID, accountID, id string) (*models.SigningCertificate, error)
+	GetByID(ctx context.Context, id string) (*models.SigningCertificate, error)
+	GetDefault(ctx context.Context, organizationID, accountID string) (*models.SigningCertificate, error)
+	List(ctx context.Context, organizationID, accountID string) ([]*models.SigningCertificate, error)
+	SetDefault(ctx context.Context, organizationID, accountID, id string) error
+	Delete(ctx context.Context, organizationID, accountID, id string) error
+	SubmissionScope(ctx context.Context, submissionID string) (string, string, error)
+}
+
+// CertificateScope identifies the owner of signing certificates.
+// OrganizationID takes precedence; AccountID is used outside an organization context.
+type CertificateScope struct {
+	OrganizationID string
+	AccountID      string
+}
+
+// GenerateCertificateRequest describes a self-signed certificate to create
+type GenerateCertificateRequest struct {
+	Name         string
+	CommonName   string
+	Organization string
+	Email        string
+	ValidDays    int
+	IsDefault    bool
+	Usage        models.CertificateUsage // defaults to signing
+	KeyType      cert.KeyType            // defaults to ECDSA P-256
+	RSAPSS       bool                    // self-sign with RSASSA-PSS, which also makes the key sign with it
+}
+
+// CertificateService manages the signing certificate store and resolves signing keys.
+// Private keys are kept encrypted at rest with cipher; keys of external
+// certificates stay in their key backend and are opened through the backends
+// keys configures for the owner of the certificate.
+type CertificateService struct {
+	repo     CertificateRepository
+	cipher   *secret.Cipher
+	fallback SealingKeyProvider
+	keys     *keystore.Policies
+}
+
+// NewCertificateService creates new certificate service.
+// fallback is used when the owning scope has no default certificate; it may be nil.
+// keys lists the external keys of each owner; without it only stored keys can be used.
+func NewCertificateService(repo CertificateRepository, cipher *secret.Cipher, fallback SealingKeyProvider, keys *keystore.Policies) *CertificateService {
+	return &CertificateService{repo: repo, cipher: cipher, fallback: fallback, keys: keys}
+}
+
+// ErrNoSigningCertificate is returned when no certificate is available for a scope
+var ErrNoSigningCertificate = errors.New("no signing certificate configured")
+
+// ErrNotTimestampingCertificate is returned when a TSA is configured with a non-timestamping certificate
+var ErrNotTimestampingCertificate = errors.New("certificate is not a timestamping certificate")
+
+// ErrPSSRequiresRSA is returned when RSASSA-PSS is requested for a non-RSA key
+var ErrPSSRequiresRSA = errors.New("RSASSA-PSS requires an RSA key")
+
+// ErrKeyBackendsNotConfigured is returned when an external key is used by an owner without key backends
+var ErrKeyBackendsNotConfigured = errors.New("key backends not configured")
+
+// ErrExternalKey is returned when an external key can't be opened or doesn't sign for its certificate
+var ErrExternalKey = errors.New("external key unusable")
+
+// oidExtKeyUsage is the X.509 extended key usage extension
+var oidExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+// oidTimeStamping is the id-kp-timeStamping key purpose
+var oidTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+
+// Import stores an uploaded certificate bundle
+func (s *CertificateService) Import(ctx context.Context, scope CertificateScope, name string, b *cert.Bundle, isDefault bool) (*models.SigningCertificate, error) {
+	return s.store(ctx, scope, name, b, "", models.CertificateSourceUploaded, isDefault)
+}
+
+// ImportExternal stores a certificate whose key stays in a key backend, e.g. a
+// PKCS#11 token or a remote signing service, addressed by keyURI. certs holds
+// the certificate of the key first, then its issuer chain. Only keys configured
+// for the scope open, and the key is probed with a test signature, so
+// unreachable or mismatching keys are rejected.
+func (s *CertificateService) ImportExternal(ctx context.Context, scope CertificateScope, name, keyURI string, certs []*x509.Certificate, isDefault bool) (*models.SigningCertificate, error) {
+	keys := s.keys.Registry(scope.OrganizationID, scope.AccountID)
+	if keys == nil {
+		return nil, fmt.Errorf("%w: %w", ErrExternalKey, ErrKeyBackendsNotConfigured)
+	}
+	if len(certs) == 0 {
+		return nil, cert.ErrNoCertificate
+	}
+
+	signer, err := keys.Signer(ctx, keyURI, certs[0].PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExternalKey, err)
+	}
+	if err := keystore.Probe(signer); err != nil {
+		return nil, fmt.Errorf("%w: test signature failed: %w", ErrExternalKey, err)
+	}
+
+	b := &cert.Bundle{PrivateKey: signer, Certificate: certs[0], Chain: certs[1:]}
+	return s.store(ctx, scope, name, b, keyURI, models.CertificateSourceExternal, isDefault)
+}
+
+// Generate creates and stores a self-signed certificate
+func (s *CertificateService) Generate(ctx context.Context, scope CertificateScope, req GenerateCertificateRequest) (*models.SigningCertificate, error) {
+	if req.CommonName == "" {
+		return nil, errors.New("common name is required")
+	}
+	if req.ValidDays <= 0 {
+		req.ValidDays = 365
+	}
+	if req.Name == "" {
+		req.Name = req.CommonName
+	}
+
+	pkey, err := cert.GeneratePrivateKey(req.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	if _, isRSA := pkey.PrivateKey.Public().(*rsa.PublicKey); req.RSAPSS && !isRSA {
+		return nil, ErrPSSRequiresRSA
+	}
+
+	subject := pkix.Name{CommonName: req.CommonName}
+	if req.Organization != "" {
+		subject.Organization = []string{req.Organization}
+	}
+
+	now := time.Now()
+	c := &cert.Certificate{
+		Subject:          subject,
+		NotBefore:        now.Add(-time.Hour),
+		NotAfter:         now.AddDate(0, 0, req.ValidDays),
+		KeyUsage:         x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtentedKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	if req.RSAPSS {
+		c.SignatureAlgorithm = x509.SHA256WithRSAPSS
+	}
+	if req.Email != "" {
+		c.EmailAddresses = []string{req.Email}
+	}
+	if req.Usage == models.CertificateUsageTimestamping {
+		// RFC 3161 2.3: timeStamping must be the only extended key usage and the extension critical
+		ext, err := asn1.Marshal([]asn1.ObjectIdentifier{oidTimeStamping})
+		if err != nil {
+			return nil, err
+		}
+		c.ExtentedKeyUsage = nil
+		c.ExtraExtensions = []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: ext}}
+	}
+
+	res, err := c.GetCertificate(pkey.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := x509.ParseCertificate(res.ByteCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store(ctx, scope, req.Name, &cert.Bundle{PrivateKey: pkey.PrivateKey, Certificate: crt}, "", models.CertificateSourceGenerated, req.IsDefault)
+}
+
+// store saves the bundle; with a keyURI the key stays in its backend and isn't stored
+func (s *CertificateService) store(ctx context.Context, scope CertificateScope, name string, b *cert.Bundle, keyURI string, source models.CertificateSource, isDefault bool) (*models.SigningCertificate, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	encrypted := []byte{}
+	if keyURI == "" {
+		der, err := cert.MarshalPrivateKey(b.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode private key: %w", err)
+		}
+		if encrypted, err = s.cipher.Encrypt(der); err != nil {
+			return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+	}
+
+	if name == "" {
+		name = b.Certificate.Subject.CommonName
+	}
+
+	// Timestamping certificates only back the TSA and never sign documents
+	usage := certificateUsage(b.Certificate)
+	if usage == models.CertificateUsageTimestamping {
+		isDefault = false
+	} else if !isDefault {
+		// The first certificate of a scope becomes its default
+		if _, err := s.repo.GetDefault(ctx, scope.OrganizationID, scope.AccountID); errors.Is(err, pgx.ErrNoRows) {
+			isDefault = true
+		}
+	}
+
+	c := &models.SigningCertificate{
+		Name:           name,
+		Subject:        b.Certificate.Subject.String(),
+		Issuer:         b.Certificate.Issuer.String(),
+		SerialNumber:   b.Certificate.SerialNumber.Text(16),
+		KeyAlgorithm:   cert.KeyAlgorithm(b.Certificate.PublicKey),
+		NotBefore:      b.Certificate.NotBefore,
+		NotAfter:       b.Certificate.NotAfter,
+		CertificatePEM: b.CertificatePEM(),
+		ChainPEM:       b.ChainPEM(),
+		PrivateKey:     encrypted,
+		KeyURI:         keyURI,
+		Source:         source,
+		Usage:          usage,
+		IsDefault:      isDefault,
+	}
+	if err := s.repo.Create(ctx, scope.OrganizationID, scope.AccountID, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// List returns all certificates of the scope
+func (s *CertificateService) List(ctx context.Context, scope CertificateScope) ([]*models.SigningCertificate, error) {
+	return s.repo.List(ctx, scope.OrganizationID, scope.AccountID)
+}
+
+// Get returns a certificate of the scope
+func (s *CertificateService) Get(ctx context.Context, scope CertificateScope, id string) (*models.SigningCertificate, error) {
+	return s.repo.Get(ctx, scope.OrganizationID, scope.AccountID, id)
+}
+
+// SetDefault makes the certificate the default of the scope
+func (s *CertificateService) SetDefault(ctx context.Context, scope CertificateScope, id string) error {
+	return s.repo.SetDefault(ctx, scope.OrganizationID, scope.AccountID, id)
+}
+
+// Delete removes a certificate of the scope
+func (s *CertificateService) Delete(ctx context.Context, scope CertificateScope, id string) error {
+	return s.repo.Delete(ctx, scope.OrganizationID, scope.AccountID, id)
+}
+
+// Resolve returns the signing key for the scope: its default certificate, or
+// the platform fallback key when the scope has none.
+func (s *CertificateService) Resolve(ctx context.Context, scope CertificateScope) (*SealingKey, error) {
+	if scope.OrganizationID != "" || scope.AccountID != "" {
+		c, err := s.repo.GetDefault(ctx, scope.OrganizationID, scope.AccountID)
+		switch {
+		case err == nil:
+			return s.decode(ctx, c)
+		case !errors.Is(err, pgx.ErrNoRows):
+			return nil, err
+		}
+	}
+
+	if s.fallback == nil {
+		return nil, ErrNoSigningCertificate
+	}
+	return s.fallback.SealingKey(ctx, "")
+}
+
+// Default returns the signing key of the scope's default certificate. Unlike
+// Resolve it never falls back to the platform key, which only seals documents
+// completed on the platform, so it serves signing on behalf of callers.
+func (s *CertificateService) Default(ctx context.Context, scope CertificateScope) (*SealingKey, error) {
+	if scope.OrganizationID == "" && scope.AccountID == "" {
+		return nil, ErrNoSigningCertificate
+	}
+	c, err := s.repo.GetDefault(ctx, scope.OrganizationID, scope.AccountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoSigningCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(ctx, c)
+}
+
+// SealingKey implements SealingKeyProvider using the submission's organization
+// default, then the creator account's default, then the platform key.
+func (s *CertificateService) SealingKey(ctx context.Context, submissionID string) (*SealingKey, error) {
+	organizationID, accountID, err := s.repo.SubmissionScope(ctx, submissionID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if organizationID != "" {
+		c, err := s.repo.GetDefault(ctx, organizationID, "")
+		if err == nil {
+			return s.decode(ctx, c)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return s.Resolve(ctx, CertificateScope{AccountID: accountID})
+}
+
+// TimestampingKey returns the key of the timestamping certificate with the given ID,
+// regardless of its owning scope. It backs the built-in TSA.
+func (s *CertificateService) TimestampingKey(ctx context.Context, id string) (*SealingKey, error) {
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Usage != models.CertificateUsageTimestamping {
+		return nil, ErrNotTimestampingCertificate
+	}
+	return s.decode(ctx, c)
+}
+
+// certificateUsage classifies a certificate by its extended key usage
+func certificateUsage(crt *x509.Certificate) models.CertificateUsage {
+	if len(crt.ExtKeyUsage) == 1 && crt.ExtKeyUsage[0] == x509.ExtKeyUsageTimeStamping {
+		return models.CertificateUsageTimestamping
+	}
+	return models.CertificateUsageSigning
+}
+
+func (s *CertificateService) decode(ctx context.Context, c *models.SigningCertificate) (*SealingKey, error) {
+	crt, err := cert.ParseCertificate([]byte(c.CertificatePEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", c.ID, err)
+	}
+
+	signer, err := s.signer(ctx, c, crt)
+	if err != nil {
+		return nil, err
+	}
+	// RSA keys of PSS-signed certificates sign with RSASSA-PSS as well
+	_, isRSA := signer.Public().(*rsa.PublicKey)
+	key := &SealingKey{Signer: signer, Certificate: crt, RSAPSS: isRSA && cert.IsPSS(crt.SignatureAlgorithm)}
+	if c.ChainPEM != "" {
+		if key.Chain, err = cert.ParseCertificates([]byte(c.ChainPEM)); err != nil {
+			return nil, fmt.Errorf("failed to parse chain of certificate %s: %w", c.ID, err)
+		}
+	}
+	return key, nil
+}
+
+// signer opens the key of a certificate: from its key backend for external
+// certificates, else by decrypting the stored key
+func (s *CertificateService) signer(ctx context.Context, c *models.SigningCertificate, crt *x509.Certificate) (crypto.Signer, error) {
+	if c.KeyURI != "" {
+		var organizationID, accountID string
+		if c.OrganizationID != nil {
+			organizationID = *c.OrganizationID
+		}
+		if c.AccountID != nil {
+			accountID = *c.AccountID
+		}
+		keys := s.keys.Registry(organizationID, accountID)
+		if keys == nil {
+			return nil, fmt.Errorf("failed to open key of certificate %s: %w", c.ID, ErrKeyBackendsNotConfigured)
+		}
+		signer, err := keys.Signer(ctx, c.KeyURI, crt.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key of certificate %s: %w", c.ID, err)
+		}
+		return signer, nil
+	}
+
+	der, err := s.cipher.Decrypt(c.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key of certificate %s: %w", c.ID, err)
+	}
+	signer, err := cert.UnmarshalPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key of certificate %s: %w", c.ID, err)
+	}
+	return signer, nil
+}
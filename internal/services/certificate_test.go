@@ -0,0 +1,395 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/security/cert"
+	"github.com/shurco/gosign/pkg/security/keystore"
+	"github.com/shurco/gosign/pkg/security/secret"
+)
+
+// memCertificateRepo is an in-memory CertificateRepository keyed by "org|account" scope.
+type memCertificateRepo struct {
+	certs  map[string][]*models.SigningCertificate
+	scopes map[string][2]string
+	nextID int
+}
+
+func newMemCertificateRepo() *memCertificateRepo {
+	return &memCertificateRepo{
+		certs:  map[string][]*models.SigningCertificate{},
+		scopes: map[string][2]string{},
+	}
+}
+
+func scopeKey(organizationID, accountID string) string {
+	if organizationID != "" {
+		return organizationID + "|"
+	}
+	return "|" + accountID
+}
+
+func (r *memCertificateRepo) Create(_ context.Context, organizationID, accountID string, c *models.SigningCertificate) error {
+	key := scopeKey(organizationID, accountID)
+	if c.IsDefault {
+		for _, other := range r.certs[key] {
+			other.IsDefault = false
+		}
+	}
+	r.nextID++
+	c.ID = fmt.Sprintf("cert-%d", r.nextID)
+	if organizationID != "" {
+		c.OrganizationID = &organizationID
+	} else {
+		c.AccountID = &accountID
+	}
+	r.certs[key] = append(r.certs[key], c)
+	return nil
+}
+
+func (r *memCertificateRepo) Get(_ context.Context, organizationID, accountID, id string) (*models.SigningCertificate, error) {
+	for _, c := range r.certs[scopeKey(organizationID, accountID)] {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *memCertificateRepo) GetByID(_ context.Context, id string) (*models.SigningCertificate, error) {
+	for _, certs := range r.certs {
+		for _, c := range certs {
+			if c.ID == id {
+				return c, nil
+			}
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *memCertificateRepo) GetDefault(_ context.Context, organizationID, accountID string) (*models.SigningCertificate, error) {
+	for _, c := range r.certs[scopeKey(organizationID, accountID)] {
+		if c.IsDefault {
+			return c, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *memCertificateRepo) List(_ context.Context, organizationID, accountID string) ([]*models.SigningCertificate, error) {
+	return r.certs[scopeKey(organizationID, accountID)], nil
+}
+
+func (r *memCertificateRepo) SetDefault(_ context.Context, organizationID, accountID, id string) error {
+	found := false
+	for _, c := range r.certs[scopeKey(organizationID, accountID)] {
+		c.IsDefault = c.ID == id
+		found = found || c.IsDefault
+	}
+	if !found {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *memCertificateRepo) Delete(_ context.Context, organizationID, accountID, id string) error {
+	key := scopeKey(organizationID, accountID)
+	for i, c := range r.certs[key] {
+		if c.ID == id {
+			r.certs[key] = append(r.certs[key][:i], r.certs[key][i+1:]...)
+			return nil
+		}
+	}
+	return pgx.ErrNoRows
+}
+
+func (r *memCertificateRepo) SubmissionScope(_ context.Context, submissionID string) (string, string, error) {
+	s, ok := r.scopes[submissionID]
+	if !ok {
+		return "", "", pgx.ErrNoRows
+	}
+	return s[0], s[1], nil
+}
+
+func newTestCertificateService(t *testing.T) (*CertificateService, *memCertificateRepo) {
+	t.Helper()
+	cipher, err := secret.NewCipher("test-encryption-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newMemCertificateRepo()
+	fallback := &FileSealingKeyProvider{Dir: t.TempDir(), Name: "Platform Seal"}
+	return NewCertificateService(repo, cipher, fallback, nil), repo
+}
+
+func TestCertificateServiceGenerate(t *testing.T) {
+	svc, _ := newTestCertificateService(t)
+	ctx := context.Background()
+	scope := CertificateScope{OrganizationID: "org-1"}
+
+	c, err := svc.Generate(ctx, scope, GenerateCertificateRequest{CommonName: "Acme Signing", Email: "sign@acme.test"})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if !c.IsDefault {
+		t.Fatal("first certificate of a scope should become the default")
+	}
+	if c.KeyAlgorithm != "ECDSA P-256" {
+		t.Fatalf("KeyAlgorithm = %s", c.KeyAlgorithm)
+	}
+
+	// The stored key must not be the plain PKCS#8 encoding.
+	key, err := svc.Resolve(ctx, scope)
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	der, err := cert.MarshalPrivateKey(key.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(c.PrivateKey, der) {
+		t.Fatal("private key is stored unencrypted")
+	}
+	if key.Certificate.Subject.CommonName != "Acme Signing" {
+		t.Fatalf("resolved certificate = %s", key.Certificate.Subject.CommonName)
+	}
+	if len(key.Certificate.EmailAddresses) != 1 {
+		t.Fatalf("expected email address in certificate, got %v", key.Certificate.EmailAddresses)
+	}
+}
+
+func TestCertificateServiceGenerateKeyTypes(t *testing.T) {
+	svc, _ := newTestCertificateService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		keyType   cert.KeyType
+		pss       bool
+		algorithm string
+	}{
+		{cert.KeyTypeECDSAP384, false, "ECDSA P-384"},
+		{cert.KeyTypeRSA2048, true, "RSA 2048"},
+		{cert.KeyTypeEd25519, false, "Ed25519"},
+	}
+	for _, tt := range tests {
+		scope := CertificateScope{OrganizationID: "org-" + string(tt.keyType)}
+		c, err := svc.Generate(ctx, scope, GenerateCertificateRequest{CommonName: "Acme", KeyType: tt.keyType, RSAPSS: tt.pss})
+		if err != nil {
+			t.Fatalf("Generate(%s) error: %v", tt.keyType, err)
+		}
+		if c.KeyAlgorithm != tt.algorithm {
+			t.Fatalf("KeyAlgorithm = %s, want %s", c.KeyAlgorithm, tt.algorithm)
+		}
+		key, err := svc.Resolve(ctx, scope)
+		if err != nil {
+			t.Fatalf("Resolve() error: %v", err)
+		}
+		if key.RSAPSS != tt.pss {
+			t.Fatalf("%s: RSAPSS = %v, want %v", tt.keyType, key.RSAPSS, tt.pss)
+		}
+	}
+
+	_, err := svc.Generate(ctx, CertificateScope{OrganizationID: "org-2"}, GenerateCertificateRequest{CommonName: "Acme", KeyType: cert.KeyTypeEd25519, RSAPSS: true})
+	if !errors.Is(err, ErrPSSRequiresRSA) {
+		t.Fatalf("Generate() error = %v, want %v", err, ErrPSSRequiresRSA)
+	}
+}
+
+func TestCertificateServiceTimestamping(t *testing.T) {
+	svc, _ := newTestCertificateService(t)
+	ctx := context.Background()
+	scope := CertificateScope{OrganizationID: "org-1"}
+
+	tsa, err := svc.Generate(ctx, scope, GenerateCertificateRequest{
+		CommonName: "Acme TSA",
+		IsDefault:  true,
+		Usage:      models.CertificateUsageTimestamping,
+	})
+	if err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	if tsa.Usage != models.CertificateUsageTimestamping || tsa.IsDefault {
+		t.Fatalf("usage = %s default = %v, want non-default timestamping", tsa.Usage, tsa.IsDefault)
+	}
+
+	key, err := svc.TimestampingKey(ctx, tsa.ID)
+	if err != nil {
+		t.Fatalf("TimestampingKey() error: %v", err)
+	}
+	if eku := key.Certificate.ExtKeyUsage; len(eku) != 1 || eku[0] != x509.ExtKeyUsageTimeStamping {
+		t.Fatalf("ExtKeyUsage = %v, want timeStamping only", eku)
+	}
+	critical := false
+	for _, ext := range key.Certificate.Extensions {
+		if ext.Id.Equal(oidExtKeyUsage) {
+			critical = ext.Critical
+		}
+	}
+	if !critical {
+		t.Fatal("extended key usage must be critical for a TSA certificate")
+	}
+
+	// A timestamping certificate never becomes the scope's signing default
+	signing, err := svc.Generate(ctx, scope, GenerateCertificateRequest{CommonName: "Acme Signing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signing.IsDefault || signing.Usage != models.CertificateUsageSigning {
+		t.Fatalf("signing certificate: usage = %s default = %v", signing.Usage, signing.IsDefault)
+	}
+	if _, err := svc.TimestampingKey(ctx, signing.ID); !errors.Is(err, ErrNotTimestampingCertificate) {
+		t.Fatalf("TimestampingKey(signing) error = %v, want ErrNotTimestampingCertificate", err)
+	}
+}
+
+func TestCertificateServiceResolve(t *testing.T) {
+	svc, repo := newTestCertificateService(t)
+	ctx := context.Background()
+
+	org := CertificateScope{OrganizationID: "org-1"}
+	account := CertificateScope{AccountID: "acc-1"}
+
+	if _, err := svc.Generate(ctx, org, GenerateCertificateRequest{CommonName: "Org A"}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Generate(ctx, org, GenerateCertificateRequest{CommonName: "Org B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SetDefault(ctx, org, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Generate(ctx, account, GenerateCertificateRequest{CommonName: "Account"}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo.scopes["sub-org"] = [2]string{"org-1", "acc-1"}
+	repo.scopes["sub-account"] = [2]string{"", "acc-1"}
+	repo.scopes["sub-none"] = [2]string{"org-2", "acc-2"}
+
+	tests := []struct {
+		submission string
+		want       string
+	}{
+		{"sub-org", "Org B"},
+		{"sub-account", "Account"},
+		{"sub-none", "Platform Seal"},
+		{"missing", "Platform Seal"},
+	}
+	for _, tt := range tests {
+		key, err := svc.SealingKey(ctx, tt.submission)
+		if err != nil {
+			t.Fatalf("SealingKey(%s) error: %v", tt.submission, err)
+		}
+		if got := key.Certificate.Subject.CommonName; got != tt.want {
+			t.Fatalf("SealingKey(%s) = %s, want %s", tt.submission, got, tt.want)
+		}
+	}
+}
+
+func TestCertificateServiceDefault(t *testing.T) {
+	svc, _ := newTestCertificateService(t)
+	ctx := context.Background()
+
+	org := CertificateScope{OrganizationID: "org-1"}
+	if _, err := svc.Generate(ctx, org, GenerateCertificateRequest{CommonName: "Org A"}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := svc.Default(ctx, org)
+	if err != nil || key.Certificate.Subject.CommonName != "Org A" {
+		t.Fatalf("Default(org) = %v, %v", key, err)
+	}
+
+	// The platform seal key is never handed out for signing on behalf of a caller
+	for _, scope := range []CertificateScope{{}, {AccountID: "acc-1"}} {
+		if _, err := svc.Default(ctx, scope); !errors.Is(err, ErrNoSigningCertificate) {
+			t.Fatalf("Default(%+v) error = %v, want %v", scope, err, ErrNoSigningCertificate)
+		}
+	}
+}
+
+func TestCertificateServiceExternalKey(t *testing.T) {
+	cipher, err := secret.NewCipher("test-encryption-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &keystore.Policies{}
+	svc := NewCertificateService(newMemCertificateRepo(), cipher, nil, keys)
+	ctx := context.Background()
+	scope := CertificateScope{OrganizationID: "org-1"}
+
+	pkey, err := cert.GeneratePrivateKey(cert.KeyTypeRSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := (&cert.Certificate{
+		Subject:            pkix.Name{CommonName: "HSM Seal"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		SignatureAlgorithm: x509.SHA256WithRSAPSS,
+	}).GetCertificate(pkey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(res.ByteCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key only lives behind the remote signing service
+	srv := httptest.NewTLSServer(&keystore.RemoteHandler{Signer: pkey.PrivateKey})
+	defer srv.Close()
+	other, _ := cert.GeneratePrivateKey(cert.KeyTypeRSA2048)
+	otherSrv := httptest.NewTLSServer(&keystore.RemoteHandler{Signer: other.PrivateKey})
+	defer otherSrv.Close()
+
+	if _, err := svc.ImportExternal(ctx, scope, "", srv.URL+"/keys/seal", []*x509.Certificate{crt}, true); !errors.Is(err, ErrExternalKey) || !errors.Is(err, ErrKeyBackendsNotConfigured) {
+		t.Fatalf("ImportExternal() without backend error = %v, want %v", err, ErrKeyBackendsNotConfigured)
+	}
+	keys.Client = srv.Client()
+	keys.Organizations = map[string]*keystore.Policy{
+		"org-1": {Remote: []keystore.RemoteEndpoint{{URL: srv.URL + "/keys/"}, {URL: otherSrv.URL}}},
+		"org-2": {Remote: []keystore.RemoteEndpoint{{URL: "https://signer.internal/"}}},
+	}
+
+	// Keys outside the endpoints configured for the organization never open
+	if _, err := svc.ImportExternal(ctx, CertificateScope{OrganizationID: "org-2"}, "", srv.URL+"/keys/seal", []*x509.Certificate{crt}, true); !errors.Is(err, keystore.ErrKeyNotAllowed) {
+		t.Fatalf("ImportExternal() of another organization's key error = %v, want %v", err, keystore.ErrKeyNotAllowed)
+	}
+
+	c, err := svc.ImportExternal(ctx, scope, "", srv.URL+"/keys/seal", []*x509.Certificate{crt}, true)
+	if err != nil {
+		t.Fatalf("ImportExternal() error: %v", err)
+	}
+	if c.Source != models.CertificateSourceExternal || len(c.PrivateKey) != 0 || c.KeyURI == "" {
+		t.Fatalf("source = %s, private key %d bytes, key URI %q", c.Source, len(c.PrivateKey), c.KeyURI)
+	}
+
+	key, err := svc.Resolve(ctx, scope)
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if !key.RSAPSS || key.Certificate.Subject.CommonName != "HSM Seal" {
+		t.Fatalf("Resolve() = %s, RSAPSS %v", key.Certificate.Subject.CommonName, key.RSAPSS)
+	}
+	if err := keystore.Probe(key.Signer); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	// A service holding a different key is rejected before anything is stored
+	if _, err := svc.ImportExternal(ctx, scope, "", otherSrv.URL, []*x509.Certificate{crt}, false); !errors.Is(err, keystore.ErrKeyMismatch) {
+		t.Fatalf("ImportExternal() with foreign key error = %v, want %v", err, keystore.ErrKeyMismatch)
+	}
+}
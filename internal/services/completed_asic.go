@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/security/asic"
+)
+
+// Paths of the files of an exported ASiC-E container
+const (
+	asicCompletedName   = "completed.pdf"
+	asicCertificateName = "certificate.pdf"
+	asicEvidenceName    = "evidence.json"
+	asicUploadsDir      = "uploads/"
+)
+
+var asicUnsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// EvidenceRecord is the JSON record describing a completed submission in its ASiC-E container.
+type EvidenceRecord struct {
+	SubmissionID string              `json:"submission_id"`
+	DocumentName string              `json:"document_name"`
+	CompletedAt  *time.Time          `json:"completed_at,omitempty"`
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Documents    []EvidenceDocument  `json:"documents"`
+	Submitters   []EvidenceSubmitter `json:"submitters"`
+	Events       []EvidenceEvent     `json:"events"`
+}
+
+// EvidenceDocument is a file bundled in the container.
+type EvidenceDocument struct {
+	Name        string `json:"name"`
+	MimeType    string `json:"mime_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	SubmitterID string `json:"submitter_id,omitempty"` // uploads only
+	FieldID     string `json:"field_id,omitempty"`
+}
+
+// EvidenceSubmitter is a signer of the submission.
+type EvidenceSubmitter struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	IP          string     `json:"ip,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// EvidenceEvent is an entry of the audit trail of the submission.
+type EvidenceEvent struct {
+	Type      string          `json:"type"`
+	IP        string          `json:"ip,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// BuildASiCE packs a completed submission into an ASiC-E container: the completed PDF,
+// the certificate PDF, the files uploaded by the signers and a JSON evidence record,
+// covered by a CAdES signature made with the sealing key.
+// It does NOT check completion; caller must ensure submission is completed.
+func (b *CompletedDocumentBuilder) BuildASiCE(ctx context.Context, submissionID string) ([]byte, error) {
+	if b.SealingKeys == nil {
+		return nil, fmt.Errorf("sealing key provider not configured")
+	}
+
+	completed, err := b.EnsureCompletedPDF(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := b.EnsureCertificatePDF(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.loadSubmissionData(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []asic.File{
+		{Name: asicCompletedName, MimeType: "application/pdf", Data: completed},
+		{Name: asicCertificateName, MimeType: "application/pdf", Data: certificate},
+	}
+	record := EvidenceRecord{
+		SubmissionID: submissionID,
+		DocumentName: data.tpl.Name,
+		CompletedAt:  data.completedAtMax,
+		GeneratedAt:  time.Now().UTC(),
+		Submitters:   make([]EvidenceSubmitter, 0, len(data.submitters)),
+	}
+	record.Documents = append(record.Documents, evidenceDocument(files[0], "", ""), evidenceDocument(files[1], "", ""))
+
+	for _, s := range data.submitters {
+		record.Submitters = append(record.Submitters, EvidenceSubmitter{
+			ID:          s.id,
+			Name:        s.name,
+			Email:       s.email,
+			IP:          s.ip,
+			Location:    s.location,
+			SentAt:      s.sentAt,
+			OpenedAt:    s.openedAt,
+			CompletedAt: s.completedAt,
+		})
+		for _, u := range submitterUploads(data.tpl, s) {
+			files = append(files, u.file)
+			record.Documents = append(record.Documents, evidenceDocument(u.file, s.id, u.fieldID))
+		}
+	}
+
+	if record.Events, err = b.loadEvidenceEvents(ctx, submissionID); err != nil {
+		return nil, err
+	}
+	evidence, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode evidence record: %w", err)
+	}
+	files = append(files, asic.File{Name: asicEvidenceName, MimeType: "application/json", Data: evidence})
+
+	key, err := b.SealingKeys.SealingKey(ctx, submissionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sealing key: %w", err)
+	}
+	if key == nil || key.Signer == nil || key.Certificate == nil {
+		return nil, fmt.Errorf("sealing key is incomplete")
+	}
+	var chains [][]*x509.Certificate
+	if len(key.Chain) > 0 {
+		chains = append(chains, append([]*x509.Certificate{key.Certificate}, key.Chain...))
+	}
+
+	container, err := asic.Create(files, sign.SignData{
+		Signer:            key.Signer,
+		RSAPSS:            key.RSAPSS,
+		Certificate:       key.Certificate,
+		CertificateChains: chains,
+		TSA:               sign.TSA{Local: b.Timestamps},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build asic container: %w", err)
+	}
+	return container, nil
+}
+
+// asicUpload is a file a submitter uploaded through a file or image field
+type asicUpload struct {
+	file    asic.File
+	fieldID string
+}
+
+// submitterUploads returns the file and image values of the fields of a submitter
+// that hold data URLs, named uploads/{submitter_id}/{field_id}.{ext}
+func submitterUploads(tpl *models.Template, s loadedSubmitter) []asicUpload {
+	var uploads []asicUpload
+	for _, f := range tpl.Fields {
+		if f.Type != models.FieldTypeFile && f.Type != models.FieldTypeImage {
+			continue
+		}
+		if s.templateSubmitter != "" && f.SubmitterID != s.templateSubmitter {
+			continue
+		}
+		value, _ := s.fields[f.ID].(string)
+		mimeType, content, ok := decodeDataURL(value)
+		if !ok {
+			continue
+		}
+		ext := ""
+		if mimeType == "image/jpeg" {
+			ext = ".jpg" // ExtensionsByType sorts .jfif and .jpe first
+		} else if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		uploads = append(uploads, asicUpload{
+			file: asic.File{
+				Name:     asicUploadsDir + asicUnsafeName.ReplaceAllString(s.id, "_") + "/" + asicUnsafeName.ReplaceAllString(f.ID, "_") + ext,
+				MimeType: mimeType,
+				Data:     content,
+			},
+			fieldID: f.ID,
+		})
+	}
+	return uploads
+}
+
+// decodeDataURL decodes a base64 data URL ("data:application/pdf;base64,...")
+func decodeDataURL(value string) (string, []byte, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(value), "data:")
+	if !ok {
+		return "", nil, false
+	}
+	header, payload, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return "", nil, false
+	}
+	content, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	mimeType := strings.TrimSuffix(header, ";base64")
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return mimeType, content, true
+}
+
+func evidenceDocument(f asic.File, submitterID, fieldID string) EvidenceDocument {
+	sum := sha256.Sum256(f.Data)
+	return EvidenceDocument{
+		Name:        f.Name,
+		MimeType:    f.MimeType,
+		Size:        len(f.Data),
+		SHA256:      hex.EncodeToString(sum[:]),
+		SubmitterID: submitterID,
+		FieldID:     fieldID,
+	}
+}
+
+// loadEvidenceEvents loads the audit trail of a submission, oldest first.
+func (b *CompletedDocumentBuilder) loadEvidenceEvents(ctx context.Context, submissionID string) ([]EvidenceEvent, error) {
+	rows, err := b.Pool.Query(ctx, `
+		SELECT type, COALESCE(host(ip)::text, ''), COALESCE(metadata_json, '{}'::jsonb)::text, created_at
+		FROM event
+		WHERE resource_type = 'submission' AND resource_id = $1
+		ORDER BY created_at ASC
+	`, submissionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []EvidenceEvent{}
+	for rows.Next() {
+		var e EvidenceEvent
+		var metadata string
+		if err := rows.Scan(&e.Type, &e.IP, &metadata, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if metadata != "{}" {
+			e.Metadata = json.RawMessage(metadata)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+func TestSubmitterUploads(t *testing.T) {
+	tpl := &models.Template{Fields: []models.Field{
+		{ID: "f-file", SubmitterID: "ts-1", Type: models.FieldTypeFile},
+		{ID: "f-image", SubmitterID: "ts-1", Type: models.FieldTypeImage},
+		{ID: "f-name", SubmitterID: "ts-1", Type: models.FieldTypeText},
+		{ID: "f-other", SubmitterID: "ts-2", Type: models.FieldTypeFile},
+		{ID: "f-plain", SubmitterID: "ts-1", Type: models.FieldTypeFile},
+	}}
+	s := loadedSubmitter{
+		id:                "sub-1",
+		templateSubmitter: "ts-1",
+		fields: map[string]any{
+			"f-file":  "data:application/pdf;base64,JVBERi0xLjc=",
+			"f-image": "data:image/jpeg;base64,/9j/",
+			"f-name":  "data:text/plain;base64,aGk=",
+			"f-other": "data:application/pdf;base64,JVBERi0xLjc=",
+			"f-plain": "scan.pdf",
+		},
+	}
+
+	uploads := submitterUploads(tpl, s)
+	if len(uploads) != 2 {
+		t.Fatalf("got %d uploads, want 2: %+v", len(uploads), uploads)
+	}
+	if u := uploads[0]; u.file.Name != "uploads/sub-1/f-file.pdf" || u.file.MimeType != "application/pdf" || string(u.file.Data) != "%PDF-1.7" || u.fieldID != "f-file" {
+		t.Errorf("upload %+v", u)
+	}
+	if u := uploads[1]; u.file.Name != "uploads/sub-1/f-image.jpg" || u.file.MimeType != "image/jpeg" {
+		t.Errorf("upload %+v", u)
+	}
+}
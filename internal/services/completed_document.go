@@ -3,10 +3,11 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"mime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,29 +15,103 @@ import (
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/internal/queries"
 	"github.com/shurco/gosign/pkg/pdf"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/storage"
 )
 
 // CompletedDocumentBuilder builds and caches a final, completed PDF for a submission.
 //
-// It is intentionally simple and filesystem-backed:
-// - source pages are in PagesDir: lc_pages/{attachment_id}/0.pdf
-// - completed PDFs are written to SignedDir: lc_signed/submission_{submission_id}.pdf
+// It is intentionally simple and backed by the blob storage of the organization owning
+// the template (see Storages), so nodes share no disk:
+// - source pages are read from lc_pages/{attachment_id}/0.pdf, or the blob it is stored as (see Blobs)
+// - completed PDFs are written to lc_signed/submission_{submission_id}_completed_v3.pdf
+// - completed PDFs are sealed with a PAdES signature using the key from SealingKeys,
+//   timestamped by Timestamps when a built-in TSA is configured
+// - in incremental signature mode every submitter stamps their values on
+//   lc_signed/submission_{submission_id}_signatures_v1.pdf and signs it on completion with a key from SubmitterKeys
+// - the original (unfilled) PDF is kept as lc_signed/submission_{submission_id}_original_v1.pdf
+// - the SHA-256 of the original, completed and certificate PDFs is recorded in DocumentHashes
 type CompletedDocumentBuilder struct {
 	Pool           *pgxpool.Pool
 	TemplateQueries *queries.TemplateQueries
-	PagesDir       string
-	SignedDir      string
+	Storages       *storage.Registry
 	AssetsDir      string
+	SealingKeys    SealingKeyProvider
+	Timestamps     sign.TimestampFunction
+	SubmitterKeys  SubmitterKeyProvider
+	DocumentHashes *queries.DocumentHashRepository
+	Blobs          *BlobService // resolves page files stored as blobs; nil reads them in place
+
+	signMu sync.Mutex
 }
 
-func (b *CompletedDocumentBuilder) CompletedPDFPath(submissionID string) string {
+func (b *CompletedDocumentBuilder) CompletedPDFKey(submissionID string) string {
 	// Versioned filename to avoid serving older cached files after new append steps
 	// (e.g. certificate/audit pages) are introduced.
-	return filepath.Join(b.SignedDir, fmt.Sprintf("submission_%s_completed_v2.pdf", submissionID))
+	return storage.Key(storage.PrefixSigned, fmt.Sprintf("submission_%s_completed_v3.pdf", submissionID))
+}
+
+func (b *CompletedDocumentBuilder) CertificatePDFKey(submissionID string) string {
+	return storage.Key(storage.PrefixSigned, fmt.Sprintf("submission_%s_certificate_v1.pdf", submissionID))
 }
 
-func (b *CompletedDocumentBuilder) CertificatePDFPath(submissionID string) string {
-	return filepath.Join(b.SignedDir, fmt.Sprintf("submission_%s_certificate_v1.pdf", submissionID))
+func (b *CompletedDocumentBuilder) OriginalPDFKey(submissionID string) string {
+	return storage.Key(storage.PrefixSigned, fmt.Sprintf("submission_%s_original_v1.pdf", submissionID))
+}
+
+// storageFor returns the blob storage of the organization owning the template of the submission
+func (b *CompletedDocumentBuilder) storageFor(ctx context.Context, submissionID string) (storage.BlobStorage, error) {
+	if b.Storages == nil {
+		return nil, fmt.Errorf("storage not configured")
+	}
+	var organizationID string
+	err := b.Pool.QueryRow(ctx, `
+		SELECT COALESCE(t.organization_id::text, '')
+		FROM submission s
+		JOIN template t ON t.id = s.template_id
+		WHERE s.id = $1
+	`, submissionID).Scan(&organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load submission: %w", err)
+	}
+	return b.Storages.ForOrganization(ctx, organizationID)
+}
+
+// loadPage reads the PDF of a template page from the storage
+func loadPage(ctx context.Context, store storage.BlobStorage, blobs *BlobService) func(attachmentID string) ([]byte, error) {
+	return func(attachmentID string) ([]byte, error) {
+		key := storage.Key(storage.PrefixPages, attachmentID, "0.pdf")
+		if blobs != nil {
+			var err error
+			if key, err = blobs.Resolve(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		return storage.ReadAll(ctx, store, key)
+	}
+}
+
+// readCached returns a stored document, or nil when it hasn't been built yet
+func readCached(ctx context.Context, store storage.BlobStorage, key string) ([]byte, error) {
+	data, err := storage.ReadAll(ctx, store, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// recordDocumentHash stores the SHA-256 of a document of the submission (no-op without DocumentHashes).
+func (b *CompletedDocumentBuilder) recordDocumentHash(ctx context.Context, submissionID string, kind models.DocumentKind, data []byte) error {
+	if b.DocumentHashes == nil {
+		return nil
+	}
+	if err := b.DocumentHashes.Record(ctx, submissionID, kind, data); err != nil {
+		return fmt.Errorf("failed to record %s document hash: %w", kind, err)
+	}
+	return nil
 }
 
 func firstNonNilTime(ts ...*time.Time) *time.Time {
@@ -51,6 +126,7 @@ func firstNonNilTime(ts ...*time.Time) *time.Time {
 }
 
 type loadedSubmitter struct {
+	id                string
 	name              string
 	email             string
 	slug              string
@@ -93,6 +169,7 @@ func (b *CompletedDocumentBuilder) loadSubmissionData(ctx context.Context, submi
 	// Load all submitters (metadata + timestamps + identity) in one go.
 	rows, err := b.Pool.Query(ctx, `
 		SELECT
+			id::text AS id,
 			COALESCE(name, '') AS name,
 			COALESCE(email, '') AS email,
 			COALESCE(slug, '') AS slug,
@@ -118,6 +195,7 @@ func (b *CompletedDocumentBuilder) loadSubmissionData(ctx context.Context, submi
 
 	for rows.Next() {
 		var (
+			id          string
 			name        string
 			email       string
 			slug        string
@@ -129,7 +207,7 @@ func (b *CompletedDocumentBuilder) loadSubmissionData(ctx context.Context, submi
 			updatedAt   time.Time
 			metaJSON    string
 		)
-		if err := rows.Scan(&name, &email, &slug, &ip, &sentAt, &openedAt, &completedAt, &createdAt, &updatedAt, &metaJSON); err != nil {
+		if err := rows.Scan(&id, &name, &email, &slug, &ip, &sentAt, &openedAt, &completedAt, &createdAt, &updatedAt, &metaJSON); err != nil {
 			return nil, fmt.Errorf("failed to scan submitter: %w", err)
 		}
 
@@ -178,6 +256,7 @@ func (b *CompletedDocumentBuilder) loadSubmissionData(ctx context.Context, submi
 		}
 
 		submitters = append(submitters, loadedSubmitter{
+			id:                id,
 			name:              name,
 			email:             email,
 			slug:              slug,
@@ -230,39 +309,82 @@ func (b *CompletedDocumentBuilder) IsSubmissionFullyCompleted(ctx context.Contex
 	return ok, nil
 }
 
-// EnsureCompletedPDF generates the completed PDF (if missing) and returns its path.
+// DocumentURL returns an expiring download URL for the completed document or the
+// certificate of a completed submission, building it first when missing.
+func (b *CompletedDocumentBuilder) DocumentURL(ctx context.Context, submissionID string, kind models.DocumentKind, filename string, expiration time.Duration) (string, error) {
+	var key string
+	switch kind {
+	case models.DocumentKindCompleted:
+		if _, err := b.EnsureCompletedPDF(ctx, submissionID); err != nil {
+			return "", err
+		}
+		key = b.CompletedPDFKey(submissionID)
+	case models.DocumentKindCertificate:
+		if _, err := b.EnsureCertificatePDF(ctx, submissionID); err != nil {
+			return "", err
+		}
+		key = b.CertificatePDFKey(submissionID)
+	default:
+		return "", fmt.Errorf("no URL for %s documents", kind)
+	}
+
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return "", err
+	}
+	return storage.DownloadURL(ctx, store, key, expiration, mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+}
+
+// EnsureCompletedPDF generates the completed PDF (if missing) and returns its content.
 // It does NOT check completion; caller must ensure submission is completed.
-func (b *CompletedDocumentBuilder) EnsureCompletedPDF(ctx context.Context, submissionID string) (string, error) {
+func (b *CompletedDocumentBuilder) EnsureCompletedPDF(ctx context.Context, submissionID string) ([]byte, error) {
 	if b.Pool == nil {
-		return "", fmt.Errorf("db pool not configured")
+		return nil, fmt.Errorf("db pool not configured")
 	}
 	if b.TemplateQueries == nil {
-		return "", fmt.Errorf("template queries not configured")
+		return nil, fmt.Errorf("template queries not configured")
+	}
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return nil, err
 	}
-	if b.PagesDir == "" || b.SignedDir == "" {
-		return "", fmt.Errorf("pages/signed dirs not configured")
+	if b.SealingKeys == nil {
+		return nil, fmt.Errorf("sealing keys not configured")
 	}
 
-	outPath := b.CompletedPDFPath(submissionID)
-	if _, err := os.Stat(outPath); err == nil {
-		return outPath, nil
+	// The original is recorded before the completed document so that both can be looked up.
+	if _, err := b.EnsureOriginalPDF(ctx, submissionID); err != nil {
+		return nil, err
+	}
+
+	outKey := b.CompletedPDFKey(submissionID)
+	existing, err := readCached(ctx, store, outKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// Documents cached before hashes were recorded still get one.
+		if err := b.recordDocumentHash(ctx, submissionID, models.DocumentKindCompleted, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
 	}
 
 	data, err := b.loadSubmissionData(ctx, submissionID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	tpl := data.tpl
 
 	// 3) Render base completed PDF.
 	outBytes, err := pdf.RenderCompletedTemplatePDF(pdf.RenderCompletedTemplatePDFInput{
-		PagesDir: b.PagesDir,
+		LoadPage: loadPage(ctx, store, b.Blobs),
 		Schema:   tpl.Schema,
 		Fields:   tpl.Fields,
 		Values:   data.values,
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 4) Append signature certificate page(s) when the submission is fully completed.
@@ -341,13 +463,13 @@ func (b *CompletedDocumentBuilder) EnsureCompletedPDF(ctx context.Context, submi
 	}
 
 	if strings.TrimSpace(b.AssetsDir) == "" {
-		return "", fmt.Errorf("assets dir not configured")
+		return nil, fmt.Errorf("assets dir not configured")
 	}
 	if strings.TrimSpace(data.publicBaseURL) == "" {
-		return "", fmt.Errorf("public_base_url is not set for submission")
+		return nil, fmt.Errorf("public_base_url is not set for submission")
 	}
 	if strings.TrimSpace(qrSlug) == "" {
-		return "", fmt.Errorf("missing submitter slug for certificate QR url")
+		return nil, fmt.Errorf("missing submitter slug for certificate QR url")
 	}
 	qrURL := fmt.Sprintf("%s/public/sign/%s/certificate", strings.TrimRight(data.publicBaseURL, "/"), qrSlug)
 
@@ -360,43 +482,110 @@ func (b *CompletedDocumentBuilder) EnsureCompletedPDF(ctx context.Context, submi
 		Signers:      certSigners,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate signature certificate: %w", err)
+		return nil, fmt.Errorf("failed to generate signature certificate: %w", err)
 	}
 	outBytes, err = pdf.AppendSignatureCertificate(outBytes, certBytes)
 	if err != nil {
-		return "", fmt.Errorf("failed to append signature certificate: %w", err)
+		return nil, fmt.Errorf("failed to append signature certificate: %w", err)
+	}
+
+	// 5) Seal the final document so any later modification is detectable.
+	sealKey, err := b.SealingKeys.SealingKey(ctx, submissionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sealing key: %w", err)
+	}
+	outBytes, err = sealPDF(outBytes, sealKey, submissionID, time.Now(), b.Timestamps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal completed PDF: %w", err)
+	}
+
+	if err := b.recordDocumentHash(ctx, submissionID, models.DocumentKindCompleted, outBytes); err != nil {
+		return nil, err
+	}
+	if err := storage.Put(ctx, store, outKey, outBytes, "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to write completed PDF: %w", err)
+	}
+	return outBytes, nil
+}
+
+// EnsureOriginalPDF renders the template of the submission without any values (if missing),
+// records its hash and returns its content. It is the document every signer is asked to fill
+// and the base of the incremental signatures.
+func (b *CompletedDocumentBuilder) EnsureOriginalPDF(ctx context.Context, submissionID string) ([]byte, error) {
+	if b.Pool == nil {
+		return nil, fmt.Errorf("db pool not configured")
+	}
+	if b.TemplateQueries == nil {
+		return nil, fmt.Errorf("template queries not configured")
+	}
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	outKey := b.OriginalPDFKey(submissionID)
+	existing, err := readCached(ctx, store, outKey)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	var templateID string
+	if err := b.Pool.QueryRow(ctx, `SELECT template_id FROM submission WHERE id = $1`, submissionID).Scan(&templateID); err != nil {
+		return nil, fmt.Errorf("failed to load submission: %w", err)
+	}
+	tpl, err := b.TemplateQueries.Template(ctx, templateID)
+	if err != nil || tpl == nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+
+	outBytes, err := pdf.RenderCompletedTemplatePDF(pdf.RenderCompletedTemplatePDFInput{
+		LoadPage: loadPage(ctx, store, b.Blobs),
+		Schema:   tpl.Schema,
+		Fields:   tpl.Fields,
+		Values:   map[string]any{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render original PDF: %w", err)
 	}
 
-	if err := os.MkdirAll(b.SignedDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to ensure signed dir: %w", err)
+	if err := b.recordDocumentHash(ctx, submissionID, models.DocumentKindOriginal, outBytes); err != nil {
+		return nil, err
 	}
-	if err := os.WriteFile(outPath, outBytes, 0644); err != nil {
-		return "", fmt.Errorf("failed to write completed PDF: %w", err)
+	if err := storage.Put(ctx, store, outKey, outBytes, "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to write original PDF: %w", err)
 	}
-	return outPath, nil
+	return outBytes, nil
 }
 
-// EnsureCertificatePDF generates the certificate-only PDF (if missing) and returns its path.
+// EnsureCertificatePDF generates the certificate-only PDF (if missing) and returns its content.
 // It does NOT check completion; caller must ensure submission is completed.
-func (b *CompletedDocumentBuilder) EnsureCertificatePDF(ctx context.Context, submissionID string) (string, error) {
+func (b *CompletedDocumentBuilder) EnsureCertificatePDF(ctx context.Context, submissionID string) ([]byte, error) {
 	if b.Pool == nil {
-		return "", fmt.Errorf("db pool not configured")
+		return nil, fmt.Errorf("db pool not configured")
 	}
 	if b.TemplateQueries == nil {
-		return "", fmt.Errorf("template queries not configured")
+		return nil, fmt.Errorf("template queries not configured")
 	}
-	if b.SignedDir == "" {
-		return "", fmt.Errorf("signed dir not configured")
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return nil, err
 	}
 
-	outPath := b.CertificatePDFPath(submissionID)
-	if _, err := os.Stat(outPath); err == nil {
-		return outPath, nil
+	outKey := b.CertificatePDFKey(submissionID)
+	existing, err := readCached(ctx, store, outKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if err := b.recordDocumentHash(ctx, submissionID, models.DocumentKindCertificate, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
 	}
 
 	data, err := b.loadSubmissionData(ctx, submissionID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	tpl := data.tpl
 
@@ -470,13 +659,13 @@ func (b *CompletedDocumentBuilder) EnsureCertificatePDF(ctx context.Context, sub
 	}
 
 	if strings.TrimSpace(b.AssetsDir) == "" {
-		return "", fmt.Errorf("assets dir not configured")
+		return nil, fmt.Errorf("assets dir not configured")
 	}
 	if strings.TrimSpace(data.publicBaseURL) == "" {
-		return "", fmt.Errorf("public_base_url is not set for submission")
+		return nil, fmt.Errorf("public_base_url is not set for submission")
 	}
 	if strings.TrimSpace(qrSlug) == "" {
-		return "", fmt.Errorf("missing submitter slug for certificate QR url")
+		return nil, fmt.Errorf("missing submitter slug for certificate QR url")
 	}
 	qrURL := fmt.Sprintf("%s/public/sign/%s/certificate", strings.TrimRight(data.publicBaseURL, "/"), qrSlug)
 
@@ -489,15 +678,15 @@ func (b *CompletedDocumentBuilder) EnsureCertificatePDF(ctx context.Context, sub
 		Signers:      certSigners,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate signature certificate: %w", err)
+		return nil, fmt.Errorf("failed to generate signature certificate: %w", err)
 	}
 
-	if err := os.MkdirAll(b.SignedDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to ensure signed dir: %w", err)
+	if err := b.recordDocumentHash(ctx, submissionID, models.DocumentKindCertificate, certBytes); err != nil {
+		return nil, err
 	}
-	if err := os.WriteFile(outPath, certBytes, 0644); err != nil {
-		return "", fmt.Errorf("failed to write certificate PDF: %w", err)
+	if err := storage.Put(ctx, store, outKey, certBytes, "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to write certificate PDF: %w", err)
 	}
-	return outPath, nil
+	return certBytes, nil
 }
 
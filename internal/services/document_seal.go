@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/security/cert"
+)
+
+// SealingKey is the certificate and private key used to seal completed documents.
+type SealingKey struct {
+	Signer      crypto.Signer
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	RSAPSS      bool // sign with RSASSA-PSS (RSA keys only)
+}
+
+// SealingKeyProvider resolves the key used to seal the completed PDF of a submission.
+type SealingKeyProvider interface {
+	SealingKey(ctx context.Context, submissionID string) (*SealingKey, error)
+}
+
+// FileSealingKeyProvider serves a single platform sealing key stored as PEM files in Dir.
+// A self-signed key pair is generated on first use when the files are missing.
+type FileSealingKeyProvider struct {
+	Dir  string
+	Name string
+
+	mu  sync.Mutex
+	key *SealingKey
+}
+
+const (
+	sealCertFile = "seal-cert.pem"
+	sealKeyFile  = "seal-key.pem"
+)
+
+// SealingKey implements SealingKeyProvider.
+func (p *FileSealingKeyProvider) SealingKey(_ context.Context, _ string) (*SealingKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.key != nil {
+		return p.key, nil
+	}
+	if p.Dir == "" {
+		return nil, fmt.Errorf("sealing key dir not configured")
+	}
+
+	certPath := filepath.Join(p.Dir, sealCertFile)
+	keyPath := filepath.Join(p.Dir, sealKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		var err error
+		certPEM, keyPEM, err = p.generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate sealing key: %w", err)
+		}
+		if err := os.MkdirAll(p.Dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to ensure sealing key dir: %w", err)
+		}
+		if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write sealing key: %w", err)
+		}
+		if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write sealing certificate: %w", err)
+		}
+	} else if certErr != nil {
+		return nil, fmt.Errorf("failed to read sealing certificate: %w", certErr)
+	} else if keyErr != nil {
+		return nil, fmt.Errorf("failed to read sealing key: %w", keyErr)
+	}
+
+	crt, err := cert.ParseCertificate(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sealing certificate: %w", err)
+	}
+	pkey, err := cert.ParsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sealing key: %w", err)
+	}
+
+	p.key = &SealingKey{Signer: pkey, Certificate: crt}
+	return p.key, nil
+}
+
+func (p *FileSealingKeyProvider) generate() ([]byte, []byte, error) {
+	pkey, err := cert.GetPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := p.Name
+	if name == "" {
+		name = "goSign Document Seal"
+	}
+
+	now := time.Now()
+	c := &cert.Certificate{
+		Subject:          pkix.Name{CommonName: name, Organization: []string{name}},
+		NotBefore:        now.Add(-time.Hour),
+		NotAfter:         now.AddDate(10, 0, 0),
+		KeyUsage:         x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtentedKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	res, err := c.GetCertificate(pkey.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []byte(res.String()), []byte(pkey.String()), nil
+}
+
+// sealPDF applies a PAdES certification signature to the completed document.
+// DocMDP still allows form filling and further signatures so that signers can countersign.
+// When timestamps is set the signature carries an RFC 3161 timestamp from it and
+// the document is extended to PAdES B-LTA: a DSS with the validation data and a
+// document timestamp over it.
+func sealPDF(data []byte, key *SealingKey, submissionID string, signedAt time.Time, timestamps sign.TimestampFunction) ([]byte, error) {
+	if key == nil || key.Signer == nil || key.Certificate == nil {
+		return nil, fmt.Errorf("sealing key is incomplete")
+	}
+
+	// sign expects each chain to start with the signing certificate itself
+	var chains [][]*x509.Certificate
+	if len(key.Chain) > 0 {
+		chains = append(chains, append([]*x509.Certificate{key.Certificate}, key.Chain...))
+	}
+
+	sealed, err := sign.SignBytes(data, sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info: sign.SignDataSignatureInfo{
+				Name:   key.Certificate.Subject.CommonName,
+				Reason: fmt.Sprintf("Completed submission %s", submissionID),
+				Date:   signedAt,
+			},
+			CertType:   sign.CertificationSignature,
+			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		Signer:            key.Signer,
+		RSAPSS:            key.RSAPSS,
+		DigestAlgorithm:   crypto.SHA256,
+		Certificate:       key.Certificate,
+		CertificateChains: chains,
+		TSA:               sign.TSA{Local: timestamps},
+	})
+	if err != nil || timestamps == nil {
+		return sealed, err
+	}
+
+	sealed, err = sign.AddDSS(sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add validation data: %w", err)
+	}
+	return sign.TimestampDocument(sealed, sign.TSA{Local: timestamps})
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func TestFileSealingKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	p1 := &FileSealingKeyProvider{Dir: dir, Name: "Test Seal"}
+	k1, err := p1.SealingKey(context.Background(), "sub-1")
+	if err != nil {
+		t.Fatalf("SealingKey() error: %v", err)
+	}
+	if k1.Certificate.Subject.CommonName != "Test Seal" {
+		t.Fatalf("unexpected subject: %s", k1.Certificate.Subject.CommonName)
+	}
+	for _, name := range []string{sealCertFile, sealKeyFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	// A fresh provider must load the persisted key instead of generating a new one.
+	p2 := &FileSealingKeyProvider{Dir: dir}
+	k2, err := p2.SealingKey(context.Background(), "sub-2")
+	if err != nil {
+		t.Fatalf("SealingKey() reload error: %v", err)
+	}
+	if !bytes.Equal(k1.Certificate.Raw, k2.Certificate.Raw) {
+		t.Fatalf("expected persisted certificate to be reused")
+	}
+}
+
+func TestSealPDF(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	key, err := (&FileSealingKeyProvider{Dir: t.TempDir()}).SealingKey(context.Background(), "sub-1")
+	if err != nil {
+		t.Fatalf("SealingKey() error: %v", err)
+	}
+
+	sealed, err := sealPDF(input, key, "sub-1", time.Now(), nil)
+	if err != nil {
+		t.Fatalf("sealPDF() error: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, input) {
+		t.Fatalf("expected sealing to be an incremental update of the original PDF")
+	}
+
+	res, err := verify.Reader(bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		t.Fatalf("verify.Reader() error: %v", err)
+	}
+	if len(res.Signers) != 1 {
+		t.Fatalf("expected 1 signer, got %d", len(res.Signers))
+	}
+	if !res.Signers[0].ValidSignature {
+		t.Fatalf("expected seal signature to be valid")
+	}
+
+	// Tampering with the signed bytes must invalidate the seal.
+	tampered := bytes.Clone(sealed)
+	idx := bytes.Index(tampered, []byte("/Type"))
+	tampered[idx+1] = 'X'
+	if res, err := verify.Reader(bytes.NewReader(tampered), int64(len(tampered))); err == nil && len(res.Signers) > 0 && res.Signers[0].ValidSignature {
+		t.Fatalf("expected tampered PDF to fail verification")
+	}
+}
+
+func TestSealPDFLongTerm(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	key, err := (&FileSealingKeyProvider{Dir: t.TempDir()}).SealingKey(context.Background(), "sub-1")
+	if err != nil {
+		t.Fatalf("SealingKey() error: %v", err)
+	}
+
+	tsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &tsaKey.PublicKey, tsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsaCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamps := func(request []byte) ([]byte, error) {
+		req, err := timestamp.ParseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            []int{1, 2, 3},
+			AddTSACertificate: true,
+		}
+		return ts.CreateResponseWithOpts(tsaCert, tsaKey, crypto.SHA256)
+	}
+
+	sealed, err := sealPDF(input, key, "sub-1", time.Now(), timestamps)
+	if err != nil {
+		t.Fatalf("sealPDF() error: %v", err)
+	}
+
+	res, err := verify.Reader(bytes.NewReader(sealed), int64(len(sealed)))
+	if err != nil {
+		t.Fatalf("verify.Reader() error: %v", err)
+	}
+	if res.Level != verify.LevelBLTA {
+		t.Fatalf("level = %q, want %q", res.Level, verify.LevelBLTA)
+	}
+}
@@ -0,0 +1,447 @@
+package field
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/services/formula"
+)
+
+// Errors maps field IDs to the reason their submitted value was rejected
+type Errors map[string]string
+
+// Error implements error
+func (e Errors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id+": "+e[id])
+	}
+	return "invalid fields: " + strings.Join(parts, "; ")
+}
+
+// State is the effective state of a field after its conditions are applied
+type State struct {
+	Visible  bool
+	Required bool
+	Disabled bool
+}
+
+// signatureIDSuffix marks the companion value holding the signature ID of a field
+const signatureIDSuffix = "_signature_id"
+
+// fieldTypeMultiple is models.FieldTypeMultiSelect as the template editor names it
+const fieldTypeMultiple models.FieldType = "multiple"
+
+// Evaluate enforces the template rules on the values a submitter sent and
+// returns the values to store. fields are all template fields, since conditions
+// and formulas may refer to fields of other submitters; submitterID selects the
+// submitter's own fields (empty selects all of them). stored holds the values
+// other submitters stored, which conditions and formulas see instead of
+// anything the request claims for their fields.
+//
+// Formula fields are recomputed, values of hidden fields and of fields the
+// submitter doesn't own are dropped, and missing or invalid input is reported
+// as Errors. The rules mirror the signing UI.
+func Evaluate(fields []models.Field, submitterID string, values, stored map[string]any) (map[string]any, error) {
+	owned := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if submitterID == "" || f.SubmitterID == submitterID {
+			owned[f.ID] = true
+		}
+	}
+
+	data := make(map[string]any, len(stored)+len(values))
+	for k, v := range stored {
+		if !owned[strings.TrimSuffix(k, signatureIDSuffix)] {
+			data[k] = v
+		}
+	}
+	for k, v := range values {
+		if owned[strings.TrimSuffix(k, signatureIDSuffix)] {
+			data[k] = v
+		}
+	}
+
+	// Formula results replace whatever the client computed, each formula after the ones
+	// it refers to; formulas referring back to themselves get no value
+	order, cyclic := formulaOrder(fields)
+	for _, f := range order {
+		result, err := formula.EvaluateFormula(f.Formula, data, fields)
+		if err != nil || math.IsNaN(result) {
+			delete(data, f.ID)
+			continue
+		}
+		data[f.ID] = result
+	}
+	for id := range cyclic {
+		delete(data, id)
+	}
+
+	out := map[string]any{}
+	errs := Errors{}
+	for _, f := range fields {
+		if !owned[f.ID] {
+			continue
+		}
+		state := FieldState(f, data)
+		if !state.Visible {
+			continue
+		}
+
+		if cyclic[f.ID] {
+			errs[f.ID] = "formula refers to itself"
+			continue
+		}
+
+		value, ok := data[f.ID]
+		if msg := validateValue(f, value, state.Required); msg != "" {
+			errs[f.ID] = msg
+			continue
+		}
+		if ok {
+			out[f.ID] = value
+		}
+		if id, ok := data[f.ID+signatureIDSuffix]; ok {
+			out[f.ID+signatureIDSuffix] = id
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// formulaOrder returns the formula fields sorted so that every formula comes after the
+// formulas it refers to, and apart from them the fields whose formula refers back to
+// itself, directly or through other formulas, or to such a field
+func formulaOrder(fields []models.Field) ([]models.Field, map[string]bool) {
+	formulas := make(map[string]models.Field)
+	for _, f := range fields {
+		if f.Formula != "" {
+			formulas[f.ID] = f
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	var (
+		order  []models.Field
+		stack  []string
+		state  = make(map[string]int, len(formulas))
+		cyclic = map[string]bool{}
+	)
+	// visit reports whether the formula of f can be computed
+	var visit func(f models.Field) bool
+	visit = func(f models.Field) bool {
+		switch state[f.ID] {
+		case visited:
+			return !cyclic[f.ID]
+		case visiting:
+			for i := len(stack) - 1; i >= 0; i-- {
+				cyclic[stack[i]] = true
+				if stack[i] == f.ID {
+					break
+				}
+			}
+			return false
+		}
+
+		state[f.ID] = visiting
+		stack = append(stack, f.ID)
+		ok := true
+		for _, ref := range formula.References(f.Formula) {
+			if dep, isFormula := formulas[ref]; isFormula && !visit(dep) {
+				ok = false
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[f.ID] = visited
+
+		if !ok || cyclic[f.ID] {
+			cyclic[f.ID] = true
+			return false
+		}
+		order = append(order, f)
+		return true
+	}
+	for _, f := range fields {
+		if f.Formula != "" {
+			visit(f)
+		}
+	}
+	return order, cyclic
+}
+
+// FieldState applies the condition groups of f to the values
+func FieldState(f models.Field, values map[string]any) State {
+	state := State{Visible: true, Required: f.Required}
+	for _, group := range f.ConditionGroups {
+		if !evaluateGroup(group, values) {
+			// A "show" group hides the field until its condition is met
+			if group.Action == models.ActionShow {
+				state.Visible = false
+			}
+			continue
+		}
+		switch group.Action {
+		case models.ActionShow:
+			state.Visible = true
+		case models.ActionHide:
+			state.Visible = false
+		case models.ActionRequire:
+			state.Required = true
+		case models.ActionDisable:
+			state.Disabled = true
+		}
+	}
+	return state
+}
+
+func evaluateGroup(group models.FieldConditionGroup, values map[string]any) bool {
+	if group.Logic == models.LogicAND {
+		for _, cond := range group.Conditions {
+			if !evaluateCondition(cond, values) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, cond := range group.Conditions {
+		if evaluateCondition(cond, values) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateCondition(cond models.FieldCondition, values map[string]any) bool {
+	value, ok := values[cond.FieldID]
+	if !ok || value == nil {
+		switch cond.Operator {
+		case models.ConditionIsEmpty:
+			return true
+		case models.ConditionEquals:
+			return cond.Value == ""
+		case models.ConditionNotEquals:
+			return cond.Value != ""
+		}
+		return false
+	}
+
+	switch cond.Operator {
+	case models.ConditionEquals:
+		return reflect.DeepEqual(value, cond.Value)
+	case models.ConditionNotEquals:
+		return !reflect.DeepEqual(value, cond.Value)
+	case models.ConditionContains:
+		return strings.Contains(stringify(value), stringify(cond.Value))
+	case models.ConditionNotContains:
+		return !strings.Contains(stringify(value), stringify(cond.Value))
+	case models.ConditionGreaterThan:
+		a, okA := toNumber(value)
+		b, okB := toNumber(cond.Value)
+		return okA && okB && a > b
+	case models.ConditionLessThan:
+		a, okA := toNumber(value)
+		b, okB := toNumber(cond.Value)
+		return okA && okB && a < b
+	case models.ConditionIsEmpty:
+		return isEmpty(value)
+	case models.ConditionIsNotEmpty:
+		return !isEmpty(value)
+	}
+	return false
+}
+
+// validateValue returns why value is not acceptable for f, or ""
+func validateValue(f models.Field, value any, required bool) string {
+	if isEmpty(value) || isBlank(value) {
+		if required {
+			return "is required"
+		}
+		return ""
+	}
+
+	switch f.Type {
+	case models.FieldTypeSignature, models.FieldTypeInitials, models.FieldTypeStamp:
+		if s, ok := value.(string); !ok || !strings.HasPrefix(s, "data:") {
+			return "must be an image"
+		}
+		return ""
+	case models.FieldTypeCells:
+		if s, ok := value.(string); required && (!ok || len([]rune(s)) != cellCount(f)) {
+			return "all cells must be filled"
+		}
+	case models.FieldTypeSelect, models.FieldTypeRadio:
+		if s, ok := value.(string); !ok || !isOption(f.Options, s) {
+			return "must be one of the options"
+		}
+		return ""
+	case models.FieldTypeMultiSelect, fieldTypeMultiple:
+		list, ok := value.([]any)
+		if !ok {
+			return "must be a list of options"
+		}
+		for _, item := range list {
+			if s, ok := item.(string); !ok || !isOption(f.Options, s) {
+				return "must be one of the options"
+			}
+		}
+		return ""
+	case models.FieldTypeNumber:
+		num, ok := toNumber(value)
+		if !ok {
+			return "must be a number"
+		}
+		if v := f.Validation; v != nil {
+			if v.Min != nil && num < *v.Min {
+				return fmt.Sprintf("must be at least %v", *v.Min)
+			}
+			if v.Max != nil && num > *v.Max {
+				return fmt.Sprintf("must be at most %v", *v.Max)
+			}
+		}
+		return ""
+	}
+
+	s, ok := value.(string)
+	if !ok || f.Validation == nil {
+		return ""
+	}
+	if v := f.Validation; f.Type == models.FieldTypeText {
+		length := float64(len([]rune(s)))
+		if v.Min != nil && length < *v.Min {
+			return fmt.Sprintf("must be at least %v characters", *v.Min)
+		}
+		if v.Max != nil && length > *v.Max {
+			return fmt.Sprintf("must be at most %v characters", *v.Max)
+		}
+	}
+	if pattern := f.Validation.Pattern; pattern != "" {
+		// Patterns come from the browser (ECMAScript syntax); the ones RE2 can't
+		// compile can't be enforced here
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			log.Warn().Err(err).Str("field_id", f.ID).Str("pattern", pattern).Msg("Validation pattern not supported, not enforced")
+			return ""
+		}
+		if !re.MatchString(s) {
+			if f.Validation.Message != "" {
+				return f.Validation.Message
+			}
+			return "has an invalid format"
+		}
+	}
+	return ""
+}
+
+// isOption reports whether value is one of the options, which the signing UI submits
+// by value, or by ID when they have none
+func isOption(options models.FieldOptions, value string) bool {
+	for _, o := range options {
+		if o.Value == value || o.ID == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cellCount returns the number of cells of a cells field, computed the way the
+// signing UI lays them out
+func cellCount(f models.Field) int {
+	if len(f.Areas) == 0 || f.Areas[0] == nil {
+		return 6
+	}
+	area := f.Areas[0]
+	if area.CellCount != nil && *area.CellCount > 0 {
+		return *area.CellCount
+	}
+	if area.CellW == nil || *area.CellW == 0 || area.W == 0 {
+		return 6
+	}
+	cellWidth := *area.CellW
+	width, count := 0.0, 0
+	for width+cellWidth+cellWidth/4 < area.W {
+		width += cellWidth
+		count++
+	}
+	return max(count, 1)
+}
+
+// isEmpty reports values JavaScript considers falsy or empty lists
+func isEmpty(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !x
+	case float64:
+		return x == 0
+	case string:
+		return x == ""
+	case []any:
+		return len(x) == 0
+	}
+	return false
+}
+
+func isBlank(v any) bool {
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+func stringify(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case []any:
+		parts := make([]string, len(x))
+		for i, p := range x {
+			parts[i] = stringify(p)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s := strings.TrimSpace(x)
+		if s == "" {
+			return 0, true
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,208 @@
+package field
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	minAge, maxAge := 18.0, 120.0
+	fields := []models.Field{
+		{ID: "name", SubmitterID: "s1", Type: models.FieldTypeText, Required: true,
+			Validation: &models.FieldValidation{Pattern: "[A-Za-z ]+", Message: "letters only"}},
+		{ID: "age", SubmitterID: "s1", Type: models.FieldTypeNumber,
+			Validation: &models.FieldValidation{Min: &minAge, Max: &maxAge}},
+		{ID: "company", SubmitterID: "s1", Type: models.FieldTypeCheckbox},
+		{ID: "company_name", SubmitterID: "s1", Type: models.FieldTypeText,
+			ConditionGroups: []models.FieldConditionGroup{{
+				Logic:      models.LogicAND,
+				Conditions: []models.FieldCondition{{FieldID: "company", Operator: models.ConditionEquals, Value: true}},
+				Action:     models.ActionShow,
+			}, {
+				Logic:      models.LogicAND,
+				Conditions: []models.FieldCondition{{FieldID: "company", Operator: models.ConditionEquals, Value: true}},
+				Action:     models.ActionRequire,
+			}}},
+		{ID: "price", SubmitterID: "s1", Type: models.FieldTypeNumber},
+		{ID: "total", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "price * 2"},
+		{ID: "sign", SubmitterID: "s1", Type: models.FieldTypeSignature, Required: true},
+		{ID: "other", SubmitterID: "s2", Type: models.FieldTypeText},
+		{ID: "vat_id", SubmitterID: "s2", Type: models.FieldTypeText,
+			ConditionGroups: []models.FieldConditionGroup{{
+				Logic:      models.LogicAND,
+				Conditions: []models.FieldCondition{{FieldID: "company", Operator: models.ConditionEquals, Value: true}},
+				Action:     models.ActionRequire,
+			}}},
+	}
+	valid := func() map[string]any {
+		return map[string]any{
+			"name":              "Jane Doe",
+			"age":               float64(30),
+			"price":             "21",
+			"sign":              "data:image/png;base64,AA==",
+			"sign_signature_id": "ABC123",
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		values := valid()
+		values["total"] = float64(1) // crafted, recomputed
+		values["company_name"] = "Acme"
+		values["other"] = "not mine"
+
+		out, err := Evaluate(fields, "s1", values, nil)
+		if err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+		if out["total"] != float64(42) {
+			t.Errorf("total = %v, want 42", out["total"])
+		}
+		if _, ok := out["company_name"]; ok {
+			t.Error("value of hidden field kept")
+		}
+		if _, ok := out["other"]; ok {
+			t.Error("value of another submitter's field kept")
+		}
+		if out["sign_signature_id"] != "ABC123" {
+			t.Errorf("signature ID = %v", out["sign_signature_id"])
+		}
+	})
+
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+		field  string
+	}{
+		{"missing required", func(v map[string]any) { delete(v, "name") }, "name"},
+		{"blank required", func(v map[string]any) { v["name"] = "  " }, "name"},
+		{"pattern", func(v map[string]any) { v["name"] = "Jane 2" }, "name"},
+		{"below min", func(v map[string]any) { v["age"] = float64(12) }, "age"},
+		{"above max", func(v map[string]any) { v["age"] = "200" }, "age"},
+		{"not a number", func(v map[string]any) { v["age"] = "old" }, "age"},
+		{"signature not an image", func(v map[string]any) { v["sign"] = "Jane" }, "sign"},
+		{"required by condition", func(v map[string]any) { v["company"] = true }, "company_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := valid()
+			tt.modify(values)
+			_, err := Evaluate(fields, "s1", values, nil)
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Evaluate error = %v, want Errors", err)
+			}
+			if _, ok := errs[tt.field]; !ok || len(errs) != 1 {
+				t.Errorf("errors = %v, want only %s", errs, tt.field)
+			}
+		})
+	}
+
+	t.Run("pattern message", func(t *testing.T) {
+		values := valid()
+		values["name"] = "Jane 2"
+		_, err := Evaluate(fields, "s1", values, nil)
+		if errs, _ := err.(Errors); errs["name"] != "letters only" {
+			t.Errorf("message = %q", errs["name"])
+		}
+	})
+
+	t.Run("condition on an earlier submitter", func(t *testing.T) {
+		stored := map[string]any{"company": true, "name": "Jane Doe"}
+
+		// The request can't override what s1 stored
+		_, err := Evaluate(fields, "s2", map[string]any{"company": false}, stored)
+		if errs, _ := err.(Errors); len(errs) != 1 || errs["vat_id"] == "" {
+			t.Fatalf("errors = %v, want only vat_id", err)
+		}
+
+		out, err := Evaluate(fields, "s2", map[string]any{"vat_id": "DE123", "name": "Mallory"}, stored)
+		if err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+		if len(out) != 1 || out["vat_id"] != "DE123" {
+			t.Errorf("out = %v, want only vat_id", out)
+		}
+
+		stored["company"] = false
+		if _, err := Evaluate(fields, "s2", map[string]any{}, stored); err != nil {
+			t.Errorf("Evaluate without company: %v", err)
+		}
+	})
+}
+
+func TestEvaluateFormulaOrder(t *testing.T) {
+	fields := []models.Field{
+		// listed before the formula it refers to
+		{ID: "gross", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "net * 1.2"},
+		{ID: "net", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "price * qty"},
+		{ID: "price", SubmitterID: "s1", Type: models.FieldTypeNumber},
+		{ID: "qty", SubmitterID: "s1", Type: models.FieldTypeNumber},
+	}
+	out, err := Evaluate(fields, "s1", map[string]any{"price": float64(10), "qty": float64(3), "gross": float64(1)}, nil)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if out["net"] != float64(30) || out["gross"] != float64(36) {
+		t.Errorf("net = %v, gross = %v, want 30 and 36", out["net"], out["gross"])
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		fields := []models.Field{
+			{ID: "a", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "b + 1"},
+			{ID: "b", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "a + 1"},
+			{ID: "c", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "b * 2"},
+			{ID: "self", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "self + 1"},
+			{ID: "d", SubmitterID: "s1", Type: models.FieldTypeNumber, Formula: "2 * 2"},
+		}
+		_, err := Evaluate(fields, "s1", map[string]any{"a": float64(1), "b": float64(1)}, nil)
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("Evaluate error = %v, want Errors", err)
+		}
+		for _, id := range []string{"a", "b", "c", "self"} {
+			if errs[id] == "" {
+				t.Errorf("no error for %s", id)
+			}
+		}
+		if _, ok := errs["d"]; ok || len(errs) != 4 {
+			t.Errorf("errors = %v", errs)
+		}
+	})
+}
+
+func TestEvaluateOptions(t *testing.T) {
+	options := models.FieldOptions{{ID: "o1", Value: "Red"}, {ID: "o2", Value: "Green"}, {ID: "o3"}}
+	fields := []models.Field{
+		{ID: "select", SubmitterID: "s1", Type: models.FieldTypeSelect, Options: options},
+		{ID: "radio", SubmitterID: "s1", Type: models.FieldTypeRadio, Options: options},
+		{ID: "multiple", SubmitterID: "s1", Type: fieldTypeMultiple, Options: options},
+		{ID: "multi_select", SubmitterID: "s1", Type: models.FieldTypeMultiSelect, Options: options},
+	}
+
+	out, err := Evaluate(fields, "s1", map[string]any{
+		"select":       "Red",
+		"radio":        "o3",
+		"multiple":     []any{"Red", "Green"},
+		"multi_select": []any{},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if out["select"] != "Red" || out["radio"] != "o3" {
+		t.Errorf("out = %v", out)
+	}
+
+	for name, values := range map[string]map[string]any{
+		"select":       {"select": "Blue"},
+		"radio":        {"radio": float64(1)},
+		"multiple":     {"multiple": []any{"Red", "Blue"}},
+		"multi_select": {"multi_select": "Red"},
+	} {
+		_, err := Evaluate(fields, "s1", values, nil)
+		if errs, _ := err.(Errors); len(errs) != 1 || errs[name] == "" {
+			t.Errorf("%s: errors = %v, want only %s", name, err, name)
+		}
+	}
+}
@@ -40,6 +40,11 @@ func ValidateFormula(formula string, fields []models.Field) error {
 	return nil
 }
 
+// References returns the IDs of the fields a formula refers to, in order of first use
+func References(formula string) []string {
+	return extractFieldReferences(formula)
+}
+
 // UUID pattern so UUIDs are treated as single field references (not split by hyphen).
 var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
 
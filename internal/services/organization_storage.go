@@ -0,0 +1,270 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/security/secret"
+	"github.com/shurco/gosign/pkg/storage"
+)
+
+// ErrUnsupportedStorageProvider is returned for an organization storage that isn't an S3 bucket
+var ErrUnsupportedStorageProvider = errors.New("organization storage must be an s3 bucket")
+
+// ErrStorageBucketRequired is returned for an organization storage without a bucket
+var ErrStorageBucketRequired = errors.New("bucket is required")
+
+// ErrStorageUnreachable is returned when a bucket can't be written with the given settings
+var ErrStorageUnreachable = errors.New("storage is not reachable with these settings")
+
+// ErrStorageInUse is returned when an organization with documents would switch storage:
+// they would stay where they were written and no longer be found
+var ErrStorageInUse = errors.New("documents of the organization are in its current storage, a different storage can't be set while they exist")
+
+// storageProbeKey is written and removed to check a bucket before it is saved
+const storageProbeKey = "test/goSign-organization-storage-probe.txt"
+
+// OrganizationStorageRequest is the storage configuration of an organization.
+// An empty SecretAccessKey keeps the stored one.
+type OrganizationStorageRequest struct {
+	Provider        string `json:"provider"`
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// OrganizationStorageService manages the blob storage of organizations, so documents of an
+// organization can be kept in a bucket of its choice (e.g. an EU region).
+// Secret access keys are encrypted at rest with cipher.
+type OrganizationStorageService struct {
+	repo   *queries.OrganizationStorageRepository
+	cipher *secret.Cipher
+}
+
+// NewOrganizationStorageService creates new organization storage service
+func NewOrganizationStorageService(repo *queries.OrganizationStorageRepository, cipher *secret.Cipher) *OrganizationStorageService {
+	return &OrganizationStorageService{repo: repo, cipher: cipher}
+}
+
+// Get returns the storage of an organization, or nil when it uses the global storage
+func (s *OrganizationStorageService) Get(ctx context.Context, organizationID string) (*models.OrganizationStorage, error) {
+	return s.repo.Get(ctx, organizationID)
+}
+
+// Config returns the storage configuration of an organization, or nil when it uses the
+// global storage. It is the loader of the storage registry.
+func (s *OrganizationStorageService) Config(ctx context.Context, organizationID string) (*storage.Config, error) {
+	st, err := s.repo.Get(ctx, organizationID)
+	if err != nil || st == nil {
+		return nil, err
+	}
+	var secretKey []byte
+	if len(st.SecretAccessKey) > 0 {
+		if secretKey, err = s.cipher.Decrypt(st.SecretAccessKey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret access key: %w", err)
+		}
+	}
+	return storageConfig(st, string(secretKey)), nil
+}
+
+// Location returns where the documents of an organization are written: "" for the global
+// storage, otherwise the location of its bucket (see models.OrganizationStorage.Location)
+func (s *OrganizationStorageService) Location(ctx context.Context, organizationID string) (string, error) {
+	if organizationID == "" {
+		return "", nil
+	}
+	st, err := s.repo.Get(ctx, organizationID)
+	if err != nil || st == nil {
+		return "", err
+	}
+	return st.Location(), nil
+}
+
+// Set checks that the bucket can be written and read with the given settings, then saves them.
+// Documents aren't moved, so the bucket can only change while the organization has none
+// (ErrStorageInUse); credentials and region can always be updated.
+func (s *OrganizationStorageService) Set(ctx context.Context, organizationID string, req OrganizationStorageRequest) (*models.OrganizationStorage, error) {
+	st, err := newOrganizationStorage(organizationID, req)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.repo.Get(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.Location() != st.Location() {
+		if err := s.checkUnused(ctx, organizationID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.probeAndEncrypt(ctx, st, current, req.SecretAccessKey); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Upsert(ctx, st); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, organizationID)
+}
+
+// Move switches an organization with a bucket of its own to another bucket its documents
+// were copied to (gosign storage migrate --org): the bucket is checked like in Set, then the
+// storage and the location of its blobs are updated together
+func (s *OrganizationStorageService) Move(ctx context.Context, organizationID string, req OrganizationStorageRequest) (*models.OrganizationStorage, error) {
+	st, err := newOrganizationStorage(organizationID, req)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.repo.Get(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("organization %s uses the global storage", organizationID)
+	}
+	if err := s.probeAndEncrypt(ctx, st, current, req.SecretAccessKey); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Move(ctx, st, current.Location()); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, organizationID)
+}
+
+func newOrganizationStorage(organizationID string, req OrganizationStorageRequest) (*models.OrganizationStorage, error) {
+	req.Provider = strings.ToLower(strings.TrimSpace(req.Provider))
+	if req.Provider != "s3" {
+		return nil, ErrUnsupportedStorageProvider
+	}
+	if strings.TrimSpace(req.Bucket) == "" {
+		return nil, ErrStorageBucketRequired
+	}
+	return &models.OrganizationStorage{
+		OrganizationID: organizationID,
+		Provider:       req.Provider,
+		Bucket:         strings.TrimSpace(req.Bucket),
+		Region:         strings.TrimSpace(req.Region),
+		Endpoint:       strings.TrimSpace(req.Endpoint),
+		AccessKeyID:    strings.TrimSpace(req.AccessKeyID),
+	}, nil
+}
+
+// probeAndEncrypt checks that st can be written and read, with the secret key of current
+// when secretKey is empty, and sets the encrypted secret key of st
+func (s *OrganizationStorageService) probeAndEncrypt(ctx context.Context, st, current *models.OrganizationStorage, secretKey string) error {
+	if secretKey == "" && current != nil && len(current.SecretAccessKey) > 0 {
+		plain, err := s.cipher.Decrypt(current.SecretAccessKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret access key: %w", err)
+		}
+		secretKey = string(plain)
+	}
+
+	if err := probeStorage(ctx, storageConfig(st, secretKey)); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnreachable, err)
+	}
+
+	if secretKey != "" {
+		var err error
+		if st.SecretAccessKey, err = s.cipher.Encrypt([]byte(secretKey)); err != nil {
+			return fmt.Errorf("failed to encrypt secret access key: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete sends the documents of an organization back to the global storage; like switching
+// buckets, it is refused while the organization has documents (ErrStorageInUse)
+func (s *OrganizationStorageService) Delete(ctx context.Context, organizationID string) error {
+	current, err := s.repo.Get(ctx, organizationID)
+	if err != nil || current == nil {
+		return err
+	}
+	if err := s.checkUnused(ctx, organizationID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, organizationID)
+}
+
+func (s *OrganizationStorageService) checkUnused(ctx context.Context, organizationID string) error {
+	used, err := s.repo.HasDocuments(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrStorageInUse
+	}
+	return nil
+}
+
+// FileOrganization returns the organization owning a file served under /drive, by its
+// storage key: pages by the template holding the attachment, submission documents by
+// their submission and files of the sign endpoint by their {organization_id}/ directory.
+// "" means the global storage.
+func (s *OrganizationStorageService) FileOrganization(ctx context.Context, key string) (string, error) {
+	prefix, name, _ := strings.Cut(key, "/")
+	switch prefix {
+	case storage.PrefixPages:
+		attachmentID, _, _ := strings.Cut(name, "/")
+		return s.repo.TemplateOrganization(ctx, attachmentID)
+	case storage.PrefixSigned, storage.PrefixUploads:
+		if dir, _, ok := strings.Cut(name, "/"); ok {
+			if uuid.Validate(dir) == nil {
+				return dir, nil
+			}
+			return "", nil
+		}
+		if rest, ok := strings.CutPrefix(name, "submission_"); ok {
+			if submissionID, _, ok := strings.Cut(rest, "_"); ok && uuid.Validate(submissionID) == nil {
+				return s.repo.SubmissionOrganization(ctx, submissionID)
+			}
+		}
+	}
+	return "", nil
+}
+
+func storageConfig(st *models.OrganizationStorage, secretKey string) *storage.Config {
+	return &storage.Config{
+		Provider: st.Provider,
+		Bucket:   st.Bucket,
+		Region:   st.Region,
+		Endpoint: st.Endpoint,
+		Options: map[string]string{
+			"access_key_id":     st.AccessKeyID,
+			"secret_access_key": secretKey,
+		},
+	}
+}
+
+// probeStorage writes, reads back and removes a small file
+func probeStorage(ctx context.Context, cfg *storage.Config) error {
+	s, err := storage.NewStorage(ctx, *cfg)
+	if err != nil {
+		return err
+	}
+	content := []byte("goSign organization storage probe")
+	if err := storage.Put(ctx, s, storageProbeKey, content, "text/plain"); err != nil {
+		return err
+	}
+	data, err := storage.ReadAll(ctx, s, storageProbeKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, content) {
+		return fmt.Errorf("read back different content")
+	}
+	return s.Delete(ctx, storageProbeKey)
+}
+
+// Organizations returns the organizations with a storage of their own
+func (s *OrganizationStorageService) Organizations(ctx context.Context) ([]string, error) {
+	return s.repo.OrganizationIDs(ctx)
+}
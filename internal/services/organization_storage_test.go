@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileOrganization(t *testing.T) {
+	s := &OrganizationStorageService{}
+	const orgID = "0b7e2f4c-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"lc_signed/" + orgID + "/signed.pdf", orgID},
+		{"lc_uploads/" + orgID + "/upload.pdf", orgID},
+		{"lc_uploads/not-an-id/upload.pdf", ""},
+		{"lc_signed/3f0c.pdf", ""},
+		{"lc_signed/submission_not-an-id_completed_v3.pdf", ""},
+		{"other/" + orgID + "/x.pdf", ""},
+	}
+	for _, tt := range tests {
+		got, err := s.FileOrganization(context.Background(), tt.key)
+		if err != nil || got != tt.want {
+			t.Errorf("FileOrganization(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/pkg/notification"
 )
@@ -14,21 +16,40 @@ type ReminderRepository interface {
 	GetSubmitters(ctx context.Context, submissionID string) ([]*models.Submitter, error)
 }
 
+// ReminderTemplates loads the template whose settings define the reminders of a submission
+type ReminderTemplates interface {
+	Template(ctx context.Context, id string) (*models.Template, error)
+}
+
 // ReminderService manages reminders for submitters
 type ReminderService struct {
 	notificationSvc *notification.Service
 	repo            ReminderRepository
+	templates       ReminderTemplates
 }
 
 // NewReminderService creates a new reminder service
-func NewReminderService(notificationSvc *notification.Service, repo ReminderRepository) *ReminderService {
+func NewReminderService(notificationSvc *notification.Service, repo ReminderRepository, templates ReminderTemplates) *ReminderService {
 	return &ReminderService{
 		notificationSvc: notificationSvc,
 		repo:            repo,
+		templates:       templates,
 	}
 }
 
-// ScheduleReminders schedules reminders for submission according to settings
+// Remind schedules the reminders of the signing group of submission invited now,
+// with the reminder settings of its template
+func (s *ReminderService) Remind(ctx context.Context, submission *models.Submission) error {
+	template, err := s.templates.Template(ctx, submission.TemplateID)
+	if err != nil {
+		return fmt.Errorf("failed to get template: %w", err)
+	}
+	return s.ScheduleReminders(ctx, submission, template)
+}
+
+// ScheduleReminders schedules reminders for submission according to settings.
+// In sequential mode only the signing group invited now is reminded (see
+// models.ActiveSigningOrder), so reminders are scheduled again once the next group is invited.
 func (s *ReminderService) ScheduleReminders(ctx context.Context, submission *models.Submission, template *models.Template) error {
 	if template.Settings == nil || !template.Settings.ReminderEnabled {
 		return nil // Reminders disabled
@@ -40,6 +61,12 @@ func (s *ReminderService) ScheduleReminders(ctx context.Context, submission *mod
 		return fmt.Errorf("failed to get submitters: %w", err)
 	}
 
+	activeOrder, ok := models.ActiveSigningOrder(submitters)
+	if !ok {
+		return nil // everyone completed, or a decline ended the signing
+	}
+	grouped := submission.SigningMode != models.SigningModeParallel
+
 	// Get submission title from metadata or use template name
 	submissionTitle := template.Name
 	if title, ok := submission.Metadata["title"].(string); ok && title != "" {
@@ -61,8 +88,13 @@ func (s *ReminderService) ScheduleReminders(ctx context.Context, submission *mod
 				submitter.Status == models.SubmitterStatusDeclined {
 				continue
 			}
+			// Later signing groups haven't been invited yet
+			if grouped && submitter.Order != activeOrder {
+				continue
+			}
 
 			notif := &models.Notification{
+				ID:        uuid.New().String(),
 				Type:      models.NotificationTypeEmail,
 				Recipient: submitter.Email,
 				Template:  "reminder",
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/storage"
+)
+
+// DefaultBlobGracePeriod is how long unreferenced blobs and files are kept before the
+// garbage collector removes them, so uploads in progress aren't collected
+const DefaultBlobGracePeriod = 7 * 24 * time.Hour
+
+// BlobService stores files content addressed: a file is uploaded once per storage under
+// its SHA-256 and shared by every attachment with the same content. Its garbage collector
+// removes blobs and files no template or submission references any more.
+type BlobService struct {
+	repo     *queries.StorageBlobRepository
+	storages *storage.Registry
+	orgs     *OrganizationStorageService
+}
+
+// NewBlobService creates new blob service
+func NewBlobService(repo *queries.StorageBlobRepository, storages *storage.Registry, orgs *OrganizationStorageService) *BlobService {
+	return &BlobService{repo: repo, storages: storages, orgs: orgs}
+}
+
+// Put stores data in the storage of an organization ("" for the global storage) unless a
+// blob with the same content is already there, and returns the blob
+func (s *BlobService) Put(ctx context.Context, organizationID string, data []byte, filename, contentType string, metadata map[string]any) (*models.StorageBlob, error) {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	location, err := s.orgs.Location(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if blob, err := s.repo.FindByChecksum(ctx, organizationID, location, checksum); err != nil || blob != nil {
+		return blob, err
+	}
+
+	store, err := s.storages.ForOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	key := storage.BlobKey(checksum)
+	if err := storage.Put(ctx, store, key, data, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store blob %s: %w", key, err)
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	return s.repo.Create(ctx, &models.StorageBlob{
+		Filename:       filename,
+		ContentType:    contentType,
+		Metadata:       metadata,
+		ByteSize:       int64(len(data)),
+		Checksum:       checksum,
+		Key:            key,
+		OrganizationID: organizationID,
+		Storage:        location,
+	})
+}
+
+// Resolve returns the key a file served under a storage key is stored at: files of template
+// pages stored as blobs are read from lc_blobs, other files (and pages stored before) in place
+func (s *BlobService) Resolve(ctx context.Context, key string) (string, error) {
+	name, ok := strings.CutPrefix(key, storage.PrefixPages+"/")
+	if !ok {
+		return key, nil
+	}
+	attachmentID, file, ok := strings.Cut(name, "/")
+	if !ok || uuid.Validate(attachmentID) != nil {
+		return key, nil
+	}
+	blobKey, err := s.repo.PageFileKey(ctx, attachmentID, file)
+	if err != nil || blobKey == "" {
+		return key, err
+	}
+	return blobKey, nil
+}
+
+// LocateFile returns the organization whose storage holds a file served under /drive and
+// the key it is stored at
+func (s *BlobService) LocateFile(ctx context.Context, key string) (string, string, error) {
+	organizationID, err := s.orgs.FileOrganization(ctx, key)
+	if err != nil {
+		return "", "", err
+	}
+	storedKey, err := s.Resolve(ctx, key)
+	return organizationID, storedKey, err
+}
+
+// RenameKeys updates the keys of the blobs of a storage moved by a migration, old key to new key
+func (s *BlobService) RenameKeys(ctx context.Context, organizationID string, renamed map[string]string) error {
+	location, err := s.orgs.Location(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	for oldKey, newKey := range renamed {
+		if err := s.repo.RenameKey(ctx, organizationID, location, oldKey, newKey); err != nil {
+			return fmt.Errorf("failed to rename blob %s: %w", oldKey, err)
+		}
+	}
+	return nil
+}
+
+// GlobalStorageOrganizations returns the organizations ("" for none) with blobs in the global
+// storage, whose keys a migration of the global storage renames
+func (s *BlobService) GlobalStorageOrganizations(ctx context.Context) ([]string, error) {
+	return s.repo.Organizations(ctx, "")
+}
+
+// BlobGCReport lists what a garbage collection removed, or would remove in a dry run
+type BlobGCReport struct {
+	DryRun      bool     `json:"dry_run"`
+	Attachments int      `json:"attachments"` // attachments of deleted templates and unused pages
+	Blobs       []string `json:"blobs"`       // keys of unreferenced blobs
+	Files       []string `json:"files"`       // files without a blob, page or submission
+	Bytes       int64    `json:"bytes"`
+}
+
+// CollectGarbage removes what no template or submission references any more, once it has
+// been so for the grace period: attachments of deleted templates and of pages no template
+// uses, blobs left without attachments, and files of deleted pages and submissions (also
+// those stored before blobs were content addressed). A dry run only reports them.
+func (s *BlobService) CollectGarbage(ctx context.Context, grace time.Duration, dryRun bool) (*BlobGCReport, error) {
+	release, ok, err := s.repo.TryLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("garbage collection is already running")
+	}
+	defer release()
+
+	before := time.Now().Add(-grace)
+	report := &BlobGCReport{DryRun: dryRun, Blobs: []string{}, Files: []string{}}
+
+	if dryRun {
+		report.Attachments, err = s.repo.OrphanedAttachments(ctx, before)
+	} else {
+		report.Attachments, err = s.repo.DeleteOrphanedAttachments(ctx, before)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to release orphaned attachments: %w", err)
+	}
+
+	blobs, err := s.repo.Unreferenced(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		if !dryRun {
+			if deleted, err := s.repo.Delete(ctx, blob.ID, before); err != nil || !deleted {
+				if err != nil {
+					return report, err
+				}
+				continue // referenced again meanwhile
+			}
+			if blob.Key != "" {
+				if err := s.deleteFile(ctx, blob.OrganizationID, blob.Storage, blob.Key); err != nil {
+					return report, err
+				}
+			}
+		}
+		if blob.Key != "" {
+			report.Blobs = append(report.Blobs, blob.Key)
+			report.Bytes += blob.ByteSize
+		}
+	}
+
+	orgs, err := s.orgs.Organizations(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, organizationID := range append([]string{""}, orgs...) {
+		if err := s.collectFiles(ctx, organizationID, before, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// collectFiles removes the files of a storage that nothing references since before
+func (s *BlobService) collectFiles(ctx context.Context, organizationID string, before time.Time, report *BlobGCReport) error {
+	store, err := s.storages.ForOrganization(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	location, err := s.orgs.Location(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	for _, prefix := range []string{storage.PrefixPages, storage.PrefixSigned, storage.PrefixBlobs} {
+		keys, err := store.List(ctx, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		for _, key := range keys {
+			orphan, err := s.orphanFile(ctx, organizationID, location, key)
+			if err != nil {
+				return err
+			}
+			if !orphan {
+				continue
+			}
+			meta, err := store.GetMetadata(ctx, key)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !meta.Modified.Before(before) {
+				continue
+			}
+			if !report.DryRun {
+				if err := store.Delete(ctx, key); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", key, err)
+				}
+			}
+			report.Files = append(report.Files, key)
+			report.Bytes += meta.Size
+		}
+	}
+	return nil
+}
+
+// orphanFile reports whether nothing references a stored file: a blob without a row, a page
+// no template uses or a document of a deleted submission. Other files are kept.
+func (s *BlobService) orphanFile(ctx context.Context, organizationID, location, key string) (bool, error) {
+	prefix, name, _ := strings.Cut(key, "/")
+	switch prefix {
+	case storage.PrefixBlobs:
+		exists, err := s.repo.BlobExists(ctx, organizationID, location, key)
+		return !exists, err
+	case storage.PrefixPages:
+		attachmentID, _, _ := strings.Cut(name, "/")
+		if uuid.Validate(attachmentID) != nil {
+			return false, nil
+		}
+		used, err := s.repo.PageInUse(ctx, attachmentID)
+		return !used, err
+	case storage.PrefixSigned:
+		rest, ok := strings.CutPrefix(name, "submission_")
+		if !ok {
+			return false, nil
+		}
+		submissionID, _, ok := strings.Cut(rest, "_")
+		if !ok || uuid.Validate(submissionID) != nil {
+			return false, nil
+		}
+		exists, err := s.repo.SubmissionExists(ctx, submissionID)
+		return !exists, err
+	}
+	return false, nil
+}
+
+// deleteFile removes the file of a blob from the storage at location. A file in a storage the
+// organization no longer uses is left there: the same key may hold a blob of the current one.
+func (s *BlobService) deleteFile(ctx context.Context, organizationID, location, key string) error {
+	current, err := s.orgs.Location(ctx, organizationID)
+	if err != nil || current != location {
+		return err
+	}
+	store, err := s.storages.ForOrganization(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/testutil"
+	"github.com/shurco/gosign/pkg/storage"
+)
+
+func TestBlobService_ResolveInPlace(t *testing.T) {
+	s := &BlobService{}
+	for _, key := range []string{
+		"lc_signed/submission_x_completed_v3.pdf",
+		"lc_uploads/upload.pdf",
+		"lc_pages/not-an-id/0.pdf",
+		"lc_pages",
+	} {
+		if got, err := s.Resolve(context.Background(), key); err != nil || got != key {
+			t.Errorf("Resolve(%q) = %q, %v, want the key itself", key, got, err)
+		}
+	}
+	for _, key := range []string{"lc_uploads/x.pdf", "lc_pages/not-an-id/0.pdf", "lc_signed/x.pdf", "lc_signed/submission_not-an-id_completed_v3.pdf"} {
+		if orphan, err := s.orphanFile(context.Background(), "", "", key); err != nil || orphan {
+			t.Errorf("orphanFile(%q) = %v, %v, want kept", key, orphan, err)
+		}
+	}
+}
+
+func TestBlobService(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgs := NewOrganizationStorageService(queries.NewOrganizationStorageRepository(pool), nil)
+	s := NewBlobService(queries.NewStorageBlobRepository(pool), storage.NewRegistry(local, orgs.Config), orgs)
+	templates := &queries.TemplateQueries{Pool: pool}
+
+	page := []byte("%PDF-1.7 page")
+	first, err := s.Put(ctx, "", page, "0.pdf", "application/pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Put(ctx, "", page, "0.pdf", "application/pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID || first.Key != storage.BlobKey(first.Checksum) {
+		t.Fatalf("identical content stored twice: %+v, %+v", first, second)
+	}
+	if data, err := storage.ReadAll(ctx, local, first.Key); err != nil || string(data) != string(page) {
+		t.Fatalf("blob content = %q, %v", data, err)
+	}
+
+	// a page no template uses: its attachment and then its blob are garbage
+	attachmentID := uuid.NewString()
+	if err := templates.CreateStorageAttachment(ctx, uuid.NewString(), first.ID, "Page", attachmentID, "0.pdf", "disk"); err != nil {
+		t.Fatal(err)
+	}
+	key := storage.Key(storage.PrefixPages, attachmentID, "0.pdf")
+	if got, err := s.Resolve(ctx, key); err != nil || got != first.Key {
+		t.Fatalf("Resolve(%q) = %q, %v, want %q", key, got, err, first.Key)
+	}
+
+	report, err := s.CollectGarbage(ctx, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Attachments != 1 || len(report.Blobs) != 0 {
+		t.Fatalf("dry run report = %+v, want 1 attachment", report)
+	}
+	if report, err = s.CollectGarbage(ctx, 0, false); err != nil || report.Attachments != 1 {
+		t.Fatalf("CollectGarbage() = %+v, %v", report, err)
+	}
+	// the blob has just lost its last reference, the next run removes it
+	if report, err = s.CollectGarbage(ctx, 0, false); err != nil || len(report.Blobs) != 1 || report.Blobs[0] != first.Key {
+		t.Fatalf("CollectGarbage() = %+v, %v, want blob %s", report, err, first.Key)
+	}
+	if exists, _ := local.Exists(ctx, first.Key); exists {
+		t.Fatal("blob file not removed")
+	}
+
+	// a blob left in a bucket the organization no longer uses isn't reused
+	orgID := uuid.NewString()
+	stale, err := queries.NewStorageBlobRepository(pool).Create(ctx, &models.StorageBlob{
+		Filename: "0.pdf", Metadata: map[string]any{}, ByteSize: int64(len(page)), Checksum: first.Checksum,
+		Key: first.Key, OrganizationID: orgID, Storage: "s3:/old-bucket",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := s.Put(ctx, orgID, page, "0.pdf", "application/pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.ID == stale.ID || blob.Storage != "" {
+		t.Fatalf("Put() = %+v, reused the blob of another storage", blob)
+	}
+	if data, err := storage.ReadAll(ctx, local, blob.Key); err != nil || string(data) != string(page) {
+		t.Fatalf("blob content = %q, %v", data, err)
+	}
+}
+
+func TestBlobService_RenameKeysOfGlobalStorage(t *testing.T) {
+	pool := testutil.NewTestDB(t)
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgs := NewOrganizationStorageService(queries.NewOrganizationStorageRepository(pool), nil)
+	repo := queries.NewStorageBlobRepository(pool)
+	s := NewBlobService(repo, storage.NewRegistry(local, orgs.Config), orgs)
+
+	// an organization without a bucket of its own stores in the global storage
+	org := &models.Organization{ID: uuid.NewString(), Name: "Global Storage Org", OwnerID: testutil.User1.AccountID}
+	if err := queries.NewOrganizationQueries(pool).CreateOrganization(ctx, org); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("%PDF-1.7 " + uuid.NewString())
+	global, err := s.Put(ctx, "", data, "a.pdf", "application/pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, org.ID, data, "a.pdf", "application/pdf", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	organizations, err := s.GlobalStorageOrganizations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := global.Key + ".moved"
+	for _, organizationID := range organizations {
+		if err := s.RenameKeys(ctx, organizationID, map[string]string{global.Key: moved}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, organizationID := range []string{"", org.ID} {
+		blob, err := repo.FindByChecksum(ctx, organizationID, "", global.Checksum)
+		if err != nil || blob == nil || blob.Key != moved {
+			t.Errorf("blob of %q = %+v, %v, want key %s", organizationID, blob, err, moved)
+		}
+	}
+}
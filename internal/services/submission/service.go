@@ -10,7 +10,6 @@ import (
 
 	"github.com/shurco/gosign/internal/models"
 	"github.com/shurco/gosign/pkg/notification"
-	"github.com/shurco/gosign/pkg/webhook"
 )
 
 // SubmissionState represents submission state
@@ -35,22 +34,38 @@ type Repository interface {
 	GetSubmittersByOrder(ctx context.Context, submissionID string, order int) ([]*models.Submitter, error)
 	GetSubmitter(ctx context.Context, id string) (*models.Submitter, error)
 	UpdateSubmitterStatus(ctx context.Context, id string, status models.SubmitterStatus) error
+	// ClaimInvitation marks a pending submitter opened in a single statement; false means
+	// it wasn't pending, e.g. a concurrent completion already invited it
+	ClaimInvitation(ctx context.Context, id string) (bool, error)
 	CreateEvent(ctx context.Context, event *models.Event) error
 }
 
+// WebhookEvents emits the webhook events of submissions; submitterID is set for submitter events
+type WebhookEvents interface {
+	Submission(ctx context.Context, eventType, submissionID, submitterID string)
+}
+
+// Reminders schedules the reminders of the signing group of a submission invited now
+type Reminders interface {
+	Remind(ctx context.Context, submission *models.Submission) error
+}
+
 // Service manages submission workflow
 type Service struct {
-	repo              Repository
-	notificationSvc   *notification.Service
-	webhookDispatcher *webhook.Dispatcher
+	repo            Repository
+	notificationSvc *notification.Service
+	webhooks        WebhookEvents
+
+	// Reminders, when set, is told about each invited signing group
+	Reminders Reminders
 }
 
 // NewService creates a new service
-func NewService(repo Repository, notificationSvc *notification.Service, webhookDispatcher *webhook.Dispatcher) *Service {
+func NewService(repo Repository, notificationSvc *notification.Service, webhooks WebhookEvents) *Service {
 	return &Service{
-		repo:              repo,
-		notificationSvc:   notificationSvc,
-		webhookDispatcher: webhookDispatcher,
+		repo:            repo,
+		notificationSvc: notificationSvc,
+		webhooks:        webhooks,
 	}
 }
 
@@ -67,6 +82,7 @@ type SubmitterInput struct {
 	Name  string
 	Email string
 	Phone string
+	Order *int // signing order; submitters sharing it sign in parallel (default: position in the list)
 }
 
 // Create creates a new submission in draft status
@@ -77,6 +93,12 @@ func (s *Service) Create(ctx context.Context, input CreateSubmissionInput) (*mod
 		signingMode = models.SigningModeSequential
 	}
 
+	for i, submitterInput := range input.Submitters {
+		if submitterInput.Order != nil && *submitterInput.Order < 0 {
+			return nil, fmt.Errorf("invalid order of submitter %d: %d", i, *submitterInput.Order)
+		}
+	}
+
 	submission := &models.Submission{
 		ID:          uuid.New().String(),
 		TemplateID:  input.TemplateID,
@@ -91,6 +113,10 @@ func (s *Service) Create(ctx context.Context, input CreateSubmissionInput) (*mod
 
 	// Create submitters with assigned order
 	for i, submitterInput := range input.Submitters {
+		order := i
+		if submitterInput.Order != nil {
+			order = *submitterInput.Order
+		}
 		submitter := &models.Submitter{
 			ID:           uuid.New().String(),
 			Name:         submitterInput.Name,
@@ -99,7 +125,7 @@ func (s *Service) Create(ctx context.Context, input CreateSubmissionInput) (*mod
 			Slug:         uuid.New().String(), // Generate unique signing link
 			Status:       models.SubmitterStatusPending,
 			SubmissionID: submission.ID,
-			Order:        i, // Assign sequential order
+			Order:        order,
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		}
@@ -110,6 +136,7 @@ func (s *Service) Create(ctx context.Context, input CreateSubmissionInput) (*mod
 	}
 
 	_ = s.logEvent(ctx, models.EventSubmissionCreated, input.CreatedByID, "submission", submission.ID, nil)
+	s.sendWebhook(ctx, models.EventSubmissionCreated, submission.ID, "")
 
 	log.Info().Str("submission_id", submission.ID).Str("signing_mode", string(signingMode)).Msg("Submission created")
 	return submission, nil
@@ -148,25 +175,29 @@ func (s *Service) Send(ctx context.Context, submissionID string) error {
 			}
 		}
 	case models.SigningModeSequential:
-		// Send invitation only to the first submitter (order 0)
-		firstSubmitter, err := s.getNextSequentialSubmitter(submitters)
+		// Send invitations only to the first signing group (the lowest order)
+		firstGroup, err := s.getNextSigningGroup(ctx, submissionID, submitters)
 		if err != nil {
-			return fmt.Errorf("failed to get first sequential submitter: %w", err)
+			return fmt.Errorf("failed to get first signing group: %w", err)
 		}
-		if err := s.sendInvitation(ctx, submission, firstSubmitter); err != nil {
-			return fmt.Errorf("failed to send invitation: %w", err)
+		for _, submitter := range firstGroup {
+			if err := s.sendInvitation(ctx, submission, submitter); err != nil {
+				return fmt.Errorf("failed to send invitation: %w", err)
+			}
 		}
 	default:
 		return fmt.Errorf("unsupported signing mode: %s", submission.SigningMode)
 	}
 
+	s.scheduleReminders(ctx, submission)
+
 	// Update submission status
 	if err := s.repo.UpdateSubmissionState(ctx, submissionID, StateInProgress); err != nil {
 		return fmt.Errorf("failed to update submission state: %w", err)
 	}
 
 	// Send webhook
-	s.sendWebhook(ctx, models.EventSubmissionCreated, submission)
+	s.sendWebhook(ctx, models.EventSubmissionSent, submissionID, "")
 
 	log.Info().Str("submission_id", submissionID).Str("signing_mode", string(submission.SigningMode)).Msg("Submission sent")
 	return nil
@@ -179,6 +210,9 @@ func (s *Service) Complete(ctx context.Context, submitterID string) error {
 	}
 
 	_ = s.logEvent(ctx, models.EventSubmitterCompleted, "", "submitter", submitterID, nil)
+	if submitter, err := s.repo.GetSubmitter(ctx, submitterID); err == nil && submitter != nil {
+		s.sendWebhook(ctx, models.EventSubmitterCompleted, submitter.SubmissionID, submitterID)
+	}
 
 	// Handle sequential signing - send invitation to next submitter
 	if err := s.handleSequentialCompletion(ctx, submitterID); err != nil {
@@ -223,7 +257,7 @@ func (s *Service) CheckCompletion(ctx context.Context, submissionID string) erro
 		_ = s.notificationSvc.Send(n)
 	}
 
-	s.sendWebhook(ctx, models.EventSubmissionCompleted, submission)
+	s.sendWebhook(ctx, models.EventSubmissionCompleted, submission.ID, "")
 
 	log.Info().Str("submission_id", submissionID).Msg("Submission completed")
 	return nil
@@ -236,6 +270,9 @@ func (s *Service) Decline(ctx context.Context, submitterID, reason string) error
 	}
 
 	_ = s.logEvent(ctx, models.EventSubmitterDeclined, "", "submitter", submitterID, map[string]any{"reason": reason})
+	if submitter, err := s.repo.GetSubmitter(ctx, submitterID); err == nil && submitter != nil {
+		s.sendWebhook(ctx, models.EventSubmitterDeclined, submitter.SubmissionID, submitterID)
+	}
 
 	log.Info().Str("submitter_id", submitterID).Str("reason", reason).Msg("Submitter declined")
 	return nil
@@ -262,7 +299,7 @@ func (s *Service) HandleDecline(ctx context.Context, submissionID string, reason
 		_ = s.notificationSvc.Send(n)
 	}
 
-	s.sendWebhook(ctx, models.EventSubmissionCancelled, submission)
+	s.sendWebhook(ctx, models.EventSubmissionCancelled, submission.ID, "")
 
 	log.Info().Str("submission_id", submissionID).Str("reason", reason).Msg("Submission declined and cancelled")
 	return nil
@@ -275,6 +312,7 @@ func (s *Service) Expire(ctx context.Context, submissionID string) error {
 	}
 
 	_ = s.logEvent(ctx, models.EventSubmissionExpired, "", "submission", submissionID, nil)
+	s.sendWebhook(ctx, models.EventSubmissionExpired, submissionID, "")
 
 	log.Info().Str("submission_id", submissionID).Msg("Submission expired")
 	return nil
@@ -335,25 +373,18 @@ func (s *Service) sendInvitation(ctx context.Context, submission *models.Submiss
 
 	_ = s.repo.UpdateSubmitterStatus(ctx, submitter.ID, models.SubmitterStatusOpened)
 	_ = s.logEvent(ctx, models.EventSubmitterSent, "", "submitter", submitter.ID, nil)
+	s.sendWebhook(ctx, models.EventSubmitterSent, submission.ID, submitter.ID)
 
 	return nil
 }
 
-// sendWebhook sends a webhook event
-func (s *Service) sendWebhook(ctx context.Context, eventType string, submission *models.Submission) {
-	// TODO: Get webhooks for account from database
-	// TODO: Send via dispatcher
-	
-	webhookEvent := &models.WebhookEvent{
-		Type:      eventType,
-		Timestamp: time.Now(),
-		Data: map[string]any{
-			"submission_id": submission.ID,
-			"template_id":   submission.TemplateID,
-		},
+// sendWebhook emits a webhook event of a submission (of one of its submitters when
+// submitterID is set); emitting never fails the submission
+func (s *Service) sendWebhook(ctx context.Context, eventType, submissionID, submitterID string) {
+	if s.webhooks == nil {
+		return
 	}
-
-	_ = webhookEvent // stub
+	s.webhooks.Submission(ctx, eventType, submissionID, submitterID)
 }
 
 // createNotification creates a notification with common fields
@@ -371,62 +402,136 @@ func (s *Service) createNotification(template, subject string, context map[strin
 	}
 }
 
-// getNextSequentialSubmitter returns the next submitter in sequential order
-func (s *Service) getNextSequentialSubmitter(submitters []*models.Submitter) (*models.Submitter, error) {
-	// Sort submitters by order
-	for _, submitter := range submitters {
-		if submitter.Order == 0 {
-			return submitter, nil
-		}
+// getNextSigningGroup returns the submitters of the signing group to sign now, those that
+// share the lowest order of the submitters who haven't completed
+func (s *Service) getNextSigningGroup(ctx context.Context, submissionID string, submitters []*models.Submitter) ([]*models.Submitter, error) {
+	order, ok := models.ActiveSigningOrder(submitters)
+	if !ok {
+		return nil, fmt.Errorf("no submitter left to sign: all completed or one declined")
+	}
+	return s.repo.GetSubmittersByOrder(ctx, submissionID, order)
+}
+
+// SigningTurn reports whether a submitter may open and complete the document now, see
+// models.SigningTurn
+func (s *Service) SigningTurn(ctx context.Context, submitterID string) (bool, error) {
+	submitter, err := s.repo.GetSubmitter(ctx, submitterID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get submitter: %w", err)
+	}
+	submission, err := s.repo.GetSubmission(ctx, submitter.SubmissionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get submission: %w", err)
+	}
+	submitters, err := s.repo.GetSubmitters(ctx, submitter.SubmissionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get submitters: %w", err)
 	}
-	return nil, fmt.Errorf("no submitter with order 0 found")
+	return models.SigningTurn(submission.SigningMode, submitters, submitterID), nil
 }
 
-// handleSequentialCompletion sends invitation to the next submitter in sequential mode
+// Advance invites the next signing group after a submitter completed outside Complete,
+// e.g. through the public signing page, which records the completion and finalizes the
+// submission itself
+func (s *Service) Advance(ctx context.Context, submitterID string) error {
+	_, err := s.inviteNextGroup(ctx, submitterID)
+	return err
+}
+
+// handleSequentialCompletion sends invitations to the next signing group in sequential mode
+// once every submitter of the completed submitter's group has completed
 func (s *Service) handleSequentialCompletion(ctx context.Context, submitterID string) error {
+	submissionID, err := s.inviteNextGroup(ctx, submitterID)
+	if err != nil || submissionID == "" {
+		return err
+	}
+	// Everyone completed, or a decline stopped the signing (CheckCompletion then does nothing)
+	return s.CheckCompletion(ctx, submissionID)
+}
+
+// inviteNextGroup sends invitations to the next signing group in sequential mode once
+// every submitter of the completed submitter's group has completed. It returns the
+// submission ID when no group is left to sign.
+func (s *Service) inviteNextGroup(ctx context.Context, submitterID string) (string, error) {
 	// Get the completed submitter
 	completedSubmitter, err := s.repo.GetSubmitter(ctx, submitterID)
 	if err != nil {
-		return fmt.Errorf("failed to get completed submitter: %w", err)
+		return "", fmt.Errorf("failed to get completed submitter: %w", err)
 	}
 
 	// Get submission to check signing mode
 	submission, err := s.repo.GetSubmission(ctx, completedSubmitter.SubmissionID)
 	if err != nil {
-		return fmt.Errorf("failed to get submission: %w", err)
+		return "", fmt.Errorf("failed to get submission: %w", err)
 	}
 
 	// Only handle sequential mode
 	if submission.SigningMode != models.SigningModeSequential {
-		return nil
+		return "", nil
 	}
 
-	// Find the next submitter in order
-	nextOrder := completedSubmitter.Order + 1
-	nextSubmitters, err := s.repo.GetSubmittersByOrder(ctx, completedSubmitter.SubmissionID, nextOrder)
+	submitters, err := s.repo.GetSubmitters(ctx, completedSubmitter.SubmissionID)
 	if err != nil {
-		return fmt.Errorf("failed to get next submitters: %w", err)
+		return "", fmt.Errorf("failed to get submitters: %w", err)
 	}
 
-	if len(nextSubmitters) == 0 {
-		// No more submitters, check if submission is complete
-		return s.CheckCompletion(ctx, completedSubmitter.SubmissionID)
+	nextOrder, ok := models.ActiveSigningOrder(submitters)
+	if !ok {
+		return submission.ID, nil
+	}
+	if nextOrder <= completedSubmitter.Order {
+		// The rest of the group (or an earlier one) is still signing
+		return "", nil
 	}
 
-	// Send invitation to the next submitter
-	nextSubmitter := nextSubmitters[0]
-	if err := s.sendInvitation(ctx, submission, nextSubmitter); err != nil {
-		return fmt.Errorf("failed to send invitation to next submitter: %w", err)
+	nextSubmitters, err := s.getNextSigningGroup(ctx, completedSubmitter.SubmissionID, submitters)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next submitters: %w", err)
 	}
 
-	log.Info().
-		Str("submission_id", submission.ID).
-		Str("completed_submitter_id", submitterID).
-		Str("next_submitter_id", nextSubmitter.ID).
-		Int("next_order", nextOrder).
-		Msg("Sequential invitation sent to next submitter")
+	// Send invitations to the whole next group. Concurrent completions of the group may
+	// all get here: only the one that claims a submitter invites it, so nobody is invited twice.
+	invited := false
+	for _, nextSubmitter := range nextSubmitters {
+		claimed, err := s.repo.ClaimInvitation(ctx, nextSubmitter.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to claim invitation of next submitter: %w", err)
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.sendInvitation(ctx, submission, nextSubmitter); err != nil {
+			// Let a retry invite the submitter
+			if rerr := s.repo.UpdateSubmitterStatus(ctx, nextSubmitter.ID, models.SubmitterStatusPending); rerr != nil {
+				log.Error().Err(rerr).Str("submitter_id", nextSubmitter.ID).Msg("Failed to release invitation of next submitter")
+			}
+			return "", fmt.Errorf("failed to send invitation to next submitter: %w", err)
+		}
 
-	return nil
+		log.Info().
+			Str("submission_id", submission.ID).
+			Str("completed_submitter_id", submitterID).
+			Str("next_submitter_id", nextSubmitter.ID).
+			Int("next_order", nextOrder).
+			Msg("Sequential invitation sent to next submitter")
+		invited = true
+	}
+	if invited {
+		s.scheduleReminders(ctx, submission)
+	}
+
+	return "", nil
+}
+
+// scheduleReminders schedules the reminders of the signing group just invited; reminders
+// never fail the signing
+func (s *Service) scheduleReminders(ctx context.Context, submission *models.Submission) {
+	if s.Reminders == nil {
+		return
+	}
+	if err := s.Reminders.Remind(ctx, submission); err != nil {
+		log.Error().Err(err).Str("submission_id", submission.ID).Msg("Failed to schedule reminders")
+	}
 }
 
 // logEvent logs an event to the database
@@ -125,6 +125,18 @@ func (m *mockRepository) UpdateSubmitterStatus(ctx context.Context, id string, s
 	return errors.New("submitter not found")
 }
 
+func (m *mockRepository) ClaimInvitation(ctx context.Context, id string) (bool, error) {
+	sub, ok := m.submitters[id]
+	if !ok {
+		return false, errors.New("submitter not found")
+	}
+	if sub.Status != models.SubmitterStatusPending {
+		return false, nil
+	}
+	sub.Status = models.SubmitterStatusOpened
+	return true, nil
+}
+
 func (m *mockRepository) CreateEvent(ctx context.Context, event *models.Event) error {
 	return nil
 }
@@ -514,3 +526,143 @@ func TestService_Send_SequentialValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestSigningGroups(t *testing.T) {
+	repo := newMockRepository()
+	repo.submissions["sub1"] = &models.Submission{ID: "sub1", SigningMode: models.SigningModeSequential, Status: models.SubmissionStatusDraft}
+	// Both buyers in parallel, then the seller, then two witnesses in parallel
+	for id, order := range map[string]int{"buyer1": 0, "buyer2": 0, "seller": 1, "witness1": 2, "witness2": 2} {
+		repo.submitters[id] = &models.Submitter{ID: id, SubmissionID: "sub1", Order: order, Status: models.SubmitterStatusPending}
+	}
+	invited := func() []string {
+		var ids []string
+		for _, id := range []string{"buyer1", "buyer2", "seller", "witness1", "witness2"} {
+			if repo.submitters[id].Status != models.SubmitterStatusPending {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	service := NewService(repo, createMockNotificationService(), nil)
+	ctx := context.Background()
+
+	require.NoError(t, service.Send(ctx, "sub1"))
+	assert.Equal(t, []string{"buyer1", "buyer2"}, invited())
+
+	require.NoError(t, service.Complete(ctx, "buyer1"))
+	assert.Equal(t, []string{"buyer1", "buyer2"}, invited(), "the seller waits for both buyers")
+
+	require.NoError(t, service.Complete(ctx, "buyer2"))
+	assert.Equal(t, []string{"buyer1", "buyer2", "seller"}, invited())
+
+	require.NoError(t, service.Complete(ctx, "seller"))
+	assert.Equal(t, []string{"buyer1", "buyer2", "seller", "witness1", "witness2"}, invited())
+
+	require.NoError(t, service.Complete(ctx, "witness2"))
+	assert.Equal(t, models.SubmissionStatus(StateInProgress), repo.submissions["sub1"].Status)
+
+	require.NoError(t, service.Complete(ctx, "witness1"))
+	assert.Equal(t, models.SubmissionStatus(StateCompleted), repo.submissions["sub1"].Status)
+}
+
+func TestSigningGroups_ConcurrentCompletion(t *testing.T) {
+	repo := newMockRepository()
+	repo.submissions["sub1"] = &models.Submission{ID: "sub1", SigningMode: models.SigningModeSequential}
+	// Both buyers completed at the same time; each completion sees the group done
+	repo.submitters["buyer1"] = &models.Submitter{ID: "buyer1", SubmissionID: "sub1", Order: 0, Status: models.SubmitterStatusCompleted}
+	repo.submitters["buyer2"] = &models.Submitter{ID: "buyer2", SubmissionID: "sub1", Order: 0, Status: models.SubmitterStatusCompleted}
+	repo.submitters["seller"] = &models.Submitter{ID: "seller", SubmissionID: "sub1", Order: 1, Status: models.SubmitterStatusPending}
+
+	// Both completions read the next group before either invites it
+	seller := *repo.submitters["seller"]
+	stale := &staleGroupRepository{mockRepository: repo, group: []*models.Submitter{&seller}}
+
+	webhooks := &recordingWebhookEvents{}
+	service := NewService(stale, createMockNotificationService(), webhooks)
+	ctx := context.Background()
+
+	require.NoError(t, service.handleSequentialCompletion(ctx, "buyer1"))
+	require.NoError(t, service.handleSequentialCompletion(ctx, "buyer2"))
+	assert.Equal(t, []string{"submitter.sent sub1 seller"}, webhooks.events, "the seller is invited once")
+}
+
+// staleGroupRepository returns a signing group as read before a concurrent invitation
+type staleGroupRepository struct {
+	*mockRepository
+	group []*models.Submitter
+}
+
+func (r *staleGroupRepository) GetSubmittersByOrder(ctx context.Context, submissionID string, order int) ([]*models.Submitter, error) {
+	return r.group, nil
+}
+
+func TestSigningGroups_Declined(t *testing.T) {
+	repo := newMockRepository()
+	repo.submissions["sub1"] = &models.Submission{ID: "sub1", SigningMode: models.SigningModeSequential, Status: models.SubmissionStatus(StateInProgress)}
+	repo.submitters["buyer1"] = &models.Submitter{ID: "buyer1", SubmissionID: "sub1", Order: 0, Status: models.SubmitterStatusOpened}
+	repo.submitters["buyer2"] = &models.Submitter{ID: "buyer2", SubmissionID: "sub1", Order: 0, Status: models.SubmitterStatusDeclined}
+	repo.submitters["seller"] = &models.Submitter{ID: "seller", SubmissionID: "sub1", Order: 1, Status: models.SubmitterStatusPending}
+
+	submitters, _ := repo.GetSubmitters(context.Background(), "sub1")
+	_, ok := models.ActiveSigningOrder(submitters)
+	assert.False(t, ok, "a decline ends the signing")
+
+	service := NewService(repo, createMockNotificationService(), nil)
+	require.NoError(t, service.Complete(context.Background(), "buyer1"))
+	assert.Equal(t, models.SubmitterStatusPending, repo.submitters["seller"].Status, "the next group isn't invited after a decline")
+	assert.Equal(t, models.SubmissionStatus(StateInProgress), repo.submissions["sub1"].Status)
+}
+
+func TestCreate_SubmitterOrders(t *testing.T) {
+	order := func(o int) *int { return &o }
+	repo := newMockRepository()
+	service := NewService(repo, nil, nil)
+
+	submission, err := service.Create(context.Background(), CreateSubmissionInput{
+		Submitters: []SubmitterInput{{Name: "A", Order: order(0)}, {Name: "B", Order: order(0)}, {Name: "C"}},
+	})
+	require.NoError(t, err)
+
+	orders := map[string]int{}
+	for _, s := range repo.submitters {
+		require.Equal(t, submission.ID, s.SubmissionID)
+		orders[s.Name] = s.Order
+	}
+	assert.Equal(t, map[string]int{"A": 0, "B": 0, "C": 2}, orders)
+
+	_, err = service.Create(context.Background(), CreateSubmissionInput{Submitters: []SubmitterInput{{Order: order(-1)}}})
+	assert.Error(t, err)
+}
+
+// recordingWebhookEvents records the webhook events emitted by the service
+type recordingWebhookEvents struct {
+	events []string
+}
+
+func (r *recordingWebhookEvents) Submission(ctx context.Context, eventType, submissionID, submitterID string) {
+	r.events = append(r.events, eventType+" "+submissionID+" "+submitterID)
+}
+
+func TestWebhookEvents(t *testing.T) {
+	repo := newMockRepository()
+	repo.submissions["sub1"] = &models.Submission{ID: "sub1", Status: models.SubmissionStatus(StateInProgress)}
+	repo.submitters["submitter1"] = &models.Submitter{ID: "submitter1", SubmissionID: "sub1", Status: models.SubmitterStatusOpened}
+	repo.submitters["submitter2"] = &models.Submitter{ID: "submitter2", SubmissionID: "sub1", Status: models.SubmitterStatusCompleted}
+
+	webhooks := &recordingWebhookEvents{}
+	service := NewService(repo, nil, webhooks)
+	ctx := context.Background()
+
+	require.NoError(t, service.Complete(ctx, "submitter1"))
+	require.NoError(t, service.CheckCompletion(ctx, "sub1"))
+	require.NoError(t, service.Decline(ctx, "submitter2", "changed my mind"))
+	require.NoError(t, service.Expire(ctx, "sub1"))
+
+	assert.Equal(t, []string{
+		"submitter.completed sub1 submitter1",
+		"submission.completed sub1 ",
+		"submitter.declined sub1 submitter2",
+		"submission.expired sub1 ",
+	}, webhooks.events)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/pdf"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/storage"
+)
+
+// ErrSubmitterKeysNotConfigured is returned when incremental signing is requested without a key provider
+var ErrSubmitterKeysNotConfigured = errors.New("submitter keys not configured")
+
+// SubmitterIdentity describes the submitter a signing key is issued to
+type SubmitterIdentity struct {
+	SubmissionID   string
+	SubmitterID    string
+	Name           string
+	Email          string
+	Role           string // template submitter name, e.g. "Buyer"
+	OrganizationID string // owner scope of the submission
+	AccountID      string
+}
+
+// SubmitterKeyProvider issues the key a submitter signs the document with,
+// e.g. a short-lived certificate of the internal CA.
+type SubmitterKeyProvider interface {
+	SubmitterKey(ctx context.Context, submitter SubmitterIdentity) (*SealingKey, error)
+}
+
+// SignedPDFKey is the document carrying one signature per submitter (incremental signature mode)
+func (b *CompletedDocumentBuilder) SignedPDFKey(submissionID string) string {
+	return storage.Key(storage.PrefixSigned, fmt.Sprintf("submission_%s_signatures_v1.pdf", submissionID))
+}
+
+// SignedPDF returns the document carrying one signature per submitter; the error wraps
+// storage.ErrNotFound when no submitter has signed yet
+func (b *CompletedDocumentBuilder) SignedPDF(ctx context.Context, submissionID string) ([]byte, error) {
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	return storage.ReadAll(ctx, store, b.SignedPDFKey(submissionID))
+}
+
+// SignatureMode returns the signature mode of a submission
+func (b *CompletedDocumentBuilder) SignatureMode(ctx context.Context, submissionID string) (models.SignatureMode, error) {
+	if b.Pool == nil {
+		return "", fmt.Errorf("db pool not configured")
+	}
+	var mode string
+	err := b.Pool.QueryRow(ctx, `
+		SELECT COALESCE(preferences->>'signature_mode', '')
+		FROM submission
+		WHERE id = $1
+	`, submissionID).Scan(&mode)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		return models.SignatureModeSeal, nil
+	}
+	return models.SignatureMode(mode), nil
+}
+
+// AppendSubmitterSignature signs the submission document on behalf of a submitter
+// who completes. The values of the fields the submitter filled are stamped on the
+// document and their signature covers them. The first signature is applied to
+// the original template document; every later one is an incremental update on
+// top of the previous signatures, so the file carries one verifiable signature
+// per submitter in signing order.
+//
+// complete, when set, marks the submitter completed in the same transaction: the
+// completion is rolled back when the document can't be signed, so no submitter
+// is completed without their signature.
+func (b *CompletedDocumentBuilder) AppendSubmitterSignature(ctx context.Context, submissionID, submitterID string, complete func(ctx context.Context, tx pgx.Tx) error) error {
+	if b.Pool == nil {
+		return fmt.Errorf("db pool not configured")
+	}
+	if b.TemplateQueries == nil {
+		return fmt.Errorf("template queries not configured")
+	}
+	store, err := b.storageFor(ctx, submissionID)
+	if err != nil {
+		return err
+	}
+	if b.SubmitterKeys == nil {
+		return ErrSubmitterKeysNotConfigured
+	}
+
+	// Completions of parallel submitters must not interleave on the same file,
+	// on this node or any other sharing the storage
+	b.signMu.Lock()
+	defer b.signMu.Unlock()
+	tx, err := b.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to lock submission document: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, "submission_signatures:"+submissionID); err != nil {
+		return fmt.Errorf("failed to lock submission document: %w", err)
+	}
+	if complete != nil {
+		if err := complete(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	identity := SubmitterIdentity{SubmissionID: submissionID, SubmitterID: submitterID}
+	var (
+		templateID  string
+		metaJSON    string
+		completedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT
+			sub.template_id,
+			COALESCE(s.name, ''),
+			COALESCE(s.email, ''),
+			COALESCE(s.metadata->>'template_submitter_id', ''),
+			COALESCE(t.organization_id::text, ''),
+			COALESCE(u.account_id::text, ''),
+			COALESCE(s.metadata, '{}'::jsonb)::text,
+			s.completed_at
+		FROM submitter s
+		JOIN submission sub ON sub.id = s.submission_id
+		JOIN template t ON t.id = sub.template_id
+		LEFT JOIN "user" u ON u.id = sub.created_by_user_id
+		WHERE s.id = $1 AND s.submission_id = $2
+	`, submitterID, submissionID).Scan(&templateID, &identity.Name, &identity.Email, &identity.Role, &identity.OrganizationID, &identity.AccountID, &metaJSON, &completedAt)
+	if err != nil {
+		return fmt.Errorf("failed to load submitter: %w", err)
+	}
+	var meta struct {
+		Fields map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(metaJSON), &meta); err != nil {
+		return fmt.Errorf("failed to load submitter fields: %w", err)
+	}
+
+	tpl, err := b.TemplateQueries.Template(ctx, templateID)
+	if err != nil || tpl == nil {
+		return fmt.Errorf("failed to load template: %w", err)
+	}
+	// Only the fields of the submitter are stamped, the others are on the document already
+	templateSubmitterID := identity.Role
+	fields := make([]models.Field, 0, len(tpl.Fields))
+	for _, f := range tpl.Fields {
+		if templateSubmitterID == "" || f.SubmitterID == templateSubmitterID {
+			fields = append(fields, f)
+		}
+	}
+	// Metadata holds the template submitter id; sign with its display name
+	for _, ts := range tpl.Submitters {
+		if ts.ID == identity.Role {
+			identity.Role = ts.Name
+			break
+		}
+	}
+
+	key, err := b.SubmitterKeys.SubmitterKey(ctx, identity)
+	if err != nil {
+		return fmt.Errorf("failed to issue submitter key: %w", err)
+	}
+
+	outKey := b.SignedPDFKey(submissionID)
+	doc, err := storage.ReadAll(ctx, store, outKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		doc, err = b.EnsureOriginalPDF(ctx, submissionID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load submission document: %w", err)
+	}
+
+	doc, err = sign.AddStamps(doc, pdf.FieldStamps(tpl.Schema, fields, meta.Fields))
+	if err != nil {
+		return fmt.Errorf("failed to fill submission document: %w", err)
+	}
+
+	signedAt := time.Now()
+	if completedAt != nil {
+		signedAt = *completedAt
+	}
+	doc, err = signSubmitterRevision(doc, key, identity, signedAt, b.Timestamps)
+	if err != nil {
+		return fmt.Errorf("failed to sign submission document: %w", err)
+	}
+
+	// Uploads replace the document atomically, readers never see a partially written revision
+	if err := storage.Put(ctx, store, outKey, doc, "application/pdf"); err != nil {
+		return fmt.Errorf("failed to write signed PDF: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// signSubmitterRevision appends an approval signature of the submitter to data.
+// Earlier signatures are kept untouched.
+func signSubmitterRevision(data []byte, key *SealingKey, submitter SubmitterIdentity, signedAt time.Time, timestamps sign.TimestampFunction) ([]byte, error) {
+	if key == nil || key.Signer == nil || key.Certificate == nil {
+		return nil, fmt.Errorf("submitter key is incomplete")
+	}
+
+	var chains [][]*x509.Certificate
+	if len(key.Chain) > 0 {
+		chains = append(chains, append([]*x509.Certificate{key.Certificate}, key.Chain...))
+	}
+
+	reason := fmt.Sprintf("Signed submission %s", submitter.SubmissionID)
+	if submitter.Role != "" {
+		reason = fmt.Sprintf("Signed submission %s as %s", submitter.SubmissionID, submitter.Role)
+	}
+
+	return sign.SignBytes(data, sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info: sign.SignDataSignatureInfo{
+				Name:        submitter.Name,
+				Reason:      reason,
+				ContactInfo: submitter.Email,
+				Date:        signedAt,
+			},
+			CertType: sign.ApprovalSignature,
+		},
+		Signer:            key.Signer,
+		RSAPSS:            key.RSAPSS,
+		DigestAlgorithm:   crypto.SHA256,
+		Certificate:       key.Certificate,
+		CertificateChains: chains,
+		TSA:               sign.TSA{Local: timestamps},
+	})
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func TestSignSubmitterRevision(t *testing.T) {
+	doc, err := os.ReadFile(filepath.Join("..", "..", "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	submitters := []SubmitterIdentity{
+		{SubmissionID: "sub-1", Name: "Alice", Email: "alice@example.com", Role: "Buyer"},
+		{SubmissionID: "sub-1", Name: "Bob", Email: "bob@example.com"},
+	}
+	for _, s := range submitters {
+		key, err := (&FileSealingKeyProvider{Dir: t.TempDir(), Name: s.Name}).SealingKey(context.Background(), "sub-1")
+		if err != nil {
+			t.Fatalf("SealingKey() error: %v", err)
+		}
+		doc, err = signSubmitterRevision(doc, key, s, time.Now(), nil)
+		if err != nil {
+			t.Fatalf("signSubmitterRevision(%s) error: %v", s.Name, err)
+		}
+	}
+
+	res, err := verify.Reader(bytes.NewReader(doc), int64(len(doc)))
+	if err != nil {
+		t.Fatalf("verify.Reader() error: %v", err)
+	}
+	if len(res.Signers) != 2 {
+		t.Fatalf("expected 2 signatures, got %d", len(res.Signers))
+	}
+
+	want := []struct{ name, reason, contact string }{
+		{"Alice", "Signed submission sub-1 as Buyer", "alice@example.com"},
+		{"Bob", "Signed submission sub-1", "bob@example.com"},
+	}
+	for i, w := range want {
+		s := res.Signers[i]
+		if s.Name != w.name || s.Reason != w.reason || s.ContactInfo != w.contact {
+			t.Fatalf("signature %d = %q/%q/%q, want %q/%q/%q", i, s.Name, s.Reason, s.ContactInfo, w.name, w.reason, w.contact)
+		}
+		if !s.ValidSignature {
+			t.Fatalf("signature %d is not valid", i)
+		}
+	}
+
+	if _, err := signSubmitterRevision(doc, nil, submitters[0], time.Now(), nil); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
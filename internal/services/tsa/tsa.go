@@ -0,0 +1,134 @@
+// Package tsa implements the instance's built-in RFC 3161 timestamp authority.
+// It signs timestamp tokens with a timestamping certificate from the certificate
+// store and can be used in-process by the PDF signer, so air-gapped deployments
+// still get trusted timestamps.
+package tsa
+
+import (
+	"context"
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/digitorus/timestamp"
+
+	"github.com/shurco/gosign/internal/services"
+)
+
+// Path is the public path of the TSA endpoint
+const Path = "/tsa"
+
+// DefaultPolicy is the ETSI EN 319 421 baseline time-stamp policy (0.4.0.2023.1.1)
+var DefaultPolicy = asn1.ObjectIdentifier{0, 4, 0, 2023, 1, 1}
+
+// KeyResolver loads the key of a timestamping certificate from the certificate store
+type KeyResolver interface {
+	TimestampingKey(ctx context.Context, id string) (*services.SealingKey, error)
+}
+
+// Config configures the TSA
+type Config struct {
+	CertificateID string                // timestamping certificate in the store
+	Policy        asn1.ObjectIdentifier // TSA policy put in every token
+	Accuracy      time.Duration         // declared accuracy of the clock
+}
+
+// Server answers RFC 3161 timestamp requests
+type Server struct {
+	keys KeyResolver
+	cfg  Config
+
+	mu  sync.Mutex
+	key *services.SealingKey
+}
+
+// New creates new timestamp authority
+func New(keys KeyResolver, cfg Config) *Server {
+	if len(cfg.Policy) == 0 {
+		cfg.Policy = DefaultPolicy
+	}
+	if cfg.Accuracy <= 0 {
+		cfg.Accuracy = time.Second
+	}
+	return &Server{keys: keys, cfg: cfg}
+}
+
+// Respond answers a DER encoded TimeStampReq with a DER encoded TimeStampResp.
+// Rejected requests are returned as error responses; err is only set for
+// internal failures, together with a systemFailure response.
+func (s *Server) Respond(ctx context.Context, raw []byte) ([]byte, error) {
+	req, err := timestamp.ParseRequest(raw)
+	if err != nil {
+		return timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.BadDataFormat)
+	}
+	if len(req.HashedMessage) != req.HashAlgorithm.Size() {
+		return timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.BadDataFormat)
+	}
+	if len(req.TSAPolicyOID) > 0 && !req.TSAPolicyOID.Equal(s.cfg.Policy) {
+		return timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.UnacceptedPolicy)
+	}
+	if len(req.Extensions) > 0 {
+		return timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.UnacceptedExtension)
+	}
+
+	resp, err := s.grant(ctx, req)
+	if err != nil {
+		failure, _ := timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.SystemFailure)
+		return failure, err
+	}
+	return resp, nil
+}
+
+// Timestamp implements sign.TimestampFunction
+func (s *Server) Timestamp(request []byte) ([]byte, error) {
+	return s.Respond(context.Background(), request)
+}
+
+func (s *Server) grant(ctx context.Context, req *timestamp.Request) ([]byte, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if now.Before(key.Certificate.NotBefore) || now.After(key.Certificate.NotAfter) {
+		return nil, fmt.Errorf("timestamping certificate %s is not valid at %s", key.Certificate.SerialNumber.Text(16), now.Format(time.RFC3339))
+	}
+
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     req.HashAlgorithm,
+		HashedMessage:     req.HashedMessage,
+		Time:              now,
+		Accuracy:          s.cfg.Accuracy,
+		Policy:            s.cfg.Policy,
+		Nonce:             req.Nonce,
+		AddTSACertificate: req.Certificates,
+	}
+	if req.Certificates {
+		ts.Certificates = key.Chain
+	}
+	return ts.CreateResponseWithOpts(key.Certificate, key.Signer, crypto.SHA256)
+}
+
+// signingKey loads the TSA key once; failures are retried on the next request
+func (s *Server) signingKey(ctx context.Context) (*services.SealingKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.key != nil {
+		return s.key, nil
+	}
+	if s.cfg.CertificateID == "" {
+		return nil, errors.New("no timestamping certificate configured")
+	}
+
+	key, err := s.keys.TimestampingKey(ctx, s.cfg.CertificateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timestamping certificate %s: %w", s.cfg.CertificateID, err)
+	}
+	s.key = key
+	return key, nil
+}
@@ -0,0 +1,213 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+type staticKeys struct {
+	key   *services.SealingKey
+	calls int
+}
+
+func (k *staticKeys) TimestampingKey(_ context.Context, id string) (*services.SealingKey, error) {
+	k.calls++
+	if id != "tsa-cert" {
+		return nil, errors.New("unknown certificate")
+	}
+	return k.key, nil
+}
+
+func newKey(t *testing.T, cn string, eku []x509.ExtKeyUsage) *services.SealingKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  eku,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services.SealingKey{Signer: priv, Certificate: crt}
+}
+
+func newTestServer(t *testing.T) (*Server, *staticKeys) {
+	t.Helper()
+	keys := &staticKeys{key: newKey(t, "Test TSA", []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})}
+	return New(keys, Config{CertificateID: "tsa-cert"}), keys
+}
+
+func TestRespond(t *testing.T) {
+	s, keys := newTestServer(t)
+	ctx := context.Background()
+
+	digest := sha256.Sum256([]byte("document"))
+	req, err := (&timestamp.Request{
+		HashAlgorithm: crypto.SHA256,
+		HashedMessage: digest[:],
+		Certificates:  true,
+		Nonce:         big.NewInt(42),
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := s.Respond(ctx, req)
+	if err != nil {
+		t.Fatalf("Respond() error: %v", err)
+	}
+	ts, err := timestamp.ParseResponse(raw)
+	if err != nil {
+		t.Fatalf("ParseResponse() error: %v", err)
+	}
+	if !bytes.Equal(ts.HashedMessage, digest[:]) || ts.Nonce.Int64() != 42 {
+		t.Fatalf("token does not echo the request: %x nonce %v", ts.HashedMessage, ts.Nonce)
+	}
+	if !ts.Policy.Equal(DefaultPolicy) {
+		t.Fatalf("policy = %v, want %v", ts.Policy, DefaultPolicy)
+	}
+	if len(ts.Certificates) == 0 || !ts.Certificates[0].Equal(keys.key.Certificate) {
+		t.Fatalf("expected TSA certificate in token")
+	}
+
+	// The key is loaded once
+	if _, err := s.Respond(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if keys.calls != 1 {
+		t.Fatalf("key loaded %d times, want 1", keys.calls)
+	}
+}
+
+func TestRespondRejects(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte("document"))
+
+	tests := []struct {
+		name    string
+		request func() []byte
+		failure timestamp.FailureInfo
+	}{
+		{
+			name:    "malformed",
+			request: func() []byte { return []byte("junk") },
+			failure: timestamp.BadDataFormat,
+		},
+		{
+			name: "digest length mismatch",
+			request: func() []byte {
+				raw, _ := (&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: digest[:20]}).Marshal()
+				return raw
+			},
+			failure: timestamp.BadDataFormat,
+		},
+		{
+			name: "unknown policy",
+			request: func() []byte {
+				raw, _ := (&timestamp.Request{
+					HashAlgorithm: crypto.SHA256,
+					HashedMessage: digest[:],
+					TSAPolicyOID:  asn1.ObjectIdentifier{1, 2, 3, 4},
+				}).Marshal()
+				return raw
+			},
+			failure: timestamp.UnacceptedPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := s.Respond(ctx, tt.request())
+			if err != nil {
+				t.Fatalf("Respond() error: %v", err)
+			}
+			// digitorus/timestamp reports error responses as formatted errors only
+			_, err = timestamp.ParseResponse(raw)
+			if err == nil || !strings.Contains(err.Error(), tt.failure.String()) {
+				t.Fatalf("ParseResponse() error = %v, want %v", err, tt.failure)
+			}
+		})
+	}
+
+	t.Run("missing certificate is a system failure", func(t *testing.T) {
+		s := New(&staticKeys{}, Config{CertificateID: "other"})
+		req, _ := (&timestamp.Request{HashAlgorithm: crypto.SHA256, HashedMessage: digest[:]}).Marshal()
+		raw, err := s.Respond(ctx, req)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := timestamp.ParseResponse(raw); err == nil {
+			t.Fatal("expected an error response")
+		}
+	})
+}
+
+func TestSignWithLocalTSA(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "..", "..", "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	s, _ := newTestServer(t)
+	signer := newKey(t, "Signer", []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection})
+
+	signed, err := sign.SignBytes(input, sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:       sign.SignDataSignatureInfo{Name: "Signer", Date: time.Now()},
+			CertType:   sign.CertificationSignature,
+			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		Signer:          signer.Signer,
+		DigestAlgorithm: crypto.SHA256,
+		Certificate:     signer.Certificate,
+		TSA:             sign.TSA{Local: s.Timestamp},
+	})
+	if err != nil {
+		t.Fatalf("SignBytes() error: %v", err)
+	}
+
+	res, err := verify.Reader(bytes.NewReader(signed), int64(len(signed)))
+	if err != nil {
+		t.Fatalf("verify.Reader() error: %v", err)
+	}
+	if len(res.Signers) != 1 || res.Signers[0].TimeStamp == nil {
+		t.Fatalf("expected a timestamped signature, got %+v", res.Signers)
+	}
+	if !res.Signers[0].TimeStamp.Policy.Equal(DefaultPolicy) {
+		t.Fatalf("timestamp not issued by the built-in TSA")
+	}
+}
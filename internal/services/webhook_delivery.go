@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/pkg/webhook"
+)
+
+const (
+	// webhookDeliveryBatch is how many deliveries are claimed and attempted at once
+	webhookDeliveryBatch = 20
+	// webhookDeliveryLease is how long a claimed delivery is hidden from other nodes; it
+	// must outlast an attempt (the dispatcher timeout)
+	webhookDeliveryLease = 5 * time.Minute
+	// webhookDocumentURLExpiration is how long the document URLs of a payload stay valid
+	// after the attempt that carried them
+	webhookDocumentURLExpiration = time.Hour
+)
+
+// WebhookDocumentURLs issues the download URLs of the documents of submissions listed in
+// webhook payloads
+type WebhookDocumentURLs interface {
+	DocumentURL(ctx context.Context, submissionID string, kind models.DocumentKind, filename string, expiration time.Duration) (string, error)
+}
+
+// WebhookDeliveryService delivers webhook events through an outbox: an event is stored as
+// a delivery per subscribed webhook before any attempt, so deliveries survive restarts.
+// Failed attempts are retried with exponential backoff until the delivery is dead.
+type WebhookDeliveryService struct {
+	// Documents signs the document URLs of payloads at each attempt, so they are short-lived
+	// whenever the delivery succeeds; nil delivers payloads without URLs
+	Documents WebhookDocumentURLs
+
+	repo       *queries.WebhookDeliveryRepository
+	dispatcher *webhook.Dispatcher
+	running    atomic.Bool
+}
+
+// NewWebhookDeliveryService creates new webhook delivery service
+func NewWebhookDeliveryService(repo *queries.WebhookDeliveryRepository, dispatcher *webhook.Dispatcher) *WebhookDeliveryService {
+	return &WebhookDeliveryService{repo: repo, dispatcher: dispatcher}
+}
+
+// Enqueue queues an event for the webhooks of an account subscribed to it and starts
+// delivering it in the background
+func (s *WebhookDeliveryService) Enqueue(ctx context.Context, accountID string, event *models.WebhookEvent) error {
+	templateID, payload, valuesPayload, err := webhookPayloads(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	n, err := s.repo.Enqueue(ctx, accountID, uuid.NewString(), event.Type, templateID, payload, valuesPayload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook event: %w", err)
+	}
+	if n > 0 {
+		s.kick()
+	}
+	return nil
+}
+
+// webhookPayloads returns the template an event is about ("" for none) and its payloads
+// without and with the values of the submitters
+func webhookPayloads(event *models.WebhookEvent) (templateID string, payload, valuesPayload []byte, err error) {
+	if valuesPayload, err = json.Marshal(event); err != nil {
+		return "", nil, nil, err
+	}
+	payload = valuesPayload
+	switch data := event.Data.(type) {
+	case *models.WebhookSubmissionData:
+		templateID = data.Submission.Template.ID
+		withoutValues := *event
+		withoutValues.Data = data.WithoutValues()
+		if payload, err = json.Marshal(&withoutValues); err != nil {
+			return "", nil, nil, err
+		}
+	case *models.WebhookTemplateData:
+		templateID = data.Template.ID
+	}
+	return templateID, payload, valuesPayload, nil
+}
+
+// Deliveries returns the deliveries of a webhook of an account, newest first, and their total
+func (s *WebhookDeliveryService) Deliveries(ctx context.Context, accountID, webhookID, status string, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	return s.repo.List(ctx, accountID, webhookID, status, limit, offset)
+}
+
+// Delivery returns a delivery of a webhook of an account, or nil
+func (s *WebhookDeliveryService) Delivery(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	return s.repo.Get(ctx, accountID, webhookID, id)
+}
+
+// Redeliver queues the event of a delivery again, whatever its state, and starts delivering
+// it; the new delivery is returned, nil when there is no such delivery
+func (s *WebhookDeliveryService) Redeliver(ctx context.Context, accountID, webhookID, id string) (*models.WebhookDelivery, error) {
+	d, err := s.repo.Redeliver(ctx, accountID, webhookID, id)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	s.kick()
+	return d, nil
+}
+
+// RotateSecret gives a webhook of an account a new signing secret and returns it. Until
+// keepPrevious has passed, deliveries are also signed with the replaced secret, so the
+// endpoint can switch to the new one; false means there is no such webhook.
+func (s *WebhookDeliveryService) RotateSecret(ctx context.Context, accountID, webhookID string, keepPrevious time.Duration) (string, bool, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return "", false, err
+	}
+	ok, err := s.repo.RotateSecret(ctx, accountID, webhookID, secret, time.Now().Add(keepPrevious))
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return secret, true, nil
+}
+
+// DeliverDue attempts the deliveries that are due, batch by batch, and returns how many
+// were delivered and how many failed
+func (s *WebhookDeliveryService) DeliverDue(ctx context.Context) (delivered, failed int, err error) {
+	for {
+		batch, err := s.repo.Claim(ctx, webhookDeliveryBatch, webhookDeliveryLease)
+		if err != nil {
+			return delivered, failed, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+		}
+		if len(batch) == 0 {
+			return delivered, failed, nil
+		}
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			recordErr error
+		)
+		for _, d := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempt := s.dispatcher.Deliver(ctx, d.Webhook, d.EventID, s.signDocumentURLs(ctx, d.Payload, time.Now()))
+				applyWebhookAttempt(d, attempt, time.Now())
+				err := s.repo.RecordAttempt(ctx, d)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					recordErr = fmt.Errorf("failed to record webhook delivery %s: %w", d.ID, err)
+					return
+				}
+				if d.Status == models.WebhookDeliveryDelivered {
+					delivered++
+					return
+				}
+				failed++
+				log.Warn().Err(attempt.Err).
+					Str("webhook_id", d.WebhookID).
+					Str("delivery_id", d.ID).
+					Int("attempts", d.Attempts).
+					Str("status", string(d.Status)).
+					Msg("Webhook delivery failed")
+			}()
+		}
+		wg.Wait()
+		if recordErr != nil {
+			return delivered, failed, recordErr
+		}
+	}
+}
+
+// kick delivers the due deliveries in the background, unless this node is already at it
+func (s *WebhookDeliveryService) kick() {
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.running.Store(false)
+		if _, _, err := s.DeliverDue(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed to deliver webhooks")
+		}
+	}()
+}
+
+// signDocumentURLs returns payload with download URLs for the documents of the submission
+// it carries, valid for webhookDocumentURLExpiration from now. Payloads without documents
+// are returned as they are, as are documents whose URL can't be issued.
+func (s *WebhookDeliveryService) signDocumentURLs(ctx context.Context, payload []byte, now time.Time) []byte {
+	if s.Documents == nil {
+		return payload
+	}
+	var event struct {
+		Data struct {
+			Submission *struct {
+				ID        string                    `json:"id"`
+				Documents []*models.WebhookDocument `json:"documents"`
+			} `json:"submission"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil || event.Data.Submission == nil || len(event.Data.Submission.Documents) == 0 {
+		return payload
+	}
+
+	submission := event.Data.Submission
+	expiresAt := now.Add(webhookDocumentURLExpiration).UTC()
+	for _, doc := range submission.Documents {
+		url, err := s.Documents.DocumentURL(ctx, submission.ID, doc.Kind, doc.Filename, webhookDocumentURLExpiration)
+		if err != nil {
+			log.Warn().Err(err).Str("submission_id", submission.ID).Str("kind", string(doc.Kind)).Msg("Failed to sign webhook document URL")
+			continue
+		}
+		doc.URL, doc.ExpiresAt = url, &expiresAt
+	}
+
+	// Replace data.submission.documents and keep everything else as it is
+	signed, err := replaceJSON(payload, submission.Documents, "data", "submission", "documents")
+	if err != nil {
+		return payload
+	}
+	return signed
+}
+
+// replaceJSON returns the JSON object doc with the member at path set to value
+func replaceJSON(doc []byte, value any, path ...string) ([]byte, error) {
+	if len(path) == 0 {
+		return json.Marshal(value)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &object); err != nil {
+		return nil, err
+	}
+	member, err := replaceJSON(object[path[0]], value, path[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	object[path[0]] = member
+	return json.Marshal(object)
+}
+
+// applyWebhookAttempt updates a delivery with the outcome of an attempt made at now: it is
+// delivered, scheduled for a retry after the backoff or, out of attempts, dead
+func applyWebhookAttempt(d *models.WebhookDelivery, attempt *webhook.Attempt, now time.Time) {
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.ResponseStatus = attempt.StatusCode
+	d.ResponseBody = attempt.Body
+	d.LatencyMS = attempt.Latency.Milliseconds()
+	d.Error = ""
+	d.NextAttemptAt = nil
+
+	switch {
+	case attempt.Err == nil:
+		d.Status = models.WebhookDeliveryDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= webhook.MaxAttempts:
+		d.Status = models.WebhookDeliveryDead
+		d.Error = attempt.Err.Error()
+	default:
+		next := now.Add(webhook.Backoff(d.Attempts))
+		d.Status = models.WebhookDeliveryPending
+		d.NextAttemptAt = &next
+		d.Error = attempt.Err.Error()
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/webhook"
+)
+
+func TestApplyWebhookAttempt(t *testing.T) {
+	now := time.Now()
+	failure := &webhook.Attempt{StatusCode: 500, Body: "oops", Latency: 120 * time.Millisecond, Err: errors.New("webhook returned status 500: oops")}
+
+	d := &models.WebhookDelivery{Status: models.WebhookDeliveryPending}
+	applyWebhookAttempt(d, failure, now)
+	if d.Status != models.WebhookDeliveryPending || d.Attempts != 1 || d.NextAttemptAt == nil || !d.NextAttemptAt.Equal(now.Add(webhook.Backoff(1))) {
+		t.Fatalf("after a failure: %+v", d)
+	}
+	if d.ResponseStatus != 500 || d.ResponseBody != "oops" || d.LatencyMS != 120 || d.Error == "" {
+		t.Fatalf("attempt not recorded: %+v", d)
+	}
+
+	applyWebhookAttempt(d, &webhook.Attempt{StatusCode: 204, Latency: time.Millisecond}, now)
+	if d.Status != models.WebhookDeliveryDelivered || d.Attempts != 2 || d.NextAttemptAt != nil || d.DeliveredAt == nil || d.Error != "" {
+		t.Fatalf("after a success: %+v", d)
+	}
+
+	d = &models.WebhookDelivery{Status: models.WebhookDeliveryPending, Attempts: webhook.MaxAttempts - 1}
+	applyWebhookAttempt(d, failure, now)
+	if d.Status != models.WebhookDeliveryDead || d.NextAttemptAt != nil || d.Error == "" {
+		t.Fatalf("after the last attempt: %+v", d)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/internal/queries"
+)
+
+// WebhookEvents emits the webhook events of the submission lifecycle and of templates. It is
+// the one place they are built: every payload is a models.WebhookEvent of the current
+// WebhookPayloadVersion carrying the whole submission (or template) as it is after the event.
+//
+// Emitting is best-effort: a failure is logged and never fails the change the event is about.
+// A nil *WebhookEvents emits nothing.
+type WebhookEvents struct {
+	repo       *queries.WebhookEventRepository
+	deliveries *WebhookDeliveryService
+	now        func() time.Time
+}
+
+// NewWebhookEvents creates new webhook event emitter queuing events to deliveries
+func NewWebhookEvents(repo *queries.WebhookEventRepository, deliveries *WebhookDeliveryService) *WebhookEvents {
+	return &WebhookEvents{repo: repo, deliveries: deliveries, now: time.Now}
+}
+
+// Submission emits a submission.* event, or a submitter.* event when submitterID is set
+func (e *WebhookEvents) Submission(ctx context.Context, eventType, submissionID, submitterID string) {
+	if e == nil {
+		return
+	}
+	if err := e.submission(ctx, eventType, submissionID, submitterID); err != nil {
+		log.Error().Err(err).Str("event_type", eventType).Str("submission_id", submissionID).Msg("Failed to emit webhook event")
+	}
+}
+
+func (e *WebhookEvents) submission(ctx context.Context, eventType, submissionID, submitterID string) error {
+	accountID, submission, err := e.repo.Submission(ctx, submissionID)
+	if err != nil {
+		return fmt.Errorf("failed to load submission: %w", err)
+	}
+	if submission == nil || accountID == "" {
+		return nil
+	}
+
+	data := &models.WebhookSubmissionData{Submission: submission}
+	submission.Status = webhookSubmissionStatus(submission.Submitters)
+	submission.Documents = webhookSubmissionDocuments(submission)
+	if submitterID != "" {
+		for _, s := range submission.Submitters {
+			if s.ID == submitterID {
+				data.Submitter = s
+			}
+		}
+	}
+	return e.deliveries.Enqueue(ctx, accountID, e.event(eventType, data))
+}
+
+// Template emits a template.* event
+func (e *WebhookEvents) Template(ctx context.Context, eventType, templateID string) {
+	if e == nil {
+		return
+	}
+	accountID, template, err := e.repo.Template(ctx, templateID)
+	if err == nil && template != nil && accountID != "" {
+		err = e.deliveries.Enqueue(ctx, accountID, e.event(eventType, &models.WebhookTemplateData{Template: template}))
+	}
+	if err != nil {
+		log.Error().Err(err).Str("event_type", eventType).Str("template_id", templateID).Msg("Failed to emit webhook event")
+	}
+}
+
+func (e *WebhookEvents) event(eventType string, data any) *models.WebhookEvent {
+	return &models.WebhookEvent{
+		Version:   models.WebhookPayloadVersion,
+		Type:      eventType,
+		Timestamp: e.now().UTC(),
+		Data:      data,
+	}
+}
+
+// webhookSubmissionStatus is the status of a submission given its submitters, as listed by
+// the signing links: completed once all completed, declined once one declined, in progress
+// once one opened or completed
+func webhookSubmissionStatus(submitters []*models.WebhookSubmitter) string {
+	if len(submitters) == 0 {
+		return string(models.SubmissionStatusPending)
+	}
+	completed, started := 0, false
+	for _, s := range submitters {
+		switch s.Status {
+		case models.SubmitterStatusDeclined:
+			return "declined"
+		case models.SubmitterStatusCompleted:
+			completed++
+			started = true
+		case models.SubmitterStatusOpened:
+			started = true
+		}
+	}
+	switch {
+	case completed == len(submitters):
+		return string(models.SubmissionStatusCompleted)
+	case started:
+		return string(models.SubmissionStatusInProgress)
+	}
+	return string(models.SubmissionStatusPending)
+}
+
+// webhookSubmissionDocuments lists the documents of a completed submission; their URLs are
+// signed when a delivery is attempted
+func webhookSubmissionDocuments(s *models.WebhookSubmission) []*models.WebhookDocument {
+	if s.Status != string(models.SubmissionStatusCompleted) {
+		return []*models.WebhookDocument{}
+	}
+	name := s.Template.Name
+	if name == "" {
+		name = "submission_" + s.ID
+	}
+	return []*models.WebhookDocument{
+		{Kind: models.DocumentKindCompleted, Filename: name + ".pdf"},
+		{Kind: models.DocumentKindCertificate, Filename: name + "_certificate.pdf"},
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+func testWebhookSubmission() *models.WebhookSubmission {
+	return &models.WebhookSubmission{
+		ID:       "sub-1",
+		Template: &models.WebhookTemplate{ID: "tpl-1", Name: "NDA"},
+		Submitters: []*models.WebhookSubmitter{
+			{ID: "s-1", Status: models.SubmitterStatusCompleted, Values: map[string]any{"f-1": "Jane"}},
+			{ID: "s-2", Status: models.SubmitterStatusCompleted, Values: map[string]any{"f-2": true}},
+		},
+	}
+}
+
+func TestWebhookSubmissionStatus(t *testing.T) {
+	tests := []struct {
+		statuses []models.SubmitterStatus
+		want     string
+	}{
+		{nil, "pending"},
+		{[]models.SubmitterStatus{"pending", "pending"}, "pending"},
+		{[]models.SubmitterStatus{"opened", "pending"}, "in_progress"},
+		{[]models.SubmitterStatus{"completed", "pending"}, "in_progress"},
+		{[]models.SubmitterStatus{"completed", "declined"}, "declined"},
+		{[]models.SubmitterStatus{"completed", "completed"}, "completed"},
+	}
+	for _, tt := range tests {
+		var submitters []*models.WebhookSubmitter
+		for _, status := range tt.statuses {
+			submitters = append(submitters, &models.WebhookSubmitter{Status: status})
+		}
+		if got := webhookSubmissionStatus(submitters); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.statuses, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookSubmissionDocuments(t *testing.T) {
+	s := testWebhookSubmission()
+	s.Status = "in_progress"
+	if docs := webhookSubmissionDocuments(s); len(docs) != 0 {
+		t.Fatalf("documents before completion: %+v", docs)
+	}
+
+	s.Status = "completed"
+	docs := webhookSubmissionDocuments(s)
+	if len(docs) != 2 || docs[0].Kind != models.DocumentKindCompleted || docs[0].Filename != "NDA.pdf" || docs[1].Kind != models.DocumentKindCertificate {
+		t.Fatalf("documents of a completed submission: %+v", docs)
+	}
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	data := &models.WebhookSubmissionData{Submission: testWebhookSubmission()}
+	data.Submitter = data.Submission.Submitters[1]
+	event := &models.WebhookEvent{Version: models.WebhookPayloadVersion, Type: models.EventSubmitterCompleted, Data: data}
+
+	templateID, payload, valuesPayload, err := webhookPayloads(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if templateID != "tpl-1" {
+		t.Fatalf("template: %q", templateID)
+	}
+	if strings.Contains(string(payload), `"values"`) {
+		t.Fatalf("values in the payload of webhooks without include_values: %s", payload)
+	}
+	if !strings.Contains(string(valuesPayload), `"f-1":"Jane"`) || !strings.Contains(string(valuesPayload), `"submitter":{"id":"s-2"`) {
+		t.Fatalf("values payload: %s", valuesPayload)
+	}
+	if !strings.Contains(string(payload), `"version":"v1"`) || !strings.Contains(string(payload), `"submitter":{"id":"s-2"`) {
+		t.Fatalf("payload: %s", payload)
+	}
+	if data.Submission.Submitters[0].Values == nil {
+		t.Fatal("the event lost its values")
+	}
+
+	templateEvent := &models.WebhookEvent{Type: models.EventTemplateUpdated, Data: &models.WebhookTemplateData{Template: &models.WebhookTemplate{ID: "tpl-2"}}}
+	templateID, payload, valuesPayload, err = webhookPayloads(templateEvent)
+	if err != nil || templateID != "tpl-2" || string(payload) != string(valuesPayload) {
+		t.Fatalf("template event: %q %s %s %v", templateID, payload, valuesPayload, err)
+	}
+}
+
+type fakeWebhookDocumentURLs struct{}
+
+func (fakeWebhookDocumentURLs) DocumentURL(_ context.Context, submissionID string, kind models.DocumentKind, filename string, expiration time.Duration) (string, error) {
+	if kind == models.DocumentKindCertificate {
+		return "", errors.New("storage unavailable")
+	}
+	return "https://files.example.com/" + submissionID + "/" + filename + "?expires=" + expiration.String(), nil
+}
+
+func TestSignDocumentURLs(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	submission := testWebhookSubmission()
+	submission.Status = "completed"
+	submission.Documents = webhookSubmissionDocuments(submission)
+	payload, err := json.Marshal(&models.WebhookEvent{Type: models.EventSubmissionCompleted, Data: &models.WebhookSubmissionData{Submission: submission}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &WebhookDeliveryService{}
+	if got := s.signDocumentURLs(context.Background(), payload, now); string(got) != string(payload) {
+		t.Fatalf("signed without Documents: %s", got)
+	}
+
+	s.Documents = fakeWebhookDocumentURLs{}
+	var signed models.WebhookEvent
+	var data models.WebhookSubmissionData
+	signed.Data = &data
+	if err := json.Unmarshal(s.signDocumentURLs(context.Background(), payload, now), &signed); err != nil {
+		t.Fatal(err)
+	}
+	docs := data.Submission.Documents
+	if len(docs) != 2 {
+		t.Fatalf("documents: %+v", docs)
+	}
+	if docs[0].URL != "https://files.example.com/sub-1/NDA.pdf?expires=1h0m0s" || docs[0].ExpiresAt == nil || !docs[0].ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("completed document: %+v", docs[0])
+	}
+	if docs[1].URL != "" || docs[1].ExpiresAt != nil {
+		t.Fatalf("a URL that couldn't be issued: %+v", docs[1])
+	}
+	if signed.Type != models.EventSubmissionCompleted || data.Submission.Template.Name != "NDA" || len(data.Submission.Submitters) != 2 {
+		t.Fatalf("the rest of the payload changed: %+v", data.Submission)
+	}
+
+	template, _ := json.Marshal(&models.WebhookEvent{Type: models.EventTemplateCreated, Data: &models.WebhookTemplateData{Template: &models.WebhookTemplate{ID: "tpl-1"}}})
+	if got := s.signDocumentURLs(context.Background(), template, now); string(got) != string(template) {
+		t.Fatalf("payload without documents changed: %s", got)
+	}
+}
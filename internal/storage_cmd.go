@@ -0,0 +1,333 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shurco/gosign/internal/config"
+	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
+	"github.com/shurco/gosign/pkg/appdir"
+	"github.com/shurco/gosign/pkg/security/secret"
+	"github.com/shurco/gosign/pkg/storage"
+	"github.com/shurco/gosign/pkg/storage/postgres"
+)
+
+// storagePrefixes are the directories of the blob storage holding documents
+var storagePrefixes = []string{storage.PrefixPages, storage.PrefixSigned, storage.PrefixUploads, storage.PrefixBlobs}
+
+// storageEnv holds what storage commands need, opened the way the server opens it
+type storageEnv struct {
+	pool       *pgxpool.Pool
+	storages   *storage.Registry
+	orgStorage *services.OrganizationStorageService
+	blobs      *services.BlobService
+}
+
+// Storage runs a storage maintenance command: gosign storage <command>
+func Storage(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gosign storage rewrap | gc [--dry-run] [--grace 168h] | migrate --from local --to s3 [--verify] | migrate --org <id> --s3-bucket <bucket> [--verify]")
+	}
+	switch args[0] {
+	case "rewrap":
+		return storageRewrap(context.Background())
+	case "gc":
+		return storageGC(context.Background(), args[1:])
+	case "migrate":
+		return storageMigrate(context.Background(), args[1:])
+	default:
+		return fmt.Errorf("unknown storage command: %s", args[0])
+	}
+}
+
+// storageRewrap re-wraps the data keys of the documents encrypted with an older
+// key-encryption key with the current one, in the global storage and in every
+// organization bucket; the documents themselves are not re-encrypted
+func storageRewrap(ctx context.Context) error {
+	env, err := openStorageEnv(ctx)
+	if err != nil {
+		return err
+	}
+	defer env.pool.Close()
+	if env.storages.Decorate == nil {
+		return errors.New("GOSIGN_STORAGE_KEYRING is not set")
+	}
+
+	orgs, err := env.orgStorage.Organizations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, orgID := range append([]string{""}, orgs...) {
+		store, err := env.storages.ForOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		encrypted, ok := store.(*storage.EncryptedStorage)
+		if !ok {
+			continue
+		}
+		name := orgID
+		if name == "" {
+			name = "global"
+		}
+		total := 0
+		for _, prefix := range storagePrefixes {
+			n, err := encrypted.Rewrap(ctx, prefix)
+			total += n
+			if err != nil {
+				return fmt.Errorf("%s storage: %w", name, err)
+			}
+		}
+		fmt.Printf("%s storage: %d documents re-wrapped\n", name, total)
+	}
+	return nil
+}
+
+// storageGC removes the blobs and files nothing references any more, or reports them with --dry-run
+func storageGC(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("storage gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what would be removed")
+	grace := flags.Duration("grace", services.DefaultBlobGracePeriod, "keep what has been unreferenced for less than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	env, err := openStorageEnv(ctx)
+	if err != nil {
+		return err
+	}
+	defer env.pool.Close()
+
+	report, err := env.blobs.CollectGarbage(ctx, *grace, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Removed"
+	if report.DryRun {
+		verb = "Would remove"
+	}
+	for _, key := range report.Blobs {
+		fmt.Printf("blob  %s\n", key)
+	}
+	for _, key := range report.Files {
+		fmt.Printf("file  %s\n", key)
+	}
+	fmt.Printf("%s %d attachments, %d blobs and %d files (%d bytes)\n", verb, report.Attachments, len(report.Blobs), len(report.Files), report.Bytes)
+	return nil
+}
+
+// storageMigrate copies the documents of the global storage from a provider to another, e.g.
+// from the local disk to S3, then updates the keys the database refers to. With --org it
+// copies the bucket of an organization to another bucket instead and switches the
+// organization to it. The copy resumes from its journal when run again; --verify compares
+// sizes and SHA-256 of every copy.
+func storageMigrate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("storage migrate", flag.ContinueOnError)
+	from := flags.String("from", "local", "provider to copy from: local or s3")
+	to := flags.String("to", "s3", "provider to copy to: local or s3")
+	org := flags.String("org", "", "organization whose bucket is copied to the bucket given by --s3-* (default the global storage)")
+	concurrency := flags.Int("concurrency", 4, "documents copied at once")
+	verify := flags.Bool("verify", false, "compare the size and SHA-256 of every copy afterwards")
+	journal := flags.String("journal", "", "resume journal (default storage-migrate-<from>-<to>.journal in the data directory)")
+	restart := flags.Bool("restart", false, "ignore the journal and copy everything again")
+	s3 := map[string]*string{
+		"bucket":            flags.String("s3-bucket", "", "S3 bucket (default from the storage settings, or of the organization)"),
+		"region":            flags.String("s3-region", "", "S3 region (default from the storage settings, or of the organization)"),
+		"endpoint":          flags.String("s3-endpoint", "", "S3 endpoint (default from the storage settings, or of the organization)"),
+		"access_key_id":     flags.String("s3-access-key-id", "", "S3 access key (default from the storage settings, or of the organization)"),
+		"secret_access_key": flags.String("s3-secret-access-key", "", "S3 secret key (default from the storage settings, or of the organization)"),
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *org == "" && *from == *to {
+		return errors.New("--from and --to must differ")
+	}
+
+	env, err := openStorageEnv(ctx)
+	if err != nil {
+		return err
+	}
+	defer env.pool.Close()
+
+	// the S3 bucket of the storage settings, even while the local storage is in use,
+	// or the current bucket of the organization
+	var storageMap map[string]any
+	var orgConfig *storage.Config
+	if *org != "" {
+		if orgConfig, err = env.orgStorage.Config(ctx, *org); err != nil {
+			return err
+		}
+		if orgConfig == nil {
+			return fmt.Errorf("organization %s has no bucket of its own, its documents are in the global storage", *org)
+		}
+		storageMap = map[string]any{
+			"bucket":            orgConfig.Bucket,
+			"region":            orgConfig.Region,
+			"endpoint":          orgConfig.Endpoint,
+			"access_key_id":     orgConfig.Options["access_key_id"],
+			"secret_access_key": orgConfig.Options["secret_access_key"],
+		}
+	} else if storageMap, err = queries.NewSettingQueries(env.pool).GetGlobalSetting(ctx, "storage"); err != nil {
+		storageMap = map[string]any{}
+	}
+	for name, value := range s3 {
+		if *value != "" {
+			storageMap[name] = *value
+		}
+	}
+	open := func(provider string) (storage.BlobStorage, error) {
+		switch provider {
+		case "local":
+			return storage.NewStorage(ctx, storage.Config{Provider: "local"})
+		case "s3":
+			return storage.NewStorage(ctx, s3StorageConfig(storageMap))
+		default:
+			return nil, fmt.Errorf("unknown storage provider: %s", provider)
+		}
+	}
+
+	// documents are copied as stored: encrypted ones stay encrypted with the same keys
+	var source, target storage.BlobStorage
+	var orgBucket services.OrganizationStorageRequest
+	journalName := fmt.Sprintf("storage-migrate-%s-%s.journal", *from, *to)
+	if *org != "" {
+		targetConfig := s3StorageConfig(storageMap)
+		if targetConfig.Bucket == orgConfig.Bucket && targetConfig.Endpoint == orgConfig.Endpoint {
+			return errors.New("--s3-bucket or --s3-endpoint must name another bucket than the one of the organization")
+		}
+		orgBucket = services.OrganizationStorageRequest{
+			Provider:        targetConfig.Provider,
+			Bucket:          targetConfig.Bucket,
+			Region:          targetConfig.Region,
+			Endpoint:        targetConfig.Endpoint,
+			AccessKeyID:     targetConfig.Options["access_key_id"],
+			SecretAccessKey: targetConfig.Options["secret_access_key"],
+		}
+		journalName = fmt.Sprintf("storage-migrate-%s-%s.journal", *org, orgBucket.Bucket)
+		if source, err = storage.NewStorage(ctx, *orgConfig); err != nil {
+			return err
+		}
+		*to = "s3"
+	} else if source, err = open(*from); err != nil {
+		return err
+	}
+	if target, err = open(*to); err != nil {
+		return err
+	}
+
+	if *journal == "" {
+		*journal = filepath.Join(appdir.Base(), journalName)
+	}
+	if *restart {
+		if err := os.Remove(*journal); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	migrator := &storage.Migrator{From: source, To: target, Concurrency: *concurrency, Journal: *journal}
+	result, err := migrator.Migrate(ctx, storagePrefixes)
+	if err != nil {
+		return err
+	}
+	// Blobs are recorded per organization: the global storage holds those of every
+	// organization without a bucket of its own
+	organizations := []string{*org}
+	if *org == "" {
+		if organizations, err = env.blobs.GlobalStorageOrganizations(ctx); err != nil {
+			return err
+		}
+	}
+	for _, organizationID := range organizations {
+		if err := env.blobs.RenameKeys(ctx, organizationID, result.Renamed()); err != nil {
+			return err
+		}
+	}
+	for _, obj := range result.Failed {
+		fmt.Printf("failed  %s: %s\n", obj.Key, obj.Error)
+	}
+	fmt.Printf("Copied %d documents (%d bytes), %d already copied, %d failed\n", len(result.Copied), result.Bytes, len(result.Skipped), len(result.Failed))
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d documents were not copied, run the command again to retry them", len(result.Failed))
+	}
+
+	if *verify {
+		mismatches, err := migrator.Verify(ctx, storagePrefixes)
+		if err != nil {
+			return err
+		}
+		for _, obj := range mismatches {
+			fmt.Printf("differs %s: %s\n", obj.Key, obj.Error)
+		}
+		if len(mismatches) > 0 {
+			return fmt.Errorf("%d copies differ from their documents", len(mismatches))
+		}
+		fmt.Println("Every copy matches its document")
+	}
+
+	if *org != "" {
+		if _, err := env.orgStorage.Move(ctx, *org, orgBucket); err != nil {
+			return err
+		}
+		fmt.Printf("Organization %s now uses the bucket %s, the old one can be emptied\n", *org, orgBucket.Bucket)
+		return nil
+	}
+	fmt.Printf("Switch the storage provider to %s in the settings and restart to use the copies\n", *to)
+	return nil
+}
+
+// openStorageEnv loads the configuration and opens the database and the storages
+func openStorageEnv(ctx context.Context) (*storageEnv, error) {
+	appdir.Init()
+	if err := config.Load(); err != nil {
+		return nil, err
+	}
+	cfg := config.Data()
+
+	pool, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		return nil, err
+	}
+	if err := queries.Init(pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	env, err := newStorageEnv(pool, cfg)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return env, nil
+}
+
+func newStorageEnv(pool *pgxpool.Pool, cfg *config.Config) (*storageEnv, error) {
+	blobStorage, err := initStorage(queries.NewSettingQueries(pool))
+	if err != nil {
+		return nil, err
+	}
+	encryptStorage, err := initStorageEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if encryptStorage != nil {
+		blobStorage = encryptStorage(blobStorage)
+	}
+	keyCipher, err := secret.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	orgStorage := services.NewOrganizationStorageService(queries.NewOrganizationStorageRepository(pool), keyCipher)
+	storages := storage.NewRegistry(blobStorage, orgStorage.Config)
+	storages.Decorate = encryptStorage
+	blobs := services.NewBlobService(queries.NewStorageBlobRepository(pool), storages, orgStorage)
+	return &storageEnv{pool: pool, storages: storages, orgStorage: orgStorage, blobs: blobs}, nil
+}
@@ -0,0 +1,258 @@
+package trust
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shurco/gosign/pkg/security/cert"
+)
+
+// Kinds of trust sources
+const (
+	KindAdobe = "adobe" // Adobe .acrobatsecuritysettings (AATL, EUTL)
+	KindTSL   = "tsl"   // ETSI TS 119 612 trusted list
+	KindLOTL  = "lotl"  // ETSI list of trusted lists; the lists it points to are imported
+	KindPEM   = "pem"   // PEM or DER certificate bundle
+)
+
+// maxListSize bounds the size of a downloaded trust list
+const maxListSize = 64 << 20
+
+// Source is a trust list the platform imports anchors from
+type Source struct {
+	List     string              // identifier of the list, e.g. "eutl12"
+	Kind     string              // one of the Kind constants
+	Location string              // URL or local file
+	Signers  []*x509.Certificate // the XML list must be signed by one of them
+	// Insecure accepts an XML list without signers unverified, and the lists a LOTL points
+	// to whose pointers name no certificates; for testing only
+	Insecure bool
+}
+
+// DefaultSources are imported when no trust sources are configured
+var DefaultSources = []Source{
+	{List: "eutl12", Kind: KindAdobe, Location: "https://trustlist.adobe.com/eutl12.acrobatsecuritysettings"},
+	{List: "tl12", Kind: KindAdobe, Location: "https://trustlist.adobe.com/tl12.acrobatsecuritysettings"},
+}
+
+// ParseSources parses trust source specifications of the form
+//
+//	[list=]kind:location[;signers=file][;insecure]
+//
+// where kind is adobe, tsl, lotl or pem, location is a URL or a local file and
+// signers names a PEM file with the certificates allowed to sign the list. tsl and
+// lotl lists need signers unless insecure is given. The list identifier defaults to
+// the base name of the location.
+func ParseSources(specs []string) ([]Source, error) {
+	sources := make([]Source, 0, len(specs))
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		src, err := ParseSource(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[src.List] {
+			return nil, fmt.Errorf("trust source %q: duplicate list %q", spec, src.List)
+		}
+		seen[src.List] = true
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// ParseSource parses a single trust source specification, see ParseSources
+func ParseSource(spec string) (Source, error) {
+	var src Source
+	rest, options, _ := strings.Cut(strings.TrimSpace(spec), ";")
+
+	if name, value, ok := strings.Cut(rest, "="); ok && !strings.Contains(name, ":") {
+		src.List, rest = strings.TrimSpace(name), value
+	}
+	kind, location, ok := strings.Cut(rest, ":")
+	if !ok || location == "" {
+		return src, fmt.Errorf("trust source %q: expected kind:location", spec)
+	}
+	src.Kind, src.Location = strings.ToLower(strings.TrimSpace(kind)), strings.TrimSpace(location)
+	switch src.Kind {
+	case KindAdobe, KindTSL, KindLOTL, KindPEM:
+	default:
+		return src, fmt.Errorf("trust source %q: unknown kind %q", spec, src.Kind)
+	}
+
+	if src.List == "" {
+		base := path.Base(src.Location)
+		src.List = strings.TrimSuffix(base, path.Ext(base))
+	}
+	if src.List == "" || src.List == "." || src.List == "/" || len(src.List) > 64 {
+		return src, fmt.Errorf("trust source %q: invalid list name", spec)
+	}
+
+	for _, opt := range strings.Split(options, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "":
+		case "signers":
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return src, fmt.Errorf("trust source %q: %w", spec, err)
+			}
+			if src.Signers, err = cert.ParseCertificates(data); err != nil {
+				return src, fmt.Errorf("trust source %q: signers: %w", spec, err)
+			}
+		case "insecure":
+			if value != "" {
+				return src, fmt.Errorf("trust source %q: insecure takes no value", spec)
+			}
+			src.Insecure = true
+		default:
+			return src, fmt.Errorf("trust source %q: unknown option %q", spec, key)
+		}
+	}
+	if (src.Kind == KindTSL || src.Kind == KindLOTL) && len(src.Signers) == 0 && !src.Insecure {
+		return src, fmt.Errorf("trust source %q: %w", spec, ErrNoTSLSigners)
+	}
+	return src, nil
+}
+
+// Loader reads trust sources from local files and URLs
+type Loader struct {
+	Client *http.Client // nil uses a client with a 30 second timeout
+}
+
+// Load reads the anchors of src. Lists a LOTL points to that fail to load are
+// skipped: their errors are returned along with the anchors of the other lists.
+func (l *Loader) Load(ctx context.Context, src Source) ([]Anchor, error) {
+	data, err := l.read(ctx, src.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch src.Kind {
+	case KindAdobe:
+		return parseSecuritySettings(src, data)
+	case KindPEM:
+		certs, err := cert.ParseCertificates(data)
+		if err != nil {
+			return nil, err
+		}
+		return anchorsOf(src.List, src.Location, certs), nil
+	case KindTSL:
+		tsl, err := parseSignedTSL(data, src.Signers, src.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		return tsl.Anchors(src.List, src.Location), nil
+	case KindLOTL:
+		lotl, err := parseSignedTSL(data, src.Signers, src.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		return l.loadPointers(ctx, src, lotl)
+	}
+	return nil, fmt.Errorf("unknown trust source kind %q", src.Kind)
+}
+
+// loadPointers imports the trusted lists the LOTL of src points to. Each list must be
+// signed by one of the certificates its pointer names and is only fetched over
+// HTTP(S), never from the local file system.
+func (l *Loader) loadPointers(ctx context.Context, src Source, lotl *TSL) ([]Anchor, error) {
+	list := src.List
+	anchors := lotl.Anchors(list, "")
+	var errs []error
+	for _, p := range lotl.Pointers {
+		if !p.IsXML() || p.IsLOTL() {
+			continue
+		}
+		var data []byte
+		err := errors.New("not an HTTP(S) URL")
+		if u, perr := url.Parse(p.Location); perr == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			data, err = l.read(ctx, p.Location)
+		}
+		if err == nil {
+			var tsl *TSL
+			if tsl, err = parseSignedTSL(data, p.Certificates, src.Insecure); err == nil {
+				if p.Territory != "" && !strings.EqualFold(tsl.Territory, p.Territory) {
+					err = fmt.Errorf("territory %q, pointer says %q", tsl.Territory, p.Territory)
+				} else {
+					anchors = append(anchors, tsl.Anchors(list, p.Location)...)
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", p.Territory, p.Location, err))
+		}
+	}
+	return anchors, errors.Join(errs...)
+}
+
+// read returns the contents of a URL, a file:// URL or a local path
+func (l *Loader) read(ctx context.Context, location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		if err == nil && u.Scheme == "file" {
+			location = u.Path
+		}
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readLimited(f)
+	}
+
+	client := l.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+	}
+	return readLimited(resp.Body)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxListSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxListSize {
+		return nil, errors.New("trust list too large")
+	}
+	return data, nil
+}
+
+// Parse reads the anchors of an uploaded trust list: an ETSI TS 119 612 XML
+// list or a PEM/DER certificate bundle
+func Parse(list, location string, data []byte) ([]Anchor, error) {
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<")) {
+		tsl, err := ParseTSL(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		return tsl.Anchors(list, location), nil
+	}
+	certs, err := cert.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	return anchorsOf(list, location, certs), nil
+}
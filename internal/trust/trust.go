@@ -6,14 +6,13 @@ import (
 	"context"
 	"crypto/sha1"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -25,32 +24,29 @@ import (
 	"github.com/shurco/gosign/pkg/utils"
 )
 
-// TrustList is ...
-var TrustList = map[string]TrustEntry{
-	"eutl12": {
-		Name: "Europe",
-		Url:  "https://trustlist.adobe.com/eutl12.acrobatsecuritysettings",
-	},
-	"tl12": {
-		Name: "Adobe",
-		Url:  "https://trustlist.adobe.com/tl12.acrobatsecuritysettings",
-	},
-}
+// DefaultUpdateDays is how often the trust lists are imported again
+const DefaultUpdateDays = 1
+
+// Sources are the platform trust lists imported by Update
+var Sources = DefaultSources
 
-// TrustEntry ...
-type TrustEntry struct {
-	Name string
-	Url  string
+// Anchor is a trusted certificate and the list that made it trusted
+type Anchor struct {
+	ID          string // set for custom anchors
+	List        string
+	Name        string
+	Location    string // where the list was read from
+	Certificate *x509.Certificate
 }
 
-// DefaultTrustList and DefaultUpdateDays are hardcoded; not configurable via env.
-const (
-	DefaultTrustList   = "eutl12"
-	DefaultTrustList12 = "tl12"
-	DefaultUpdateDays  = 1
-)
+// Scope selects the custom anchors of an organization or, without one, of an
+// account, which are trusted in addition to the platform lists
+type Scope struct {
+	OrganizationID string
+	AccountID      string
+}
 
-// SecuritySettings is ...
+// SecuritySettings is the trust list embedded in Adobe .acrobatsecuritysettings files
 type SecuritySettings struct {
 	TrustedIdentities struct {
 		Identity []struct {
@@ -60,125 +56,167 @@ type SecuritySettings struct {
 	} `xml:"TrustedIdentities"`
 }
 
-// Update fetches and applies Adobe trust lists (eutl12, tl12); skips if last update was within DefaultUpdateDays.
+// Update imports the trust lists of Sources that were not imported within
+// DefaultUpdateDays and drops lists that are no longer configured. Sources that
+// fail keep their previous anchors; their errors are returned together.
 func Update() error {
-	dateUp, err := queries.DB.TimeUpdateAdobeTL(context.Background())
-	if err != nil {
-		return err
-	}
+	ctx := context.Background()
+	loader := &Loader{}
 
-	daysDiff := 999
-	if dateUp != nil {
-		daysDiff = utils.DaysBetween(*dateUp, time.Now())
-	}
-
-	if daysDiff < DefaultUpdateDays {
-		return nil
-	}
+	lists := make([]string, 0, len(Sources))
+	var errs []error
+	for _, src := range Sources {
+		lists = append(lists, src.List)
 
-	trustLists := []string{DefaultTrustList, DefaultTrustList12}
-	fmt.Printf("├─[🌍] Updating the list of trusted Adobe certificates\n")
-	for _, v := range trustLists {
-		trust, found := TrustList[v]
-		if !found {
-			return errors.New("not found trust list")
+		updated, err := queries.DB.TrustListUpdatedAt(ctx, src.List)
+		if err != nil {
+			return err
+		}
+		if updated != nil && utils.DaysBetween(*updated, time.Now()) < DefaultUpdateDays {
+			continue
 		}
-		err := parseTL(v, trust.Url)
+
+		fmt.Printf("├─[🌍] Updating trust list %s\n", src.List)
+		anchors, err := loader.Load(ctx, src)
 		if err != nil {
+			errs = append(errs, fmt.Errorf("trust list %s: %w", src.List, err))
+		}
+		if len(anchors) == 0 {
+			continue
+		}
+		if err := queries.DB.ReplaceTrustList(ctx, src.List, Records(anchors)); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if err := queries.DB.DeleteTrustListsExcept(ctx, lists); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
 }
 
-func parseTL(list, url string) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+// Lookup returns the anchors whose subject key identifier is keyID: the ones of
+// the platform lists and the custom anchors of scope
+func Lookup(ctx context.Context, keyID []byte, scope Scope) ([]Anchor, error) {
+	if len(keyID) == 0 || queries.DB == nil {
+		return nil, nil
+	}
+	rows, err := queries.DB.TrustAnchorsBySKI(ctx, strings.ToUpper(hex.EncodeToString(keyID)), scope.OrganizationID, scope.AccountID)
 	if err != nil {
-		return errors.New("error fetching security settings")
+		return nil, err
+	}
+	anchors := make([]Anchor, 0, len(rows))
+	for _, row := range rows {
+		anchors = append(anchors, FromRecord(row))
 	}
-	defer resp.Body.Close()
+	return anchors, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("received non-OK HTTP status code")
+// Records converts anchors to trust list rows
+func Records(anchors []Anchor) []models.TrustCert {
+	records := make([]models.TrustCert, 0, len(anchors))
+	for _, a := range anchors {
+		fingerprint := sha1.Sum(a.Certificate.Raw)
+		records = append(records, models.TrustCert{
+			List:        a.List,
+			Name:        a.Name,
+			AKI:         strings.ToUpper(hex.EncodeToString(fingerprint[:])),
+			SKI:         strings.ToUpper(hex.EncodeToString(subjectKeyID(a.Certificate))),
+			Location:    a.Location,
+			Certificate: a.Certificate.Raw,
+		})
 	}
+	return records
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+// FromRecord converts a trust list row to an anchor. Rows imported before
+// certificates were kept have no Certificate.
+func FromRecord(row models.TrustCert) Anchor {
+	anchor := Anchor{ID: row.ID, List: row.List, Name: row.Name, Location: row.Location}
+	if len(row.Certificate) > 0 {
+		if c, err := x509.ParseCertificate(row.Certificate); err == nil {
+			anchor.Certificate = c
+		}
 	}
+	return anchor
+}
+
+// subjectKeyID returns the subject key identifier of c; for certificates
+// without the extension it is derived from the public key (RFC 5280, 4.2.1.2
+// method 1), which is how issuers usually compute their authority key IDs
+func subjectKeyID(c *x509.Certificate) []byte {
+	if len(c.SubjectKeyId) > 0 {
+		return c.SubjectKeyId
+	}
+	var spki struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(c.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil
+	}
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:]
+}
 
+// anchorsOf returns the certificates as anchors of list
+func anchorsOf(list, location string, certs []*x509.Certificate) []Anchor {
+	anchors := make([]Anchor, 0, len(certs))
+	for _, c := range certs {
+		anchors = append(anchors, Anchor{List: list, Name: commonName(c), Location: location, Certificate: c})
+	}
+	return anchors
+}
+
+func commonName(c *x509.Certificate) string {
+	if c.Subject.CommonName != "" {
+		return c.Subject.CommonName
+	}
+	return c.Subject.String()
+}
+
+// parseSecuritySettings reads the anchors of an Adobe .acrobatsecuritysettings
+// file, a PDF with the list embedded as SecuritySettings.xml
+func parseSecuritySettings(src Source, data []byte) ([]Anchor, error) {
 	securityFile := "SecuritySettings.xml"
-	
+
 	// Extract embedded file using digitorus/pdf
 	fileContents, err := extractEmbeddedFile(bytes.NewReader(data), int64(len(data)), securityFile)
 	if err != nil {
-		return fmt.Errorf("error extracting attachments: %w", err)
+		return nil, fmt.Errorf("error extracting attachments: %w", err)
 	}
 
 	// Validate that extracted data looks like XML
 	if len(fileContents) == 0 {
-		return errors.New("extracted file is empty")
+		return nil, errors.New("extracted file is empty")
 	}
-	
+
 	// Check if data starts with XML declaration or root element
 	if !bytes.HasPrefix(fileContents, []byte("<?xml")) && !bytes.HasPrefix(fileContents, []byte("<")) {
-		return fmt.Errorf("extracted file does not appear to be XML (starts with: %q)", string(fileContents[:min(50, len(fileContents))]))
-	}
-
-	// Save to file
-	if err := os.WriteFile("./"+securityFile, fileContents, 0644); err != nil {
-		return errors.New("error writing file")
+		return nil, fmt.Errorf("extracted file does not appear to be XML (starts with: %q)", string(fileContents[:min(50, len(fileContents))]))
 	}
 
 	var securitySettings SecuritySettings
-	err = xml.Unmarshal(fileContents, &securitySettings)
-	if err != nil {
-		return fmt.Errorf("error decoding XML: %w", err)
+	if err := xml.Unmarshal(fileContents, &securitySettings); err != nil {
+		return nil, fmt.Errorf("error decoding XML: %w", err)
 	}
 
-	trustList := models.TrustCerts{}
+	var certs []*x509.Certificate
 	for _, identity := range securitySettings.TrustedIdentities.Identity {
 		decodedData, err := base64.StdEncoding.DecodeString(identity.Certificate)
 		if err != nil {
-			return errors.New("error decoding certificate")
+			return nil, errors.New("error decoding certificate")
 		}
 
 		cert, err := x509.ParseCertificate(decodedData)
 		if err != nil {
-			//if err.Error() == "x509: unsupported elliptic curve" {
-			//	fmt.Printf("elliptic\n")
-			//	continue
-			//}
-			//fmt.Printf("error parsing certificate: %s\n", err)
-			//log.Warn().Err(err).Send()
+			// e.g. unsupported elliptic curves
 			continue
 		}
-
-		akiHash := sha1.Sum(decodedData)
-		trustCert := models.TrustCert{
-			List: list,
-			Name: cert.Subject.CommonName,
-			AKI:  strings.ToUpper(hex.EncodeToString(akiHash[:])),
-			SKI:  strings.ToUpper(hex.EncodeToString(cert.SubjectKeyId[:])),
-		}
-
-		trustList.Certs = append(trustList.Certs, trustCert)
+		certs = append(certs, cert)
 	}
 
-	if err := queries.DB.DeleteAdobeTL(context.Background(), list); err != nil {
-		return err
-	}
-
-	if err := queries.DB.AddAdobeTL(context.Background(), trustList); err != nil {
-		return err
-	}
-
-	if err := os.Remove("./" + securityFile); err != nil {
-		return errors.New("error removing file")
-	}
-	return nil
+	return anchorsOf(src.List, src.Location, certs), nil
 }
 
 // extractEmbeddedFile extracts an embedded file from PDF using digitorus/pdf
@@ -0,0 +1,274 @@
+package trust
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/pkg/security/xmldsig"
+)
+
+func testSigner(t *testing.T, cn string) (crypto.Signer, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, crt
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func signFixture(t *testing.T, data []byte, key crypto.Signer, crt *x509.Certificate) []byte {
+	t.Helper()
+	signed, err := xmldsig.Sign(data, key, crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func anchorNames(anchors []Anchor) []string {
+	names := make([]string, 0, len(anchors))
+	for _, a := range anchors {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Source
+		wantErr bool
+	}{
+		{spec: "adobe:https://trustlist.adobe.com/tl12.acrobatsecuritysettings",
+			want: Source{List: "tl12", Kind: KindAdobe, Location: "https://trustlist.adobe.com/tl12.acrobatsecuritysettings"}},
+		{spec: "eu=lotl:https://ec.europa.eu/tools/lotl/eu-lotl.xml;insecure",
+			want: Source{List: "eu", Kind: KindLOTL, Location: "https://ec.europa.eu/tools/lotl/eu-lotl.xml", Insecure: true}},
+		{spec: " pem:/etc/gosign/roots.pem ",
+			want: Source{List: "roots", Kind: KindPEM, Location: "/etc/gosign/roots.pem"}},
+		{spec: "tsl:https://example.com/tl.xml?version=5;insecure",
+			want: Source{List: "tl", Kind: KindTSL, Location: "https://example.com/tl.xml?version=5", Insecure: true}},
+		{spec: "zip:/tmp/list.zip", wantErr: true},
+		{spec: "https://example.com/tl.xml", wantErr: true},
+		{spec: "tsl:", wantErr: true},
+		{spec: "tsl:/tmp/tl.xml;signers=/does/not/exist.pem", wantErr: true},
+		{spec: "tsl:/tmp/tl.xml;refresh=1h", wantErr: true},
+		{spec: "tsl:/tmp/tl.xml", wantErr: true},
+		{spec: "lotl:/tmp/lotl.xml", wantErr: true},
+		{spec: "tsl:/tmp/tl.xml;insecure=false", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSource(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseSource(%q) = %+v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSource: %v", err)
+			}
+			if got.List != tt.want.List || got.Kind != tt.want.Kind || got.Location != tt.want.Location || got.Insecure != tt.want.Insecure {
+				t.Errorf("ParseSource(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("signers", func(t *testing.T) {
+		got, err := ParseSource("tsl:/tmp/tl.xml;signers=" + filepath.Join("testdata", "roots.pem"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Signers) != 2 {
+			t.Errorf("signers = %d, want 2", len(got.Signers))
+		}
+	})
+
+	t.Run("duplicate lists", func(t *testing.T) {
+		if _, err := ParseSources([]string{"pem:/a/roots.pem", "pem:/b/roots.pem"}); err == nil {
+			t.Error("ParseSources accepted two lists named roots")
+		}
+	})
+}
+
+func TestParseTSL(t *testing.T) {
+	tsl, err := ParseTSL(readFixture(t, "tl-zz.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tsl.Territory != "ZZ" || tsl.Operator != "Example Supervisory Body" {
+		t.Errorf("territory %q, operator %q", tsl.Territory, tsl.Operator)
+	}
+	if !tsl.NextUpdate.Equal(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("next update %v", tsl.NextUpdate)
+	}
+	if len(tsl.Services) != 3 {
+		t.Fatalf("services = %d, want 3", len(tsl.Services))
+	}
+
+	anchors := tsl.Anchors("zz", "https://example.com/tl-zz.xml")
+	if got := strings.Join(anchorNames(anchors), ","); got != "Example Qualified CA,Example QTSA" {
+		t.Errorf("anchors = %s, want the granted services only", got)
+	}
+	for _, a := range anchors {
+		if a.List != "zz" || a.Location != "https://example.com/tl-zz.xml" || a.Certificate == nil {
+			t.Errorf("anchor %+v", a)
+		}
+	}
+
+	if _, err := ParseTSL([]byte(`<?xml version="1.0"?><!DOCTYPE x [<!ENTITY e "x">]><TrustServiceStatusList/>`)); err == nil {
+		t.Error("ParseTSL accepted a DTD")
+	}
+}
+
+func TestLoadLOTL(t *testing.T) {
+	lotlKey, lotlCert := testSigner(t, "LOTL signer")
+	tlKey, tlCert := testSigner(t, "ZZ TL signer")
+	_, otherCert := testSigner(t, "Other signer")
+
+	signedTL := signFixture(t, readFixture(t, "tl-zz.xml"), tlKey, tlCert)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tl-zz.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(signedTL)
+	}))
+	defer srv.Close()
+
+	lotl := func(tlSigner *x509.Certificate) string {
+		tmpl := string(readFixture(t, "lotl.xml"))
+		tmpl = strings.NewReplacer(
+			"{{LOTL_SIGNER}}", base64.StdEncoding.EncodeToString(lotlCert.Raw),
+			"{{LOTL_LOCATION}}", srv.URL+"/lotl.xml",
+			"{{TL_SIGNER}}", base64.StdEncoding.EncodeToString(tlSigner.Raw),
+			"{{TL_LOCATION}}", srv.URL+"/tl-zz.xml",
+			"{{MISSING_LOCATION}}", srv.URL+"/tl-zy.xml",
+		).Replace(tmpl)
+		path := filepath.Join(t.TempDir(), "lotl.xml")
+		if err := os.WriteFile(path, signFixture(t, []byte(tmpl), lotlKey, lotlCert), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	loader := &Loader{Client: srv.Client()}
+	ctx := context.Background()
+
+	t.Run("follows pointers", func(t *testing.T) {
+		src := Source{List: "eu", Kind: KindLOTL, Location: lotl(tlCert), Signers: []*x509.Certificate{lotlCert}}
+		anchors, err := loader.Load(ctx, src)
+		if err == nil || !strings.Contains(err.Error(), "ZY") {
+			t.Errorf("error = %v, want the failed ZY list reported", err)
+		}
+		if got := strings.Join(anchorNames(anchors), ","); got != "Example Qualified CA,Example QTSA" {
+			t.Fatalf("anchors = %s", got)
+		}
+		for _, a := range anchors {
+			if a.List != "eu" || a.Location != srv.URL+"/tl-zz.xml" {
+				t.Errorf("anchor %s: list %q, location %q", a.Name, a.List, a.Location)
+			}
+		}
+	})
+
+	t.Run("list signed by another key", func(t *testing.T) {
+		src := Source{List: "eu", Kind: KindLOTL, Location: lotl(otherCert), Signers: []*x509.Certificate{lotlCert}}
+		anchors, err := loader.Load(ctx, src)
+		if len(anchors) != 0 {
+			t.Errorf("imported %d anchors from a list with a foreign signature", len(anchors))
+		}
+		if err == nil || !strings.Contains(err.Error(), "ZZ") {
+			t.Errorf("error = %v, want the ZZ list reported", err)
+		}
+	})
+
+	t.Run("untrusted LOTL", func(t *testing.T) {
+		src := Source{List: "eu", Kind: KindLOTL, Location: "file://" + lotl(tlCert), Signers: []*x509.Certificate{otherCert}}
+		if _, err := loader.Load(ctx, src); err == nil {
+			t.Error("loaded a LOTL signed by another key")
+		}
+	})
+
+	t.Run("single list", func(t *testing.T) {
+		src := Source{List: "zz", Kind: KindTSL, Location: srv.URL + "/tl-zz.xml", Signers: []*x509.Certificate{tlCert}}
+		anchors, err := loader.Load(ctx, src)
+		if err != nil || len(anchors) != 2 {
+			t.Errorf("Load = %d anchors, %v", len(anchors), err)
+		}
+	})
+
+	t.Run("no signers", func(t *testing.T) {
+		src := Source{List: "zz", Kind: KindTSL, Location: srv.URL + "/tl-zz.xml"}
+		if _, err := loader.Load(ctx, src); !errors.Is(err, ErrNoTSLSigners) {
+			t.Errorf("error = %v, want ErrNoTSLSigners", err)
+		}
+		src.Insecure = true
+		anchors, err := loader.Load(ctx, src)
+		if err != nil || len(anchors) != 2 {
+			t.Errorf("insecure Load = %d anchors, %v", len(anchors), err)
+		}
+	})
+}
+
+func TestLoadPEM(t *testing.T) {
+	path, err := filepath.Abs(filepath.Join("testdata", "roots.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchors, err := (&Loader{}).Load(context.Background(), Source{List: "roots", Kind: KindPEM, Location: "file://" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(anchorNames(anchors), ","); got != "Example Root A,Example Root B" {
+		t.Errorf("anchors = %s", got)
+	}
+
+	records := Records(anchors)
+	for i, r := range records {
+		if r.List != "roots" || r.SKI == "" || len(r.AKI) != 40 || !bytes.Equal(r.Certificate, anchors[i].Certificate.Raw) {
+			t.Errorf("record %+v", r)
+		}
+		if back := FromRecord(r); back.Certificate == nil || !back.Certificate.Equal(anchors[i].Certificate) {
+			t.Error("FromRecord lost the certificate")
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package trust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shurco/gosign/pkg/security/xmldsig"
+)
+
+// Service types and statuses of ETSI TS 119 612 whose certificates are anchors
+const (
+	ServiceTypeCAQC           = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	ServiceTypeCAPKC          = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"
+	ServiceTypeNationalRootCA = "http://uri.etsi.org/TrstSvc/Svctype/NationalRootCA-QC"
+	ServiceTypeTSAQTST        = "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"
+	ServiceTypeTSA            = "http://uri.etsi.org/TrstSvc/Svctype/TSA"
+
+	ServiceStatusGranted    = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
+	ServiceStatusRecognised = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognisedatnationallevel"
+
+	// MimeTypeTSL marks pointers to XML trusted lists (the others are PDFs)
+	MimeTypeTSL = "application/vnd.etsi.tsl+xml"
+)
+
+var anchorServiceTypes = map[string]bool{
+	ServiceTypeCAQC:           true,
+	ServiceTypeCAPKC:          true,
+	ServiceTypeNationalRootCA: true,
+	ServiceTypeTSAQTST:        true,
+	ServiceTypeTSA:            true,
+}
+
+var activeServiceStatuses = map[string]bool{
+	ServiceStatusGranted:    true,
+	ServiceStatusRecognised: true,
+	// Statuses of lists issued before eIDAS
+	"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision":       true,
+	"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/supervisionincessation": true,
+	"http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/accredited":             true,
+}
+
+// TSL is an ETSI TS 119 612 trusted list or list of trusted lists
+type TSL struct {
+	Type       string
+	Territory  string
+	Operator   string
+	NextUpdate time.Time
+	Pointers   []Pointer
+	Services   []Service
+}
+
+// Pointer points to another trusted list
+type Pointer struct {
+	Location     string
+	Territory    string
+	Type         string
+	MimeType     string
+	Certificates []*x509.Certificate // allowed to sign the list
+}
+
+// IsXML reports whether the pointer is to the XML form of a list
+func (p Pointer) IsXML() bool {
+	return p.MimeType == "" || p.MimeType == MimeTypeTSL
+}
+
+// IsLOTL reports whether the pointer is to a list of trusted lists
+func (p Pointer) IsLOTL() bool {
+	return strings.HasSuffix(strings.ToLower(p.Type), "listofthelists")
+}
+
+// Service is a trust service of a provider
+type Service struct {
+	Provider     string
+	Name         string
+	Type         string
+	Status       string
+	Certificates []*x509.Certificate
+}
+
+// Active reports whether the certificates of the service are trust anchors
+func (s Service) Active() bool {
+	return anchorServiceTypes[s.Type] && activeServiceStatuses[s.Status]
+}
+
+type tslName struct {
+	Lang  string `xml:"lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tslDigitalID struct {
+	Certificate string `xml:"X509Certificate"`
+}
+
+type tslXML struct {
+	XMLName xml.Name `xml:"TrustServiceStatusList"`
+	Scheme  struct {
+		Type       string    `xml:"TSLType"`
+		Operator   []tslName `xml:"SchemeOperatorName>Name"`
+		Territory  string    `xml:"SchemeTerritory"`
+		NextUpdate string    `xml:"NextUpdate>dateTime"`
+		Pointers   []struct {
+			Location   string         `xml:"TSLLocation"`
+			Identities []tslDigitalID `xml:"ServiceDigitalIdentities>ServiceDigitalIdentity>DigitalId"`
+			Info       []struct {
+				Type      string `xml:"TSLType"`
+				Territory string `xml:"SchemeTerritory"`
+				MimeType  string `xml:"MimeType"`
+			} `xml:"AdditionalInformation>OtherInformation"`
+		} `xml:"PointersToOtherTSL>OtherTSLPointer"`
+	} `xml:"SchemeInformation"`
+	Providers []struct {
+		Name     []tslName `xml:"TSPInformation>TSPName>Name"`
+		Services []struct {
+			Type       string         `xml:"ServiceInformation>ServiceTypeIdentifier"`
+			Name       []tslName      `xml:"ServiceInformation>ServiceName>Name"`
+			Identities []tslDigitalID `xml:"ServiceInformation>ServiceDigitalIdentity>DigitalId"`
+			Status     string         `xml:"ServiceInformation>ServiceStatus"`
+		} `xml:"TSPServices>TSPService"`
+	} `xml:"TrustServiceProviderList>TrustServiceProvider"`
+}
+
+// ParseTSL parses a trusted list. The signature is not checked, see
+// parseSignedTSL.
+func ParseTSL(data []byte) (*TSL, error) {
+	if bytes.Contains(data, []byte("<!DOCTYPE")) {
+		return nil, errors.New("trusted list: DTDs are not supported")
+	}
+	var raw tslXML
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("trusted list: %w", err)
+	}
+
+	tsl := &TSL{
+		Type:      strings.TrimSpace(raw.Scheme.Type),
+		Territory: strings.TrimSpace(raw.Scheme.Territory),
+		Operator:  englishName(raw.Scheme.Operator),
+	}
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(raw.Scheme.NextUpdate)); err == nil {
+		tsl.NextUpdate = t
+	}
+
+	for _, p := range raw.Scheme.Pointers {
+		pointer := Pointer{Location: strings.TrimSpace(p.Location)}
+		for _, info := range p.Info {
+			pointer.Type = firstNonEmpty(pointer.Type, strings.TrimSpace(info.Type))
+			pointer.Territory = firstNonEmpty(pointer.Territory, strings.TrimSpace(info.Territory))
+			pointer.MimeType = firstNonEmpty(pointer.MimeType, strings.TrimSpace(info.MimeType))
+		}
+		pointer.Certificates = parseDigitalIDs(p.Identities)
+		tsl.Pointers = append(tsl.Pointers, pointer)
+	}
+
+	for _, p := range raw.Providers {
+		provider := englishName(p.Name)
+		for _, s := range p.Services {
+			tsl.Services = append(tsl.Services, Service{
+				Provider:     provider,
+				Name:         englishName(s.Name),
+				Type:         strings.TrimSpace(s.Type),
+				Status:       strings.TrimSpace(s.Status),
+				Certificates: parseDigitalIDs(s.Identities),
+			})
+		}
+	}
+	return tsl, nil
+}
+
+// ErrNoTSLSigners is returned for a trusted list without certificates to check its signature
+var ErrNoTSLSigners = errors.New("trusted list: no signer certificates to verify it with")
+
+// parseSignedTSL parses a trusted list after checking it is signed by one of
+// signers. A list without signers is refused, or parsed unverified when insecure.
+func parseSignedTSL(data []byte, signers []*x509.Certificate, insecure bool) (*TSL, error) {
+	if len(signers) == 0 {
+		if !insecure {
+			return nil, ErrNoTSLSigners
+		}
+		return ParseTSL(data)
+	}
+	if _, err := xmldsig.VerifyWith(data, signers); err != nil {
+		return nil, err
+	}
+	return ParseTSL(data)
+}
+
+// Anchors returns the certificates of the active services of the list
+func (t *TSL) Anchors(list, location string) []Anchor {
+	var anchors []Anchor
+	for _, s := range t.Services {
+		if !s.Active() {
+			continue
+		}
+		for _, c := range s.Certificates {
+			name := s.Name
+			if name == "" {
+				name = commonName(c)
+			}
+			anchors = append(anchors, Anchor{List: list, Name: name, Location: location, Certificate: c})
+		}
+	}
+	return anchors
+}
+
+// parseDigitalIDs returns the certificates of digital identities; other kinds
+// of identities (subject names, key identifiers) are ignored
+func parseDigitalIDs(ids []tslDigitalID) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, id := range ids {
+		if id.Certificate == "" {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(id.Certificate), ""))
+		if err != nil {
+			continue
+		}
+		if c, err := x509.ParseCertificate(der); err == nil {
+			certs = append(certs, c)
+		}
+	}
+	return certs
+}
+
+// englishName picks the English one of multilingual names, or the first
+func englishName(names []tslName) string {
+	for _, n := range names {
+		if strings.EqualFold(n.Lang, "en") {
+			return strings.TrimSpace(n.Value)
+		}
+	}
+	if len(names) > 0 {
+		return strings.TrimSpace(names[0].Value)
+	}
+	return ""
+}
+
+func firstNonEmpty(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
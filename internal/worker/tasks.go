@@ -6,10 +6,9 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 
-	"github.com/shurco/gosign/internal/queries"
+	"github.com/shurco/gosign/internal/services"
 	"github.com/shurco/gosign/internal/trust"
 	"github.com/shurco/gosign/pkg/notification"
-	"github.com/shurco/gosign/pkg/webhook"
 )
 
 // NotificationTask task for processing notification queue
@@ -36,75 +35,35 @@ func (t *NotificationTask) ShouldRetry(err error) bool {
 	return true
 }
 
-// WebhookTask task for processing webhooks queue
+// WebhookTask task for processing webhooks queue: attempts the due deliveries of the
+// outbox, including the retries of failed ones
 type WebhookTask struct {
-	dispatcher *webhook.Dispatcher
-	db         *pgxpool.Pool
-	accountID  string
+	deliveries *services.WebhookDeliveryService
 }
 
 // NewWebhookTask creates new task for webhooks
-func NewWebhookTask(dispatcher *webhook.Dispatcher, db *pgxpool.Pool, accountID string) *WebhookTask {
+func NewWebhookTask(deliveries *services.WebhookDeliveryService) *WebhookTask {
 	return &WebhookTask{
-		dispatcher: dispatcher,
-		db:         db,
-		accountID:  accountID,
+		deliveries: deliveries,
 	}
 }
 
-// Execute performs the task - process pending webhooks
+// Execute performs the task - process due webhook deliveries
 func (t *WebhookTask) Execute(ctx context.Context) error {
-	// Get pending webhooks for each event type
-	eventTypes := []string{
-		"submission.created",
-		"submission.completed",
-		"submission.cancelled",
-		"submission.expired",
-		"submitter.completed",
-		"submitter.declined",
+	delivered, failed, err := t.deliveries.DeliverDue(ctx)
+	if err != nil {
+		return err
 	}
 
-	processed := 0
-	failed := 0
-
-	for _, eventType := range eventTypes {
-		webhooks, err := queries.GetPendingWebhooks(ctx, t.db, t.accountID, eventType)
-		if err != nil {
-			log.Error().Err(err).Str("event_type", eventType).Msg("Failed to get pending webhooks")
-			continue
-		}
-
-		for _, webhook := range webhooks {
-			// Check if webhook should be skipped (too many failures)
-			if webhook.FailureCount >= 5 {
-				if err := queries.DisableWebhook(ctx, t.db, webhook.ID); err != nil {
-					log.Error().Err(err).Str("webhook_id", webhook.ID).Msg("Failed to disable webhook")
-				}
-				log.Warn().Str("webhook_id", webhook.ID).Msg("Webhook disabled due to too many failures")
-				continue
-			}
-
-			// Try to send webhook
-			// Note: Actual sending should be via dispatcher with proper event data
-			// This is a placeholder for the queue processing mechanism
-			
-			// Update last triggered timestamp
-			if err := queries.UpdateWebhookLastTriggered(ctx, t.db, webhook.ID); err != nil {
-				log.Error().Err(err).Str("webhook_id", webhook.ID).Msg("Failed to update webhook timestamp")
-				failed++
-			} else {
-				processed++
-			}
-		}
+	if delivered > 0 || failed > 0 {
+		log.Info().Int("delivered", delivered).Int("failed", failed).Msg("Webhook task completed")
 	}
-
-	log.Info().Int("processed", processed).Int("failed", failed).Msg("Webhook task completed")
 	return nil
 }
 
 // ShouldRetry determines if task should be retried on error
 func (t *WebhookTask) ShouldRetry(err error) bool {
-	return true
+	return false // deliveries left are due at the next run
 }
 
 // CleanupTask task for cleaning expired data
@@ -187,7 +146,7 @@ func (t *CleanupTask) ShouldRetry(err error) bool {
 	return false // cleanup is not critical, no retry
 }
 
-// TrustUpdateTask task for updating trust certificates (eutl12, tl12; hardcoded).
+// TrustUpdateTask task for updating trust certificates (the lists of trust.Sources).
 type TrustUpdateTask struct{}
 
 // NewTrustUpdateTask creates a new task for updating trust certificates
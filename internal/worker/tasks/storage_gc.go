@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shurco/gosign/internal/services"
+)
+
+// StorageGCTask removes blobs and files no template or submission references any more
+type StorageGCTask struct {
+	blobs  *services.BlobService
+	grace  time.Duration
+	dryRun bool
+}
+
+// NewStorageGCTask creates a new task collecting what has been unreferenced for the grace
+// period; a dry run only logs what would be removed
+func NewStorageGCTask(blobs *services.BlobService, grace time.Duration, dryRun bool) *StorageGCTask {
+	return &StorageGCTask{
+		blobs:  blobs,
+		grace:  grace,
+		dryRun: dryRun,
+	}
+}
+
+// Execute collects the garbage of the storages
+func (t *StorageGCTask) Execute(ctx context.Context) error {
+	report, err := t.blobs.CollectGarbage(ctx, t.grace, t.dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to collect storage garbage: %w", err)
+	}
+
+	log.Info().
+		Bool("dry_run", report.DryRun).
+		Int("attachments", report.Attachments).
+		Int("blobs", len(report.Blobs)).
+		Int("files", len(report.Files)).
+		Int64("bytes", report.Bytes).
+		Msg("Storage garbage collected")
+	return nil
+}
+
+// ShouldRetry determines if the task should be retried on error
+func (t *StorageGCTask) ShouldRetry(err error) bool {
+	return false // the next run picks up what is left
+}
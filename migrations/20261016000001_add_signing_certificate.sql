@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Per-organization (or per-account) signing certificates with encrypted private keys
+CREATE TABLE IF NOT EXISTS "public"."signing_certificate" (
+  "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  "organization_id" uuid,
+  "account_id" uuid,
+  "name" varchar(255) NOT NULL,
+  "subject" text NOT NULL,
+  "issuer" text NOT NULL,
+  "serial_number" varchar(128) NOT NULL,
+  "key_algorithm" varchar(32) NOT NULL,
+  "not_before" timestamptz NOT NULL,
+  "not_after" timestamptz NOT NULL,
+  "certificate" text NOT NULL,
+  "chain" text NOT NULL DEFAULT '',
+  "private_key" bytea NOT NULL,
+  "source" varchar(20) NOT NULL DEFAULT 'uploaded',
+  "is_default" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT fk_signing_certificate_org FOREIGN KEY ("organization_id") REFERENCES "organization"("id") ON DELETE CASCADE,
+  CONSTRAINT fk_signing_certificate_account FOREIGN KEY ("account_id") REFERENCES "account"("id") ON DELETE CASCADE,
+  CONSTRAINT check_signing_certificate_scope CHECK ("organization_id" IS NOT NULL OR "account_id" IS NOT NULL),
+  CONSTRAINT check_signing_certificate_source CHECK ("source" IN ('uploaded', 'generated'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_certificate_org_id ON "public"."signing_certificate"("organization_id");
+CREATE INDEX IF NOT EXISTS idx_signing_certificate_account_id ON "public"."signing_certificate"("account_id");
+
+-- Only one default certificate per scope
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_certificate_default_org
+  ON "public"."signing_certificate"("organization_id") WHERE "is_default" AND "organization_id" IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_certificate_default_account
+  ON "public"."signing_certificate"("account_id") WHERE "is_default" AND "organization_id" IS NULL;
+
+DROP TRIGGER IF EXISTS update_signing_certificate_updated_at ON "public"."signing_certificate";
+CREATE TRIGGER update_signing_certificate_updated_at
+    BEFORE UPDATE ON "public"."signing_certificate"
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."signing_certificate";
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Certificates issued by the internal CA; revoked rows feed the published CRL
+CREATE TABLE IF NOT EXISTS "public"."issued_certificate" (
+  "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  "serial_number" varchar(64) NOT NULL,
+  "subject" text NOT NULL,
+  "organization_id" uuid,
+  "account_id" uuid,
+  "submitter_id" uuid,
+  "certificate" text NOT NULL,
+  "not_before" timestamptz NOT NULL,
+  "not_after" timestamptz NOT NULL,
+  "revoked_at" timestamptz,
+  "revocation_reason" integer NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT fk_issued_certificate_org FOREIGN KEY ("organization_id") REFERENCES "organization"("id") ON DELETE SET NULL,
+  CONSTRAINT fk_issued_certificate_account FOREIGN KEY ("account_id") REFERENCES "account"("id") ON DELETE SET NULL,
+  CONSTRAINT fk_issued_certificate_submitter FOREIGN KEY ("submitter_id") REFERENCES "submitter"("id") ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_issued_certificate_serial ON "public"."issued_certificate"("serial_number");
+CREATE INDEX IF NOT EXISTS idx_issued_certificate_org_id ON "public"."issued_certificate"("organization_id");
+CREATE INDEX IF NOT EXISTS idx_issued_certificate_account_id ON "public"."issued_certificate"("account_id");
+CREATE INDEX IF NOT EXISTS idx_issued_certificate_revoked ON "public"."issued_certificate"("revoked_at") WHERE "revoked_at" IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."issued_certificate";
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Timestamping certificates back the built-in RFC 3161 TSA and are never used to sign documents
+ALTER TABLE "public"."signing_certificate"
+  ADD COLUMN IF NOT EXISTS "usage" varchar(20) NOT NULL DEFAULT 'signing';
+
+ALTER TABLE "public"."signing_certificate"
+  ADD CONSTRAINT check_signing_certificate_usage CHECK ("usage" IN ('signing', 'timestamping'));
+
+ALTER TABLE "public"."signing_certificate"
+  ADD CONSTRAINT check_signing_certificate_default_usage CHECK (NOT "is_default" OR "usage" = 'signing');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."signing_certificate" DROP CONSTRAINT IF EXISTS check_signing_certificate_default_usage;
+ALTER TABLE "public"."signing_certificate" DROP CONSTRAINT IF EXISTS check_signing_certificate_usage;
+ALTER TABLE "public"."signing_certificate" DROP COLUMN IF EXISTS "usage";
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Keys of external certificates stay on a PKCS#11 token or a remote signing service;
+-- key_uri addresses them and private_key is left empty
+ALTER TABLE "public"."signing_certificate"
+  ADD COLUMN IF NOT EXISTS "key_uri" text NOT NULL DEFAULT '';
+
+ALTER TABLE "public"."signing_certificate" DROP CONSTRAINT IF EXISTS check_signing_certificate_source;
+ALTER TABLE "public"."signing_certificate"
+  ADD CONSTRAINT check_signing_certificate_source CHECK ("source" IN ('uploaded', 'generated', 'external'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."signing_certificate" WHERE "source" = 'external';
+ALTER TABLE "public"."signing_certificate" DROP CONSTRAINT IF EXISTS check_signing_certificate_source;
+ALTER TABLE "public"."signing_certificate"
+  ADD CONSTRAINT check_signing_certificate_source CHECK ("source" IN ('uploaded', 'generated'));
+ALTER TABLE "public"."signing_certificate" DROP COLUMN IF EXISTS "key_uri";
+-- +goose StatementEnd
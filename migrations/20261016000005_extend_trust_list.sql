@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Trust lists are configurable (ETSI TSL/LOTL, PEM bundles) and organizations or
+-- accounts may add their own roots; rows without an owner are platform anchors.
+-- location is where the list holding the anchor was read from, certificate is
+-- the DER anchor used to check issuer signatures
+ALTER TABLE "public"."trust_list" ALTER COLUMN "list" TYPE varchar(64);
+ALTER TABLE "public"."trust_list"
+  ADD COLUMN IF NOT EXISTS "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+  ADD COLUMN IF NOT EXISTS "organization_id" uuid,
+  ADD COLUMN IF NOT EXISTS "account_id" uuid,
+  ADD COLUMN IF NOT EXISTS "location" text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS "certificate" bytea,
+  ADD CONSTRAINT fk_trust_list_org FOREIGN KEY ("organization_id") REFERENCES "organization"("id") ON DELETE CASCADE,
+  ADD CONSTRAINT fk_trust_list_account FOREIGN KEY ("account_id") REFERENCES "account"("id") ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_trust_list_ski ON "public"."trust_list"("ski");
+CREATE INDEX IF NOT EXISTS idx_trust_list_list ON "public"."trust_list"("list");
+CREATE INDEX IF NOT EXISTS idx_trust_list_org_id ON "public"."trust_list"("organization_id");
+CREATE INDEX IF NOT EXISTS idx_trust_list_account_id ON "public"."trust_list"("account_id");
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "public"."trust_list" WHERE "organization_id" IS NOT NULL OR "account_id" IS NOT NULL OR length("list") > 10;
+DROP INDEX IF EXISTS idx_trust_list_ski;
+DROP INDEX IF EXISTS idx_trust_list_list;
+DROP INDEX IF EXISTS idx_trust_list_org_id;
+DROP INDEX IF EXISTS idx_trust_list_account_id;
+ALTER TABLE "public"."trust_list"
+  DROP CONSTRAINT IF EXISTS fk_trust_list_org,
+  DROP CONSTRAINT IF EXISTS fk_trust_list_account,
+  DROP COLUMN IF EXISTS "certificate",
+  DROP COLUMN IF EXISTS "location",
+  DROP COLUMN IF EXISTS "account_id",
+  DROP COLUMN IF EXISTS "organization_id",
+  DROP COLUMN IF EXISTS "id";
+ALTER TABLE "public"."trust_list" ALTER COLUMN "list" TYPE varchar(10);
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- SHA-256 of the documents produced for a submission (original, completed and
+-- certificate PDFs) so that any copy can be checked against our records
+CREATE TABLE IF NOT EXISTS "public"."document_hash" (
+  "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  "submission_id" uuid NOT NULL,
+  "kind" varchar(16) NOT NULL,
+  "sha256" char(64) NOT NULL,
+  "size" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT fk_document_hash_submission FOREIGN KEY ("submission_id") REFERENCES "submission"("id") ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_hash_submission_kind ON "public"."document_hash"("submission_id", "kind", "sha256");
+CREATE INDEX IF NOT EXISTS idx_document_hash_sha256 ON "public"."document_hash"("sha256");
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."document_hash";
+-- +goose StatementEnd
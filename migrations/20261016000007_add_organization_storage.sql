@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Blob storage of the documents of an organization (data residency); organizations
+-- without a row use the global storage. The secret access key is encrypted.
+CREATE TABLE IF NOT EXISTS "public"."organization_storage" (
+  "organization_id" uuid PRIMARY KEY,
+  "provider" varchar(16) NOT NULL,
+  "bucket" varchar(255) NOT NULL,
+  "region" varchar(64) NOT NULL DEFAULT '',
+  "endpoint" varchar(255) NOT NULL DEFAULT '',
+  "access_key_id" varchar(255) NOT NULL DEFAULT '',
+  "secret_access_key" bytea,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT fk_organization_storage_org FOREIGN KEY ("organization_id") REFERENCES "organization"("id") ON DELETE CASCADE
+);
+
+-- Pages are served by attachment ID; this finds the template (and organization) holding one
+CREATE INDEX IF NOT EXISTS idx_template_schema ON "public"."template" USING gin ("schema" jsonb_path_ops);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_template_schema;
+DROP TABLE IF EXISTS "public"."organization_storage";
+-- +goose StatementEnd
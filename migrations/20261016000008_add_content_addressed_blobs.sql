@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Blobs are content addressed: a file is stored once per storage under its SHA-256
+-- (lc_blobs/ab/abcd…) and shared by every attachment with the same content.
+-- Blobs created before keep a NULL checksum and their files under lc_pages.
+ALTER TABLE "public"."storage_blob"
+  ADD COLUMN IF NOT EXISTS "checksum" varchar(64),
+  ADD COLUMN IF NOT EXISTS "key" varchar,
+  ADD COLUMN IF NOT EXISTS "organization_id" uuid, -- whose storage holds the file, NULL for the global storage
+  ADD COLUMN IF NOT EXISTS "reference_count" int NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS "created_at" timestamptz NOT NULL DEFAULT now(),
+  ADD COLUMN IF NOT EXISTS "unreferenced_at" timestamptz;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "storage_blob_on_checksum"
+  ON "public"."storage_blob" (COALESCE("organization_id"::text, ''), "checksum")
+  WHERE "checksum" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "storage_blob_unreferenced"
+  ON "public"."storage_blob" ("unreferenced_at")
+  WHERE "reference_count" = 0;
+
+-- Files of template pages (0.pdf, 0.jpg, p/0.jpg) are attached to the page:
+-- record_type 'Page', record_id the attachment ID of the page, name the file name
+CREATE INDEX IF NOT EXISTS "storage_attachment_on_record"
+  ON "public"."storage_attachment" ("record_type", "record_id");
+
+UPDATE "public"."storage_blob" b
+SET "reference_count" = (SELECT count(*) FROM "public"."storage_attachment" a WHERE a."blob_id" = b."id");
+UPDATE "public"."storage_blob" SET "unreferenced_at" = now() WHERE "reference_count" = 0;
+
+-- Attachments keep the reference counts of their blobs; a blob left without any is
+-- stamped so the garbage collector removes it once the grace period has passed
+CREATE OR REPLACE FUNCTION fn_storage_blob_reference_count()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+	AS $function$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		UPDATE "storage_blob"
+		SET "reference_count" = "reference_count" + 1, "unreferenced_at" = NULL
+		WHERE "id" = NEW."blob_id";
+		RETURN NEW;
+	END IF;
+	UPDATE "storage_blob"
+	SET "reference_count" = GREATEST("reference_count" - 1, 0),
+		"unreferenced_at" = CASE WHEN "reference_count" <= 1 THEN now() ELSE NULL END
+	WHERE "id" = OLD."blob_id";
+	RETURN OLD;
+END;
+$function$;
+
+CREATE TRIGGER "tg_storage_blob_reference_count"
+	AFTER INSERT OR DELETE ON "public"."storage_attachment"
+	FOR EACH ROW EXECUTE FUNCTION fn_storage_blob_reference_count();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS "tg_storage_blob_reference_count" ON "public"."storage_attachment";
+DROP FUNCTION IF EXISTS fn_storage_blob_reference_count();
+DROP INDEX IF EXISTS "storage_attachment_on_record";
+DROP INDEX IF EXISTS "storage_blob_unreferenced";
+DROP INDEX IF EXISTS "storage_blob_on_checksum";
+ALTER TABLE "public"."storage_blob"
+  DROP COLUMN IF EXISTS "unreferenced_at",
+  DROP COLUMN IF EXISTS "created_at",
+  DROP COLUMN IF EXISTS "reference_count",
+  DROP COLUMN IF EXISTS "organization_id",
+  DROP COLUMN IF EXISTS "checksum",
+  DROP COLUMN IF EXISTS "key";
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Outbox of webhook deliveries: an event is queued once per subscribed webhook and
+-- delivered by the worker, retried with exponential backoff across restarts until it
+-- is delivered or dead (too many failed attempts). The row keeps the outcome of the
+-- last attempt; a manual redelivery queues a new row for the same event.
+CREATE TABLE "public"."webhook_delivery" (
+  "id" uuid DEFAULT gen_random_uuid (),
+  "webhook_id" uuid NOT NULL,
+  "event_id" uuid NOT NULL, -- the same for every delivery of an event, redeliveries included
+  "event_type" varchar(100) NOT NULL,
+  "payload" jsonb NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending', -- pending, delivered, dead
+  "attempts" int NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz DEFAULT now(), -- NULL once delivered or dead
+  "last_attempt_at" timestamptz,
+  "response_status" int, -- NULL when no response was received
+  "response_body" text, -- excerpt
+  "latency_ms" int,
+  "error" text,
+  "redelivery_of" uuid, -- the delivery manually redelivered
+  "delivered_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  FOREIGN KEY ("webhook_id") REFERENCES "public"."webhook"("id") ON DELETE CASCADE,
+  FOREIGN KEY ("redelivery_of") REFERENCES "public"."webhook_delivery"("id") ON DELETE SET NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "webhook_delivery_due" ON "public"."webhook_delivery" USING BTREE ("next_attempt_at") WHERE "status" = 'pending';
+CREATE INDEX "webhook_delivery_on_webhook_id" ON "public"."webhook_delivery" USING BTREE ("webhook_id", "created_at" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "public"."webhook_delivery";
+-- +goose StatementEnd
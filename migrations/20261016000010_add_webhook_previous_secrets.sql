@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Secrets replaced by a rotation keep signing deliveries, next to the new one, until they
+-- expire: [{"secret": "whsec_…", "expires_at": "…"}]
+ALTER TABLE "public"."webhook"
+  ADD COLUMN IF NOT EXISTS "previous_secrets" jsonb NOT NULL DEFAULT '[]'::jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."webhook" DROP COLUMN IF EXISTS "previous_secrets";
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- template_ids limits the submission and template events of a webhook to some templates
+-- (all of them when empty); include_values adds the values submitters filled to payloads
+ALTER TABLE "public"."webhook"
+  ADD COLUMN IF NOT EXISTS "template_ids" jsonb NOT NULL DEFAULT '[]'::jsonb,
+  ADD COLUMN IF NOT EXISTS "include_values" boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "public"."webhook"
+  DROP COLUMN IF EXISTS "include_values",
+  DROP COLUMN IF EXISTS "template_ids";
+-- +goose StatementEnd
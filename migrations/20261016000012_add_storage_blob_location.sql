@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Storage holding the file of a blob within the storage of its organization: '' for the
+-- global storage, provider:endpoint/bucket for a bucket of the organization. Blobs are
+-- deduplicated per storage, so a file is never assumed to be in a bucket it wasn't written to.
+ALTER TABLE "public"."storage_blob" ADD COLUMN IF NOT EXISTS "storage" varchar NOT NULL DEFAULT '';
+
+UPDATE "public"."storage_blob" b
+SET "storage" = s."provider" || ':' || s."endpoint" || '/' || s."bucket"
+FROM "public"."organization_storage" s
+WHERE s."organization_id" = b."organization_id";
+
+DROP INDEX IF EXISTS "storage_blob_on_checksum";
+CREATE UNIQUE INDEX IF NOT EXISTS "storage_blob_on_checksum"
+  ON "public"."storage_blob" (COALESCE("organization_id"::text, ''), "storage", "checksum")
+  WHERE "checksum" IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "storage_blob_on_checksum";
+CREATE UNIQUE INDEX IF NOT EXISTS "storage_blob_on_checksum"
+  ON "public"."storage_blob" (COALESCE("organization_id"::text, ''), "checksum")
+  WHERE "checksum" IS NOT NULL;
+ALTER TABLE "public"."storage_blob" DROP COLUMN IF EXISTS "storage";
+-- +goose StatementEnd
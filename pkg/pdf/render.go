@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,11 +20,13 @@ import (
 //
 // Notes:
 //   - The current goSign storage model stores each PDF page as its own attachment:
-//     lc_pages/{attachment_id}/0.pdf
+//     lc_pages/{attachment_id}/0.pdf, read with LoadPage (e.g. from the blob storage)
+//     or from PagesDir
 //   - Field areas are stored as percentages (0..1) relative to an A4 page.
 //   - For signature/initials fields, the frontend stores a PNG data URL in the field value.
 type RenderCompletedTemplatePDFInput struct {
-	PagesDir string // e.g. "./lc_pages"
+	PagesDir string                                    // e.g. "./lc_pages"
+	LoadPage func(attachmentID string) ([]byte, error) // takes precedence over PagesDir
 	Schema   []models.Schema
 	Fields   []models.Field
 	Values   map[string]any // field_id -> value (string/bool/[]any/etc.)
@@ -35,9 +38,15 @@ type RenderCompletedTemplatePDFInput struct {
 // It intentionally does NOT require PDF form fields; it uses template-defined areas
 // (percent-based coordinates) and draws text/images at those coordinates.
 func RenderCompletedTemplatePDF(input RenderCompletedTemplatePDFInput) ([]byte, error) {
-	if input.PagesDir == "" {
+	if input.PagesDir == "" && input.LoadPage == nil {
 		return nil, fmt.Errorf("pages dir is required")
 	}
+	loadPage := input.LoadPage
+	if loadPage == nil {
+		loadPage = func(attachmentID string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(input.PagesDir, attachmentID, "0.pdf"))
+		}
+	}
 	if len(input.Schema) == 0 {
 		return nil, fmt.Errorf("template schema is empty")
 	}
@@ -58,13 +67,14 @@ func RenderCompletedTemplatePDF(input RenderCompletedTemplatePDFInput) ([]byte,
 			continue
 		}
 
-		pagePath := filepath.Join(input.PagesDir, schemaItem.AttachmentID, "0.pdf")
-		if _, err := os.Stat(pagePath); err != nil {
+		page, err := loadPage(schemaItem.AttachmentID)
+		if err != nil {
 			return nil, fmt.Errorf("missing page PDF for attachment %s: %w", schemaItem.AttachmentID, err)
 		}
 
 		pdf.AddPage()
-		tpl := pdf.ImportPage(pagePath, 1, "/MediaBox")
+		var pageStream io.ReadSeeker = bytes.NewReader(page)
+		tpl := pdf.ImportPageStream(&pageStream, 1, "/MediaBox")
 		pdf.UseImportedTemplate(tpl, 0, 0, 0, 0)
 
 		// Overlay all fields that have at least one area on this page attachment.
@@ -62,3 +62,28 @@ func TestRenderCompletedTemplatePDF_missingPageFile(t *testing.T) {
 		t.Fatal("expected error for missing page PDF")
 	}
 }
+
+func TestRenderCompletedTemplatePDF_loadPage(t *testing.T) {
+	var loaded []string
+	out, err := RenderCompletedTemplatePDF(RenderCompletedTemplatePDFInput{
+		LoadPage: func(attachmentID string) ([]byte, error) {
+			loaded = append(loaded, attachmentID)
+			return buildTestPDF(t, 1, "p"), nil
+		},
+		Schema: []models.Schema{{AttachmentID: "a1", Name: "p1"}, {AttachmentID: "a2", Name: "p2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) == 0 || len(loaded) != 2 || loaded[0] != "a1" || loaded[1] != "a2" {
+		t.Fatalf("loaded %v, %d bytes", loaded, len(out))
+	}
+
+	_, err = RenderCompletedTemplatePDF(RenderCompletedTemplatePDFInput{
+		LoadPage: func(string) ([]byte, error) { return nil, os.ErrNotExist },
+		Schema:   []models.Schema{{AttachmentID: "missing", Name: "x"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for a page LoadPage can't load")
+	}
+}
@@ -0,0 +1,129 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/signintech/gopdf"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+// reportPage is the writing position on the pages of a rendered report
+type reportPage struct {
+	pdf   *gopdf.GoPdf
+	fonts standardFonts
+	y     float64
+}
+
+const (
+	reportMargin     = 50.0
+	reportLineHeight = 13.0
+)
+
+// line writes text at indent x, wrapping it to the page width and starting a
+// new page when the current one is full
+func (p *reportPage) line(x float64, text string) {
+	lines := []string{text}
+	if wrapped, err := p.pdf.SplitText(text, A4WidthPt-reportMargin-x); err == nil && len(wrapped) > 0 {
+		lines = wrapped
+	}
+	for _, l := range lines {
+		if p.y > A4HeightPt-reportMargin {
+			p.pdf.AddPage()
+			p.y = reportMargin
+		}
+		p.pdf.SetXY(x, p.y)
+		p.pdf.Cell(nil, l)
+		p.y += reportLineHeight
+	}
+}
+
+// field writes a "label: value" line
+func (p *reportPage) field(x float64, label, value string) {
+	if value == "" {
+		return
+	}
+	p.line(x, label+": "+value)
+}
+
+// RenderVerificationReportPDF renders a validation report as a PDF document.
+// assetsDir may hold fonts/Arial.ttf; system fonts are used otherwise.
+func RenderVerificationReportPDF(report *verify.Report, assetsDir string) ([]byte, error) {
+	if report == nil {
+		return nil, fmt.Errorf("report is required")
+	}
+
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	p := &reportPage{pdf: &pdf, fonts: addStandardFonts(&pdf, assetsDir), y: reportMargin}
+	pdf.AddPage()
+
+	p.fonts.SetBold(&pdf, 16)
+	p.line(reportMargin, "Signature Validation Report")
+	p.y += reportLineHeight / 2
+
+	p.fonts.SetNormal(&pdf, 10)
+	p.field(reportMargin, "Validation time", report.ValidationTime.Format("2006-01-02 15:04:05 MST"))
+	p.field(reportMargin, "Result", report.Indication)
+	p.field(reportMargin, "Document SHA-256", report.Document.SHA256)
+	p.field(reportMargin, "Document size", fmt.Sprintf("%d bytes, %d revisions", report.Document.Size, report.Document.Revisions))
+	p.field(reportMargin, "PAdES level", report.Document.Level)
+
+	for _, s := range report.Signatures {
+		p.y += reportLineHeight
+		p.fonts.SetBold(&pdf, 11)
+		p.line(reportMargin, fmt.Sprintf("%s: %s (%s)", s.ID, s.SignerName, s.Kind))
+
+		p.fonts.SetNormal(&pdf, 9)
+		indication := s.Indication
+		if s.SubIndication != "" {
+			indication += " / " + s.SubIndication
+		}
+		x := reportMargin + 10
+		p.field(x, "Indication", indication)
+		p.field(x, "Format", s.Format)
+		p.field(x, "PAdES level", s.Level)
+		p.field(x, "Reason", s.Reason)
+		if s.TimestampTime != nil {
+			p.field(x, "Proof of existence", s.TimestampTime.Format("2006-01-02 15:04:05 MST"))
+		}
+		p.field(x, "Byte range", strings.Trim(fmt.Sprint(s.ByteRange), "[]"))
+		p.field(x, "Covers whole document", fmt.Sprint(s.CoversWholeDocument))
+		for _, e := range s.Errors {
+			p.field(x, "Error", e)
+		}
+
+		if len(s.Modifications) > 0 {
+			p.line(x, "Modifications after signing:")
+			for _, m := range s.Modifications {
+				p.line(x+10, fmt.Sprintf("Revision %d (%d bytes at offset %d): %s [%s]", m.Revision, m.Length, m.Offset, m.Kind, strings.Join(m.Changes, ", ")))
+			}
+		}
+
+		p.line(x, "Certificate chain:")
+		for i, c := range s.Chain {
+			trust := "not a trust anchor"
+			if c.TrustAnchor != nil {
+				trust = fmt.Sprintf("trust anchor from %s", c.TrustAnchor.Source)
+				if c.TrustAnchor.Location != "" {
+					trust += ", " + c.TrustAnchor.Location
+				}
+			}
+			p.line(x+10, fmt.Sprintf("%d. %s (%s)", i+1, c.Subject, trust))
+			if c.Embedded {
+				p.field(x+20, "Issuer", c.Issuer)
+				p.field(x+20, "Serial", c.SerialNumber)
+				p.field(x+20, "Valid", fmt.Sprintf("%s - %s", c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02")))
+				p.field(x+20, "Revocation", c.Revocation)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
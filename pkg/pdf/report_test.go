@@ -0,0 +1,45 @@
+package pdf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func TestRenderVerificationReportPDF(t *testing.T) {
+	poe := time.Now()
+	report := &verify.Report{
+		ValidationTime: time.Now(),
+		Indication:     verify.IndicationIndeterminate,
+		Document:       verify.ReportDocument{Size: 1024, SHA256: "00ff", Revisions: 2, Level: verify.LevelBT},
+		Signatures: []verify.SignatureReport{{
+			ID:            "sig-1",
+			Kind:          verify.KindSignature,
+			Format:        "adbe.pkcs7.detached",
+			SignerName:    "Signer",
+			TimestampTime: &poe,
+			Indication:    verify.IndicationIndeterminate,
+			SubIndication: verify.SubIndicationNoCertificateChainFound,
+			ByteRange:     []int64{0, 100, 200, 300},
+			Modifications: []verify.Modification{{Revision: 2, Offset: 500, Length: 524, Kind: verify.KindFormFill, Changes: []string{verify.KindFormFill}}},
+			Chain: []verify.ChainElement{
+				{Subject: "CN=Signer", Issuer: "CN=CA", SerialNumber: "01", Embedded: true, Revocation: "good"},
+				{Subject: "CA", TrustAnchor: &verify.TrustAnchor{Source: "eutl12", Name: "CA"}},
+			},
+		}},
+	}
+
+	out, err := RenderVerificationReportPDF(report, "")
+	if err != nil {
+		t.Fatalf("RenderVerificationReportPDF() error: %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF")) {
+		t.Fatal("output is not a PDF")
+	}
+
+	if _, err := RenderVerificationReportPDF(nil, ""); err == nil {
+		t.Fatal("expected error for nil report")
+	}
+}
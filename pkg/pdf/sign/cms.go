@@ -0,0 +1,44 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/digitorus/pkcs7"
+)
+
+// CMSAttributes are attributes added to a CMS signature besides the signing
+// certificate and the signature timestamp.
+type CMSAttributes struct {
+	Signed   []pkcs7.Attribute
+	Unsigned []pkcs7.Attribute
+}
+
+// SignCMS signs arbitrary content with the key, certificate chain, digest
+// algorithm and TSA of sign_data and returns the DER encoded CMS SignedData.
+// The content is embedded unless detached. RevocationFunction is not called,
+// see FetchRevocationData; embedding revocation data is left to attributes.
+func SignCMS(content []byte, sign_data SignData, attributes CMSAttributes, detached bool) ([]byte, error) {
+	if !sign_data.DigestAlgorithm.Available() {
+		sign_data.DigestAlgorithm = crypto.SHA256
+	}
+
+	context := SignContext{SignData: sign_data}
+	if err := context.checkSigner(); err != nil {
+		return nil, err
+	}
+	// RFC 8419: Ed25519 with signed attributes uses SHA-512
+	if _, ok := context.SignData.Signer.Public().(ed25519.PublicKey); ok {
+		context.SignData.DigestAlgorithm = crypto.SHA512
+	}
+
+	signed_data, err := context.newSignedData(content, attributes.Signed, attributes.Unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signature: %w", err)
+	}
+	if detached {
+		signed_data.Detach()
+	}
+	return signed_data.Finish()
+}
@@ -4,6 +4,13 @@ import (
 	"strconv"
 )
 
+// catalogKeysReplaced are the catalog entries createCatalog writes itself;
+// every other entry (e.g. /DSS, /Names, /Metadata) is carried over.
+var catalogKeysReplaced = map[string]bool{"Type": true, "Version": true, "Pages": true, "AcroForm": true}
+
+// acroFormKeysReplaced are the AcroForm entries createCatalog writes itself
+var acroFormKeysReplaced = map[string]bool{"Fields": true, "NeedAppearances": true, "SigFlags": true}
+
 func (context *SignContext) createCatalog() (catalog string, err error) {
 	catalog = strconv.Itoa(int(context.CatalogData.ObjectId)) + " 0 obj\n"
 	catalog += "<< /Type /Catalog"
@@ -27,8 +34,15 @@ func (context *SignContext) createCatalog() (catalog string, err error) {
 		catalog += " /Pages " + strconv.Itoa(int(pages.GetID())) + " " + strconv.Itoa(int(pages.GetGen())) + " R"
 	}
 
+	// Keep the fields of earlier signatures next to the new one.
+	acro_form := root.Key("AcroForm")
+	fields := acro_form.Key("Fields")
 	catalog += " /AcroForm <<"
-	catalog += " /Fields [" + strconv.Itoa(int(context.VisualSignData.ObjectId)) + " 0 R]"
+	catalog += " /Fields ["
+	for i := 0; i < fields.Len(); i++ {
+		catalog += pdfObject(fields, fields.Index(i)) + " "
+	}
+	catalog += strconv.Itoa(int(context.VisualSignData.ObjectId)) + " 0 R]"
 
 	switch context.SignData.Signature.CertType {
 	case CertificationSignature, UsageRightsSignature:
@@ -36,12 +50,18 @@ func (context *SignContext) createCatalog() (catalog string, err error) {
 	}
 
 	switch context.SignData.Signature.CertType {
-	case CertificationSignature:
+	case CertificationSignature, ApprovalSignature, TimeStampSignature:
 		catalog += " /SigFlags 3"
 	case UsageRightsSignature:
 		catalog += " /SigFlags 1"
 	}
 
+	for _, key := range acro_form.Keys() {
+		if !acroFormKeysReplaced[key] {
+			catalog += " " + pdfName(key) + " " + pdfObject(acro_form, acro_form.Key(key))
+		}
+	}
+
 	catalog += " >>"
 
 	switch context.SignData.Signature.CertType {
@@ -51,6 +71,17 @@ func (context *SignContext) createCatalog() (catalog string, err error) {
 		catalog += " /Perms << /UR3 " + strconv.Itoa(int(context.SignData.ObjectId)) + " 0 R >>"
 	}
 
+	for _, key := range root_keys {
+		if catalogKeysReplaced[key] {
+			continue
+		}
+		// A new certification or usage rights signature replaces the permissions
+		if key == "Perms" && (context.SignData.Signature.CertType == CertificationSignature || context.SignData.Signature.CertType == UsageRightsSignature) {
+			continue
+		}
+		catalog += " " + pdfName(key) + " " + pdfObject(root, root.Key(key))
+	}
+
 	catalog += " >>"
 	catalog += "\nendobj\n"
 
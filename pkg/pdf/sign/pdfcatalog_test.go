@@ -54,7 +54,7 @@ func TestCreateCatalog(t *testing.T) {
 		return
 	}
 
-	expected_catalog := "11 0 obj\n<< /Type /Catalog /Version /2.0 /Pages 3 0 R /AcroForm << /Fields [10 0 R] /NeedAppearances false /SigFlags 1 >> /Perms << /UR3 0 0 R >> >>\nendobj\n"
+	expected_catalog := "11 0 obj\n<< /Type /Catalog /Version /2.0 /Pages 3 0 R /AcroForm << /Fields [10 0 R] /NeedAppearances false /SigFlags 1 >> /Perms << /UR3 0 0 R >> /Metadata 2 0 R >>\nendobj\n"
 
 	if catalog != expected_catalog {
 		t.Errorf("Catalog mismatch, expected %s, but got %s", expected_catalog, catalog)
@@ -0,0 +1,246 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/digitorus/pkcs7"
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+)
+
+// ErrNoSignatures is returned by AddDSS for documents without signatures
+var ErrNoSignatures = errors.New("document has no signatures")
+
+var (
+	oidRevocationInfoArchival = asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8}
+	oidTimeStampToken         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+)
+
+// AddDSS appends a Document Security Store (ISO 32000-2, 12.8.4.3) as an
+// incremental update. It holds the certificates of every signature in input,
+// including their timestamp tokens, with OCSP responses and CRLs from
+// revocationFunction (DefaultEmbedRevocationStatusFunction when nil). A signed
+// and timestamped document with a DSS is PAdES B-LT; an existing DSS is extended.
+func AddDSS(input []byte, revocationFunction RevocationFunction) ([]byte, error) {
+	if revocationFunction == nil {
+		revocationFunction = DefaultEmbedRevocationStatusFunction
+	}
+
+	u, err := newIncrementalUpdate(input)
+	if err != nil {
+		return nil, err
+	}
+	root := u.rdr.Trailer().Key("Root")
+
+	store := newSecurityStore(u, root.Key("DSS"))
+	fetched := make(map[string]revocation.InfoArchival)
+
+	signatures := 0
+	for _, x := range u.rdr.Xref() {
+		v, err := u.rdr.GetObject(x.Ptr().GetID())
+		if err != nil || v.Key("Filter").Name() != "Adobe.PPKLite" {
+			continue
+		}
+
+		contents := []byte(v.Key("Contents").RawString())
+		p7, err := pkcs7.Parse(contents)
+		if err != nil {
+			continue
+		}
+		signatures++
+
+		vri := strings.ToUpper(hex.EncodeToString(sha1Sum(contents)))
+		if store.hasVRI(vri) {
+			continue
+		}
+
+		var info revocation.InfoArchival
+		_ = p7.UnmarshalSignedAttribute(oidRevocationInfoArchival, &info)
+
+		certificates := signatureCertificates(p7)
+		for _, cert := range certificates {
+			if isSelfSigned(cert) {
+				continue
+			}
+			// Certificates shared by several signatures are only looked up once
+			status, ok := fetched[string(cert.Raw)]
+			if !ok {
+				if err := revocationFunction(cert, findIssuer(cert, certificates), &status); err != nil {
+					return nil, fmt.Errorf("failed to fetch revocation data for %s: %w", cert.Subject.CommonName, err)
+				}
+				fetched[string(cert.Raw)] = status
+			}
+			info.OCSP = append(info.OCSP, status.OCSP...)
+			info.CRL = append(info.CRL, status.CRL...)
+		}
+
+		entry := vriEntry{}
+		for _, cert := range certificates {
+			entry.certs = appendUnique(entry.certs, store.add(&store.certs, cert.Raw))
+		}
+		for _, o := range info.OCSP {
+			entry.ocsps = appendUnique(entry.ocsps, store.add(&store.ocsps, o.FullBytes))
+		}
+		for _, c := range info.CRL {
+			entry.crls = appendUnique(entry.crls, store.add(&store.crls, c.FullBytes))
+		}
+		store.vri = append(store.vri, "/"+vri+" "+entry.String())
+		store.vriKeys[vri] = true
+	}
+	if signatures == 0 {
+		return nil, ErrNoSignatures
+	}
+
+	dss_id := u.reserve()
+	u.writeObject(dss_id, store.String(), nil)
+
+	catalog_id := u.reserve()
+	catalog := "<<"
+	for _, key := range root.Keys() {
+		if key != "DSS" {
+			catalog += " " + pdfName(key) + " " + pdfObject(root, root.Key(key))
+		}
+	}
+	catalog += " /DSS " + strconv.Itoa(int(dss_id)) + " 0 R >>"
+	u.writeObject(catalog_id, catalog, nil)
+
+	return u.finish(catalog_id)
+}
+
+// TimestampDocument adds a document timestamp (ETSI.RFC3161) over input. Added
+// after AddDSS it protects the validation data, which makes the document PAdES B-LTA.
+func TimestampDocument(input []byte, tsa TSA) ([]byte, error) {
+	return SignBytes(input, SignData{
+		Signature: SignDataSignature{
+			CertType: TimeStampSignature,
+			Info:     SignDataSignatureInfo{Date: time.Now()},
+		},
+		DigestAlgorithm: crypto.SHA256,
+		TSA:             tsa,
+	})
+}
+
+// securityStore collects the DSS arrays, deduplicated by content
+type securityStore struct {
+	u       *incrementalUpdate
+	refs    map[[32]byte]string
+	certs   []string
+	ocsps   []string
+	crls    []string
+	vri     []string
+	vriKeys map[string]bool
+}
+
+func newSecurityStore(u *incrementalUpdate, dss pdf.Value) *securityStore {
+	s := &securityStore{u: u, refs: make(map[[32]byte]string), vriKeys: make(map[string]bool)}
+
+	existing := func(list *[]string, array pdf.Value) {
+		for i := 0; i < array.Len(); i++ {
+			item := array.Index(i)
+			ref := pdfObject(array, item)
+			*list = append(*list, ref)
+			if data, err := io.ReadAll(item.Reader()); err == nil {
+				s.refs[sha256.Sum256(data)] = ref
+			}
+		}
+	}
+	existing(&s.certs, dss.Key("Certs"))
+	existing(&s.ocsps, dss.Key("OCSPs"))
+	existing(&s.crls, dss.Key("CRLs"))
+
+	vri := dss.Key("VRI")
+	for _, key := range vri.Keys() {
+		s.vri = append(s.vri, pdfName(key)+" "+pdfObject(vri, vri.Key(key)))
+		s.vriKeys[key] = true
+	}
+	return s
+}
+
+func (s *securityStore) hasVRI(key string) bool {
+	return s.vriKeys[key]
+}
+
+// add stores data once and returns the reference to its stream
+func (s *securityStore) add(list *[]string, data []byte) string {
+	sum := sha256.Sum256(data)
+	if ref, ok := s.refs[sum]; ok {
+		return ref
+	}
+	ref := s.u.writeStream(data)
+	s.refs[sum] = ref
+	*list = append(*list, ref)
+	return ref
+}
+
+func (s *securityStore) String() string {
+	dss := "<< /Type /DSS"
+	dss += " /Certs [" + strings.Join(s.certs, " ") + "]"
+	dss += " /OCSPs [" + strings.Join(s.ocsps, " ") + "]"
+	dss += " /CRLs [" + strings.Join(s.crls, " ") + "]"
+	dss += " /VRI << " + strings.Join(s.vri, " ") + " >>"
+	return dss + " >>"
+}
+
+// vriEntry is the validation data of a single signature
+type vriEntry struct {
+	certs, ocsps, crls []string
+}
+
+func (e vriEntry) String() string {
+	return "<< /Cert [" + strings.Join(e.certs, " ") + "] /OCSP [" + strings.Join(e.ocsps, " ") + "] /CRL [" + strings.Join(e.crls, " ") + "] >>"
+}
+
+// signatureCertificates returns the certificates of a signature and of its timestamp tokens
+func signatureCertificates(p7 *pkcs7.PKCS7) []*x509.Certificate {
+	certificates := append([]*x509.Certificate{}, p7.Certificates...)
+	for _, s := range p7.Signers {
+		for _, attr := range s.UnauthenticatedAttributes {
+			if !attr.Type.Equal(oidTimeStampToken) {
+				continue
+			}
+			if token, err := pkcs7.Parse(attr.Value.Bytes); err == nil {
+				certificates = append(certificates, token.Certificates...)
+			}
+		}
+	}
+	return certificates
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, c := range candidates {
+		if bytes.Equal(cert.RawIssuer, c.RawSubject) && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func appendUnique(list []string, ref string) []string {
+	for _, r := range list {
+		if r == ref {
+			return list
+		}
+	}
+	return append(list, ref)
+}
+
+func sha1Sum(data []byte) []byte {
+	sum := sha1.Sum(data)
+	return sum[:]
+}
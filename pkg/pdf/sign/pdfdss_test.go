@@ -0,0 +1,173 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"github.com/digitorus/timestamp"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+// testTSA returns a TimestampFunction backed by a throwaway self-signed TSA certificate
+func testTSA(t *testing.T) TimestampFunction {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func(request []byte) ([]byte, error) {
+		req, err := timestamp.ParseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            []int{1, 2, 3},
+			AddTSACertificate: true,
+		}
+		return ts.CreateResponseWithOpts(cert, key, crypto.SHA256)
+	}
+}
+
+func testSignData(t *testing.T, tsa TSA) SignData {
+	t.Helper()
+	certificate_data_block, _ := pem.Decode([]byte(signCertPem))
+	cert, err := x509.ParseCertificate(certificate_data_block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key_data_block, _ := pem.Decode([]byte(signKeyPem))
+	pkey, err := x509.ParsePKCS1PrivateKey(key_data_block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return SignData{
+		Signature: SignDataSignature{
+			Info:       SignDataSignatureInfo{Name: "John Doe", Date: time.Now()},
+			CertType:   CertificationSignature,
+			DocMDPPerm: AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		Signer:          pkey,
+		Certificate:     cert,
+		TSA:             tsa,
+	}
+}
+
+func TestAddDSSAndTimestampDocument(t *testing.T) {
+	tsa := TSA{Local: testTSA(t)}
+
+	for _, name := range []string{"testfile12.pdf", "testfile14.pdf", "testfile20.pdf"} {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(testPDFFixturePath(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := SignBytes(input, testSignData(t, tsa))
+			if err != nil {
+				t.Fatalf("SignBytes() error: %v", err)
+			}
+
+			withDSS, err := AddDSS(signed, nil)
+			if err != nil {
+				t.Fatalf("AddDSS() error: %v", err)
+			}
+			rdr, err := pdf.NewReader(bytes.NewReader(withDSS), int64(len(withDSS)))
+			if err != nil {
+				t.Fatalf("reading document with DSS: %v", err)
+			}
+			dss := rdr.Trailer().Key("Root").Key("DSS")
+			// Signer and TSA certificate
+			if dss.Key("Certs").Len() != 2 || len(dss.Key("VRI").Keys()) != 1 {
+				t.Fatalf("unexpected DSS: %v", dss)
+			}
+
+			// Running it again doesn't duplicate anything
+			again, err := AddDSS(withDSS, nil)
+			if err != nil {
+				t.Fatalf("AddDSS() again error: %v", err)
+			}
+			rdr, err = pdf.NewReader(bytes.NewReader(again), int64(len(again)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := rdr.Trailer().Key("Root").Key("DSS").Key("Certs").Len(); n != 2 {
+				t.Fatalf("DSS has %d certificates after second run, want 2", n)
+			}
+
+			archived, err := TimestampDocument(withDSS, tsa)
+			if err != nil {
+				t.Fatalf("TimestampDocument() error: %v", err)
+			}
+
+			res, err := verify.Reader(bytes.NewReader(archived), int64(len(archived)))
+			if err != nil {
+				t.Fatalf("verify.Reader() error: %v", err)
+			}
+			if len(res.Signers) != 2 {
+				t.Fatalf("got %d signatures, want signature and document timestamp", len(res.Signers))
+			}
+			for _, s := range res.Signers {
+				if !s.ValidSignature {
+					t.Fatalf("invalid %s signature: %s", s.SigFormat, res.Error)
+				}
+			}
+			if res.Level != verify.LevelBLTA {
+				t.Fatalf("level = %q, want %q", res.Level, verify.LevelBLTA)
+			}
+		})
+	}
+}
+
+func TestAddDSSWithoutSignature(t *testing.T) {
+	input, err := os.ReadFile(testPDFFixturePath(t, "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddDSS(input, nil); !errors.Is(err, ErrNoSignatures) {
+		t.Fatalf("AddDSS() error = %v, want %v", err, ErrNoSignatures)
+	}
+}
+
+func TestTimestampDocumentRequiresTSA(t *testing.T) {
+	input, err := os.ReadFile(testPDFFixturePath(t, "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TimestampDocument(input, TSA{}); err == nil {
+		t.Fatal("expected error without TSA")
+	}
+}
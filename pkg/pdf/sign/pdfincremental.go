@@ -0,0 +1,181 @@
+package sign
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/digitorus/pdf"
+)
+
+// incrementalUpdate appends plain objects (no signature) to a PDF as an
+// incremental update, e.g. a Document Security Store. The xref section is
+// written the same way SignContext does, so documents can be signed again.
+type incrementalUpdate struct {
+	input     *bytes.Reader
+	rdr       *pdf.Reader
+	out       bytes.Buffer
+	firstID   uint32
+	offsets   []int64
+	rewritten []rewrittenObject
+}
+
+// rewrittenObject is a new version of an object of the input
+type rewrittenObject struct {
+	ptr    pdf.Ptr
+	offset int64
+}
+
+func newIncrementalUpdate(input []byte) (*incrementalUpdate, error) {
+	rdr, err := pdf.NewReader(bytes.NewReader(input), int64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+
+	u := &incrementalUpdate{
+		input:   bytes.NewReader(input),
+		rdr:     rdr,
+		firstID: uint32(rdr.XrefInformation.ItemCount),
+	}
+	u.out.Write(input)
+	// File always needs an empty line after %%EOF.
+	u.out.WriteString("\n")
+	return u, nil
+}
+
+// reserve allocates the next object number
+func (u *incrementalUpdate) reserve() uint32 {
+	u.offsets = append(u.offsets, 0)
+	return u.firstID + uint32(len(u.offsets)-1)
+}
+
+// writeObject writes a reserved object; stream is omitted when nil
+func (u *incrementalUpdate) writeObject(id uint32, dict string, stream []byte) {
+	u.offsets[id-u.firstID] = int64(u.out.Len())
+	u.out.WriteString(strconv.Itoa(int(id)) + " 0 obj\n" + dict)
+	if stream != nil {
+		u.out.WriteString("\nstream\n")
+		u.out.Write(stream)
+		u.out.WriteString("\nendstream")
+	}
+	u.out.WriteString("\nendobj\n")
+}
+
+// rewrite writes a new version of an existing object, e.g. a page getting annotations
+func (u *incrementalUpdate) rewrite(ptr pdf.Ptr, dict string) {
+	u.rewritten = append(u.rewritten, rewrittenObject{ptr: ptr, offset: int64(u.out.Len())})
+	u.out.WriteString(strconv.Itoa(int(ptr.GetID())) + " " + strconv.Itoa(int(ptr.GetGen())) + " obj\n" + dict + "\nendobj\n")
+}
+
+// writeStream writes data as a new stream object and returns its reference
+func (u *incrementalUpdate) writeStream(data []byte) string {
+	id := u.reserve()
+	u.writeObject(id, "<< /Length "+strconv.Itoa(len(data))+" >>", data)
+	return strconv.Itoa(int(id)) + " 0 R"
+}
+
+// finish writes the xref section and trailer with root as the new catalog
+func (u *incrementalUpdate) finish(root uint32) ([]byte, error) {
+	for i, offset := range u.offsets {
+		if offset == 0 {
+			return nil, fmt.Errorf("object %d was reserved but not written", u.firstID+uint32(i))
+		}
+	}
+
+	switch u.rdr.XrefInformation.Type {
+	case "table":
+		if err := u.writeXrefTable(root); err != nil {
+			return nil, err
+		}
+	case "stream":
+		u.writeXrefStream(root)
+	default:
+		return nil, fmt.Errorf("unknown xref type: %s", u.rdr.XrefInformation.Type)
+	}
+	return u.out.Bytes(), nil
+}
+
+func (u *incrementalUpdate) writeXrefTable(root uint32) error {
+	info := u.rdr.XrefInformation
+	xref_start := int64(u.out.Len())
+	count := info.ItemCount + int64(len(u.offsets))
+
+	// Like writeXrefTable of SignContext: the previous table is copied in full
+	// and extended with the new objects.
+	xref_size := "xref\n0 " + strconv.FormatInt(info.ItemCount, 10) + "\n"
+	u.out.WriteString("xref\n0 " + strconv.FormatInt(count, 10) + "\n")
+	table_start := u.out.Len()
+	if err := writePartFromSourceFileToTargetFile(u.input, &u.out, info.StartPos+int64(len(xref_size)), info.Length-int64(len(xref_size))); err != nil {
+		return err
+	}
+	// Entries are 20 bytes each; those of rewritten objects point at their new version.
+	// The copy may start with the end of line of the subsection header.
+	table := bytes.TrimLeft(u.out.Bytes()[table_start:], "\r\n")
+	for _, obj := range u.rewritten {
+		entry := int(obj.ptr.GetID()) * 20
+		if int64(obj.ptr.GetID()) >= info.ItemCount || entry+18 > len(table) {
+			return fmt.Errorf("object %d is not in the xref table", obj.ptr.GetID())
+		}
+		copy(table[entry:], fmt.Sprintf("%010d %05d n", obj.offset, obj.ptr.GetGen()))
+	}
+	for _, offset := range u.offsets {
+		position := strconv.FormatInt(offset, 10)
+		u.out.WriteString(leftPad(position, "0", 10-len(position)) + " 00000 n \n")
+	}
+
+	trailer_buf := make([]byte, info.IncludingTrailerEndPos-info.EndPos)
+	if _, err := u.input.ReadAt(trailer_buf, info.EndPos+1); err != nil {
+		return err
+	}
+	trailer := string(trailer_buf)
+	trailer = strings.ReplaceAll(trailer, "Root "+pdfReference(u.rdr.Trailer().Key("Root").GetPtr()), "Root "+strconv.Itoa(int(root))+" 0 R")
+	trailer = strings.ReplaceAll(trailer, "Size "+strconv.FormatInt(info.ItemCount, 10), "Size "+strconv.FormatInt(count, 10))
+	u.out.WriteString(trailer)
+
+	u.out.WriteString(strconv.FormatInt(xref_start, 10) + "\n%%EOF")
+	return nil
+}
+
+func (u *incrementalUpdate) writeXrefStream(root uint32) {
+	info := u.rdr.XrefInformation
+	xref_id := u.reserve()
+	xref_start := int64(u.out.Len())
+	u.offsets[xref_id-u.firstID] = xref_start
+
+	// Rewritten objects get a subsection each, ahead of the new objects
+	rewritten := slices.Clone(u.rewritten)
+	slices.SortFunc(rewritten, func(a, b rewrittenObject) int { return int(a.ptr.GetID()) - int(b.ptr.GetID()) })
+	index := ""
+	buffer := bytes.NewBuffer(nil)
+	for _, obj := range rewritten {
+		index += strconv.Itoa(int(obj.ptr.GetID())) + " 1 "
+		writeXrefStreamLine(buffer, 1, int(obj.offset), byte(obj.ptr.GetGen()))
+	}
+	for _, offset := range u.offsets {
+		writeXrefStreamLine(buffer, 1, int(offset), 0)
+	}
+	// Rows are always 5 bytes wide, so encoding can't fail
+	stream, _ := EncodePNGUPBytes(5, buffer.Bytes())
+
+	trailer := u.rdr.Trailer()
+	dict := "<< /Type /XRef /Length " + strconv.Itoa(len(stream)) +
+		" /Filter /FlateDecode /DecodeParms << /Columns 5 /Predictor 12 >> /W [ 1 3 1 ]" +
+		" /Prev " + strconv.FormatInt(info.StartPos, 10) +
+		" /Size " + strconv.FormatInt(info.ItemCount+int64(len(u.offsets)), 10) +
+		" /Index [ " + index + strconv.FormatInt(info.ItemCount, 10) + " " + strconv.Itoa(len(u.offsets)) + " ]" +
+		" /Root " + strconv.Itoa(int(root)) + " 0 R"
+	if ptr := trailer.Key("Info").GetPtr(); ptr.GetID() != 0 {
+		dict += " /Info " + pdfReference(ptr)
+	}
+	if id := trailer.Key("ID"); id.Len() == 2 {
+		dict += " /ID [<" + hex.EncodeToString([]byte(id.Index(0).RawString())) + "><" + hex.EncodeToString([]byte(id.Index(1).RawString())) + ">]"
+	}
+	dict += " >>"
+
+	u.out.WriteString(strconv.Itoa(int(xref_id)) + " 0 obj\n" + dict + "\nstream\n")
+	u.out.Write(stream)
+	u.out.WriteString("\nendstream\nendobj\nstartxref\n" + strconv.FormatInt(xref_start, 10) + "\n%%EOF")
+}
@@ -0,0 +1,68 @@
+package sign
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/digitorus/pdf"
+)
+
+// pdfObject serializes v, read as a child of parent, back into PDF syntax.
+// Indirect objects are written as references so that copied dictionaries keep
+// pointing at the original objects.
+func pdfObject(parent, v pdf.Value) string {
+	if ptr := v.GetPtr(); ptr.GetID() != 0 && ptr != parent.GetPtr() {
+		return pdfReference(ptr)
+	}
+
+	switch v.Kind() {
+	case pdf.Bool:
+		return strconv.FormatBool(v.Bool())
+	case pdf.Integer:
+		return strconv.FormatInt(v.Int64(), 10)
+	case pdf.Real:
+		return strconv.FormatFloat(v.Float64(), 'f', -1, 64)
+	case pdf.String:
+		return "<" + hex.EncodeToString([]byte(v.RawString())) + ">"
+	case pdf.Name:
+		return pdfName(v.Name())
+	case pdf.Dict:
+		var b strings.Builder
+		b.WriteString("<<")
+		for _, key := range v.Keys() {
+			b.WriteString(" " + pdfName(key) + " " + pdfObject(v, v.Key(key)))
+		}
+		b.WriteString(" >>")
+		return b.String()
+	case pdf.Array:
+		var b strings.Builder
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(" " + pdfObject(v, v.Index(i)))
+		}
+		b.WriteString(" ]")
+		return b.String()
+	}
+	return "null"
+}
+
+func pdfReference(ptr pdf.Ptr) string {
+	return strconv.Itoa(int(ptr.GetID())) + " " + strconv.Itoa(int(ptr.GetGen())) + " R"
+}
+
+// pdfName escapes a name as described in ISO 32000-1, 7.3.5
+func pdfName(name string) string {
+	var b strings.Builder
+	b.WriteString("/")
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -24,9 +24,15 @@ func (context *SignContext) createSignaturePlaceholder() (dssd string, byte_rang
 	// Using a buffer because it's way faster than concatenating.
 	var signature_buffer bytes.Buffer
 	signature_buffer.WriteString(strconv.Itoa(int(context.SignData.ObjectId)) + " 0 obj\n")
-	signature_buffer.WriteString("<< /Type /Sig")
-	signature_buffer.WriteString(" /Filter /Adobe.PPKLite")
-	signature_buffer.WriteString(" /SubFilter /adbe.pkcs7.detached")
+	if context.SignData.Signature.CertType == TimeStampSignature {
+		signature_buffer.WriteString("<< /Type /DocTimeStamp")
+		signature_buffer.WriteString(" /Filter /Adobe.PPKLite")
+		signature_buffer.WriteString(" /SubFilter /ETSI.RFC3161")
+	} else {
+		signature_buffer.WriteString("<< /Type /Sig")
+		signature_buffer.WriteString(" /Filter /Adobe.PPKLite")
+		signature_buffer.WriteString(" /SubFilter /adbe.pkcs7.detached")
+	}
 
 	byte_range_start_byte = int64(signature_buffer.Len()) + 1
 
@@ -83,35 +89,41 @@ func (context *SignContext) createSignaturePlaceholder() (dssd string, byte_rang
 		signature_buffer.WriteString(" /ContactInfo ")
 		signature_buffer.WriteString(pdfString(context.SignData.Signature.Info.ContactInfo))
 	}
-	signature_buffer.WriteString(" /M ")
-	signature_buffer.WriteString(pdfDateTime(context.SignData.Signature.Info.Date))
+	// The signing time of a document timestamp is taken from the token
+	if context.SignData.Signature.CertType != TimeStampSignature {
+		signature_buffer.WriteString(" /M ")
+		signature_buffer.WriteString(pdfDateTime(context.SignData.Signature.Info.Date))
+	}
 	signature_buffer.WriteString(" >>")
 	signature_buffer.WriteString("\nendobj\n")
 
 	return signature_buffer.String(), byte_range_start_byte, signature_contents_start_byte
 }
 
-func (context *SignContext) fetchRevocationData() error {
-	if context.SignData.RevocationFunction != nil {
-		if context.SignData.CertificateChains != nil && (len(context.SignData.CertificateChains) > 0) {
-			certificate_chain := context.SignData.CertificateChains[0]
-			if certificate_chain != nil && (len(certificate_chain) > 0) {
-				for i, certificate := range certificate_chain {
-					if i < len(certificate_chain)-1 {
-						err := context.SignData.RevocationFunction(certificate, certificate_chain[i+1], &context.SignData.RevocationData)
-						if err != nil {
-							return err
-						}
-					} else {
-						err := context.SignData.RevocationFunction(certificate, nil, &context.SignData.RevocationData)
-						if err != nil {
-							return err
-						}
-					}
-				}
-			}
+// FetchRevocationData calls RevocationFunction for every certificate of the
+// first certificate chain, collecting the answers in RevocationData.
+func (sign_data *SignData) FetchRevocationData() error {
+	if sign_data.RevocationFunction == nil || len(sign_data.CertificateChains) == 0 {
+		return nil
+	}
+
+	certificate_chain := sign_data.CertificateChains[0]
+	for i, certificate := range certificate_chain {
+		var issuer *x509.Certificate
+		if i < len(certificate_chain)-1 {
+			issuer = certificate_chain[i+1]
+		}
+		if err := sign_data.RevocationFunction(certificate, issuer, &sign_data.RevocationData); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func (context *SignContext) fetchRevocationData() error {
+	if err := context.SignData.FetchRevocationData(); err != nil {
+		return err
+	}
 
 	// Calculate space needed for signature.
 	for _, crl := range context.SignData.RevocationData.CRL {
@@ -166,8 +178,33 @@ func (context *SignContext) createSignature() ([]byte, error) {
 	sign_content = append(sign_content, file_content[context.ByteRangeValues[0]:(context.ByteRangeValues[0]+context.ByteRangeValues[1])]...)
 	sign_content = append(sign_content, file_content[context.ByteRangeValues[2]:(context.ByteRangeValues[2]+context.ByteRangeValues[3])]...)
 
+	// A document timestamp is the bare RFC 3161 token over the byte ranges.
+	if context.SignData.Signature.CertType == TimeStampSignature {
+		return context.createTimestampToken(sign_content)
+	}
+
+	signed_data, err := context.newSignedData(sign_content, []pkcs7.Attribute{
+		{
+			Type:  asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8},
+			Value: context.SignData.RevocationData,
+		},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// PDF needs a detached signature, meaning the content isn't included.
+	signed_data.Detach()
+
+	return signed_data.Finish()
+}
+
+// newSignedData signs content with the signing certificate attribute and
+// attributes as signed attributes. The signature timestamp, when a TSA is set,
+// is added to the unsigned attributes.
+func (context *SignContext) newSignedData(content []byte, attributes, unsigned []pkcs7.Attribute) (*pkcs7.SignedData, error) {
 	// Initialize pkcs7 signer.
-	signed_data, err := pkcs7.NewSignedData(sign_content)
+	signed_data, err := pkcs7.NewSignedData(content)
 	if err != nil {
 		return nil, fmt.Errorf("new signed data: %w", err)
 	}
@@ -179,13 +216,7 @@ func (context *SignContext) createSignature() ([]byte, error) {
 	}
 
 	signer_config := pkcs7.SignerInfoConfig{
-		ExtraSignedAttributes: []pkcs7.Attribute{
-			{
-				Type:  asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8},
-				Value: context.SignData.RevocationData,
-			},
-			*signingCertificate,
-		},
+		ExtraSignedAttributes: append(attributes, *signingCertificate),
 	}
 
 	// Add the first certificate chain without our own certificate.
@@ -194,15 +225,26 @@ func (context *SignContext) createSignature() ([]byte, error) {
 		certificate_chain = context.SignData.CertificateChains[0][1:]
 	}
 
+	var signer crypto.Signer = context.SignData.Signer
+	if context.SignData.RSAPSS {
+		signer = pssSigner{signer}
+	}
+
 	// Add the signer and sign the data.
-	if err := signed_data.AddSignerChain(context.SignData.Certificate, context.SignData.Signer, certificate_chain, signer_config); err != nil {
+	if err := signed_data.AddSignerChain(context.SignData.Certificate, signer, certificate_chain, signer_config); err != nil {
 		return nil, fmt.Errorf("add signer chain: %w", err)
 	}
 
-	// PDF needs a detached signature, meaning the content isn't included.
-	signed_data.Detach()
+	// pkcs7 only knows PKCS #1 v1.5 for RSA keys.
+	if context.SignData.RSAPSS {
+		algorithm, err := pssAlgorithm(context.SignData.DigestAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("pss parameters: %w", err)
+		}
+		signed_data.GetSignedData().SignerInfos[0].DigestEncryptionAlgorithm = algorithm
+	}
 
-	if context.SignData.TSA.URL != "" {
+	if context.SignData.TSA.enabled() {
 		signature_data := signed_data.GetSignedData()
 
 		timestamp_response, err := context.GetTSA(signature_data.SignerInfos[0].EncryptedDigest)
@@ -224,12 +266,29 @@ func (context *SignContext) createSignature() ([]byte, error) {
 			Type:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14},
 			Value: asn1.RawValue{FullBytes: ts.RawToken},
 		}
-		if err := signature_data.SignerInfos[0].SetUnauthenticatedAttributes([]pkcs7.Attribute{timestamp_attribute}); err != nil {
+		unsigned = append([]pkcs7.Attribute{timestamp_attribute}, unsigned...)
+	}
+	if len(unsigned) > 0 {
+		if err := signed_data.GetSignedData().SignerInfos[0].SetUnauthenticatedAttributes(unsigned); err != nil {
 			return nil, err
 		}
 	}
 
-	return signed_data.Finish()
+	return signed_data, nil
+}
+
+func (context *SignContext) createTimestampToken(sign_content []byte) ([]byte, error) {
+	timestamp_response, err := context.GetTSA(sign_content)
+	if err != nil {
+		return nil, fmt.Errorf("get timestamp: %w", err)
+	}
+
+	ts, err := timestamp.ParseResponse(timestamp_response)
+	if err != nil {
+		return nil, fmt.Errorf("parse timestamp: %w", err)
+	}
+
+	return ts.RawToken, nil
 }
 
 func (context *SignContext) GetTSA(sign_content []byte) (timestamp_response []byte, err error) {
@@ -241,6 +300,10 @@ func (context *SignContext) GetTSA(sign_content []byte) (timestamp_response []by
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	if context.SignData.TSA.Local != nil {
+		return context.SignData.TSA.Local(ts_request)
+	}
+
 	ts_request_reader := bytes.NewReader(ts_request)
 	req, err := http.NewRequest("POST", context.SignData.TSA.URL, ts_request_reader)
 	if err != nil {
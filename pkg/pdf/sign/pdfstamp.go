@@ -0,0 +1,187 @@
+package sign
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // decode JPEG stamps
+	_ "image/png"  // decode PNG stamps
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Stamp is content drawn on a page by AddStamps
+type Stamp struct {
+	Page     int        // 1-based page number
+	Rect     [4]float64 // lower-left x, y and upper-right x, y in default user space
+	Text     string     // set in Helvetica when there is no Image
+	FontSize float64    // 10 when zero
+	Image    []byte     // PNG or JPEG, stretched over Rect
+}
+
+// AddStamps draws stamps on the pages of input as stamp annotations in an
+// incremental update. Earlier revisions, and the signatures over them, stay
+// untouched; a signature added afterwards covers the stamps.
+func AddStamps(input []byte, stamps []Stamp) ([]byte, error) {
+	if len(stamps) == 0 {
+		return input, nil
+	}
+	u, err := newIncrementalUpdate(input)
+	if err != nil {
+		return nil, err
+	}
+
+	font := ""
+	annots := make(map[int][]string)
+	var pages []int
+	for _, s := range stamps {
+		if s.Page < 1 || s.Page > u.rdr.NumPage() {
+			return nil, fmt.Errorf("stamp page %d out of range", s.Page)
+		}
+		page := u.rdr.Page(s.Page).V
+		w, h := s.Rect[2]-s.Rect[0], s.Rect[3]-s.Rect[1]
+		if w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("empty stamp rectangle on page %d", s.Page)
+		}
+
+		var appearance string
+		if s.Image != nil {
+			img, err := u.writeImage(s.Image)
+			if err != nil {
+				return nil, err
+			}
+			content := "q " + pdfNumber(w) + " 0 0 " + pdfNumber(h) + " 0 0 cm /Im0 Do Q"
+			appearance = u.writeForm(w, h, "<< /XObject << /Im0 "+img+" >> >>", content)
+		} else {
+			if font == "" {
+				id := u.reserve()
+				u.writeObject(id, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+				font = strconv.Itoa(int(id)) + " 0 R"
+			}
+			size := s.FontSize
+			if size <= 0 {
+				size = 10
+			}
+			content := "BT /Helv " + pdfNumber(size) + " Tf 1 2 Td " + winAnsiString(s.Text) + " Tj ET"
+			appearance = u.writeForm(w, h, "<< /Font << /Helv "+font+" >> >>", content)
+		}
+
+		id := u.reserve()
+		annot := "<< /Type /Annot /Subtype /Stamp /F 132" +
+			" /Rect [ " + pdfNumber(s.Rect[0]) + " " + pdfNumber(s.Rect[1]) + " " + pdfNumber(s.Rect[2]) + " " + pdfNumber(s.Rect[3]) + " ]" +
+			" /P " + pdfReference(page.GetPtr()) +
+			" /AP << /N " + appearance + " >>"
+		if s.Image == nil {
+			annot += " /Contents " + pdfString(s.Text)
+		}
+		u.writeObject(id, annot+" >>", nil)
+
+		if _, ok := annots[s.Page]; !ok {
+			pages = append(pages, s.Page)
+		}
+		annots[s.Page] = append(annots[s.Page], strconv.Itoa(int(id))+" 0 R")
+	}
+
+	// Pages keep their keys and get the stamps appended to their annotations
+	for _, n := range pages {
+		page := u.rdr.Page(n).V
+		dict := "<<"
+		for _, key := range page.Keys() {
+			if key != "Annots" {
+				dict += " " + pdfName(key) + " " + pdfObject(page, page.Key(key))
+			}
+		}
+		dict += " /Annots ["
+		existing := page.Key("Annots")
+		for i := 0; i < existing.Len(); i++ {
+			dict += " " + pdfObject(existing, existing.Index(i))
+		}
+		dict += " " + strings.Join(annots[n], " ") + " ] >>"
+		u.rewrite(page.GetPtr(), dict)
+	}
+
+	return u.finish(u.rdr.Trailer().Key("Root").GetPtr().GetID())
+}
+
+// writeForm writes a form XObject and returns its reference
+func (u *incrementalUpdate) writeForm(w, h float64, resources, content string) string {
+	id := u.reserve()
+	u.writeObject(id, "<< /Type /XObject /Subtype /Form /BBox [ 0 0 "+pdfNumber(w)+" "+pdfNumber(h)+" ]"+
+		" /Resources "+resources+" /Length "+strconv.Itoa(len(content))+" >>", []byte(content))
+	return strconv.Itoa(int(id)) + " 0 R"
+}
+
+// writeImage writes an image XObject, with its alpha channel as soft mask, and returns its reference
+func (u *incrementalUpdate) writeImage(data []byte) (string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode stamp image: %w", err)
+	}
+	bounds := src.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	rgb := make([]byte, 0, len(rgba.Pix)/4*3)
+	alpha := make([]byte, 0, len(rgba.Pix)/4)
+	opaque := true
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		rgb = append(rgb, rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])
+		alpha = append(alpha, rgba.Pix[i+3])
+		opaque = opaque && rgba.Pix[i+3] == 0xff
+	}
+
+	size := " /Width " + strconv.Itoa(bounds.Dx()) + " /Height " + strconv.Itoa(bounds.Dy()) + " /BitsPerComponent 8 /Filter /FlateDecode"
+	dict := "<< /Type /XObject /Subtype /Image" + size + " /ColorSpace /DeviceRGB"
+	if !opaque {
+		mask := deflate(alpha)
+		id := u.reserve()
+		u.writeObject(id, "<< /Type /XObject /Subtype /Image"+size+" /ColorSpace /DeviceGray /Length "+strconv.Itoa(len(mask))+" >>", mask)
+		dict += " /SMask " + strconv.Itoa(int(id)) + " 0 R"
+	}
+	pixels := deflate(rgb)
+	id := u.reserve()
+	u.writeObject(id, dict+" /Length "+strconv.Itoa(len(pixels))+" >>", pixels)
+	return strconv.Itoa(int(id)) + " 0 R", nil
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	// Writing to a bytes.Buffer can't fail
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return b.Bytes()
+}
+
+// winAnsiString encodes text as a literal string for a WinAnsiEncoding font;
+// characters it lacks are replaced
+func winAnsiString(text string) string {
+	encoded, err := encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder()).String(text)
+	if err != nil {
+		encoded = ""
+	}
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := 0; i < len(encoded); i++ {
+		switch c := encoded[i]; {
+		case c == '\\' || c == '(' || c == ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,89 @@
+package sign
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/digitorus/pdf"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func TestAddStamps(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(1, 1, color.NRGBA{R: 0x20, G: 0x40, B: 0x80, A: 0xff})
+	var signature bytes.Buffer
+	if err := png.Encode(&signature, img); err != nil {
+		t.Fatal(err)
+	}
+	stamps := []Stamp{
+		{Page: 1, Rect: [4]float64{50, 50, 250, 70}, Text: "Jane Doe (Käuferin)"},
+		{Page: 1, Rect: [4]float64{50, 100, 150, 150}, Image: signature.Bytes()},
+	}
+
+	for _, name := range []string{"testfile12.pdf", "testfile14.pdf", "testfile20.pdf"} {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(testPDFFixturePath(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			signData := testSignData(t, TSA{})
+			signData.Signature.CertType = ApprovalSignature
+
+			signed, err := SignBytes(input, signData)
+			if err != nil {
+				t.Fatalf("SignBytes() error: %v", err)
+			}
+			stamped, err := AddStamps(signed, stamps)
+			if err != nil {
+				t.Fatalf("AddStamps() error: %v", err)
+			}
+
+			rdr, err := pdf.NewReader(bytes.NewReader(stamped), int64(len(stamped)))
+			if err != nil {
+				t.Fatalf("reading stamped document: %v", err)
+			}
+			annots := rdr.Page(1).V.Key("Annots")
+			var found []pdf.Value
+			for i := 0; i < annots.Len(); i++ {
+				if a := annots.Index(i); a.Key("Subtype").Name() == "Stamp" {
+					found = append(found, a)
+				}
+			}
+			if len(found) != 2 {
+				t.Fatalf("page has %d stamps, want 2", len(found))
+			}
+			if got := found[0].Key("Contents").Text(); got != stamps[0].Text {
+				t.Fatalf("stamp text = %q", got)
+			}
+			if found[1].Key("AP").Key("N").Key("Resources").Key("XObject").Key("Im0").Key("SMask").IsNull() {
+				t.Fatal("transparent image stamp has no soft mask")
+			}
+
+			// The first signature stays valid and a second one covers the stamps
+			resigned, err := SignBytes(stamped, signData)
+			if err != nil {
+				t.Fatalf("SignBytes() over stamps error: %v", err)
+			}
+			res, err := verify.Reader(bytes.NewReader(resigned), int64(len(resigned)))
+			if err != nil {
+				t.Fatalf("verify.Reader() error: %v", err)
+			}
+			if len(res.Signers) != 2 {
+				t.Fatalf("got %d signatures, want 2", len(res.Signers))
+			}
+			for i, s := range res.Signers {
+				if !s.ValidSignature {
+					t.Fatalf("signature %d is not valid: %s", i, res.Error)
+				}
+			}
+		})
+	}
+
+	if _, err := AddStamps([]byte("%PDF-1.4"), []Stamp{{Page: 1}}); err == nil {
+		t.Fatal("expected error for an invalid document")
+	}
+}
@@ -36,7 +36,12 @@ func (context *SignContext) createVisualSignature() (visual_signature string, er
 
 	visual_signature += " /F 132"
 	visual_signature += " /FT /Sig"
-	visual_signature += " /T " + pdfString("Signature")
+	// Field names must be unique; later signatures are numbered after the existing fields.
+	field_name := "Signature"
+	if existing := root.Key("AcroForm").Key("Fields").Len(); existing > 0 {
+		field_name += strconv.Itoa(existing + 1)
+	}
+	visual_signature += " /T " + pdfString(field_name)
 	visual_signature += " /Ff 0"
 	visual_signature += " /V " + strconv.Itoa(int(context.SignData.ObjectId)) + " 0 R"
 
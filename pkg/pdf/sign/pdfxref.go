@@ -128,7 +128,8 @@ func (context *SignContext) writeXrefStream() error {
 		return err
 	}
 
-	if _, err := context.OutputBuffer.Write([]byte("\nendstream\n")); err != nil {
+	// The xref stream is an object of its own; writeTrailer only adds the offset.
+	if _, err := context.OutputBuffer.Write([]byte("\nendstream\nendobj\nstartxref\n")); err != nil {
 		return err
 	}
 
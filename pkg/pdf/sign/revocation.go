@@ -7,13 +7,63 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/shurco/gosign/pkg/pdf/revocation"
 
 	"golang.org/x/crypto/ocsp"
 )
 
+// LocalRevocationSource answers revocation queries in-process for certificates
+// issued by this instance, so no network round trip to our own endpoints is needed.
+// ok is false when the certificate was not issued by the source.
+type LocalRevocationSource interface {
+	OCSPFor(cert, issuer *x509.Certificate) (resp []byte, ok bool, err error)
+	CRLFor(cert *x509.Certificate) (crl []byte, ok bool, err error)
+}
+
+var (
+	localSourcesMu sync.RWMutex
+	localSources   []LocalRevocationSource
+)
+
+// RegisterLocalRevocationSource makes DefaultEmbedRevocationStatusFunction consult
+// src before fetching OCSP responses and CRLs over HTTP.
+func RegisterLocalRevocationSource(src LocalRevocationSource) {
+	localSourcesMu.Lock()
+	defer localSourcesMu.Unlock()
+	localSources = append(localSources, src)
+}
+
+func localOCSP(cert, issuer *x509.Certificate) ([]byte, bool, error) {
+	localSourcesMu.RLock()
+	defer localSourcesMu.RUnlock()
+	for _, src := range localSources {
+		if resp, ok, err := src.OCSPFor(cert, issuer); ok || err != nil {
+			return resp, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
+func localCRL(cert *x509.Certificate) ([]byte, bool, error) {
+	localSourcesMu.RLock()
+	defer localSourcesMu.RUnlock()
+	for _, src := range localSources {
+		if crl, ok, err := src.CRLFor(cert); ok || err != nil {
+			return crl, ok, err
+		}
+	}
+	return nil, false, nil
+}
+
 func embedOCSPRevocationStatus(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+	if body, ok, err := localOCSP(cert, issuer); err != nil {
+		return err
+	} else if ok {
+		return i.AddOCSP(body)
+	}
+
 	if len(cert.OCSPServer) == 0 {
 		return fmt.Errorf("certificate has no OCSP server URLs")
 	}
@@ -48,6 +98,12 @@ func embedOCSPRevocationStatus(cert, issuer *x509.Certificate, i *revocation.Inf
 // embedCRLRevocationStatus requires an issuer as it needs to implement the
 // the interface, a nil argment might be given if the issuer is not known.
 func embedCRLRevocationStatus(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+	if body, ok, err := localCRL(cert); err != nil {
+		return err
+	} else if ok {
+		return i.AddCRL(body)
+	}
+
 	if len(cert.CRLDistributionPoints) == 0 {
 		return fmt.Errorf("certificate has no CRL distribution points")
 	}
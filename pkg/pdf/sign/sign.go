@@ -3,6 +3,7 @@ package sign
 import (
 	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
@@ -21,10 +22,21 @@ type CatalogData struct {
 	RootString string
 }
 
+// TimestampFunction answers a DER encoded RFC 3161 TimeStampReq in-process
+// and returns the DER encoded TimeStampResp.
+type TimestampFunction func(request []byte) (response []byte, err error)
+
+// TSA configures the timestamp authority. Local takes precedence over URL, so a
+// built-in TSA can be used without a network round trip.
 type TSA struct {
 	URL      string
 	Username string
 	Password string
+	Local    TimestampFunction
+}
+
+func (t TSA) enabled() bool {
+	return t.URL != "" || t.Local != nil
 }
 
 type RevocationFunction func(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error
@@ -39,6 +51,8 @@ type SignData struct {
 	TSA                TSA
 	RevocationData     revocation.InfoArchival
 	RevocationFunction RevocationFunction
+	// RSAPSS signs with RSASSA-PSS instead of PKCS #1 v1.5 (RSA keys only)
+	RSAPSS bool
 }
 
 type VisualSignData struct {
@@ -61,6 +75,9 @@ const (
 	CertificationSignature = iota + 1
 	ApprovalSignature
 	UsageRightsSignature
+	// TimeStampSignature is a document timestamp (ETSI.RFC3161). It only needs
+	// a TSA; Signer and Certificate are not used.
+	TimeStampSignature
 )
 
 const (
@@ -122,6 +139,23 @@ func SignFile(input string, output string, sign_data SignData) error {
 	return Sign(input_file, output_file, rdr, size, sign_data)
 }
 
+// SignBytes signs an in-memory PDF and returns the signed document.
+func SignBytes(input []byte, sign_data SignData) ([]byte, error) {
+	input_reader := bytes.NewReader(input)
+	size := int64(len(input))
+
+	rdr, err := pdf.NewReader(input_reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	if err := Sign(input_reader, &output, rdr, size, sign_data); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
 func Sign(input io.ReadSeeker, output io.Writer, rdr *pdf.Reader, size int64, sign_data SignData) error {
 	sign_data.ObjectId = uint32(rdr.XrefInformation.ItemCount) + 3
 
@@ -184,42 +218,20 @@ func (context *SignContext) SignPDF() error {
 	// Base size for signature.
 	context.SignatureMaxLength = context.SignatureMaxLengthBase
 
-	switch context.SignData.Certificate.SignatureAlgorithm.String() {
-	case "SHA1-RSA", "ECDSA-SHA1", "DSA-SHA1":
-		context.SignatureMaxLength += uint32(hex.EncodedLen(128))
-	case "SHA256-RSA", "ECDSA-SHA256", "DSA-SHA256":
-		context.SignatureMaxLength += uint32(hex.EncodedLen(256))
-	case "SHA384-RSA", "ECDSA-SHA384":
-		context.SignatureMaxLength += uint32(hex.EncodedLen(384))
-	case "SHA512-RSA", "ECDSA-SHA512":
-		context.SignatureMaxLength += uint32(hex.EncodedLen(512))
-	}
-
-	// Add size of digest algorithm twice (for file digist and signing certificate attribute)
-	context.SignatureMaxLength += uint32(hex.EncodedLen(context.SignData.DigestAlgorithm.Size() * 2))
-
-	// Add size for my certificate.
-	degenerated, err := pkcs7.DegenerateCertificate(context.SignData.Certificate.Raw)
-	if err != nil {
-		return fmt.Errorf("failed to degenerate certificate: %w", err)
-	}
-
-	context.SignatureMaxLength += uint32(hex.EncodedLen(len(degenerated)))
-
-	// Add size for certificate chain.
-	var certificate_chain []*x509.Certificate
-	if len(context.SignData.CertificateChains) > 0 && len(context.SignData.CertificateChains[0]) > 1 {
-		certificate_chain = context.SignData.CertificateChains[0][1:]
-	}
-
-	if len(certificate_chain) > 0 {
-		for _, cert := range certificate_chain {
-			degenerated, err := pkcs7.DegenerateCertificate(cert.Raw)
-			if err != nil {
-				return fmt.Errorf("failed to degenerate certificate in chain: %w", err)
-			}
-
-			context.SignatureMaxLength += uint32(hex.EncodedLen(len(degenerated)))
+	if context.SignData.Signature.CertType == TimeStampSignature {
+		if !context.SignData.TSA.enabled() {
+			return fmt.Errorf("document timestamp requires a TSA")
+		}
+	} else {
+		if err := context.checkSigner(); err != nil {
+			return err
+		}
+		// RFC 8419: Ed25519 with signed attributes uses SHA-512
+		if _, ok := context.SignData.Signer.Public().(ed25519.PublicKey); ok {
+			context.SignData.DigestAlgorithm = crypto.SHA512
+		}
+		if err := context.addSignerSize(); err != nil {
+			return err
 		}
 	}
 
@@ -228,7 +240,7 @@ func (context *SignContext) SignPDF() error {
 	//
 	// Different TSA servers provide different response sizes, we
 	// might need to make this configurable or detect and store.
-	if context.SignData.TSA.URL != "" {
+	if context.SignData.TSA.enabled() {
 		context.SignatureMaxLength += uint32(hex.EncodedLen(9000))
 	}
 
@@ -317,3 +329,43 @@ func (context *SignContext) SignPDF() error {
 
 	return nil
 }
+
+// addSignerSize reserves placeholder space for the signer certificate, its chain and the signature value.
+func (context *SignContext) addSignerSize() error {
+	// Size of the signature value, which depends on the signer key.
+	context.SignatureMaxLength += uint32(hex.EncodedLen(signatureSize(context.SignData.Signer)))
+	if context.SignData.RSAPSS {
+		// RSASSA-PSS parameters
+		context.SignatureMaxLength += uint32(hex.EncodedLen(64))
+	}
+
+	// Add size of digest algorithm twice (for file digist and signing certificate attribute)
+	context.SignatureMaxLength += uint32(hex.EncodedLen(context.SignData.DigestAlgorithm.Size() * 2))
+
+	// Add size for my certificate.
+	degenerated, err := pkcs7.DegenerateCertificate(context.SignData.Certificate.Raw)
+	if err != nil {
+		return fmt.Errorf("failed to degenerate certificate: %w", err)
+	}
+
+	context.SignatureMaxLength += uint32(hex.EncodedLen(len(degenerated)))
+
+	// Add size for certificate chain.
+	var certificate_chain []*x509.Certificate
+	if len(context.SignData.CertificateChains) > 0 && len(context.SignData.CertificateChains[0]) > 1 {
+		certificate_chain = context.SignData.CertificateChains[0][1:]
+	}
+
+	if len(certificate_chain) > 0 {
+		for _, cert := range certificate_chain {
+			degenerated, err := pkcs7.DegenerateCertificate(cert.Raw)
+			if err != nil {
+				return fmt.Errorf("failed to degenerate certificate in chain: %w", err)
+			}
+
+			context.SignatureMaxLength += uint32(hex.EncodedLen(len(degenerated)))
+		}
+	}
+
+	return nil
+}
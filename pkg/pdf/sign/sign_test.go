@@ -438,3 +438,40 @@ func BenchmarkSignPDF(b *testing.B) {
 		}
 	}
 }
+
+func TestSignSequential(t *testing.T) {
+	for _, name := range []string{"testfile12.pdf", "testfile14.pdf", "testfile20.pdf"} {
+		t.Run(name, func(t *testing.T) {
+			doc, err := os.ReadFile(testPDFFixturePath(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signers := []string{"Alice", "Bob", "Carol"}
+			for _, signer := range signers {
+				sign_data := testSignData(t, TSA{})
+				sign_data.Signature.CertType = ApprovalSignature
+				sign_data.Signature.Info.Name = signer
+				sign_data.Signature.Info.Reason = "Signed by " + signer
+
+				doc, err = SignBytes(doc, sign_data)
+				if err != nil {
+					t.Fatalf("%s: SignBytes() error: %v", signer, err)
+				}
+			}
+
+			res, err := verify.Reader(bytes.NewReader(doc), int64(len(doc)))
+			if err != nil {
+				t.Fatalf("verify.Reader() error: %v", err)
+			}
+			if len(res.Signers) != len(signers) {
+				t.Fatalf("got %d signatures, want %d", len(res.Signers), len(signers))
+			}
+			for i, s := range res.Signers {
+				if s.Name != signers[i] || s.Reason != "Signed by "+signers[i] || !s.ValidSignature {
+					t.Fatalf("signature %d: got %q (%q, valid %v)", i, s.Name, s.Reason, s.ValidSignature)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+)
+
+var (
+	oidRSASSAPSS = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidMGF1      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}
+)
+
+// pssParameters is RSASSA-PSS-params (RFC 4055, section 3.1)
+type pssParameters struct {
+	Hash         pkix.AlgorithmIdentifier `asn1:"explicit,tag:0"`
+	MGF          pkix.AlgorithmIdentifier `asn1:"explicit,tag:1"`
+	SaltLength   int                      `asn1:"explicit,tag:2"`
+	TrailerField int                      `asn1:"optional,explicit,tag:3,default:1"`
+}
+
+// pssSigner signs digests with RSASSA-PSS, the salt as long as the digest
+type pssSigner struct {
+	crypto.Signer
+}
+
+func (s pssSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.Signer.Sign(rand, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: opts.HashFunc()})
+}
+
+// pssAlgorithm returns the RSASSA-PSS signature algorithm identifier for hash
+func pssAlgorithm(hash crypto.Hash) (pkix.AlgorithmIdentifier, error) {
+	hashAlgorithm := pkix.AlgorithmIdentifier{Algorithm: getOIDFromHashAlgorithm(hash), Parameters: asn1.NullRawValue}
+	mgfParameters, err := asn1.Marshal(hashAlgorithm)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	params, err := asn1.Marshal(pssParameters{
+		Hash:         hashAlgorithm,
+		MGF:          pkix.AlgorithmIdentifier{Algorithm: oidMGF1, Parameters: asn1.RawValue{FullBytes: mgfParameters}},
+		SaltLength:   hash.Size(),
+		TrailerField: 1,
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidRSASSAPSS, Parameters: asn1.RawValue{FullBytes: params}}, nil
+}
+
+// signatureSize returns the maximum DER size of a signature made by signer
+func signatureSize(signer crypto.Signer) int {
+	switch k := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return k.Size()
+	case *ecdsa.PublicKey:
+		// SEQUENCE of two INTEGERs, each up to one byte longer than the curve
+		return 2*((k.Curve.Params().BitSize+7)/8+3) + 3
+	case ed25519.PublicKey:
+		return ed25519.SignatureSize
+	}
+	return 512
+}
+
+// checkSigner validates the signer against the signature options
+func (context *SignContext) checkSigner() error {
+	if context.SignData.Signer == nil || context.SignData.Certificate == nil {
+		return fmt.Errorf("signer and certificate are required")
+	}
+	switch context.SignData.Signer.Public().(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		if context.SignData.RSAPSS {
+			return fmt.Errorf("RSASSA-PSS requires an RSA key")
+		}
+	default:
+		return fmt.Errorf("unsupported signer key type %T", context.SignData.Signer.Public())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func testSelfSigned(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Key Test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestSignKeyTypes(t *testing.T) {
+	input, err := os.ReadFile(testPDFFixturePath(t, "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsa2048, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsa3072, _ := rsa.GenerateKey(rand.Reader, 3072)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		pss    bool
+	}{
+		{"RSA-2048", rsa2048, false},
+		{"RSA-3072-PSS", rsa3072, true},
+		{"ECDSA-P256", p256, false},
+		{"ECDSA-P384", p384, false},
+		{"Ed25519", edKey, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := testSelfSigned(t, tt.signer)
+			signed, err := SignBytes(input, SignData{
+				Signature: SignDataSignature{
+					Info:     SignDataSignatureInfo{Name: tt.name, Date: time.Now()},
+					CertType: ApprovalSignature,
+				},
+				Signer:          tt.signer,
+				DigestAlgorithm: crypto.SHA256,
+				Certificate:     cert,
+				RSAPSS:          tt.pss,
+			})
+			if err != nil {
+				t.Fatalf("SignBytes() error: %v", err)
+			}
+
+			res, err := verify.Reader(bytes.NewReader(signed), int64(len(signed)))
+			if err != nil {
+				t.Fatalf("verify.Reader() error: %v", err)
+			}
+			if len(res.Signers) != 1 || !res.Signers[0].ValidSignature || !res.Signers[0].TrustedIssuer {
+				t.Fatalf("signature not verified: %+v, %s", res.Signers, res.Error)
+			}
+		})
+	}
+
+	t.Run("PSS requires RSA", func(t *testing.T) {
+		_, err := SignBytes(input, SignData{
+			Signature:   SignDataSignature{CertType: ApprovalSignature},
+			Signer:      p256,
+			Certificate: testSelfSigned(t, p256),
+			RSAPSS:      true,
+		})
+		if err == nil {
+			t.Fatal("expected error for RSASSA-PSS with an ECDSA key")
+		}
+	})
+}
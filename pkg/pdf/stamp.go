@@ -0,0 +1,80 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"strings"
+
+	"github.com/shurco/gosign/internal/models"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+)
+
+// FieldStamps returns the values of fields as stamps for sign.AddStamps, placed
+// where RenderCompletedTemplatePDF draws them on a document rendered from schema.
+// Values that can't be drawn are skipped, as the renderer skips them.
+func FieldStamps(schema []models.Schema, fields []models.Field, values map[string]any) []sign.Stamp {
+	var stamps []sign.Stamp
+	page := 0
+	for _, schemaItem := range schema {
+		if schemaItem.AttachmentID == "" {
+			continue
+		}
+		page++
+
+		for _, field := range fields {
+			val, ok := values[field.ID]
+			if !ok {
+				continue
+			}
+
+			for _, area := range field.Areas {
+				if area == nil || area.AttachmentID != schemaItem.AttachmentID {
+					continue
+				}
+
+				x := clamp01(area.X) * A4WidthPt
+				y := clamp01(area.Y) * A4HeightPt
+				w := clamp01(area.W) * A4WidthPt
+				h := clamp01(area.H) * A4HeightPt
+				if h <= 0 {
+					h = 12
+				}
+				if w <= 0 {
+					continue
+				}
+				rect := [4]float64{x, y, x + w, y + h}
+
+				switch field.Type {
+				case models.FieldTypeSignature, models.FieldTypeInitials, models.FieldTypeStamp, models.FieldTypeImage:
+					imgBytes, err := decodeImageDataURL(val)
+					if err != nil || len(imgBytes) == 0 {
+						continue
+					}
+					if _, _, err := image.DecodeConfig(bytes.NewReader(imgBytes)); err != nil {
+						continue
+					}
+					stamps = append(stamps, sign.Stamp{Page: page, Rect: rect, Image: imgBytes})
+
+					if field.Preferences != nil && field.Preferences.WithSignatureID {
+						if sigID, ok := values[field.ID+"_signature_id"].(string); ok && strings.TrimSpace(sigID) != "" {
+							stamps = append(stamps, sign.Stamp{
+								Page:     page,
+								Rect:     [4]float64{x, y + h, x + w, y + h + 10},
+								Text:     "ID: " + strings.TrimSpace(sigID),
+								FontSize: 8,
+							})
+						}
+					}
+
+				default:
+					text := stringifyValue(val)
+					if strings.TrimSpace(text) == "" {
+						continue
+					}
+					stamps = append(stamps, sign.Stamp{Page: page, Rect: rect, Text: text})
+				}
+			}
+		}
+	}
+	return stamps
+}
@@ -0,0 +1,50 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+func TestFieldStamps(t *testing.T) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	sigDataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes())
+
+	schema := []models.Schema{{AttachmentID: ""}, {AttachmentID: "att-1"}, {AttachmentID: "att-2"}}
+	fields := []models.Field{
+		{ID: "name", Type: models.FieldTypeText, Areas: []*models.Areas{{AttachmentID: "att-2", X: 0.1, Y: 0.5, W: 0.5, H: 0.1}}},
+		{ID: "sig", Type: models.FieldTypeSignature, Preferences: &models.FieldPreferences{WithSignatureID: true},
+			Areas: []*models.Areas{{AttachmentID: "att-1", X: 0, Y: 0, W: 0.2, H: 0.1}}},
+		{ID: "broken", Type: models.FieldTypeImage, Areas: []*models.Areas{{AttachmentID: "att-1", W: 0.2, H: 0.1}}},
+		{ID: "unset", Type: models.FieldTypeText, Areas: []*models.Areas{{AttachmentID: "att-1", W: 0.2, H: 0.1}}},
+	}
+	values := map[string]any{
+		"name":             "Jane",
+		"sig":              sigDataURL,
+		"sig_signature_id": "abc",
+		"broken":           "data:image/png;base64,AAAA",
+	}
+
+	stamps := FieldStamps(schema, fields, values)
+	// Computed at run time like the stamps, constant expressions round differently
+	w, h, x, y := 0.2, 0.1, 0.1, 0.5
+	if len(stamps) != 3 {
+		t.Fatalf("got %d stamps, want 3: %+v", len(stamps), stamps)
+	}
+	if s := stamps[0]; s.Page != 1 || s.Image == nil || s.Rect != [4]float64{0, 0, w * A4WidthPt, h * A4HeightPt} {
+		t.Fatalf("signature stamp = %+v", s)
+	}
+	if s := stamps[1]; s.Page != 1 || s.Text != "ID: abc" || s.FontSize != 8 {
+		t.Fatalf("signature ID stamp = %+v", s)
+	}
+	if s := stamps[2]; s.Page != 2 || s.Text != "Jane" || s.Rect[0] != x*A4WidthPt || s.Rect[1] != y*A4HeightPt {
+		t.Fatalf("text stamp = %+v", s)
+	}
+}
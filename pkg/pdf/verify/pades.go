@@ -0,0 +1,166 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io"
+
+	"github.com/digitorus/pdf"
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"golang.org/x/crypto/ocsp"
+)
+
+// PAdES baseline levels (ETSI EN 319 142-1)
+const (
+	LevelBB   = "B-B"
+	LevelBT   = "B-T"
+	LevelBLT  = "B-LT"
+	LevelBLTA = "B-LTA"
+)
+
+var levelOrder = map[string]int{LevelBB: 1, LevelBT: 2, LevelBLT: 3, LevelBLTA: 4}
+
+// signedRevision is what setLevels needs to know about a signature
+type signedRevision struct {
+	end          int64 // end of the revision covered by the signature
+	docTimestamp bool
+	certificates []*x509.Certificate
+	revocation   revocation.InfoArchival
+}
+
+// setLevels determines the PAdES level of every signature and of the document,
+// which is the lowest level of its signatures. Document timestamps get no level.
+func setLevels(file io.ReaderAt, rdr *pdf.Reader, resp *Response, revisions []signedRevision) {
+	dss := readValidationData(rdr.Trailer().Key("Root").Key("DSS"))
+
+	for i := range resp.Signers {
+		signer := &resp.Signers[i]
+		rev := revisions[i]
+		if rev.docTimestamp || !signer.ValidSignature {
+			continue
+		}
+
+		signer.Level = LevelBB
+
+		// A later document timestamp also proves the signature existed at that time
+		var timestamps []signedRevision
+		for j, other := range revisions {
+			if other.docTimestamp && resp.Signers[j].ValidSignature && other.end > rev.end {
+				timestamps = append(timestamps, other)
+			}
+		}
+		if signer.TimeStamp == nil && len(timestamps) == 0 {
+			continue
+		}
+		signer.Level = LevelBT
+
+		if dss == nil || !dss.complete(rev) {
+			continue
+		}
+		signer.Level = LevelBLT
+
+		for _, ts := range timestamps {
+			if revisionHasDSS(file, ts.end) {
+				signer.Level = LevelBLTA
+				break
+			}
+		}
+	}
+
+	for _, signer := range resp.Signers {
+		if signer.Level == "" {
+			continue
+		}
+		if resp.Level == "" || levelOrder[signer.Level] < levelOrder[resp.Level] {
+			resp.Level = signer.Level
+		}
+	}
+}
+
+// validationData holds the revocation data of a Document Security Store
+type validationData struct {
+	ocsp []*ocsp.Response
+	crls []*x509.RevocationList
+}
+
+func readValidationData(dss pdf.Value) *validationData {
+	if dss.IsNull() {
+		return nil
+	}
+
+	data := &validationData{}
+	ocsps := dss.Key("OCSPs")
+	for i := 0; i < ocsps.Len(); i++ {
+		raw, err := io.ReadAll(ocsps.Index(i).Reader())
+		if err != nil {
+			continue
+		}
+		if resp, err := ocsp.ParseResponse(raw, nil); err == nil {
+			data.ocsp = append(data.ocsp, resp)
+		}
+	}
+	crls := dss.Key("CRLs")
+	for i := 0; i < crls.Len(); i++ {
+		raw, err := io.ReadAll(crls.Index(i).Reader())
+		if err != nil {
+			continue
+		}
+		if crl, err := x509.ParseRevocationList(raw); err == nil {
+			data.crls = append(data.crls, crl)
+		}
+	}
+	return data
+}
+
+// complete reports whether there is revocation data for every certificate of
+// the signature that is not a trust anchor, either in the DSS or in the signature.
+func (d *validationData) complete(rev signedRevision) bool {
+	embedded := &validationData{}
+	for _, o := range rev.revocation.OCSP {
+		if resp, err := ocsp.ParseResponse(o.FullBytes, nil); err == nil {
+			embedded.ocsp = append(embedded.ocsp, resp)
+		}
+	}
+	for _, c := range rev.revocation.CRL {
+		if crl, err := x509.ParseRevocationList(c.FullBytes); err == nil {
+			embedded.crls = append(embedded.crls, crl)
+		}
+	}
+
+	for _, cert := range rev.certificates {
+		if isSelfSigned(cert) {
+			continue
+		}
+		if !d.covers(cert) && !embedded.covers(cert) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *validationData) covers(cert *x509.Certificate) bool {
+	for _, resp := range d.ocsp {
+		if resp.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return true
+		}
+	}
+	for _, crl := range d.crls {
+		if bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			return true
+		}
+	}
+	return false
+}
+
+// revisionHasDSS reports whether the document revision ending at end has a DSS
+func revisionHasDSS(file io.ReaderAt, end int64) bool {
+	rdr, err := pdf.NewReader(io.NewSectionReader(file, 0, end), end)
+	if err != nil {
+		return false
+	}
+	return !rdr.Trailer().Key("Root").Key("DSS").IsNull()
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
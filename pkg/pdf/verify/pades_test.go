@@ -0,0 +1,185 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+)
+
+type testIssued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func issue(t *testing.T, cn string, parent *testIssued, tmpl *x509.Certificate) *testIssued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.Subject = pkix.Name{CommonName: cn}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIssued{cert: cert, key: key}
+}
+
+func TestLevels(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join(testRepoRoot(t), "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := issue(t, "Test CA", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+	leaf := issue(t, "Signer", ca, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature})
+	tsaCert := issue(t, "Test TSA", nil, &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+
+	tsa := sign.TSA{Local: func(request []byte) ([]byte, error) {
+		req, err := timestamp.ParseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            []int{1, 2, 3},
+			AddTSACertificate: true,
+		}
+		return ts.CreateResponseWithOpts(tsaCert.cert, tsaCert.key, crypto.SHA256)
+	}}
+
+	// The CA answers for its leaf offline
+	revocationFunction := func(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+		if issuer == nil {
+			t.Fatalf("no issuer found for %s", cert.Subject.CommonName)
+		}
+		resp, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: cert.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, ca.key)
+		if err != nil {
+			return err
+		}
+		return i.AddOCSP(resp)
+	}
+
+	signData := sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:       sign.SignDataSignatureInfo{Name: "Signer", Date: time.Now()},
+			CertType:   sign.CertificationSignature,
+			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		Signer:            leaf.key,
+		DigestAlgorithm:   crypto.SHA256,
+		Certificate:       leaf.cert,
+		CertificateChains: [][]*x509.Certificate{{leaf.cert, ca.cert}},
+	}
+
+	level := func(t *testing.T, doc []byte) string {
+		t.Helper()
+		res, err := Reader(bytes.NewReader(doc), int64(len(doc)))
+		if err != nil {
+			t.Fatalf("Reader() error: %v", err)
+		}
+		return res.Level
+	}
+
+	basic, err := sign.SignBytes(input, signData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := level(t, basic); got != LevelBB {
+		t.Fatalf("level = %q, want %q", got, LevelBB)
+	}
+
+	signData.TSA = tsa
+	timestamped, err := sign.SignBytes(input, signData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := level(t, timestamped); got != LevelBT {
+		t.Fatalf("level = %q, want %q", got, LevelBT)
+	}
+
+	// A DSS without revocation data for the leaf is not enough
+	incomplete, err := sign.AddDSS(timestamped, func(*x509.Certificate, *x509.Certificate, *revocation.InfoArchival) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := level(t, incomplete); got != LevelBT {
+		t.Fatalf("level = %q, want %q", got, LevelBT)
+	}
+
+	longTerm, err := sign.AddDSS(timestamped, revocationFunction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := level(t, longTerm); got != LevelBLT {
+		t.Fatalf("level = %q, want %q", got, LevelBLT)
+	}
+
+	archived, err := sign.TimestampDocument(longTerm, tsa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Reader(bytes.NewReader(archived), int64(len(archived)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Level != LevelBLTA {
+		t.Fatalf("level = %q, want %q", res.Level, LevelBLTA)
+	}
+	if len(res.Signers) != 2 {
+		t.Fatalf("got %d signatures, want 2", len(res.Signers))
+	}
+	for _, s := range res.Signers {
+		if s.SigFormat == "ETSI.RFC3161" && (!s.ValidSignature || s.Name != "Test TSA" || s.Level != "") {
+			t.Fatalf("unexpected document timestamp: %+v", s)
+		}
+	}
+
+	// Tampering with the archived document invalidates the document timestamp
+	tampered := append([]byte{}, archived...)
+	tampered[len(longTerm)+10] ^= 0xff
+	if got := level(t, tampered); got == LevelBLTA {
+		t.Fatalf("tampered document still reported as %s", got)
+	}
+}
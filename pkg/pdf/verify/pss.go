@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+var (
+	oidRSASSAPSS     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+)
+
+var hashOIDs = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// pssParameters is RSASSA-PSS-params (RFC 4055, section 3.1)
+type pssParameters struct {
+	Hash         pkix.AlgorithmIdentifier `asn1:"explicit,tag:0"`
+	MGF          pkix.AlgorithmIdentifier `asn1:"explicit,tag:1"`
+	SaltLength   int                      `asn1:"explicit,tag:2"`
+	TrailerField int                      `asn1:"optional,explicit,tag:3,default:1"`
+}
+
+// attribute mirrors the signed attribute encoding of pkcs7
+type attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// isPSS reports whether a signer of p7 uses RSASSA-PSS
+func isPSS(p7 *pkcs7.PKCS7) bool {
+	for _, s := range p7.Signers {
+		if s.DigestEncryptionAlgorithm.Algorithm.Equal(oidRSASSAPSS) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPSS verifies RSASSA-PSS signatures, which pkcs7 doesn't support. trusted
+// reports whether the signer certificates chain up to roots.
+func verifyPSS(p7 *pkcs7.PKCS7, roots *x509.CertPool) (trusted bool, err error) {
+	if len(p7.Signers) == 0 {
+		return false, errors.New("no signers")
+	}
+
+	trusted = true
+	for _, s := range p7.Signers {
+		var signer *x509.Certificate
+		for _, c := range p7.Certificates {
+			if c.SerialNumber.Cmp(s.IssuerAndSerialNumber.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, s.IssuerAndSerialNumber.IssuerName.FullBytes) {
+				signer = c
+				break
+			}
+		}
+		if signer == nil {
+			return false, errors.New("signer certificate not found")
+		}
+		pub, ok := signer.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return false, fmt.Errorf("RSASSA-PSS signature with %T key", signer.PublicKey)
+		}
+
+		var params pssParameters
+		if _, err := asn1.Unmarshal(s.DigestEncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+			return false, fmt.Errorf("invalid RSASSA-PSS parameters: %w", err)
+		}
+		hash, ok := hashOIDs[params.Hash.Algorithm.String()]
+		if !ok {
+			return false, fmt.Errorf("unsupported RSASSA-PSS hash %s", params.Hash.Algorithm)
+		}
+		digestHash, ok := hashOIDs[s.DigestAlgorithm.Algorithm.String()]
+		if !ok {
+			return false, fmt.Errorf("unsupported digest algorithm %s", s.DigestAlgorithm.Algorithm)
+		}
+
+		attrs := make([]attribute, 0, len(s.AuthenticatedAttributes))
+		var (
+			messageDigest []byte
+			signingTime   time.Time
+		)
+		for _, a := range s.AuthenticatedAttributes {
+			attrs = append(attrs, attribute{Type: a.Type, Value: a.Value})
+			switch {
+			case a.Type.Equal(oidMessageDigest):
+				_, _ = asn1.Unmarshal(a.Value.Bytes, &messageDigest)
+			case a.Type.Equal(oidSigningTime):
+				_, _ = asn1.Unmarshal(a.Value.Bytes, &signingTime)
+			}
+		}
+
+		h := digestHash.New()
+		h.Write(p7.Content)
+		if messageDigest == nil || !bytes.Equal(h.Sum(nil), messageDigest) {
+			return false, errors.New("message digest mismatch")
+		}
+
+		signed, err := marshalAttributes(attrs)
+		if err != nil {
+			return false, err
+		}
+		h = hash.New()
+		h.Write(signed)
+		if err := rsa.VerifyPSS(pub, hash, h.Sum(nil), s.EncryptedDigest, &rsa.PSSOptions{SaltLength: params.SaltLength, Hash: hash}); err != nil {
+			return false, err
+		}
+
+		if signingTime.IsZero() {
+			signingTime = time.Now()
+		}
+		if _, err := signer.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: roots,
+			CurrentTime:   signingTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			trusted = false
+		}
+	}
+	return trusted, nil
+}
+
+// marshalAttributes returns the DER SET of signed attributes the signature is computed over
+func marshalAttributes(attrs []attribute) ([]byte, error) {
+	encoded, err := asn1.Marshal(struct {
+		A []attribute `asn1:"set"`
+	}{A: attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the leading sequence octets
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(encoded, &raw); err != nil {
+		return nil, err
+	}
+	return raw.Bytes, nil
+}
@@ -0,0 +1,483 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pdf"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Main indications of a validation report (ETSI EN 319 102-1, 5.1.3)
+const (
+	IndicationTotalPassed   = "TOTAL-PASSED"
+	IndicationTotalFailed   = "TOTAL-FAILED"
+	IndicationIndeterminate = "INDETERMINATE"
+)
+
+// Sub-indications of a validation report (ETSI EN 319 102-1, 5.1.3)
+const (
+	// TOTAL-FAILED
+	SubIndicationFormatFailure    = "FORMAT_FAILURE"
+	SubIndicationHashFailure      = "HASH_FAILURE"
+	SubIndicationSigCryptoFailure = "SIG_CRYPTO_FAILURE"
+	SubIndicationRevoked          = "REVOKED"
+	SubIndicationNotYetValid      = "NOT_YET_VALID"
+
+	// INDETERMINATE
+	SubIndicationNoSigningCertificateFound = "NO_SIGNING_CERTIFICATE_FOUND"
+	SubIndicationNoCertificateChainFound   = "NO_CERTIFICATE_CHAIN_FOUND"
+	SubIndicationRevokedNoPOE              = "REVOKED_NO_POE"
+	SubIndicationOutOfBoundsNoPOE          = "OUT_OF_BOUNDS_NO_POE"
+)
+
+// Kinds of signatures and of modifications in a report
+const (
+	KindSignature         = "signature"
+	KindDocumentTimestamp = "document-timestamp"
+	KindValidationData    = "validation-data" // DSS, e.g. added for PAdES B-LT
+	KindFormFill          = "form-fill"
+	KindAnnotation        = "annotation"
+	KindStructure         = "structure" // catalog, pages, appearance streams and the like
+	KindOther             = "other"
+)
+
+// TrustAnchor is a certificate vouched for by a trust source
+type TrustAnchor struct {
+	Source      string            `json:"source"`             // e.g. a trust list or "internal-ca"
+	Location    string            `json:"location,omitempty"` // where the trust list was read from
+	Name        string            `json:"name"`
+	Certificate *x509.Certificate `json:"-"` // nil when the source only knows the key identifier
+}
+
+// TrustFunc looks up the trust anchors with the given subject key identifier.
+// Key identifiers aren't unique, so the report only accepts an anchor whose
+// certificate matches the chain.
+type TrustFunc func(keyID []byte) []*TrustAnchor
+
+// Report is a validation report in the style of ETSI EN 319 102-1
+type Report struct {
+	ValidationTime time.Time         `json:"validation_time"`
+	Indication     string            `json:"indication"` // the worst indication of the signatures
+	Document       ReportDocument    `json:"document"`
+	Signatures     []SignatureReport `json:"signatures"`
+}
+
+// ReportDocument describes the validated document
+type ReportDocument struct {
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Revisions int    `json:"revisions"`
+	Level     string `json:"level,omitempty"` // PAdES baseline level
+}
+
+// SignatureReport is the validation result of one signature or document timestamp
+type SignatureReport struct {
+	ID                  string         `json:"id"`
+	Kind                string         `json:"kind"`
+	Format              string         `json:"format"`
+	Level               string         `json:"level,omitempty"`
+	SignerName          string         `json:"signer_name"`
+	Reason              string         `json:"reason,omitempty"`
+	Location            string         `json:"location,omitempty"`
+	TimestampTime       *time.Time     `json:"timestamp_time,omitempty"` // proof of existence: signature or later document timestamp
+	Indication          string         `json:"indication"`
+	SubIndication       string         `json:"sub_indication,omitempty"`
+	Errors              []string       `json:"errors,omitempty"`
+	ByteRange           []int64        `json:"byte_range"`
+	CoversWholeDocument bool           `json:"covers_whole_document"`
+	Modifications       []Modification `json:"modifications"` // revisions appended after signing
+	Chain               []ChainElement `json:"chain"`
+}
+
+// Modification is a document revision appended after a signature
+type Modification struct {
+	Revision int      `json:"revision"` // 1-based revision number
+	Offset   int64    `json:"offset"`   // start of the revision in the file
+	Length   int64    `json:"length"`
+	Kind     string   `json:"kind"`
+	Changes  []string `json:"changes"` // kinds of the objects changed by the revision
+}
+
+// ChainElement is a certificate of a signature's chain, signing certificate first
+type ChainElement struct {
+	Subject      string       `json:"subject"`
+	Issuer       string       `json:"issuer,omitempty"`
+	SerialNumber string       `json:"serial_number,omitempty"`
+	NotBefore    time.Time    `json:"not_before,omitzero"`
+	NotAfter     time.Time    `json:"not_after,omitzero"`
+	SHA256       string       `json:"sha256,omitempty"`
+	Embedded     bool         `json:"embedded"` // false for trust anchors only known to the trust source
+	TrustAnchor  *TrustAnchor `json:"trust_anchor,omitempty"`
+	Revocation   string       `json:"revocation,omitempty"` // good, revoked or unknown per embedded OCSP
+}
+
+var indicationOrder = map[string]int{IndicationTotalPassed: 0, IndicationIndeterminate: 1, IndicationTotalFailed: 2}
+
+// NewReport verifies the document and builds its validation report. trust
+// decides which chains are anchored; without it no chain is.
+func NewReport(file io.ReaderAt, size int64, trust TrustFunc) (*Report, error) {
+	resp, err := Reader(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(file, 0, size)); err != nil {
+		return nil, err
+	}
+	revisions, err := findRevisions(file, size)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		ValidationTime: time.Now().UTC(),
+		Indication:     IndicationTotalPassed,
+		Document: ReportDocument{
+			Size:      size,
+			SHA256:    hex.EncodeToString(h.Sum(nil)),
+			Revisions: len(revisions),
+			Level:     resp.Level,
+		},
+	}
+
+	kinds := classifyRevisions(file, revisions, resp.Signers)
+	for i, signer := range resp.Signers {
+		sr := signatureReport(signer, report.ValidationTime, proofOfExistence(signer, resp.Signers), trust)
+		sr.ID = fmt.Sprintf("sig-%d", i+1)
+		if end := signedEnd(signer); end > 0 {
+			sr.CoversWholeDocument = end >= size || strings.TrimSpace(readString(file, end, size-end)) == ""
+			for j, rev := range revisions {
+				if rev.start >= end {
+					sr.Modifications = append(sr.Modifications, Modification{
+						Revision: j + 1,
+						Offset:   rev.start,
+						Length:   rev.end - rev.start,
+						Kind:     kinds[j].kind,
+						Changes:  kinds[j].changes,
+					})
+				}
+			}
+		}
+		if indicationOrder[sr.Indication] > indicationOrder[report.Indication] {
+			report.Indication = sr.Indication
+		}
+		report.Signatures = append(report.Signatures, sr)
+	}
+	return report, nil
+}
+
+// signedEnd returns the end of the bytes covered by the signature, 0 if unknown
+func signedEnd(signer Signer) int64 {
+	n := len(signer.ByteRange)
+	if n < 2 {
+		return 0
+	}
+	return signer.ByteRange[n-2] + signer.ByteRange[n-1]
+}
+
+// proofOfExistence returns the earliest time the signature is proven to have
+// existed at: its signature timestamp, else a later valid document timestamp
+func proofOfExistence(signer Signer, signers []Signer) *time.Time {
+	var poe *time.Time
+	if signer.TimeStamp != nil {
+		t := signer.TimeStamp.Time.UTC()
+		poe = &t
+	}
+	for _, other := range signers {
+		if other.SigFormat != "ETSI.RFC3161" || !other.ValidSignature || other.TimeStamp == nil || signedEnd(other) <= signedEnd(signer) {
+			continue
+		}
+		if t := other.TimeStamp.Time.UTC(); poe == nil || t.Before(*poe) {
+			poe = &t
+		}
+	}
+	return poe
+}
+
+// SignerReport evaluates a signature verified outside of a PDF, e.g. a CMS
+// signature checked with SignedData, at validation time now. Its signature
+// timestamp is the proof of existence.
+func SignerReport(signer Signer, now time.Time, trust TrustFunc) SignatureReport {
+	return signatureReport(signer, now, proofOfExistence(signer, nil), trust)
+}
+
+// signatureReport evaluates one signature at validation time now
+func signatureReport(signer Signer, now time.Time, poe *time.Time, trust TrustFunc) SignatureReport {
+	sr := SignatureReport{
+		Kind:          KindSignature,
+		Format:        signer.SigFormat,
+		Level:         signer.Level,
+		SignerName:    signer.Name,
+		Reason:        signer.Reason,
+		Location:      signer.Location,
+		ByteRange:     signer.ByteRange,
+		Modifications: []Modification{},
+		Chain:         []ChainElement{},
+	}
+	if signer.SigFormat == "ETSI.RFC3161" {
+		sr.Kind = KindDocumentTimestamp
+	}
+	sr.TimestampTime = poe
+	if signer.Error != "" {
+		sr.Errors = append(sr.Errors, signer.Error)
+	}
+
+	anchored := false
+	for _, c := range signer.Certificates {
+		el := ChainElement{
+			Subject:      c.Certificate.Subject.String(),
+			Issuer:       c.Certificate.Issuer.String(),
+			SerialNumber: c.Certificate.SerialNumber.Text(16),
+			NotBefore:    c.Certificate.NotBefore,
+			NotAfter:     c.Certificate.NotAfter,
+			SHA256:       fingerprint(c.Certificate),
+			Embedded:     true,
+		}
+		if c.OCSPResponse != nil {
+			el.Revocation = ocspStatus(c.OCSPResponse.Status)
+		}
+		if trust != nil && !anchored {
+			el.TrustAnchor = findAnchor(trust(c.Certificate.SubjectKeyId), func(a *x509.Certificate) bool {
+				return bytes.Equal(a.RawSubjectPublicKeyInfo, c.Certificate.RawSubjectPublicKeyInfo)
+			})
+			anchored = el.TrustAnchor != nil
+		}
+		sr.Chain = append(sr.Chain, el)
+	}
+	// The anchor of the chain needn't be embedded in the signature
+	if n := len(signer.Certificates); n > 0 && trust != nil && !anchored {
+		top := signer.Certificates[n-1].Certificate
+		anchor := findAnchor(trust(top.AuthorityKeyId), func(a *x509.Certificate) bool {
+			return top.CheckSignatureFrom(a) == nil
+		})
+		if anchor != nil && !isSelfSigned(top) {
+			anchored = true
+			sr.Chain = append(sr.Chain, ChainElement{Subject: anchor.Name, TrustAnchor: anchor})
+		}
+	}
+
+	sr.Indication, sr.SubIndication = indication(signer, poe, now, anchored)
+	return sr
+}
+
+// findAnchor returns the first of the anchors whose certificate matches; anchors
+// without a certificate are taken on their key identifier
+func findAnchor(anchors []*TrustAnchor, matches func(*x509.Certificate) bool) *TrustAnchor {
+	for _, a := range anchors {
+		if a != nil && (a.Certificate == nil || matches(a.Certificate)) {
+			return a
+		}
+	}
+	return nil
+}
+
+// indication maps the verification result of a signature to an indication and
+// sub-indication. The checks follow the order of the basic signature validation
+// of ETSI EN 319 102-1: format, cryptographic verification, revocation, validity
+// period, then the chain.
+func indication(signer Signer, poe *time.Time, now time.Time, anchored bool) (string, string) {
+	if len(signer.ByteRange) == 0 || (signer.Error == "" && !signer.ValidSignature) {
+		return IndicationTotalFailed, SubIndicationFormatFailure
+	}
+	if !signer.ValidSignature {
+		if strings.Contains(strings.ToLower(signer.Error), "digest mismatch") {
+			return IndicationTotalFailed, SubIndicationHashFailure
+		}
+		return IndicationTotalFailed, SubIndicationSigCryptoFailure
+	}
+	if len(signer.Certificates) == 0 {
+		return IndicationIndeterminate, SubIndicationNoSigningCertificateFound
+	}
+
+	leaf := signer.Certificates[0]
+	if resp := leaf.OCSPResponse; resp != nil && resp.Status == ocsp.Revoked {
+		switch {
+		case poe == nil:
+			return IndicationIndeterminate, SubIndicationRevokedNoPOE
+		case !poe.Before(resp.RevokedAt):
+			return IndicationTotalFailed, SubIndicationRevoked
+		}
+	}
+
+	signingTime := now
+	if poe != nil {
+		signingTime = *poe
+	}
+	if signingTime.Before(leaf.Certificate.NotBefore) {
+		return IndicationTotalFailed, SubIndicationNotYetValid
+	}
+	if signingTime.After(leaf.Certificate.NotAfter) {
+		return IndicationIndeterminate, SubIndicationOutOfBoundsNoPOE
+	}
+
+	if !anchored {
+		return IndicationIndeterminate, SubIndicationNoCertificateChainFound
+	}
+	return IndicationTotalPassed, ""
+}
+
+func ocspStatus(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	}
+	return "unknown"
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// revision is a byte span of the file ending with an %%EOF marker
+type revision struct {
+	start, end int64
+}
+
+var eofMarker = []byte("%%EOF")
+
+// findRevisions splits the file at its %%EOF markers; trailing end-of-line
+// characters belong to the revision they follow.
+func findRevisions(file io.ReaderAt, size int64) ([]revision, error) {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []revision
+	var start int64
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], eofMarker)
+		if i < 0 {
+			break
+		}
+		end := offset + i + len(eofMarker)
+		for end < len(data) && (data[end] == '\r' || data[end] == '\n') {
+			end++
+		}
+		revisions = append(revisions, revision{start: start, end: int64(end)})
+		start, offset = int64(end), end
+	}
+	if start < size {
+		revisions = append(revisions, revision{start: start, end: size})
+	}
+	return revisions, nil
+}
+
+// revisionKind is what a document revision changed
+type revisionKind struct {
+	kind    string
+	changes []string
+}
+
+var objectHeader = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d+)\s+(\d+)\s+obj\b`)
+
+// classifyRevisions tells for every revision what it changed, judging by the
+// objects written in it
+func classifyRevisions(file io.ReaderAt, revisions []revision, signers []Signer) []revisionKind {
+	kinds := make([]revisionKind, len(revisions))
+	for i, rev := range revisions {
+		kinds[i] = revisionKind{kind: KindOther, changes: []string{}}
+		if i == 0 {
+			continue
+		}
+
+		// A revision ending where a signature's byte range ends was written to hold it
+		for _, s := range signers {
+			if end := signedEnd(s); end > rev.start && end <= rev.end {
+				kinds[i].kind = KindSignature
+				if s.SigFormat == "ETSI.RFC3161" {
+					kinds[i].kind = KindDocumentTimestamp
+				}
+			}
+		}
+
+		rdr, err := pdf.NewReader(io.NewSectionReader(file, 0, rev.end), rev.end)
+		if err != nil {
+			continue
+		}
+		dss := rdr.Trailer().Key("Root").Key("DSS").GetPtr().GetID()
+
+		changes := map[string]bool{}
+		content := readString(file, rev.start, rev.end-rev.start)
+		for _, m := range objectHeader.FindAllStringSubmatch(content, -1) {
+			id, err := strconv.ParseUint(m[1], 10, 32)
+			if err != nil {
+				continue
+			}
+			v, err := rdr.GetObject(uint32(id))
+			if err != nil {
+				continue
+			}
+			changes[objectKind(v, uint32(id) == dss && dss != 0)] = true
+		}
+		for c := range changes {
+			kinds[i].changes = append(kinds[i].changes, c)
+		}
+		slices.Sort(kinds[i].changes)
+
+		if kinds[i].kind != KindOther {
+			continue
+		}
+		switch {
+		case changes[KindFormFill]:
+			kinds[i].kind = KindFormFill
+		case changes[KindAnnotation]:
+			kinds[i].kind = KindAnnotation
+		case changes[KindValidationData]:
+			kinds[i].kind = KindValidationData
+		}
+	}
+	return kinds
+}
+
+// objectKind classifies a changed object
+func objectKind(v pdf.Value, isDSS bool) string {
+	if isDSS || !v.Key("VRI").IsNull() || !v.Key("OCSPs").IsNull() || !v.Key("CRLs").IsNull() {
+		return KindValidationData
+	}
+	switch v.Key("Type").Name() {
+	case "Sig":
+		return KindSignature
+	case "DocTimeStamp":
+		return KindDocumentTimestamp
+	case "Annot":
+		if v.Key("FT").Name() == "Sig" {
+			return KindSignature
+		}
+		if v.Key("Subtype").Name() == "Widget" && !v.Key("FT").IsNull() {
+			return KindFormFill
+		}
+		if v.Key("Subtype").Name() != "Widget" {
+			return KindAnnotation
+		}
+	}
+	switch ft := v.Key("FT").Name(); {
+	case ft == "Sig":
+		return KindSignature
+	case ft != "":
+		return KindFormFill
+	}
+	return KindStructure
+}
+
+func readString(file io.ReaderAt, offset, length int64) string {
+	if length <= 0 {
+		return ""
+	}
+	data, _ := io.ReadAll(io.NewSectionReader(file, offset, length))
+	return string(data)
+}
@@ -0,0 +1,174 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+)
+
+func TestNewReport(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join(testRepoRoot(t), "fixtures", "testfiles", "testfile20.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := issue(t, "Test CA", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+	leaf := issue(t, "Signer", ca, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature})
+	tsaCert := issue(t, "Test TSA", ca, &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	tsa := sign.TSA{Local: func(request []byte) ([]byte, error) {
+		req, err := timestamp.ParseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            []int{1, 2, 3},
+			AddTSACertificate: true,
+		}
+		return ts.CreateResponseWithOpts(tsaCert.cert, tsaCert.key, crypto.SHA256)
+	}}
+
+	signed, err := sign.SignBytes(input, sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:       sign.SignDataSignatureInfo{Name: "Signer", Date: time.Now()},
+			CertType:   sign.CertificationSignature,
+			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
+		},
+		Signer:            leaf.key,
+		DigestAlgorithm:   crypto.SHA256,
+		Certificate:       leaf.cert,
+		CertificateChains: [][]*x509.Certificate{{leaf.cert, ca.cert}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	longTerm, err := sign.AddDSS(signed, func(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+		resp, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: cert.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, ca.key)
+		if err != nil {
+			return err
+		}
+		return i.AddOCSP(resp)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived, err := sign.TimestampDocument(longTerm, tsa)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := func(t *testing.T, doc []byte, trust TrustFunc) *Report {
+		t.Helper()
+		r, err := NewReport(bytes.NewReader(doc), int64(len(doc)), trust)
+		if err != nil {
+			t.Fatalf("NewReport() error: %v", err)
+		}
+		return r
+	}
+	trustCA := func(keyID []byte) []*TrustAnchor {
+		if bytes.Equal(keyID, ca.cert.SubjectKeyId) {
+			return []*TrustAnchor{{Source: "test", Name: "Test CA", Certificate: ca.cert}}
+		}
+		return nil
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		r := report(t, archived, nil)
+		if r.Indication != IndicationIndeterminate {
+			t.Fatalf("indication = %s, want %s", r.Indication, IndicationIndeterminate)
+		}
+		if s := r.Signatures[0]; s.SubIndication != SubIndicationNoCertificateChainFound {
+			t.Fatalf("sub-indication = %s, want %s", s.SubIndication, SubIndicationNoCertificateChainFound)
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		r := report(t, archived, trustCA)
+		if r.Indication != IndicationTotalPassed {
+			t.Fatalf("indication = %s, want %s: %+v", r.Indication, IndicationTotalPassed, r.Signatures)
+		}
+		if r.Document.Revisions != 4 || r.Document.Level != LevelBLTA {
+			t.Fatalf("document = %+v, want 4 revisions at %s", r.Document, LevelBLTA)
+		}
+		if len(r.Signatures) != 2 {
+			t.Fatalf("got %d signatures, want 2", len(r.Signatures))
+		}
+
+		sig, docTS := r.Signatures[0], r.Signatures[1]
+		if sig.Kind != KindSignature || docTS.Kind != KindDocumentTimestamp {
+			t.Fatalf("kinds = %s, %s", sig.Kind, docTS.Kind)
+		}
+		if sig.CoversWholeDocument || !docTS.CoversWholeDocument {
+			t.Fatalf("covers whole document = %v, %v, want false, true", sig.CoversWholeDocument, docTS.CoversWholeDocument)
+		}
+		// The document timestamp proves the signature existed before it
+		if sig.TimestampTime == nil {
+			t.Fatal("signature has no proof of existence")
+		}
+
+		var kinds []string
+		for _, m := range sig.Modifications {
+			kinds = append(kinds, m.Kind)
+		}
+		if len(kinds) != 2 || kinds[0] != KindValidationData || kinds[1] != KindDocumentTimestamp {
+			t.Fatalf("modifications after signing = %v, want [%s %s]", kinds, KindValidationData, KindDocumentTimestamp)
+		}
+		if len(docTS.Modifications) != 0 {
+			t.Fatalf("document timestamp has modifications: %+v", docTS.Modifications)
+		}
+
+		if len(sig.Chain) != 2 || sig.Chain[0].Subject != "CN=Signer" {
+			t.Fatalf("chain = %+v, want signer then CA", sig.Chain)
+		}
+		if sig.Chain[0].TrustAnchor != nil || sig.Chain[1].TrustAnchor == nil || sig.Chain[1].TrustAnchor.Source != "test" {
+			t.Fatalf("trust anchors = %v, %v", sig.Chain[0].TrustAnchor, sig.Chain[1].TrustAnchor)
+		}
+	})
+
+	t.Run("anchor with the same key identifier", func(t *testing.T) {
+		other := issue(t, "Other CA", nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true})
+		impostor := func(keyID []byte) []*TrustAnchor {
+			if bytes.Equal(keyID, ca.cert.SubjectKeyId) {
+				return []*TrustAnchor{{Source: "test", Name: "Other CA", Certificate: other.cert}}
+			}
+			return nil
+		}
+		if r := report(t, archived, impostor); r.Indication == IndicationTotalPassed {
+			t.Fatal("chain anchored by a certificate with another key")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte{}, signed...)
+		i := bytes.Index(tampered, []byte("endobj"))
+		tampered[i-1] = ' '
+		r := report(t, tampered, trustCA)
+		if s := r.Signatures[0]; s.Indication != IndicationTotalFailed || s.SubIndication != SubIndicationHashFailure {
+			t.Fatalf("indication = %s/%s, want %s/%s", s.Indication, s.SubIndication, IndicationTotalFailed, SubIndicationHashFailure)
+		}
+	})
+}
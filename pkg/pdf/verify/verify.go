@@ -29,6 +29,9 @@ var (
 type Response struct {
 	Error string
 
+	// Level is the PAdES baseline level of the document, the lowest of its signatures
+	Level string
+
 	DocumentInfo DocumentInfo
 	Signers      []Signer
 }
@@ -42,8 +45,11 @@ type Signer struct {
 	TrustedIssuer      bool                 `json:"trusted_issuer"`
 	RevokedCertificate bool                 `json:"revoked_certificate"`
 	SigFormat          string               `json:"sig_format"`
-	Certificates       []Certificate        `json:"certificates"`
+	Level              string               `json:"level"`
+	Certificates       []Certificate        `json:"certificates"` // signing certificate first, then its issuers
 	TimeStamp          *timestamp.Timestamp `json:"time_stamp"`
+	ByteRange          []int64              `json:"byte_range"`      // (offset, length) pairs covered by the signature
+	Error              string               `json:"error,omitempty"` // why the signature did not verify
 }
 
 type Certificate struct {
@@ -106,6 +112,8 @@ func Reader(file io.ReaderAt, size int64) (apiResp *Response, err error) {
 		return nil, ErrNoSignature
 	}
 
+	var revisions []signedRevision
+
 	// Walk over the cross references in the document
 	// Use GetObject instead of Resolve for new API
 	for _, x := range rdr.Xref() {
@@ -136,6 +144,45 @@ func Reader(file io.ReaderAt, size int64) (apiResp *Response, err error) {
 
 		signer.SigFormat = v.Key("SubFilter").String()[1:]
 
+		byteRange := v.Key("ByteRange")
+		end := byteRange.Index(byteRange.Len()-2).Int64() + byteRange.Index(byteRange.Len()-1).Int64()
+		for i := 0; i < byteRange.Len(); i++ {
+			signer.ByteRange = append(signer.ByteRange, byteRange.Index(i).Int64())
+		}
+
+		// A document timestamp holds a bare RFC 3161 token over the byte ranges
+		if signer.SigFormat == "ETSI.RFC3161" {
+			ts, err := timestamp.Parse([]byte(v.Key("Contents").RawString()))
+			if err != nil {
+				apiResp.Error = fmt.Sprintln("Failed to parse document timestamp:", err)
+				continue
+			}
+			content, err := byteRangeContent(file, byteRange)
+			if err != nil {
+				apiResp.Error = fmt.Sprintln("Failed to get ByteRange:", err)
+				continue
+			}
+
+			h := ts.HashAlgorithm.New()
+			h.Write(content)
+			signer.ValidSignature = bytes.Equal(h.Sum(nil), ts.HashedMessage)
+			if !signer.ValidSignature {
+				apiResp.Error = fmt.Sprintln("Hash in document timestamp is different from document")
+				signer.Error = "document timestamp: message digest mismatch"
+			}
+			signer.TimeStamp = ts
+			for _, cert := range ts.Certificates {
+				signer.Certificates = append(signer.Certificates, Certificate{Certificate: cert})
+			}
+			if len(ts.Certificates) > 0 {
+				signer.Name = ts.Certificates[0].Subject.CommonName
+			}
+
+			apiResp.Signers = append(apiResp.Signers, signer)
+			revisions = append(revisions, signedRevision{end: end, docTimestamp: true, certificates: ts.Certificates})
+			continue
+		}
+
 		// (Required) The signature value. When ByteRange is present, the
 		// value shall be a hexadecimal string (see 7.3.4.3, â€œHexadecimal
 		// Stringsâ€) representing the value of the byte range digest.
@@ -149,149 +196,18 @@ func Reader(file io.ReaderAt, size int64) (apiResp *Response, err error) {
 			continue
 		}
 
-		// An array of pairs of integers (starting byte offset, length in
-		// bytes) that shall describe the exact byte range for the digest
-		// calculation. Multiple discontiguous byte ranges shall be used to
-		// describe a digest that does not include the signature value (the
-		// Contents entry) itself.
-		for i := 0; i < v.Key("ByteRange").Len(); i++ {
-			// As the byte range comes in pairs, we increment one extra
-			i++
-
-			// Read the byte range from the raw file and add it to the contents.
-			// This content will be hashed with the corresponding algorithm to
-			// verify the signature.
-
-			content, err := io.ReadAll(io.NewSectionReader(file, v.Key("ByteRange").Index(i-1).Int64(), v.Key("ByteRange").Index(i).Int64()))
-			if err != nil {
-				apiResp.Error = fmt.Sprintln("Failed to get ByteRange:", i, err)
-			}
-
-			p7.Content = append(p7.Content, content...)
-		}
-
-		// Signer certificate
-		// http://www.alvestrand.no/objectid/1.2.840.113549.1.9.html
-		// http://www.alvestrand.no/objectid/1.2.840.113583.1.1.8.html
-		// var isn []byte
-		for _, s := range p7.Signers {
-			//isn = s.IssuerAndSerialNumber.IssuerName.FullBytes
-			//for _, a := range s.AuthenticatedAttributes {
-			//fmt.Printf("A: %v, %#v\n", s.IssuerAndSerialNumber.SerialNumber, a.Type)
-			//}
-
-			// Timestamp
-			// http://www.alvestrand.no/objectid/1.2.840.113549.1.9.16.2.14.html
-			// Timestamp
-			// 1.2.840.113549.1.9.16.2.14 - RFC 3161 id-aa-timeStampToken
-			for _, attr := range s.UnauthenticatedAttributes {
-				// fmt.Printf("U: %v, %#v\n", s.IssuerAndSerialNumber.SerialNumber, attr.Type)
-
-				if attr.Type.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}) {
-					signer.TimeStamp, err = timestamp.Parse(attr.Value.Bytes)
-					if err != nil {
-						apiResp.Error = fmt.Sprintln("Failed to parse timestamp", err)
-					} else {
-						r := bytes.NewReader(s.EncryptedDigest)
-						h := crypto.SHA256.New()
-						b := make([]byte, 32)
-						for {
-							n, err := r.Read(b)
-							if err == io.EOF {
-								break
-							}
-
-							h.Write(b[:n])
-						}
-
-						if !bytes.Equal(h.Sum(nil), signer.TimeStamp.HashedMessage) {
-							apiResp.Error = fmt.Sprintln("Hash in timestamp is different from pkcs7")
-						}
-
-						break
-					}
-				}
-			}
-		}
-
-		// Directory of certificates, including OCSP
-		certPool := x509.NewCertPool()
-		for _, cert := range p7.Certificates {
-			certPool.AddCert(cert)
-		}
-
-		// Verify the digital signature of the pdf file.
-		if err := p7.VerifyWithChain(certPool); err != nil {
-			if err := p7.Verify(); err == nil {
-				signer.ValidSignature = true
-				signer.TrustedIssuer = false
-			} else {
-				apiResp.Error = fmt.Sprintln("Failed to verify signature:", err)
-			}
-		} else {
-			signer.ValidSignature = true
-			signer.TrustedIssuer = true
+		content, err := byteRangeContent(file, byteRange)
+		if err != nil {
+			apiResp.Error = fmt.Sprintln("Failed to get ByteRange:", err)
 		}
+		p7.Content = append(p7.Content, content...)
 
 		// PDF signature certificate revocation information attribute (1.2.840.113583.1.1.8)
 		var revInfo revocation.InfoArchival
 		_ = p7.UnmarshalSignedAttribute(asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8}, &revInfo)
 
-		// Parse OCSP response
-		ocspStatus := make(map[string]*ocsp.Response)
-		for _, o := range revInfo.OCSP {
-			resp, err := ocsp.ParseResponse(o.FullBytes, nil)
-			if err != nil {
-				apiResp.Error = fmt.Sprintln("Failed to parse or verify OCSP response", err)
-				continue
-			}
-			ocspStatus[fmt.Sprintf("%x", resp.SerialNumber)] = resp
-		}
-
-		// Build certificate chains and verify revocation status
-		for _, cert := range p7.Certificates {
-			var c Certificate
-			c.Certificate = cert
-
-			chain, err := cert.Verify(x509.VerifyOptions{
-				Intermediates: certPool,
-				CurrentTime:   cert.NotBefore,
-				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-			})
-			if err != nil {
-				c.VerifyError = err.Error()
-			}
-
-			if resp, ok := ocspStatus[fmt.Sprintf("%x", cert.SerialNumber)]; ok {
-				c.OCSPResponse = resp
-				c.OCSPEmbedded = true
-
-				if resp.Status != ocsp.Good {
-					signer.RevokedCertificate = true
-				}
-
-				if len(chain) > 0 && len(chain[0]) > 1 {
-					issuer := chain[0][1]
-					if resp.Certificate != nil {
-						err = resp.Certificate.CheckSignatureFrom(issuer)
-						if err != nil {
-							apiResp.Error = fmt.Sprintln("OCSP signing cerificate not from certificate issuer:", err)
-						}
-					} else {
-						// CA Signed response
-						err = resp.CheckSignatureFrom(issuer)
-						if err != nil {
-							apiResp.Error = fmt.Sprintln("Failed to verify OCSP response signature:", err)
-						}
-					}
-				}
-			}
-			//  else {
-			// 	// Check OCSP status for certificate out of band
-			// }
-
-			// Add certificate to result
-			signer.Certificates = append(signer.Certificates, c)
+		if warning := SignedData(p7, revInfo, &signer); warning != "" {
+			apiResp.Error = warning
 		}
 
 		// Certificate revocation lists when included in this document
@@ -330,13 +246,209 @@ func Reader(file io.ReaderAt, size int64) (apiResp *Response, err error) {
 		// v.Key("Cert").Text()
 
 		apiResp.Signers = append(apiResp.Signers, signer)
+
+		certificates := p7.Certificates
+		if signer.TimeStamp != nil {
+			certificates = append(certificates, signer.TimeStamp.Certificates...)
+		}
+		revisions = append(revisions, signedRevision{end: end, certificates: certificates, revocation: revInfo})
 	}
 
+	setLevels(file, rdr, apiResp, revisions)
 	apiResp.DocumentInfo = documentInfo
 
 	return
 }
 
+// SignedData verifies the CMS signature p7 over p7.Content and fills in the
+// signature, timestamp and certificate fields of signer. The certificates are
+// checked against the revocation data rev. Problems that don't make the
+// signature invalid are returned as warning.
+func SignedData(p7 *pkcs7.PKCS7, rev revocation.InfoArchival, signer *Signer) (warning string) {
+	var err error
+
+	// Signer certificate
+	// http://www.alvestrand.no/objectid/1.2.840.113549.1.9.html
+	// http://www.alvestrand.no/objectid/1.2.840.113583.1.1.8.html
+	// var isn []byte
+	for _, s := range p7.Signers {
+		//isn = s.IssuerAndSerialNumber.IssuerName.FullBytes
+		//for _, a := range s.AuthenticatedAttributes {
+		//fmt.Printf("A: %v, %#v\n", s.IssuerAndSerialNumber.SerialNumber, a.Type)
+		//}
+
+		// Timestamp
+		// http://www.alvestrand.no/objectid/1.2.840.113549.1.9.16.2.14.html
+		// Timestamp
+		// 1.2.840.113549.1.9.16.2.14 - RFC 3161 id-aa-timeStampToken
+		for _, attr := range s.UnauthenticatedAttributes {
+			// fmt.Printf("U: %v, %#v\n", s.IssuerAndSerialNumber.SerialNumber, attr.Type)
+
+			if attr.Type.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}) {
+				signer.TimeStamp, err = timestamp.Parse(attr.Value.Bytes)
+				if err != nil {
+					warning = fmt.Sprintln("Failed to parse timestamp", err)
+				} else {
+					r := bytes.NewReader(s.EncryptedDigest)
+					h := crypto.SHA256.New()
+					b := make([]byte, 32)
+					for {
+						n, err := r.Read(b)
+						if err == io.EOF {
+							break
+						}
+
+						h.Write(b[:n])
+					}
+
+					if !bytes.Equal(h.Sum(nil), signer.TimeStamp.HashedMessage) {
+						warning = fmt.Sprintln("Hash in timestamp is different from pkcs7")
+					}
+
+					break
+				}
+			}
+		}
+	}
+
+	// Directory of certificates, including OCSP
+	certPool := x509.NewCertPool()
+	for _, cert := range p7.Certificates {
+		certPool.AddCert(cert)
+	}
+
+	// Verify the digital signature over the content.
+	if isPSS(p7) {
+		if trusted, err := verifyPSS(p7, certPool); err == nil {
+			signer.ValidSignature = true
+			signer.TrustedIssuer = trusted
+		} else {
+			warning = fmt.Sprintln("Failed to verify signature:", err)
+			signer.Error = err.Error()
+		}
+	} else if err := p7.VerifyWithChain(certPool); err != nil {
+		if err := p7.Verify(); err == nil {
+			signer.ValidSignature = true
+			signer.TrustedIssuer = false
+		} else {
+			warning = fmt.Sprintln("Failed to verify signature:", err)
+			signer.Error = err.Error()
+		}
+	} else {
+		signer.ValidSignature = true
+		signer.TrustedIssuer = true
+	}
+
+	// Parse OCSP response
+	ocspStatus := make(map[string]*ocsp.Response)
+	for _, o := range rev.OCSP {
+		resp, err := ocsp.ParseResponse(o.FullBytes, nil)
+		if err != nil {
+			warning = fmt.Sprintln("Failed to parse or verify OCSP response", err)
+			continue
+		}
+		ocspStatus[fmt.Sprintf("%x", resp.SerialNumber)] = resp
+	}
+
+	// Build certificate chains and verify revocation status
+	for _, cert := range signerChainOrder(p7) {
+		var c Certificate
+		c.Certificate = cert
+
+		chain, err := cert.Verify(x509.VerifyOptions{
+			Intermediates: certPool,
+			CurrentTime:   cert.NotBefore,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			c.VerifyError = err.Error()
+		}
+
+		if resp, ok := ocspStatus[fmt.Sprintf("%x", cert.SerialNumber)]; ok {
+			c.OCSPResponse = resp
+			c.OCSPEmbedded = true
+
+			if resp.Status != ocsp.Good {
+				signer.RevokedCertificate = true
+			}
+
+			if len(chain) > 0 && len(chain[0]) > 1 {
+				issuer := chain[0][1]
+				if resp.Certificate != nil {
+					err = resp.Certificate.CheckSignatureFrom(issuer)
+					if err != nil {
+						warning = fmt.Sprintln("OCSP signing cerificate not from certificate issuer:", err)
+					}
+				} else {
+					// CA Signed response
+					err = resp.CheckSignatureFrom(issuer)
+					if err != nil {
+						warning = fmt.Sprintln("Failed to verify OCSP response signature:", err)
+					}
+				}
+			}
+		}
+		//  else {
+		// 	// Check OCSP status for certificate out of band
+		// }
+
+		// Add certificate to result
+		signer.Certificates = append(signer.Certificates, c)
+	}
+
+	return warning
+}
+
+// signerChainOrder returns the certificates of p7 starting with the signing
+// certificate followed by its issuers; unrelated certificates come last.
+func signerChainOrder(p7 *pkcs7.PKCS7) []*x509.Certificate {
+	leaf := p7.GetOnlySigner()
+	if leaf == nil {
+		return p7.Certificates
+	}
+
+	ordered := []*x509.Certificate{leaf}
+	used := map[*x509.Certificate]bool{leaf: true}
+	for cur := leaf; !bytes.Equal(cur.RawIssuer, cur.RawSubject); {
+		var issuer *x509.Certificate
+		for _, c := range p7.Certificates {
+			if !used[c] && bytes.Equal(c.RawSubject, cur.RawIssuer) && cur.CheckSignatureFrom(c) == nil {
+				issuer = c
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		ordered = append(ordered, issuer)
+		used[issuer] = true
+		cur = issuer
+	}
+	for _, c := range p7.Certificates {
+		if !used[c] {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered
+}
+
+// byteRangeContent reads the signed content. ByteRange is an array of pairs of
+// integers (starting byte offset, length in bytes) that shall describe the
+// exact byte range for the digest calculation. Multiple discontiguous byte
+// ranges shall be used to describe a digest that does not include the
+// signature value (the Contents entry) itself.
+func byteRangeContent(file io.ReaderAt, byteRange pdf.Value) ([]byte, error) {
+	var content []byte
+	for i := 0; i+1 < byteRange.Len(); i += 2 {
+		part, err := io.ReadAll(io.NewSectionReader(file, byteRange.Index(i).Int64(), byteRange.Index(i+1).Int64()))
+		if err != nil {
+			return content, fmt.Errorf("range %d: %w", i/2, err)
+		}
+		content = append(content, part...)
+	}
+	return content, nil
+}
+
 // parseDocumentInfo parses document information
 func parseDocumentInfo(v pdf.Value, documentInfo *DocumentInfo) {
 	keys := []string{
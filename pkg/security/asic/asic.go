@@ -0,0 +1,159 @@
+// Package asic creates and verifies ASiC-E containers with CAdES signatures
+// (ETSI EN 319 162-1): a ZIP file holding the signed files, a mimetype entry,
+// a manifest (META-INF/ASiCManifest.xml) with the digest of every file, and a
+// detached CMS signature of the manifest (META-INF/signature.p7s).
+package asic
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/security/cms"
+)
+
+// MimeTypeASiCE is the content of the mimetype entry of an ASiC-E container
+const MimeTypeASiCE = "application/vnd.etsi.asic-e+zip"
+
+// Names of the entries that describe the container
+const (
+	EntryMimeType  = "mimetype"
+	EntryManifest  = "META-INF/ASiCManifest.xml"
+	EntrySignature = "META-INF/signature.p7s"
+)
+
+const (
+	namespaceASiC   = "http://uri.etsi.org/02918/v1.2.1#"
+	namespaceDSig   = "http://www.w3.org/2000/09/xmldsig#"
+	digestSHA256URI = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+// File is a file of a container
+type File struct {
+	Name     string // path in the container, e.g. "documents/contract.pdf"
+	MimeType string
+	Data     []byte
+}
+
+// manifest is ASiCManifest (ETSI EN 319 162-1, annex A.4)
+type manifest struct {
+	XMLName    xml.Name          `xml:"asic:ASiCManifest"`
+	XMLNSASiC  string            `xml:"xmlns:asic,attr"`
+	XMLNSDSig  string            `xml:"xmlns:ds,attr"`
+	SigRef     manifestSigRef    `xml:"asic:SigReference"`
+	References []manifestDataRef `xml:"asic:DataObjectReference"`
+}
+
+type manifestSigRef struct {
+	URI      string `xml:"URI,attr"`
+	MimeType string `xml:"MimeType,attr"`
+}
+
+type manifestDataRef struct {
+	URI          string         `xml:"URI,attr"`
+	MimeType     string         `xml:"MimeType,attr,omitempty"`
+	DigestMethod manifestMethod `xml:"ds:DigestMethod"`
+	DigestValue  string         `xml:"ds:DigestValue"`
+}
+
+type manifestMethod struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+
+// Create packs files into an ASiC-E container signed with the key, certificate
+// chain, TSA and revocation options of data
+func Create(files []File, data sign.SignData) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, errors.New("asic: no files")
+	}
+
+	m := manifest{
+		XMLNSASiC: namespaceASiC,
+		XMLNSDSig: namespaceDSig,
+		SigRef:    manifestSigRef{URI: EntrySignature, MimeType: cms.MimeTypeDetached},
+	}
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if err := checkName(f.Name); err != nil {
+			return nil, err
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("asic: duplicate file %q", f.Name)
+		}
+		seen[f.Name] = true
+
+		sum := sha256.Sum256(f.Data)
+		m.References = append(m.References, manifestDataRef{
+			URI:          f.Name,
+			MimeType:     f.MimeType,
+			DigestMethod: manifestMethod{Algorithm: digestSHA256URI},
+			DigestValue:  base64.StdEncoding.EncodeToString(sum[:]),
+		})
+	}
+
+	manifestXML, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("asic: manifest: %w", err)
+	}
+	manifestXML = append([]byte(xml.Header), manifestXML...)
+
+	signature, err := cms.Sign(manifestXML, data, cms.Options{Detached: true})
+	if err != nil {
+		return nil, fmt.Errorf("asic: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	// The mimetype entry comes first and is stored uncompressed, so the type
+	// of the container can be read at a fixed offset
+	mw, err := w.CreateHeader(&zip.FileHeader{Name: EntryMimeType, Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mw.Write([]byte(MimeTypeASiCE)); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := writeEntry(w, f.Name, f.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeEntry(w, EntryManifest, manifestXML); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(w, EntrySignature, signature); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeEntry(w *zip.Writer, name string, data []byte) error {
+	fw, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// checkName rejects names that aren't clean relative paths or that collide
+// with the entries describing the container
+func checkName(name string) error {
+	switch {
+	case name == "" || name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") || name == "..":
+		return fmt.Errorf("asic: invalid file name %q", name)
+	case name == EntryMimeType || strings.HasPrefix(name, "META-INF/"):
+		return fmt.Errorf("asic: reserved file name %q", name)
+	}
+	return nil
+}
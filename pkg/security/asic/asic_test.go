@@ -0,0 +1,229 @@
+package asic
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+func TestCreateVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustCert := func(keyID []byte) []*verify.TrustAnchor {
+		if bytes.Equal(keyID, cert.SubjectKeyId) {
+			return []*verify.TrustAnchor{{Source: "test", Name: "Signer", Certificate: cert}}
+		}
+		return nil
+	}
+
+	files := []File{
+		{Name: "document.pdf", MimeType: "application/pdf", Data: []byte("%PDF-1.7 document")},
+		{Name: "evidence.json", MimeType: "application/json", Data: []byte(`{"submission_id":"42"}`)},
+	}
+	container, err := Create(files, sign.SignData{Signer: key, Certificate: cert})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		zr, err := zip.NewReader(bytes.NewReader(container), int64(len(container)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first := zr.File[0]; first.Name != EntryMimeType || first.Method != zip.Store {
+			t.Fatalf("first entry %s, method %d", first.Name, first.Method)
+		}
+
+		r, err := Verify(container, trustCert)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if r.Indication != verify.IndicationTotalPassed || len(r.Errors) > 0 {
+			t.Fatalf("indication %s, errors %v, signature %+v", r.Indication, r.Errors, r.Signature.Signatures)
+		}
+		if len(r.Files) != len(files) || !r.Files[0].Valid || r.Files[0].Name != "document.pdf" || r.Files[1].MimeType != "application/json" {
+			t.Errorf("files %+v", r.Files)
+		}
+		if len(r.Unsigned) > 0 {
+			t.Errorf("unsigned %v", r.Unsigned)
+		}
+	})
+
+	t.Run("modified file", func(t *testing.T) {
+		tampered := rewrite(t, container, "document.pdf", []byte("%PDF-1.7 forged"), "")
+		r, err := Verify(tampered, trustCert)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if r.Indication != verify.IndicationTotalFailed || r.Files[0].Valid || len(r.Errors) != 1 {
+			t.Fatalf("indication %s, files %+v, errors %v", r.Indication, r.Files, r.Errors)
+		}
+	})
+
+	t.Run("unsigned file", func(t *testing.T) {
+		extended := rewrite(t, container, "", nil, "extra.txt")
+		r, err := Verify(extended, trustCert)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if r.Indication != verify.IndicationTotalPassed || len(r.Unsigned) != 1 || r.Unsigned[0] != "extra.txt" {
+			t.Fatalf("indication %s, unsigned %v", r.Indication, r.Unsigned)
+		}
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		for _, name := range []string{"", "/abs.pdf", "../up.pdf", "a//b.pdf", EntryMimeType, "META-INF/other.xml"} {
+			if _, err := Create([]File{{Name: name, Data: []byte("x")}}, sign.SignData{Signer: key, Certificate: cert}); err == nil {
+				t.Errorf("Create(%q) succeeded", name)
+			}
+		}
+		dup := []File{{Name: "a.txt"}, {Name: "a.txt"}}
+		if _, err := Create(dup, sign.SignData{Signer: key, Certificate: cert}); err == nil {
+			t.Error("Create() with duplicate names succeeded")
+		}
+	})
+
+	t.Run("not a container", func(t *testing.T) {
+		if _, err := Verify([]byte("%PDF-1.7"), nil); !errors.Is(err, ErrNotContainer) {
+			t.Fatalf("Verify() error = %v, want %v", err, ErrNotContainer)
+		}
+	})
+
+	t.Run("mimetype entry", func(t *testing.T) {
+		tests := map[string][]byte{
+			"missing":    repack(t, container, nil, false),
+			"not first":  repack(t, container, &zip.FileHeader{Name: EntryMimeType, Method: zip.Store}, true),
+			"compressed": repack(t, container, &zip.FileHeader{Name: EntryMimeType, Method: zip.Deflate}, false),
+		}
+		for name, c := range tests {
+			if _, err := Verify(c, trustCert); !errors.Is(err, ErrNotContainer) {
+				t.Errorf("%s: Verify() error = %v, want %v", name, err, ErrNotContainer)
+			}
+		}
+		if _, err := Verify(repack(t, container, &zip.FileHeader{Name: EntryMimeType, Method: zip.Store}, false), trustCert); err != nil {
+			t.Fatalf("Verify() of the repacked container error: %v", err)
+		}
+	})
+}
+
+// rewrite copies a container, replacing the data of the entry replace and
+// appending an entry named add
+func rewrite(t *testing.T, container []byte, replace string, data []byte, add string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(container), int64(len(container)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		content := data
+		if f.Name != replace {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+	}
+	if add != "" {
+		fw, err := w.Create(add)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte("unsigned"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// repack copies a container, writing its mimetype entry with header first, or
+// last when last is set, and leaving it out when header is nil
+func repack(t *testing.T, container []byte, header *zip.FileHeader, last bool) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(container), int64(len(container)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	writeMimeType := func() {
+		if header == nil {
+			return
+		}
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(MimeTypeASiCE))
+	}
+	if !last {
+		writeMimeType()
+	}
+	for _, f := range zr.File {
+		if f.Name == EntryMimeType {
+			continue
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(fw, rc); err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+	}
+	if last {
+		writeMimeType()
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,187 @@
+package asic
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	_ "crypto/sha512" // digest algorithms of manifests
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shurco/gosign/pkg/pdf/verify"
+	"github.com/shurco/gosign/pkg/security/cms"
+)
+
+// maxContentSize bounds the uncompressed size of the entries of a container
+const maxContentSize = 512 << 20
+
+var (
+	ErrNotContainer = errors.New("asic: not an ASiC-E container")
+	ErrNoManifest   = errors.New("asic: the container has no ASiC manifest")
+)
+
+var digestMethods = map[string]crypto.Hash{
+	digestSHA256URI: crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+// Result is the verification result of an ASiC-E container
+type Result struct {
+	Indication string       `json:"indication"` // of the signature, TOTAL-FAILED when a file doesn't match the manifest
+	Files      []FileResult `json:"files"`
+	Unsigned   []string     `json:"unsigned,omitempty"` // files the manifest doesn't cover
+	Errors     []string     `json:"errors,omitempty"`
+	Signature  *cms.Result  `json:"signature"`
+}
+
+// FileResult is a file referenced by the manifest
+type FileResult struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Valid    bool   `json:"valid"` // present and matching the digest of the manifest
+}
+
+// manifestXML reads ASiCManifest regardless of namespace prefixes
+type manifestXML struct {
+	SigRef struct {
+		URI string `xml:"URI,attr"`
+	} `xml:"SigReference"`
+	References []struct {
+		URI          string `xml:"URI,attr"`
+		MimeType     string `xml:"MimeType,attr"`
+		DigestMethod struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"DigestMethod"`
+		DigestValue string `xml:"DigestValue"`
+	} `xml:"DataObjectReference"`
+}
+
+// Verify opens an ASiC-E container and verifies its CAdES signature and the
+// files its manifest covers. trust decides which chains are anchored; without
+// it no chain is.
+func Verify(container []byte, trust verify.TrustFunc) (*Result, error) {
+	zr, err := zip.NewReader(bytes.NewReader(container), int64(len(container)))
+	if err != nil {
+		return nil, ErrNotContainer
+	}
+
+	// EN 319 162-1 requires the mimetype entry first and stored, so the type can be
+	// read at a fixed offset
+	if len(zr.File) == 0 || zr.File[0].Name != EntryMimeType || zr.File[0].Method != zip.Store {
+		return nil, fmt.Errorf("%w: mimetype must be the first, uncompressed entry", ErrNotContainer)
+	}
+
+	entries := make(map[string][]byte, len(zr.File))
+	var names []string
+	budget := int64(maxContentSize)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readEntry(f, budget)
+		if err != nil {
+			return nil, err
+		}
+		budget -= int64(len(data))
+		entries[f.Name] = data
+		names = append(names, f.Name)
+	}
+	if strings.TrimSpace(string(entries[EntryMimeType])) != MimeTypeASiCE {
+		return nil, ErrNotContainer
+	}
+
+	manifestName := ""
+	for _, name := range names {
+		if strings.HasPrefix(name, "META-INF/ASiCManifest") && strings.HasSuffix(name, ".xml") {
+			manifestName = name
+			break
+		}
+	}
+	if manifestName == "" {
+		return nil, ErrNoManifest
+	}
+	manifestData := entries[manifestName]
+	if bytes.Contains(manifestData, []byte("<!DOCTYPE")) {
+		return nil, errors.New("asic: DTDs are not supported")
+	}
+	var m manifestXML
+	if err := xml.Unmarshal(manifestData, &m); err != nil {
+		return nil, fmt.Errorf("asic: manifest: %w", err)
+	}
+
+	signature, ok := entries[m.SigRef.URI]
+	if !ok {
+		return nil, fmt.Errorf("asic: signature %q not found", m.SigRef.URI)
+	}
+	sigResult, err := cms.Verify(signature, manifestData, trust)
+	if err != nil {
+		return nil, fmt.Errorf("asic: %w", err)
+	}
+
+	result := &Result{Indication: sigResult.Indication, Files: []FileResult{}, Signature: sigResult}
+	covered := map[string]bool{}
+	for _, ref := range m.References {
+		covered[ref.URI] = true
+		fr := FileResult{Name: ref.URI, MimeType: ref.MimeType}
+		data, ok := entries[ref.URI]
+		hash, known := digestMethods[ref.DigestMethod.Algorithm]
+		switch {
+		case !ok:
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: missing", ref.URI))
+		case !known:
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: unsupported digest method %s", ref.URI, ref.DigestMethod.Algorithm))
+		default:
+			fr.Size = int64(len(data))
+			h := hash.New()
+			h.Write(data)
+			digest := h.Sum(nil)
+			if hash == crypto.SHA256 {
+				fr.SHA256 = hex.EncodeToString(digest)
+			}
+			fr.Valid = strings.TrimSpace(ref.DigestValue) == base64.StdEncoding.EncodeToString(digest)
+			if !fr.Valid {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: digest mismatch", ref.URI))
+			}
+		}
+		result.Files = append(result.Files, fr)
+	}
+	if len(result.Errors) > 0 || len(result.Files) == 0 {
+		result.Indication = verify.IndicationTotalFailed
+	}
+
+	for _, name := range names {
+		if name != EntryMimeType && !strings.HasPrefix(name, "META-INF/") && !covered[name] {
+			result.Unsigned = append(result.Unsigned, name)
+		}
+	}
+	return result, nil
+}
+
+// readEntry reads an entry of at most limit bytes; the size in the header
+// isn't trusted
+func readEntry(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, errors.New("asic: the container is too large")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("asic: %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("asic: %s: %w", f.Name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errors.New("asic: the container is too large")
+	}
+	return data, nil
+}
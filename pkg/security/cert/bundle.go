@@ -0,0 +1,193 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	// ErrNoPrivateKey is returned when the input holds no usable private key.
+	ErrNoPrivateKey = errors.New("no private key found")
+	// ErrNoCertificate is returned when the input holds no certificate.
+	ErrNoCertificate = errors.New("no certificate found")
+	// ErrKeyMismatch is returned when no certificate matches the private key.
+	ErrKeyMismatch = errors.New("private key does not match certificate")
+)
+
+// Bundle hold a private key, its certificate and the issuer chain
+type Bundle struct {
+	PrivateKey  crypto.Signer
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+}
+
+// ParsePKCS12 returns bundle decoded from PKCS#12 (.p12/.pfx) data
+func ParsePKCS12(data []byte, password string) (*Bundle, error) {
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	b := &Bundle{PrivateKey: signer, Certificate: leaf, Chain: chain}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ParsePEMBundle returns bundle from PEM encoded key and certificates.
+// Certificates may be split between certPEM and chainPEM in any order; the
+// certificate matching the key becomes the leaf, the rest is the chain.
+func ParsePEMBundle(certPEM, keyPEM, chainPEM []byte) (*Bundle, error) {
+	signer, err := ParseAnyPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := ParseCertificates(append(append([]byte{}, certPEM...), chainPEM...))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{PrivateKey: signer}
+	for _, c := range certs {
+		if b.Certificate == nil && publicKeyEqual(c.PublicKey, signer.Public()) {
+			b.Certificate = c
+			continue
+		}
+		b.Chain = append(b.Chain, c)
+	}
+	if b.Certificate == nil {
+		return nil, ErrKeyMismatch
+	}
+	return b, nil
+}
+
+// ParseCertificates returns all certificates from PEM data
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return certs, nil
+}
+
+// ParseAnyPrivateKey returns first PEM private key in PKCS#8, PKCS#1 or SEC 1 form
+func ParseAnyPrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, ErrNoPrivateKey
+		}
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, errors.New("encrypted PEM private keys are not supported, upload PKCS#12 instead")
+		}
+		if signer, err := parsePrivateKeyDER(block.Bytes); err == nil {
+			return signer, nil
+		}
+	}
+}
+
+func parsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, ErrNoPrivateKey
+}
+
+// MarshalPrivateKey returns private key in PKCS#8 DER form
+func MarshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	return x509.MarshalPKCS8PrivateKey(key)
+}
+
+// UnmarshalPrivateKey returns private key from PKCS#8 DER form
+func UnmarshalPrivateKey(der []byte) (crypto.Signer, error) {
+	return parsePrivateKeyDER(der)
+}
+
+// KeyAlgorithm returns short description of the key type, e.g. "ECDSA P-256" or "RSA 2048"
+func KeyAlgorithm(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// Validate checks that the private key belongs to the certificate
+func (b *Bundle) Validate() error {
+	if b.PrivateKey == nil {
+		return ErrNoPrivateKey
+	}
+	if b.Certificate == nil {
+		return ErrNoCertificate
+	}
+	if !publicKeyEqual(b.Certificate.PublicKey, b.PrivateKey.Public()) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// ChainPEM returns the chain certificates PEM encoded
+func (b *Bundle) ChainPEM() string {
+	var w bytes.Buffer
+	for _, c := range b.Chain {
+		_ = pem.Encode(&w, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return w.String()
+}
+
+// CertificatePEM returns the leaf certificate PEM encoded
+func (b *Bundle) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b.Certificate.Raw}))
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
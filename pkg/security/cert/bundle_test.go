@@ -0,0 +1,124 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func testChain(t *testing.T) (*PrivateKey, *Result, *Result) {
+	t.Helper()
+
+	caKey, err := GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := Certificate{
+		Subject:   pkix.Name{CommonName: "Test CA"},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+		IsCA:      true,
+		KeyUsage:  x509.KeyUsageCertSign,
+	}
+	caRes, err := ca.GetCertificate(caKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caRes.ByteCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userKey, err := GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := Certificate{
+		Subject:          pkix.Name{CommonName: "Signer"},
+		NotBefore:        time.Now(),
+		NotAfter:         time.Now().Add(time.Hour),
+		KeyUsage:         x509.KeyUsageDigitalSignature,
+		Parent:           caCert,
+		ParentPrivateKey: caKey.PrivateKey,
+	}
+	userRes, err := user.GetCertificate(userKey.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userKey, userRes, caRes
+}
+
+func TestParsePEMBundle(t *testing.T) {
+	userKey, userRes, caRes := testChain(t)
+
+	t.Run("chain before leaf", func(t *testing.T) {
+		b, err := ParsePEMBundle([]byte(caRes.String()+userRes.String()), []byte(userKey.String()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Certificate.Subject.CommonName != "Signer" {
+			t.Fatalf("leaf = %s, want Signer", b.Certificate.Subject.CommonName)
+		}
+		if len(b.Chain) != 1 || b.Chain[0].Subject.CommonName != "Test CA" {
+			t.Fatalf("unexpected chain: %v", b.Chain)
+		}
+	})
+
+	t.Run("key mismatch", func(t *testing.T) {
+		other, _ := GetPrivateKey()
+		_, err := ParsePEMBundle([]byte(userRes.String()), []byte(other.String()), nil)
+		if !errors.Is(err, ErrKeyMismatch) {
+			t.Fatalf("err = %v, want ErrKeyMismatch", err)
+		}
+	})
+
+	t.Run("PKCS#1 RSA key", func(t *testing.T) {
+		k, err := ParseAnyPrivateKey([]byte(RSAPKEYDATA))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := KeyAlgorithm(k.Public()); got != "RSA 512" {
+			t.Fatalf("KeyAlgorithm() = %s", got)
+		}
+	})
+}
+
+func TestParsePKCS12(t *testing.T) {
+	userKey, userRes, caRes := testChain(t)
+	leaf, _ := x509.ParseCertificate(userRes.ByteCert)
+	ca, _ := x509.ParseCertificate(caRes.ByteCert)
+
+	pfx, err := pkcs12.Modern.Encode(userKey.PrivateKey, leaf, []*x509.Certificate{ca}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ParsePKCS12(pfx, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Chain) != 1 {
+		t.Fatalf("chain length = %d, want 1", len(b.Chain))
+	}
+
+	der, err := MarshalPrivateKey(b.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := UnmarshalPrivateKey(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !publicKeyEqual(k.Public(), leaf.PublicKey) {
+		t.Fatal("round-tripped key does not match certificate")
+	}
+
+	if _, err := ParsePKCS12(pfx, "wrong"); err == nil {
+		t.Fatal("expected error for wrong password")
+	}
+}
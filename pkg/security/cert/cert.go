@@ -2,7 +2,9 @@ package cert
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -27,6 +29,7 @@ type Certificate struct {
 	NotAfter         time.Time
 	IPAddress        []net.IP
 	DNSNames         []string
+	EmailAddresses   []string
 	IsCA             bool
 	Parent           *x509.Certificate
 	ParentPrivateKey any
@@ -34,6 +37,15 @@ type Certificate struct {
 	ExtentedKeyUsage []x509.ExtKeyUsage
 	SubjectKeyId     []byte
 	AuthorityKeyId   []byte
+
+	CRLDistributionPoints []string
+	IssuingCertificateURL []string
+	OCSPServer            []string
+	ExtraExtensions       []pkix.Extension
+
+	// SignatureAlgorithm used by the issuer, e.g. x509.SHA256WithRSAPSS.
+	// Zero selects the default for the issuer key.
+	SignatureAlgorithm x509.SignatureAlgorithm
 }
 
 // Result hold created certificate in []byte format
@@ -64,14 +76,21 @@ func (c *Certificate) SetTemplate() x509.Certificate {
 		IsCA:                  c.IsCA,
 		IPAddresses:           c.IPAddress,
 		DNSNames:              c.DNSNames,
+		EmailAddresses:        c.EmailAddresses,
 		BasicConstraintsValid: true,
 		SubjectKeyId:          c.SubjectKeyId,
 		AuthorityKeyId:        c.AuthorityKeyId,
+		CRLDistributionPoints: c.CRLDistributionPoints,
+		IssuingCertificateURL: c.IssuingCertificateURL,
+		OCSPServer:            c.OCSPServer,
+		ExtraExtensions:       c.ExtraExtensions,
+		SignatureAlgorithm:    c.SignatureAlgorithm,
 	}
 }
 
-// GetCertificate generate certificate and returns it in Result struct
-func (c *Certificate) GetCertificate(pkey *ecdsa.PrivateKey) (*Result, error) {
+// GetCertificate generate certificate for the public key of pkey and returns it
+// in Result struct. Without a parent the certificate is self-signed by pkey.
+func (c *Certificate) GetCertificate(pkey crypto.Signer) (*Result, error) {
 	serial, err := GetSerial()
 	if err != nil {
 		return nil, err
@@ -88,7 +107,7 @@ func (c *Certificate) GetCertificate(pkey *ecdsa.PrivateKey) (*Result, error) {
 		c.ParentPrivateKey = pkey
 	}
 
-	der, err := x509.CreateCertificate(rand.Reader, &template, c.Parent, &pkey.PublicKey, c.ParentPrivateKey)
+	der, err := x509.CreateCertificate(rand.Reader, &template, c.Parent, pkey.Public(), c.ParentPrivateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +178,12 @@ func CertInfo(cert *x509.Certificate) string {
 		}
 	}
 
+	if cert.PublicKeyAlgorithm == x509.Ed25519 {
+		if edkey, ok := cert.PublicKey.(ed25519.PublicKey); ok {
+			buf.WriteString(fmt.Sprintf("%16sED25519 Public-Key: (%d bit)\n", "", len(edkey)*8))
+		}
+	}
+
 	buf.WriteString(fmt.Sprintf("%8sX509v3 extensions:\n", ""))
 	if len(parseExtKeyUsage(cert.ExtKeyUsage)) != 0 {
 		buf.WriteString(fmt.Sprintf("%12sX509v3 Extended Key Usage:\n", ""))
@@ -2,7 +2,7 @@ package cert
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -19,7 +19,7 @@ type CertRevocationList struct {
 }
 
 // CreateCRL Create certificate revocation list
-func CreateCRL(pkey *ecdsa.PrivateKey, caCert *x509.Certificate, crl *x509.RevocationList, nextUpdate time.Time) (*CertRevocationList, *big.Int, error) {
+func CreateCRL(pkey crypto.Signer, caCert *x509.Certificate, crl *x509.RevocationList, nextUpdate time.Time) (*CertRevocationList, *big.Int, error) {
 	crlNumber := time.Now().UTC().Format("20060102150405")
 	num, _ := big.NewInt(0).SetString(crlNumber, 10)
 
@@ -63,7 +63,7 @@ func ParseCRL(crl []byte) (*x509.RevocationList, error) {
 	return x509.ParseRevocationList(c.Bytes)
 }
 
-func RevokeCertificate(crl []byte, cert *x509.Certificate, caCert *x509.Certificate, pkey *ecdsa.PrivateKey, nextUpdate time.Time) (*CertRevocationList, *big.Int, error) {
+func RevokeCertificate(crl []byte, cert *x509.Certificate, caCert *x509.Certificate, pkey crypto.Signer, nextUpdate time.Time) (*CertRevocationList, *big.Int, error) {
 	crlF, err := ParseCRL(crl)
 	if err != nil {
 		return nil, nil, err
@@ -43,7 +43,7 @@ func TestCreateCRL(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	b, err := x509.MarshalPKIXPublicKey(&pkey.PublicKey)
+	b, err := x509.MarshalPKIXPublicKey(pkey.PrivateKey.Public())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,73 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+)
+
+// ErrDecodeCSR is returned when the input is not a PEM-encoded certificate request.
+var ErrDecodeCSR = errors.New("can't decode certificate request")
+
+// CertificateRequest hold certificate signing request information
+type CertificateRequest struct {
+	Subject            pkix.Name
+	EmailAddresses     []string
+	DNSNames           []string
+	SignatureAlgorithm x509.SignatureAlgorithm // zero selects the default for the key
+}
+
+// CSR hold created PKCS#10 certificate signing request
+type CSR struct {
+	Byte []byte
+}
+
+// GetCSR creates certificate signing request signed by pkey
+func (r *CertificateRequest) GetCSR(pkey crypto.Signer) (*CSR, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:            r.Subject,
+		EmailAddresses:     r.EmailAddresses,
+		DNSNames:           r.DNSNames,
+		SignatureAlgorithm: r.SignatureAlgorithm,
+	}, pkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSR{Byte: der}, nil
+}
+
+// String returns certificate signing request in pem encoded format
+func (c *CSR) String() string {
+	var w bytes.Buffer
+	if err := pem.Encode(&w, &pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: c.Byte,
+	}); err != nil {
+		return ""
+	}
+
+	return w.String()
+}
+
+// ParseCSR returns parsed certificate request after checking its signature
+func ParseCSR(csr []byte) (*x509.CertificateRequest, error) {
+	p, _ := pem.Decode(csr)
+	if p == nil || p.Type != "CERTIFICATE REQUEST" {
+		return nil, ErrDecodeCSR
+	}
+
+	r, err := x509.ParseCertificateRequest(p.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.CheckSignature(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,47 @@
+package cert
+
+import (
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+)
+
+func TestGetCSR(t *testing.T) {
+	for _, keyType := range []KeyType{KeyTypeECDSAP384, KeyTypeRSA2048, KeyTypeEd25519} {
+		t.Run(string(keyType), func(t *testing.T) {
+			p, err := GeneratePrivateKey(keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := CertificateRequest{
+				Subject:        pkix.Name{CommonName: "Jane Signer"},
+				EmailAddresses: []string{"jane@example.com"},
+			}
+			csr, err := req.GetCSR(p.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ParseCSR([]byte(csr.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Subject.CommonName != "Jane Signer" || parsed.EmailAddresses[0] != "jane@example.com" {
+				t.Fatalf("unexpected request %v %v", parsed.Subject, parsed.EmailAddresses)
+			}
+			if !publicKeyEqual(parsed.PublicKey, p.PrivateKey.Public()) {
+				t.Fatal("request public key doesn't match")
+			}
+		})
+	}
+}
+
+func TestParseCSRInvalid(t *testing.T) {
+	if _, err := ParseCSR([]byte("")); !errors.Is(err, ErrDecodeCSR) {
+		t.Fatalf("got %v, want %v", err, ErrDecodeCSR)
+	}
+	if _, err := ParseCSR([]byte(PKEYDATA)); !errors.Is(err, ErrDecodeCSR) {
+		t.Fatalf("got %v, want %v", err, ErrDecodeCSR)
+	}
+}
@@ -2,22 +2,67 @@ package cert
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 )
 
+// KeyType is the algorithm and size of a generated private key
+type KeyType string
+
+// Supported key types
+const (
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+	KeyTypeECDSAP384 KeyType = "ecdsa-p384"
+	KeyTypeRSA2048   KeyType = "rsa-2048"
+	KeyTypeRSA3072   KeyType = "rsa-3072"
+	KeyTypeRSA4096   KeyType = "rsa-4096"
+	KeyTypeEd25519   KeyType = "ed25519"
+)
+
+// ErrUnsupportedKeyType is returned for unknown key types
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
 // PrivateKey hold private key
 type PrivateKey struct {
-	*ecdsa.PrivateKey
+	PrivateKey crypto.Signer
 }
 
-// GetPrivateKey returns struct PrivateKey containing the private key
+// GetPrivateKey returns struct PrivateKey containing an ECDSA P-256 private key
 func GetPrivateKey() (*PrivateKey, error) {
-	pkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return GeneratePrivateKey(KeyTypeECDSAP256)
+}
+
+// GeneratePrivateKey returns struct PrivateKey containing a new private key of
+// the given type; an empty type is ECDSA P-256
+func GeneratePrivateKey(keyType KeyType) (*PrivateKey, error) {
+	var (
+		pkey crypto.Signer
+		err  error
+	)
+	switch keyType {
+	case KeyTypeECDSAP256, "":
+		pkey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeECDSAP384:
+		pkey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyTypeRSA2048:
+		pkey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA3072:
+		pkey, err = rsa.GenerateKey(rand.Reader, 3072)
+	case KeyTypeRSA4096:
+		pkey, err = rsa.GenerateKey(rand.Reader, 4096)
+	case KeyTypeEd25519:
+		_, pkey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return &PrivateKey{}, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, keyType)
+	}
 	if err != nil {
 		return &PrivateKey{}, err
 	}
@@ -27,35 +72,67 @@ func GetPrivateKey() (*PrivateKey, error) {
 	}, nil
 }
 
-// String returns string of private key in pem encoded format
+// ParseKeyType returns the key type for its name, e.g. "rsa-3072"
+func ParseKeyType(s string) (KeyType, error) {
+	switch t := KeyType(s); t {
+	case KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeRSA2048, KeyTypeRSA3072, KeyTypeRSA4096, KeyTypeEd25519:
+		return t, nil
+	case "":
+		return KeyTypeECDSAP256, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedKeyType, s)
+}
+
+// String returns string of private key in pem encoded format: SEC 1 for ECDSA,
+// PKCS#1 for RSA and PKCS#8 for Ed25519 keys
 func (p *PrivateKey) String() string {
-	b, err := x509.MarshalECPrivateKey(p.PrivateKey)
+	var (
+		block = &pem.Block{}
+		err   error
+	)
+	switch k := p.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		block.Type = "EC PRIVATE KEY"
+		block.Bytes, err = x509.MarshalECPrivateKey(k)
+	case *rsa.PrivateKey:
+		block.Type = "RSA PRIVATE KEY"
+		block.Bytes = x509.MarshalPKCS1PrivateKey(k)
+	default:
+		block.Type = "PRIVATE KEY"
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(k)
+	}
 	if err != nil {
 		return ""
 	}
 
 	var w bytes.Buffer
-	if err := pem.Encode(&w, &pem.Block{
-		Type:  "EC PRIVATE KEY",
-		Bytes: b,
-	}); err != nil {
+	if err := pem.Encode(&w, block); err != nil {
 		return ""
 	}
 
 	return w.String()
 }
 
-// ParsePrivatekey parse given []byte private key to struct *ecdsa.PrivateKey
-func ParsePrivateKey(pkey []byte) (*ecdsa.PrivateKey, error) {
+// ParsePrivateKey parse given PEM private key in PKCS#8, PKCS#1 or SEC 1 form
+func ParsePrivateKey(pkey []byte) (crypto.Signer, error) {
 	b, _ := pem.Decode(pkey)
 	if b == nil {
-		return &ecdsa.PrivateKey{}, fmt.Errorf("no pem data found")
+		return nil, fmt.Errorf("no pem data found")
 	}
 
-	u, err := x509.ParseECPrivateKey(b.Bytes)
+	u, err := parsePrivateKeyDER(b.Bytes)
 	if err != nil {
-		return &ecdsa.PrivateKey{}, err
+		return nil, err
 	}
 
 	return u, nil
 }
+
+// IsPSS reports whether alg is an RSASSA-PSS signature algorithm
+func IsPSS(alg x509.SignatureAlgorithm) bool {
+	switch alg {
+	case x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+		return true
+	}
+	return false
+}
@@ -1,9 +1,14 @@
 package cert
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -53,14 +58,100 @@ func TestParsePrivateKey(t *testing.T) {
 		}
 	})
 
-	t.Run("Test parsing unsuported rsa private key", func(t *testing.T) {
-		_, err := ParsePrivateKey([]byte(RSAPKEYDATA))
-		if err == nil {
-			t.Fatalf("got no error, want error contains x509: failed to parse private key (use ParsePKCS1PrivateKey instead for this key format")
+	t.Run("Test parsing pkcs1 rsa private key", func(t *testing.T) {
+		p, err := ParsePrivateKey([]byte(RSAPKEYDATA))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := p.(*rsa.PrivateKey); !ok {
+			t.Fatalf("got %T, want *rsa.PrivateKey", p)
+		}
+	})
+}
+
+func TestGeneratePrivateKey(t *testing.T) {
+	tests := []struct {
+		keyType   KeyType
+		algorithm string
+		pemType   string
+	}{
+		{KeyTypeECDSAP256, "ECDSA P-256", "EC PRIVATE KEY"},
+		{KeyTypeECDSAP384, "ECDSA P-384", "EC PRIVATE KEY"},
+		{KeyTypeRSA2048, "RSA 2048", "RSA PRIVATE KEY"},
+		{KeyTypeEd25519, "Ed25519", "PRIVATE KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.keyType), func(t *testing.T) {
+			p, err := GeneratePrivateKey(tt.keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := KeyAlgorithm(p.PrivateKey.Public()); got != tt.algorithm {
+				t.Fatalf("got %v, want %v", got, tt.algorithm)
+			}
+			if !strings.HasPrefix(p.String(), "-----BEGIN "+tt.pemType+"-----") {
+				t.Fatalf("unexpected pem encoding %q", p.String())
+			}
+
+			parsed, err := ParsePrivateKey([]byte(p.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !publicKeyEqual(parsed.Public(), p.PrivateKey.Public()) {
+				t.Fatal("parsed key doesn't match generated key")
+			}
+
+			// Self-signed certificate with the key
+			res, err := (&Certificate{
+				Subject:   pkix.Name{CommonName: "cert"},
+				NotBefore: time.Now(),
+				NotAfter:  time.Now().Add(time.Hour),
+			}).GetCertificate(p.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := ParseCertificate([]byte(res.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("Test unsupported key type", func(t *testing.T) {
+		if _, err := GeneratePrivateKey("dsa-1024"); !errors.Is(err, ErrUnsupportedKeyType) {
+			t.Fatalf("got %v, want %v", err, ErrUnsupportedKeyType)
 		}
 	})
 }
 
+func TestRSAPSSCertificate(t *testing.T) {
+	p, err := GeneratePrivateKey(KeyTypeRSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := (&Certificate{
+		Subject:            pkix.Name{CommonName: "cert"},
+		NotBefore:          time.Now(),
+		NotAfter:           time.Now().Add(time.Hour),
+		SignatureAlgorithm: x509.SHA256WithRSAPSS,
+	}).GetCertificate(p.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(res.ByteCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPSS(c.SignatureAlgorithm) {
+		t.Fatalf("got %v, want RSASSA-PSS", c.SignatureAlgorithm)
+	}
+}
+
 func TestParseEmptyPrivateKeyFile(t *testing.T) {
 	_, err := ParsePrivateKey([]byte(""))
 	if err != nil {
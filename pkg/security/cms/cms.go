@@ -0,0 +1,120 @@
+// Package cms signs and verifies arbitrary files (XML, ZIP, images, ...) with
+// CMS signatures (RFC 5652) of the CAdES baseline profile (ETSI EN 319 122-1).
+//
+// A signature is either detached (.p7s), kept next to the file it signs, or
+// enveloping (.p7m), carrying the file. Signatures are made with the key,
+// certificate chain, TSA and revocation options of sign.SignData: every
+// signature is CAdES-B-B, and CAdES-B-T when a TSA is set. Revocation data is
+// embedded in the revocationValues unsigned attribute.
+package cms
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/digitorus/pkcs7"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+)
+
+// File extensions and MIME types of CMS signatures
+const (
+	ExtDetached      = ".p7s"
+	ExtEnveloping    = ".p7m"
+	MimeTypeDetached = "application/pkcs7-signature"
+	MimeTypeEnvelope = "application/pkcs7-mime"
+)
+
+var (
+	// id-aa-ets-revocationValues (RFC 5126, 6.3.4)
+	oidRevocationValues = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 24}
+	// id-pkix-ocsp-basic (RFC 6960, 4.2.1)
+	oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+)
+
+// Options of a signature
+type Options struct {
+	// Detached leaves the content out of the signature (.p7s); otherwise the
+	// signature envelops it (.p7m)
+	Detached bool
+}
+
+// Sign signs content and returns the DER encoded CMS signature. The revocation
+// data of data, including what RevocationFunction fetches, is embedded.
+func Sign(content []byte, data sign.SignData, opts Options) ([]byte, error) {
+	if err := data.FetchRevocationData(); err != nil {
+		return nil, fmt.Errorf("failed to fetch revocation data: %w", err)
+	}
+
+	var attributes sign.CMSAttributes
+	if len(data.RevocationData.CRL) > 0 || len(data.RevocationData.OCSP) > 0 {
+		values, err := marshalRevocationValues(data.RevocationData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed revocation data: %w", err)
+		}
+		attributes.Unsigned = append(attributes.Unsigned, pkcs7.Attribute{
+			Type:  oidRevocationValues,
+			Value: asn1.RawValue{FullBytes: values},
+		})
+	}
+
+	return sign.SignCMS(content, data, attributes, opts.Detached)
+}
+
+// revocationValues is RevocationValues (RFC 5126, 6.3.4)
+type revocationValues struct {
+	CRLVals  []asn1.RawValue `asn1:"optional,explicit,tag:0"`
+	OCSPVals []asn1.RawValue `asn1:"optional,explicit,tag:1"` // BasicOCSPResponse
+}
+
+// ocspResponse is OCSPResponse (RFC 6960, 4.2.1)
+type ocspResponse struct {
+	Status asn1.Enumerated
+	Bytes  ocspResponseBytes `asn1:"explicit,optional,tag:0"`
+}
+
+type ocspResponseBytes struct {
+	Type     asn1.ObjectIdentifier
+	Response []byte
+}
+
+// marshalRevocationValues converts revocation data to RevocationValues, which
+// holds basic OCSP responses rather than complete ones
+func marshalRevocationValues(info revocation.InfoArchival) ([]byte, error) {
+	values := revocationValues{CRLVals: info.CRL}
+	for _, raw := range info.OCSP {
+		var resp ocspResponse
+		if _, err := asn1.Unmarshal(raw.FullBytes, &resp); err != nil {
+			return nil, fmt.Errorf("ocsp response: %w", err)
+		}
+		if resp.Status != 0 || !resp.Bytes.Type.Equal(oidOCSPBasic) {
+			return nil, errors.New("ocsp response: not a successful basic response")
+		}
+		values.OCSPVals = append(values.OCSPVals, asn1.RawValue{FullBytes: resp.Bytes.Response})
+	}
+	return asn1.Marshal(values)
+}
+
+// unmarshalRevocationValues converts RevocationValues back to revocation data
+func unmarshalRevocationValues(der []byte) (revocation.InfoArchival, error) {
+	var (
+		values revocationValues
+		info   revocation.InfoArchival
+	)
+	if _, err := asn1.Unmarshal(der, &values); err != nil {
+		return info, err
+	}
+	info.CRL = values.CRLVals
+	for _, basic := range values.OCSPVals {
+		resp, err := asn1.Marshal(ocspResponse{Bytes: ocspResponseBytes{Type: oidOCSPBasic, Response: basic.FullBytes}})
+		if err != nil {
+			return info, err
+		}
+		if err := info.AddOCSP(resp); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
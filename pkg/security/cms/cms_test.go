@@ -0,0 +1,200 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/sign"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+var serial int64
+
+func issue(t *testing.T, cn string, key crypto.Signer, parent *testCert, tmpl *x509.Certificate) *testCert {
+	t.Helper()
+	if key == nil {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = k
+	}
+	serial++
+	tmpl.SerialNumber = big.NewInt(serial)
+	tmpl.Subject = pkix.Name{CommonName: cn}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(24 * time.Hour)
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func TestSignVerify(t *testing.T) {
+	ca := issue(t, "Test CA", nil, nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+	leaf := issue(t, "Signer", nil, ca, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature})
+	tsaCert := issue(t, "Test TSA", nil, ca, &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	tsa := sign.TSA{Local: func(request []byte) ([]byte, error) {
+		req, err := timestamp.ParseRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Policy:            []int{1, 2, 3},
+			AddTSACertificate: true,
+		}
+		return ts.CreateResponseWithOpts(tsaCert.cert, tsaCert.key, crypto.SHA256)
+	}}
+	embedOCSP := func(cert, issuer *x509.Certificate, i *revocation.InfoArchival) error {
+		if issuer == nil {
+			return nil
+		}
+		resp, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: cert.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, ca.key)
+		if err != nil {
+			return err
+		}
+		return i.AddOCSP(resp)
+	}
+	trustCA := func(keyID []byte) []*verify.TrustAnchor {
+		if bytes.Equal(keyID, ca.cert.SubjectKeyId) {
+			return []*verify.TrustAnchor{{Source: "test", Name: "Test CA", Certificate: ca.cert}}
+		}
+		return nil
+	}
+
+	content := []byte(`<?xml version="1.0"?><invoice id="42"/>`)
+	signData := sign.SignData{
+		Signer:             leaf.key,
+		Certificate:        leaf.cert,
+		CertificateChains:  [][]*x509.Certificate{{leaf.cert, ca.cert}},
+		TSA:                tsa,
+		RevocationFunction: embedOCSP,
+	}
+
+	detached, err := Sign(content, signData, Options{Detached: true})
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+
+	t.Run("detached", func(t *testing.T) {
+		r, err := Verify(detached, content, trustCA)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if !r.Detached || r.Level != verify.LevelBT || r.Indication != verify.IndicationTotalPassed {
+			t.Fatalf("detached %v, level %s, indication %s %v", r.Detached, r.Level, r.Indication, r.Signatures[0].Errors)
+		}
+		s := r.Signatures[0]
+		if s.SignerName != "Signer" || s.Format != Format || s.TimestampTime == nil || !s.CoversWholeDocument {
+			t.Errorf("signature %+v", s)
+		}
+		if len(s.Chain) < 2 || s.Chain[0].Revocation != "good" || s.Chain[len(s.Chain)-1].TrustAnchor == nil {
+			t.Errorf("chain %+v", s.Chain)
+		}
+		if r.Content.Size != int64(len(content)) {
+			t.Errorf("content size %d", r.Content.Size)
+		}
+	})
+
+	t.Run("PEM", func(t *testing.T) {
+		encoded := pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: detached})
+		if r, err := Verify(encoded, content, nil); err != nil || r.Indication != verify.IndicationIndeterminate {
+			t.Fatalf("Verify() = %+v, %v", r, err)
+		}
+	})
+
+	t.Run("modified content", func(t *testing.T) {
+		r, err := Verify(detached, append(bytes.Clone(content), ' '), trustCA)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if s := r.Signatures[0]; r.Indication != verify.IndicationTotalFailed || s.SubIndication != verify.SubIndicationHashFailure {
+			t.Fatalf("indication %s/%s, want %s/%s", r.Indication, s.SubIndication, verify.IndicationTotalFailed, verify.SubIndicationHashFailure)
+		}
+	})
+
+	t.Run("missing content", func(t *testing.T) {
+		if _, err := Verify(detached, nil, trustCA); !errors.Is(err, ErrNoContent) {
+			t.Fatalf("Verify() error = %v, want %v", err, ErrNoContent)
+		}
+	})
+
+	t.Run("enveloping RSA-PSS", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaLeaf := issue(t, "RSA Signer", key, ca, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature})
+		enveloped, err := Sign(content, sign.SignData{
+			Signer:            key,
+			Certificate:       rsaLeaf.cert,
+			CertificateChains: [][]*x509.Certificate{{rsaLeaf.cert, ca.cert}},
+			RSAPSS:            true,
+		}, Options{})
+		if err != nil {
+			t.Fatalf("Sign() error: %v", err)
+		}
+
+		r, err := Verify(enveloped, nil, trustCA)
+		if err != nil {
+			t.Fatalf("Verify() error: %v", err)
+		}
+		if r.Detached || r.Level != verify.LevelBB || r.Indication != verify.IndicationTotalPassed {
+			t.Fatalf("detached %v, level %s, indication %s %v", r.Detached, r.Level, r.Indication, r.Signatures[0].Errors)
+		}
+		if _, err := Verify(enveloped, []byte("other"), trustCA); !errors.Is(err, ErrContentMismatch) {
+			t.Fatalf("Verify() error = %v, want %v", err, ErrContentMismatch)
+		}
+	})
+
+	t.Run("not a signature", func(t *testing.T) {
+		if _, err := Verify(content, nil, nil); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package cms
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+
+	"github.com/shurco/gosign/pkg/pdf/revocation"
+	"github.com/shurco/gosign/pkg/pdf/verify"
+)
+
+// Format is the signature format reported for CMS signatures
+const Format = "CAdES"
+
+var (
+	ErrInvalidSignature = errors.New("not a CMS signature")
+	ErrNoContent        = errors.New("detached signature: the signed content is required")
+	ErrContentMismatch  = errors.New("the content differs from the content enveloped in the signature")
+)
+
+var (
+	oidSigningCertificate   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+)
+
+// Result is the validation report of a CMS signature in the style of ETSI EN
+// 319 102-1, see verify.Report
+type Result struct {
+	ValidationTime time.Time                `json:"validation_time"`
+	Indication     string                   `json:"indication"`
+	Detached       bool                     `json:"detached"`
+	Level          string                   `json:"level,omitempty"` // CAdES baseline level
+	Content        Content                  `json:"content"`
+	Signatures     []verify.SignatureReport `json:"signatures"`
+}
+
+// Content describes the signed content
+type Content struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Verify verifies a DER or PEM encoded CMS signature. content is the signed
+// file of a detached signature; it may be nil for an enveloping signature, or
+// else must equal the enveloped content. trust decides which chains are
+// anchored; without it no chain is.
+func Verify(signature, content []byte, trust verify.TrustFunc) (*Result, error) {
+	if block, _ := pem.Decode(signature); block != nil {
+		signature = block.Bytes
+	}
+	p7, err := pkcs7.Parse(signature)
+	if err != nil || len(p7.Signers) == 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	result := &Result{
+		ValidationTime: time.Now().UTC(),
+		Detached:       len(p7.Content) == 0,
+	}
+	switch {
+	case result.Detached && content == nil:
+		return nil, ErrNoContent
+	case result.Detached:
+		p7.Content = content
+	case content != nil && !bytes.Equal(content, p7.Content):
+		return nil, ErrContentMismatch
+	}
+	sum := sha256.Sum256(p7.Content)
+	result.Content = Content{Size: int64(len(p7.Content)), SHA256: hex.EncodeToString(sum[:])}
+
+	signer := verify.Signer{
+		SigFormat: Format,
+		ByteRange: []int64{0, result.Content.Size},
+	}
+	if leaf := p7.GetOnlySigner(); leaf != nil {
+		signer.Name = leaf.Subject.CommonName
+	}
+
+	var rev revocation.InfoArchival
+	for _, attr := range p7.Signers[0].UnauthenticatedAttributes {
+		if attr.Type.Equal(oidRevocationValues) {
+			if rev, err = unmarshalRevocationValues(attr.Value.Bytes); err != nil {
+				signer.Error = fmt.Sprintf("invalid revocation values: %v", err)
+			}
+		}
+	}
+	warning := verify.SignedData(p7, rev, &signer)
+	signer.Level = level(p7, signer)
+
+	report := verify.SignerReport(signer, result.ValidationTime, trust)
+	if warning != "" && signer.ValidSignature {
+		report.Errors = append(report.Errors, strings.TrimSpace(warning))
+	}
+	report.ID = "sig-1"
+	report.CoversWholeDocument = signer.ValidSignature
+	result.Indication = report.Indication
+	result.Level = signer.Level
+	result.Signatures = []verify.SignatureReport{report}
+	return result, nil
+}
+
+// level returns the CAdES baseline level of a valid signature: B-B needs the
+// signing certificate attribute, B-T a signature timestamp as well
+func level(p7 *pkcs7.PKCS7, signer verify.Signer) string {
+	if !signer.ValidSignature {
+		return ""
+	}
+	for _, attr := range p7.Signers[0].AuthenticatedAttributes {
+		if attr.Type.Equal(oidSigningCertificateV2) || attr.Type.Equal(oidSigningCertificate) {
+			if signer.TimeStamp != nil {
+				return verify.LevelBT
+			}
+			return verify.LevelBB
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+// Package keystore resolves signing keys that live outside the application host,
+// e.g. on a PKCS#11 token or behind a remote signing service, as crypto.Signer.
+//
+// Keys are addressed by URI: the scheme selects the backend ("pkcs11:" per
+// RFC 7512, "https:" for a remote signing service) and the rest is interpreted
+// by it. The certificate of the key is kept by the caller, so backends only
+// have to produce signatures.
+//
+// Key URIs are chosen by users, so the backends of an owner only open the keys
+// its Policy, set by an administrator, allows.
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownBackend is returned for key URIs no backend is registered for.
+	ErrUnknownBackend = errors.New("no key backend for URI scheme")
+	// ErrKeyNotFound is returned when the backend has no key for the URI.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyMismatch is returned when a key doesn't belong to the expected public key.
+	ErrKeyMismatch = errors.New("key does not match public key")
+	// ErrKeyNotAllowed is returned for keys outside what an administrator configured.
+	ErrKeyNotAllowed = errors.New("key not allowed")
+)
+
+// Backend opens signing keys of one URI scheme.
+type Backend interface {
+	// Signer returns a signer for the key at uri. pub is the public key of the
+	// certificate the key is used with; backends that can't read public keys
+	// report it from Public.
+	Signer(ctx context.Context, uri *url.URL, pub crypto.PublicKey) (crypto.Signer, error)
+}
+
+// Registry maps URI schemes to backends.
+type Registry struct {
+	mu       sync.RWMutex
+	backends map[string]Backend
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{backends: map[string]Backend{}}
+}
+
+// Register makes backend serve key URIs of the scheme
+func (r *Registry) Register(scheme string, backend Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[strings.ToLower(scheme)] = backend
+}
+
+// Signer returns the signer of the key at uri and checks it belongs to pub.
+func (r *Registry) Signer(ctx context.Context, uri string, pub crypto.PublicKey) (crypto.Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key URI: %w", err)
+	}
+
+	r.mu.RLock()
+	backend, ok := r.backends[strings.ToLower(u.Scheme)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, u.Scheme)
+	}
+
+	signer, err := backend.Signer(ctx, u, pub)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKeyEqual(signer.Public(), pub) {
+		return nil, ErrKeyMismatch
+	}
+	return signer, nil
+}
+
+// Probe signs a random digest with signer and verifies the signature against
+// its public key. It catches unreachable keys and keys that don't match the
+// certificate before a document is signed with them.
+func Probe(signer crypto.Signer) error {
+	msg := make([]byte, 32)
+	if _, err := rand.Read(msg); err != nil {
+		return err
+	}
+	digest := sha256.Sum256(msg)
+
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return ErrKeyMismatch
+		}
+	case *ecdsa.PublicKey:
+		sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return ErrKeyMismatch
+		}
+	case ed25519.PublicKey:
+		sig, err := signer.Sign(rand.Reader, msg, crypto.Hash(0))
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(pub, msg, sig) {
+			return ErrKeyMismatch
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
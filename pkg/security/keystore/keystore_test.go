@@ -0,0 +1,44 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+type staticBackend struct {
+	signer crypto.Signer
+}
+
+func (b staticBackend) Signer(context.Context, *url.URL, crypto.PublicKey) (crypto.Signer, error) {
+	return b.signer, nil
+}
+
+func TestRegistry(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	r := NewRegistry()
+	r.Register("TEST", staticBackend{signer: key})
+	ctx := context.Background()
+
+	signer, err := r.Signer(ctx, "test:seal", key.Public())
+	if err != nil {
+		t.Fatalf("Signer() error: %v", err)
+	}
+	if err := Probe(signer); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	if _, err := r.Signer(ctx, "test:seal", other.Public()); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("Signer() with foreign public key error = %v, want %v", err, ErrKeyMismatch)
+	}
+	if _, err := r.Signer(ctx, "vault:seal", key.Public()); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("Signer() with unknown scheme error = %v, want %v", err, ErrUnknownBackend)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build cgo
+
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// PKCS11Backend opens keys of PKCS#11 tokens (pkcs11 URIs) through a single
+// module, e.g. SoftHSM or a network HSM client library. Sessions are kept open
+// per token until Close.
+type PKCS11Backend struct {
+	Module string // path to the PKCS#11 library
+	Pin    string // user PIN, used when the URI carries no pin-value
+
+	mu       sync.Mutex
+	contexts map[string]*crypto11.Context
+}
+
+// Signer implements Backend
+func (b *PKCS11Backend) Signer(_ context.Context, uri *url.URL, _ crypto.PublicKey) (crypto.Signer, error) {
+	p, err := ParsePKCS11URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	// Loading arbitrary libraries named by a key URI would run their code in-process
+	if p.ModulePath != "" && p.ModulePath != b.Module {
+		return nil, fmt.Errorf("PKCS#11 module %s is not configured", p.ModulePath)
+	}
+
+	tokenCtx, err := b.context(p)
+	if err != nil {
+		return nil, err
+	}
+	var label []byte
+	if p.Object != "" {
+		label = []byte(p.Object)
+	}
+	signer, err := tokenCtx.FindKeyPair(p.ID, label)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11: %w", err)
+	}
+	if signer == nil {
+		return nil, ErrKeyNotFound
+	}
+	return signer, nil
+}
+
+func (b *PKCS11Backend) context(p *PKCS11URI) (*crypto11.Context, error) {
+	if b.Module == "" {
+		return nil, errors.New("PKCS#11 module not configured")
+	}
+	pin := b.Pin
+	if p.PinValue != "" {
+		pin = p.PinValue
+	}
+	key := "label:" + p.Token
+	config := &crypto11.Config{Path: b.Module, TokenLabel: p.Token, Pin: pin}
+	if p.Token == "" {
+		key = "serial:" + p.Serial
+		config = &crypto11.Config{Path: b.Module, TokenSerial: p.Serial, Pin: pin}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.contexts[key]; ok {
+		return c, nil
+	}
+	c, err := crypto11.Configure(config)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11: %w", err)
+	}
+	if b.contexts == nil {
+		b.contexts = map[string]*crypto11.Context{}
+	}
+	b.contexts[key] = c
+	return c, nil
+}
+
+// Close logs out of all tokens and unloads the module
+func (b *PKCS11Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []error
+	for key, c := range b.contexts {
+		errs = append(errs, c.Close())
+		delete(b.contexts, key)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,33 @@
+//go:build !cgo
+
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/url"
+)
+
+// ErrPKCS11Unavailable is returned by PKCS#11 keys in builds without cgo
+var ErrPKCS11Unavailable = errors.New("PKCS#11 support requires a cgo build")
+
+// PKCS11Backend opens keys of PKCS#11 tokens. PKCS#11 modules are C libraries,
+// so without cgo every key fails with ErrPKCS11Unavailable.
+type PKCS11Backend struct {
+	Module string // path to the PKCS#11 library
+	Pin    string // user PIN, used when the URI carries no pin-value
+}
+
+// Signer implements Backend
+func (b *PKCS11Backend) Signer(_ context.Context, uri *url.URL, _ crypto.PublicKey) (crypto.Signer, error) {
+	if _, err := ParsePKCS11URI(uri); err != nil {
+		return nil, err
+	}
+	return nil, ErrPKCS11Unavailable
+}
+
+// Close implements io.Closer
+func (b *PKCS11Backend) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build cgo
+
+package keystore
+
+import (
+	"context"
+	"crypto/elliptic"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+// TestPKCS11Backend runs against an initialized SoftHSM token, e.g.
+//
+//	softhsm2-util --init-token --free --label gosign --pin 1234 --so-pin 1234
+//	GOSIGN_TEST_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so GOSIGN_TEST_PKCS11_TOKEN=gosign GOSIGN_TEST_PKCS11_PIN=1234 go test ./pkg/security/keystore
+func TestPKCS11Backend(t *testing.T) {
+	module := os.Getenv("GOSIGN_TEST_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("GOSIGN_TEST_PKCS11_MODULE not set")
+	}
+	token, pin := os.Getenv("GOSIGN_TEST_PKCS11_TOKEN"), os.Getenv("GOSIGN_TEST_PKCS11_PIN")
+
+	hsm, err := crypto11.Configure(&crypto11.Config{Path: module, TokenLabel: token, Pin: pin})
+	if err != nil {
+		t.Fatalf("Configure() error: %v", err)
+	}
+	defer hsm.Close()
+	label := []byte("gosign-test-" + t.Name())
+	key, err := hsm.GenerateECDSAKeyPairWithLabel([]byte{0x42}, label, elliptic.P256())
+	if err != nil {
+		t.Fatalf("GenerateECDSAKeyPairWithLabel() error: %v", err)
+	}
+	defer func() { _ = key.Delete() }()
+
+	backend := &PKCS11Backend{Module: module, Pin: pin}
+	defer backend.Close()
+	r := NewRegistry()
+	r.Register("pkcs11", backend)
+
+	uri := "pkcs11:token=" + url.PathEscape(token) + ";object=" + url.PathEscape(string(label))
+	signer, err := r.Signer(context.Background(), uri, key.Public())
+	if err != nil {
+		t.Fatalf("Signer() error: %v", err)
+	}
+	if err := Probe(signer); err != nil {
+		t.Fatalf("Probe() error: %v", err)
+	}
+
+	if _, err := r.Signer(context.Background(), uri+"?module-path=/tmp/evil.so", key.Public()); err == nil {
+		t.Fatal("expected error for an unconfigured module")
+	}
+	if _, err := r.Signer(context.Background(), "pkcs11:token="+url.PathEscape(token)+";object=missing", key.Public()); err == nil {
+		t.Fatal("expected error for a missing key")
+	}
+}
@@ -0,0 +1,62 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PKCS11URI is the part of an RFC 7512 PKCS#11 URI that selects a key, e.g.
+// pkcs11:token=gosign;object=seal?pin-value=1234
+type PKCS11URI struct {
+	Token      string // token label
+	Serial     string // token serial number
+	Object     string // key label
+	ID         []byte // key ID
+	PinValue   string
+	ModulePath string
+}
+
+// ParsePKCS11URI parses the token and key attributes of a PKCS#11 URI
+func ParsePKCS11URI(uri *url.URL) (*PKCS11URI, error) {
+	if uri.Scheme != "pkcs11" {
+		return nil, fmt.Errorf("not a PKCS#11 URI: %s", uri.Scheme)
+	}
+
+	p := &PKCS11URI{}
+	for attr := range strings.SplitSeq(uri.Opaque, ";") {
+		if attr == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(attr, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid PKCS#11 URI attribute %q", attr)
+		}
+		value, err := url.PathUnescape(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PKCS#11 URI attribute %q: %w", name, err)
+		}
+		switch name {
+		case "token":
+			p.Token = value
+		case "serial":
+			p.Serial = value
+		case "object":
+			p.Object = value
+		case "id":
+			p.ID = []byte(value)
+		}
+	}
+	query := uri.Query()
+	p.PinValue = query.Get("pin-value")
+	p.ModulePath = query.Get("module-path")
+
+	if p.Token == "" && p.Serial == "" {
+		return nil, errors.New("PKCS#11 URI must select a token by label or serial")
+	}
+	if p.Object == "" && p.ID == nil {
+		return nil, errors.New("PKCS#11 URI must select a key by object label or id")
+	}
+	return p, nil
+}
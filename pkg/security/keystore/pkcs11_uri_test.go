@@ -0,0 +1,44 @@
+package keystore
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+)
+
+func TestParsePKCS11URI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    PKCS11URI
+		wantErr bool
+	}{
+		{uri: "pkcs11:token=gosign;object=seal", want: PKCS11URI{Token: "gosign", Object: "seal"}},
+		{uri: "pkcs11:serial=42;id=%01%02?pin-value=1234", want: PKCS11URI{Serial: "42", ID: []byte{1, 2}, PinValue: "1234"}},
+		{uri: "pkcs11:token=My%20Token;object=seal?module-path=/usr/lib/softhsm/libsofthsm2.so", want: PKCS11URI{Token: "My Token", Object: "seal", ModulePath: "/usr/lib/softhsm/libsofthsm2.so"}},
+		{uri: "pkcs11:object=seal", wantErr: true},
+		{uri: "pkcs11:token=gosign", wantErr: true},
+		{uri: "https://signer/keys/seal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParsePKCS11URI(u)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePKCS11URI(%s) expected error", tt.uri)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePKCS11URI(%s) error: %v", tt.uri, err)
+			continue
+		}
+		if got.Token != tt.want.Token || got.Serial != tt.want.Serial || got.Object != tt.want.Object ||
+			!bytes.Equal(got.ID, tt.want.ID) || got.PinValue != tt.want.PinValue || got.ModulePath != tt.want.ModulePath {
+			t.Errorf("ParsePKCS11URI(%s) = %+v, want %+v", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+)
+
+// Policies lists the external keys each organization and account may use. It
+// is loaded from a file an administrator maintains, e.g.
+//
+//	{"organizations": {"<id>": {
+//	  "remote": [{"url": "https://signer.internal/keys/acme/", "token": "…"}],
+//	  "pkcs11": [{"token": "acme", "objects": ["seal"]}]
+//	}}}
+type Policies struct {
+	Organizations map[string]*Policy `json:"organizations,omitempty"`
+	Accounts      map[string]*Policy `json:"accounts,omitempty"`
+
+	PKCS11 Backend      `json:"-"` // shared PKCS#11 backend, optional
+	Client *http.Client `json:"-"` // client of remote signing services, optional
+}
+
+// Policy lists the external keys of one owner
+type Policy struct {
+	Remote []RemoteEndpoint `json:"remote,omitempty"`
+	PKCS11 []PKCS11Slot     `json:"pkcs11,omitempty"`
+}
+
+// PKCS11Slot is a PKCS#11 token and the key objects of it an owner may use
+type PKCS11Slot struct {
+	Token   string   `json:"token,omitempty"`  // token label
+	Serial  string   `json:"serial,omitempty"` // token serial number, when there is no label
+	Objects []string `json:"objects"`          // labels of the keys
+}
+
+// LoadPolicies reads a policies file
+func LoadPolicies(path string) (*Policies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: %w", err)
+	}
+	p := &Policies{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("keystore: %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Registry returns the backends of the keys an organization, or an account
+// outside an organization, may use; nil when it may use none.
+func (p *Policies) Registry(organizationID, accountID string) *Registry {
+	if p == nil {
+		return nil
+	}
+	policy := p.Accounts[accountID]
+	if organizationID != "" {
+		policy = p.Organizations[organizationID]
+	}
+	if policy == nil {
+		return nil
+	}
+
+	r := NewRegistry()
+	if len(policy.Remote) > 0 {
+		r.Register("https", &RemoteBackend{Endpoints: policy.Remote, Client: p.Client})
+	}
+	if len(policy.PKCS11) > 0 && p.PKCS11 != nil {
+		r.Register("pkcs11", &pkcs11Slots{backend: p.PKCS11, slots: policy.PKCS11})
+	}
+	return r
+}
+
+// pkcs11Slots opens the keys of the configured slots through a shared backend
+type pkcs11Slots struct {
+	backend Backend
+	slots   []PKCS11Slot
+}
+
+// Signer implements Backend
+func (b *pkcs11Slots) Signer(ctx context.Context, uri *url.URL, pub crypto.PublicKey) (crypto.Signer, error) {
+	p, err := ParsePKCS11URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range b.slots {
+		if slot.Token == "" && slot.Serial == "" {
+			continue
+		}
+		if (slot.Token != "" && slot.Token != p.Token) || (slot.Serial != "" && slot.Serial != p.Serial) {
+			continue
+		}
+		if p.Object != "" && slices.Contains(slot.Objects, p.Object) {
+			return b.backend.Signer(ctx, uri, pub)
+		}
+	}
+	return nil, fmt.Errorf("%w: PKCS#11 key %q of token %q is not configured", ErrKeyNotAllowed, p.Object, p.Token+p.Serial)
+}
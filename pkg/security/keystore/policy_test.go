@@ -0,0 +1,65 @@
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key-backends.json")
+	if err := os.WriteFile(path, []byte(`{
+		"organizations": {
+			"org-1": {
+				"remote": [{"url": "https://signer.internal/keys/org-1/", "token": "t1"}],
+				"pkcs11": [{"token": "org-1", "objects": ["seal"]}]
+			}
+		},
+		"accounts": {"acc-1": {"remote": [{"url": "https://signer.internal/keys/acc-1/"}]}}
+	}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := LoadPolicies(path)
+	if err != nil {
+		t.Fatalf("LoadPolicies() error: %v", err)
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	policies.PKCS11 = staticBackend{signer: key}
+	ctx := context.Background()
+
+	if policies.Registry("org-2", "acc-1") != nil {
+		t.Fatal("an organization without a policy gets the policy of an account")
+	}
+	if policies.Registry("", "acc-2") != nil {
+		t.Fatal("registry for an account without a policy")
+	}
+
+	org := policies.Registry("org-1", "")
+	if _, err := org.Signer(ctx, "https://signer.internal/keys/org-1/seal", key.Public()); err != nil {
+		t.Fatalf("configured remote key: %v", err)
+	}
+	if _, err := org.Signer(ctx, "https://signer.internal/keys/acc-1/seal", key.Public()); !errors.Is(err, ErrKeyNotAllowed) {
+		t.Fatalf("key of another owner: %v, want %v", err, ErrKeyNotAllowed)
+	}
+	if _, err := org.Signer(ctx, "http://signer.internal/keys/org-1/seal", key.Public()); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("plain http: %v, want %v", err, ErrUnknownBackend)
+	}
+	if _, err := org.Signer(ctx, "pkcs11:token=org-1;object=seal", key.Public()); err != nil {
+		t.Fatalf("configured PKCS#11 key: %v", err)
+	}
+	for _, uri := range []string{"pkcs11:token=org-2;object=seal", "pkcs11:token=org-1;object=other", "pkcs11:token=org-1;id=%01"} {
+		if _, err := org.Signer(ctx, uri, key.Public()); !errors.Is(err, ErrKeyNotAllowed) {
+			t.Fatalf("%s: %v, want %v", uri, err, ErrKeyNotAllowed)
+		}
+	}
+
+	account := policies.Registry("", "acc-1")
+	if _, err := account.Signer(ctx, "pkcs11:token=org-1;object=seal", key.Public()); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("PKCS#11 key of an account without slots: %v, want %v", err, ErrUnknownBackend)
+	}
+}
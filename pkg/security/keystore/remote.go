@@ -0,0 +1,246 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Remote signing service protocol.
+//
+// The client POSTs a SignRequest as JSON to the key URL, e.g.
+// https://signer.internal/keys/seal, with the optional bearer token of the
+// endpoint the URL is under, and the service answers with a SignResponse. Only
+// the digest leaves the application host; the private key never does. Ed25519 keys sign the message itself, so
+// for them Hash is empty and Digest holds the data to sign.
+
+// SignRequest is the body of a remote signing request
+type SignRequest struct {
+	Hash    string `json:"hash,omitempty"`    // SHA-256, SHA-384 or SHA-512; empty for Ed25519
+	Digest  string `json:"digest"`            // base64 (standard encoding)
+	Padding string `json:"padding,omitempty"` // RSA only: "pss" for RSASSA-PSS (salt length = hash length), else PKCS#1 v1.5
+}
+
+// SignResponse is the body of a successful remote signing response
+type SignResponse struct {
+	Signature string `json:"signature"` // base64; ASN.1 DER for ECDSA
+}
+
+// Padding values of SignRequest
+const (
+	PaddingPKCS1v15 = "pkcs1v15"
+	PaddingPSS      = "pss"
+)
+
+// maxRemoteMessageSize bounds the messages of the remote signing protocol
+const maxRemoteMessageSize = 64 << 10
+
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA256: "SHA-256",
+	crypto.SHA384: "SHA-384",
+	crypto.SHA512: "SHA-512",
+}
+
+// RemoteEndpoint is a remote signing service keys may be used from
+type RemoteEndpoint struct {
+	URL   string `json:"url"`             // https URL the key URLs start with, e.g. https://signer.internal/keys/
+	Token string `json:"token,omitempty"` // bearer token sent to the service, optional
+}
+
+// RemoteBackend opens keys of remote signing services (https URIs). Only keys
+// under one of its endpoints open, and they get the token of that endpoint, so
+// a token is never sent to a URL other than the one it was configured for.
+type RemoteBackend struct {
+	Endpoints []RemoteEndpoint
+	Client    *http.Client // defaults to a client with a 30s timeout
+}
+
+// Signer implements Backend. The service doesn't disclose public keys, so the
+// signer reports pub.
+func (b *RemoteBackend) Signer(_ context.Context, uri *url.URL, pub crypto.PublicKey) (crypto.Signer, error) {
+	if uri.Scheme != "https" || uri.Host == "" {
+		return nil, fmt.Errorf("remote key URI must be an https URL")
+	}
+	if pub == nil {
+		return nil, fmt.Errorf("remote key requires the public key of its certificate")
+	}
+	endpoint, ok := b.endpoint(uri)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a configured remote signing service", ErrKeyNotAllowed, uri.Redacted())
+	}
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &RemoteSigner{URL: uri.String(), Token: endpoint.Token, Client: client, PublicKey: pub}, nil
+}
+
+// endpoint returns the endpoint uri is under: same host, and the endpoint path
+// or a path below it. Paths with dot segments never match.
+func (b *RemoteBackend) endpoint(uri *url.URL) (RemoteEndpoint, bool) {
+	if uri.User != nil || uri.RawQuery != "" || uri.Fragment != "" {
+		return RemoteEndpoint{}, false
+	}
+	if uri.Path != "" && uri.Path != path.Clean(uri.Path) {
+		return RemoteEndpoint{}, false
+	}
+	for _, e := range b.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Scheme != "https" || !strings.EqualFold(u.Host, uri.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(u.Path, "/")
+		if uri.Path == prefix || strings.HasPrefix(uri.Path, prefix+"/") {
+			return e, true
+		}
+	}
+	return RemoteEndpoint{}, false
+}
+
+// RemoteSigner is a crypto.Signer backed by a remote signing service.
+type RemoteSigner struct {
+	URL       string
+	Token     string
+	Client    *http.Client
+	PublicKey crypto.PublicKey
+}
+
+// Public implements crypto.Signer
+func (s *RemoteSigner) Public() crypto.PublicKey {
+	return s.PublicKey
+}
+
+// Sign implements crypto.Signer by sending digest to the signing service
+func (s *RemoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := SignRequest{Digest: base64.StdEncoding.EncodeToString(digest)}
+	if h := opts.HashFunc(); h != 0 {
+		name, ok := hashNames[h]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %s", h)
+		}
+		req.Hash = name
+	}
+	if _, ok := s.PublicKey.(*rsa.PublicKey); ok {
+		req.Padding = PaddingPKCS1v15
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+				return nil, fmt.Errorf("remote signer supports RSASSA-PSS with salt length equal to the hash only")
+			}
+			req.Padding = PaddingPSS
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteMessageSize))
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var res SignResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("remote signer: invalid response: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(res.Signature)
+	if err != nil || len(sig) == 0 {
+		return nil, errors.New("remote signer: invalid signature encoding")
+	}
+	return sig, nil
+}
+
+// RemoteHandler serves the remote signing protocol for a local key. It is the
+// reference implementation of the service side, e.g. for a signing host that
+// keeps the key away from the application.
+type RemoteHandler struct {
+	Signer crypto.Signer
+	Token  string // required bearer token, optional
+}
+
+// ServeHTTP implements http.Handler
+func (h *RemoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Token != "" {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var req SignRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRemoteMessageSize)).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	digest, err := base64.StdEncoding.DecodeString(req.Digest)
+	if err != nil {
+		http.Error(w, "invalid digest encoding", http.StatusBadRequest)
+		return
+	}
+
+	var opts crypto.SignerOpts = crypto.Hash(0)
+	if req.Hash != "" {
+		var hash crypto.Hash
+		for hf, name := range hashNames {
+			if name == req.Hash {
+				hash = hf
+			}
+		}
+		if hash == 0 || len(digest) != hash.Size() {
+			http.Error(w, "unsupported hash", http.StatusBadRequest)
+			return
+		}
+		opts = hash
+		if req.Padding == PaddingPSS {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+	}
+
+	sig, err := h.Signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		http.Error(w, "signing failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SignResponse{Signature: base64.StdEncoding.EncodeToString(sig)})
+}
@@ -0,0 +1,132 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	digest := sha256.Sum256([]byte("document"))
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		opts   crypto.SignerOpts
+		verify func(sig []byte) bool
+	}{
+		{"ecdsa", ecKey, crypto.SHA256, func(sig []byte) bool {
+			return ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig)
+		}},
+		{"rsa", rsaKey, crypto.SHA256, func(sig []byte) bool {
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+		}},
+		{"rsa-pss", rsaKey, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, func(sig []byte) bool {
+			return rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: sha256.Size}) == nil
+		}},
+		{"ed25519", edKey, crypto.Hash(0), func(sig []byte) bool {
+			return ed25519.Verify(edKey.Public().(ed25519.PublicKey), []byte("document"), sig)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(&RemoteHandler{Signer: tt.key, Token: "secret"})
+			defer srv.Close()
+
+			r := NewRegistry()
+			r.Register("https", &RemoteBackend{Endpoints: []RemoteEndpoint{{URL: srv.URL + "/keys/", Token: "secret"}}, Client: srv.Client()})
+			signer, err := r.Signer(context.Background(), srv.URL+"/keys/seal", tt.key.Public())
+			if err != nil {
+				t.Fatalf("Signer() error: %v", err)
+			}
+
+			msg := digest[:]
+			if tt.opts.HashFunc() == 0 {
+				msg = []byte("document")
+			}
+			sig, err := signer.Sign(rand.Reader, msg, tt.opts)
+			if err != nil {
+				t.Fatalf("Sign() error: %v", err)
+			}
+			if !tt.verify(sig) {
+				t.Fatal("signature does not verify")
+			}
+			if err := Probe(signer); err != nil {
+				t.Fatalf("Probe() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteSignerUnauthorized(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	srv := httptest.NewTLSServer(&RemoteHandler{Signer: key, Token: "secret"})
+	defer srv.Close()
+
+	backend := &RemoteBackend{Endpoints: []RemoteEndpoint{{URL: srv.URL, Token: "wrong"}}, Client: srv.Client()}
+	r := NewRegistry()
+	r.Register("https", backend)
+	signer, err := r.Signer(context.Background(), srv.URL, key.Public())
+	if err != nil {
+		t.Fatalf("Signer() error: %v", err)
+	}
+	if err := Probe(signer); err == nil {
+		t.Fatal("expected error for a wrong token")
+	}
+}
+
+func TestRemoteBackendEndpoints(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	backend := &RemoteBackend{Endpoints: []RemoteEndpoint{
+		{URL: "https://signer.internal/keys/acme/", Token: "acme"},
+		{URL: "https://other.internal", Token: "other"},
+	}}
+
+	tests := []struct {
+		uri   string
+		token string // empty: rejected
+	}{
+		{"https://signer.internal/keys/acme/seal", "acme"},
+		{"https://SIGNER.internal/keys/acme", "acme"},
+		{"https://other.internal/any/key", "other"},
+		{"http://signer.internal/keys/acme/seal", ""},
+		{"https://signer.internal/keys/acme-evil/seal", ""},
+		{"https://signer.internal/keys/acme/../globex/seal", ""},
+		{"https://signer.internal/keys/acme/seal?next=http://attacker", ""},
+		{"https://user@signer.internal/keys/acme/seal", ""},
+		{"https://attacker.example/keys/acme/seal", ""},
+		{"https://169.254.169.254/latest/meta-data", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := backend.Signer(context.Background(), u, key.Public())
+		if tt.token == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.uri)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Signer() error: %v", tt.uri, err)
+			continue
+		}
+		if got := signer.(*RemoteSigner).Token; got != tt.token {
+			t.Errorf("%s: token %q, want %q", tt.uri, got, tt.token)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package kms wraps data keys with key-encryption keys (envelope encryption).
+//
+// A KMS keeps the key-encryption keys (KEKs) and never hands them out: callers
+// give it a data key to wrap and get back the ID of the KEK used, which they
+// store next to the wrapped key to unwrap it later. Keys are rotated by making
+// a new KEK current; data keys wrapped with older ones stay readable as long as
+// those KEKs are kept.
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrUnknownKey is returned (wrapped) when a data key was wrapped with a KEK the KMS doesn't have
+var ErrUnknownKey = errors.New("kms: unknown key-encryption key")
+
+// KMS wraps and unwraps data keys
+type KMS interface {
+	// CurrentKeyID returns the ID of the KEK new data keys are wrapped with
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the current KEK and returns its ID
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped with the KEK keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring is a KMS holding its KEKs in memory, loaded from a file or given
+// directly (tests). KEKs are 256-bit AES keys; data keys are wrapped with
+// AES-256-GCM, authenticated with the ID of the KEK.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// keyringFile is the JSON form of a keyring:
+//
+//	{"current": "2026-10", "keys": {"2026-01": "<base64>", "2026-10": "<base64>"}}
+type keyringFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// NewKeyring creates a keyring wrapping new data keys with the KEK current
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("kms: invalid key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("kms: key %q must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := k.keys[current]; !ok {
+		return nil, fmt.Errorf("kms: current key %q is not in the keyring", current)
+	}
+	return k, nil
+}
+
+// LoadKeyring reads a keyring file: a JSON object with the ID of the current
+// KEK and every KEK, base64 encoded
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("kms: %w", err)
+	}
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("kms: %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, encoded := range f.Keys {
+		if keys[id], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("kms: key %q: %w", id, err)
+		}
+	}
+	return NewKeyring(f.Current, keys)
+}
+
+// CurrentKeyID implements KMS
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// WrapKey implements KMS
+func (k *Keyring) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, dataKey, []byte(k.current)), nil
+}
+
+// UnwrapKey implements KMS
+func (k *Keyring) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	n := aead.NonceSize()
+	if len(wrapped) < n+aead.Overhead() {
+		return nil, errors.New("kms: wrapped key too short")
+	}
+	dataKey, err := aead.Open(nil, wrapped[:n], wrapped[n:], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("kms: unwrap with %q: %w", keyID, err)
+	}
+	return dataKey, nil
+}
@@ -0,0 +1,83 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	ctx := context.Background()
+	old, current := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	k, err := NewKeyring("2026-10", map[string][]byte{"2026-01": old, "2026-10": current})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey := bytes.Repeat([]byte{7}, 32)
+	keyID, wrapped, err := k.WrapKey(ctx, dataKey)
+	if err != nil || keyID != "2026-10" {
+		t.Fatalf("WrapKey() = %q, %v", keyID, err)
+	}
+	if bytes.Contains(wrapped, dataKey) {
+		t.Fatal("wrapped key contains the data key")
+	}
+	if got, err := k.UnwrapKey(ctx, keyID, wrapped); err != nil || !bytes.Equal(got, dataKey) {
+		t.Fatalf("UnwrapKey() = %x, %v", got, err)
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		if _, err := k.UnwrapKey(ctx, "2026-01", wrapped); err == nil {
+			t.Fatal("unwrapped with another key")
+		}
+		if _, err := k.UnwrapKey(ctx, "2025-01", wrapped); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("UnwrapKey() error = %v, want %v", err, ErrUnknownKey)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte{}, wrapped...)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := k.UnwrapKey(ctx, keyID, tampered); err == nil {
+			t.Fatal("unwrapped a tampered key")
+		}
+		if _, err := k.UnwrapKey(ctx, keyID, wrapped[:8]); err == nil {
+			t.Fatal("unwrapped a truncated key")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewKeyring("missing", map[string][]byte{"2026-10": current}); err == nil {
+			t.Error("accepted a current key not in the keyring")
+		}
+		if _, err := NewKeyring("short", map[string][]byte{"short": current[:16]}); err == nil {
+			t.Error("accepted a 128-bit key")
+		}
+	})
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32))
+	if err := os.WriteFile(path, []byte(`{"current":"k1","keys":{"k1":"`+key+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.CurrentKeyID() != "k1" {
+		t.Fatalf("CurrentKeyID() = %q", k.CurrentKeyID())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"current":"k1","keys":{"k1":"not base64"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyring(path); err == nil {
+		t.Fatal("loaded an invalid key")
+	}
+}
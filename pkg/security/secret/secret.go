@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrCiphertextTooShort is returned when the input cannot contain a nonce and tag.
+var ErrCiphertextTooShort = errors.New("ciphertext too short")
+
+// Cipher encrypts small secrets (private keys, tokens) with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher derives a 256-bit key from passphrase and returns a ready Cipher.
+func NewCipher(passphrase string) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("encryption passphrase is empty")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns nonce || ciphertext || tag.
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt reverses Encrypt and authenticates the ciphertext.
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(data) < n+c.aead.Overhead() {
+		return nil, ErrCiphertextTooShort
+	}
+	return c.aead.Open(nil, data[:n], data[n:], nil)
+}
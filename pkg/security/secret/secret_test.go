@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher("passphrase")
+	if err != nil {
+		t.Fatalf("NewCipher() error: %v", err)
+	}
+
+	plain := []byte("private key material")
+	enc, err := c.Encrypt(plain)
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	if bytes.Contains(enc, plain) {
+		t.Fatalf("ciphertext must not contain plaintext")
+	}
+
+	dec, err := c.Decrypt(enc)
+	if err != nil {
+		t.Fatalf("Decrypt() error: %v", err)
+	}
+	if !bytes.Equal(dec, plain) {
+		t.Fatalf("Decrypt() = %q, want %q", dec, plain)
+	}
+}
+
+func TestCipherRejectsTampering(t *testing.T) {
+	c, _ := NewCipher("passphrase")
+	enc, _ := c.Encrypt([]byte("data"))
+	enc[len(enc)-1] ^= 0xff
+	if _, err := c.Decrypt(enc); err == nil {
+		t.Fatal("expected error for tampered ciphertext")
+	}
+
+	other, _ := NewCipher("other")
+	enc, _ = c.Encrypt([]byte("data"))
+	if _, err := other.Decrypt(enc); err == nil {
+		t.Fatal("expected error when decrypting with a different passphrase")
+	}
+
+	if _, err := c.Decrypt([]byte("short")); err != ErrCiphertextTooShort {
+		t.Fatalf("Decrypt(short) error = %v, want ErrCiphertextTooShort", err)
+	}
+}
+
+func TestNewCipherEmptyPassphrase(t *testing.T) {
+	if _, err := NewCipher(""); err == nil {
+		t.Fatal("expected error for empty passphrase")
+	}
+}
@@ -0,0 +1,365 @@
+package xmldsig
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// element is a node of a parsed document. Names keep their prefixes, so the
+// document can be serialized the way it was written.
+type element struct {
+	parent   *element
+	prefix   string
+	local    string
+	attrs    []xml.Attr // including namespace declarations
+	children []any      // *element, xml.CharData, xml.Comment or xml.ProcInst
+}
+
+// document is a parsed XML document
+type document struct {
+	root    *element
+	rootEnd int64 // offset of the end tag of the root element
+}
+
+// parse reads an XML document. DTDs are rejected.
+func parse(data []byte) (*document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = true
+
+	doc := &document{}
+	var cur *element
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		tok = xml.CopyToken(tok)
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := &element{parent: cur, prefix: t.Name.Space, local: t.Name.Local, attrs: t.Attr}
+			if cur == nil {
+				if doc.root != nil {
+					return nil, errors.New("xml: multiple root elements")
+				}
+				doc.root = el
+			} else {
+				cur.children = append(cur.children, el)
+			}
+			cur = el
+		case xml.EndElement:
+			if cur == nil || t.Name.Space != cur.prefix || t.Name.Local != cur.local {
+				return nil, fmt.Errorf("xml: unexpected end element %s", t.Name.Local)
+			}
+			if cur.parent == nil {
+				doc.rootEnd = offset
+			}
+			cur = cur.parent
+		case xml.Directive:
+			return nil, errors.New("xml: DTDs are not supported")
+		default:
+			// Content outside the document element is not signed
+			if cur != nil {
+				cur.children = append(cur.children, t)
+			}
+		}
+	}
+	if doc.root == nil || cur != nil {
+		return nil, errors.New("xml: incomplete document")
+	}
+	return doc, nil
+}
+
+// namespace returns the namespace URI bound to prefix in the scope of e
+func (e *element) namespace(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespace
+	}
+	for el := e; el != nil; el = el.parent {
+		for _, a := range el.attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// inScope returns all namespace declarations in the scope of e
+func (e *element) inScope() map[string]string {
+	ns := map[string]string{}
+	for el := e; el != nil; el = el.parent {
+		for _, a := range el.attrs {
+			if prefix, ok := nsDecl(a); ok {
+				if _, seen := ns[prefix]; !seen {
+					ns[prefix] = a.Value
+				}
+			}
+		}
+	}
+	return ns
+}
+
+// is reports whether e is the element local of namespace ns
+func (e *element) is(ns, local string) bool {
+	return e.local == local && e.namespace(e.prefix) == ns
+}
+
+// child returns the first child element local of namespace ns
+func (e *element) child(ns, local string) *element {
+	for _, c := range e.children {
+		if el, ok := c.(*element); ok && el.is(ns, local) {
+			return el
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements local of namespace ns
+func (e *element) childrenNamed(ns, local string) []*element {
+	var out []*element
+	for _, c := range e.children {
+		if el, ok := c.(*element); ok && el.is(ns, local) {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// attr returns the value of the unprefixed attribute name
+func (e *element) attr(name string) string {
+	for _, a := range e.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// text returns the character data of e and its descendants
+func (e *element) text() string {
+	var b strings.Builder
+	for _, c := range e.children {
+		switch t := c.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case *element:
+			b.WriteString(t.text())
+		}
+	}
+	return b.String()
+}
+
+// findID returns the element whose Id (or ID, id) attribute is id
+func (e *element) findID(id string) *element {
+	for _, name := range []string{"Id", "ID", "id"} {
+		if e.attr(name) == id {
+			return e
+		}
+	}
+	for _, c := range e.children {
+		if el, ok := c.(*element); ok {
+			if found := el.findID(id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func nsDecl(a xml.Attr) (string, bool) {
+	if a.Name.Space == "" && a.Name.Local == "xmlns" {
+		return "", true
+	}
+	if a.Name.Space == "xmlns" {
+		return a.Name.Local, true
+	}
+	return "", false
+}
+
+// canonicalizer serializes a subtree per Canonical XML 1.0 or Exclusive XML
+// Canonicalization 1.0
+type canonicalizer struct {
+	exclusive   bool
+	comments    bool
+	inclusiveNS map[string]bool // InclusiveNamespaces PrefixList of exclusive c14n
+	skip        *element        // removed by the enveloped-signature transform
+	buf         bytes.Buffer
+}
+
+// canonicalize returns the canonical form of the subtree rooted at e
+func (c *canonicalizer) canonicalize(e *element) []byte {
+	c.buf.Reset()
+	c.element(e, map[string]string{"": ""}, true)
+	return bytes.Clone(c.buf.Bytes())
+}
+
+func (c *canonicalizer) element(e *element, rendered map[string]string, apex bool) {
+	if e == c.skip {
+		return
+	}
+
+	// Namespace declarations to render on e
+	candidates := map[string]string{}
+	if c.exclusive {
+		used := map[string]bool{e.prefix: true}
+		for _, a := range e.attrs {
+			if _, ok := nsDecl(a); !ok && a.Name.Space != "" && a.Name.Space != "xml" {
+				used[a.Name.Space] = true
+			}
+		}
+		for p := range c.inclusiveNS {
+			used[p] = true
+		}
+		scope := e.inScope()
+		for p := range used {
+			if uri, ok := scope[p]; ok || p == "" {
+				candidates[p] = uri
+			}
+		}
+	} else if apex {
+		candidates = e.inScope()
+	} else {
+		for _, a := range e.attrs {
+			if p, ok := nsDecl(a); ok {
+				candidates[p] = a.Value
+			}
+		}
+	}
+
+	var decls []string
+	for p, uri := range candidates {
+		if prev, ok := rendered[p]; ok && prev == uri {
+			continue
+		}
+		if uri == "" && (p != "" || rendered[""] == "") {
+			continue
+		}
+		decls = append(decls, p)
+	}
+	sort.Strings(decls)
+	next := rendered
+	if len(decls) > 0 {
+		next = make(map[string]string, len(rendered)+len(decls))
+		for k, v := range rendered {
+			next[k] = v
+		}
+		for _, p := range decls {
+			next[p] = candidates[p]
+		}
+	}
+
+	type attr struct {
+		ns, local, qname, value string
+	}
+	var attrs []attr
+	for _, a := range e.attrs {
+		if _, ok := nsDecl(a); ok {
+			continue
+		}
+		qname := a.Name.Local
+		ns := ""
+		if a.Name.Space != "" {
+			qname = a.Name.Space + ":" + a.Name.Local
+			ns = e.namespace(a.Name.Space)
+		}
+		attrs = append(attrs, attr{ns: ns, local: a.Name.Local, qname: qname, value: a.Value})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].ns != attrs[j].ns {
+			return attrs[i].ns < attrs[j].ns
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	qname := e.local
+	if e.prefix != "" {
+		qname = e.prefix + ":" + e.local
+	}
+	c.buf.WriteString("<" + qname)
+	for _, p := range decls {
+		if p == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(` xmlns:` + p + `="`)
+		}
+		escapeAttr(&c.buf, next[p])
+		c.buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.buf.WriteString(" " + a.qname + `="`)
+		escapeAttr(&c.buf, a.value)
+		c.buf.WriteByte('"')
+	}
+	c.buf.WriteByte('>')
+
+	for _, child := range e.children {
+		switch t := child.(type) {
+		case *element:
+			c.element(t, next, false)
+		case xml.CharData:
+			escapeText(&c.buf, string(t))
+		case xml.Comment:
+			if c.comments {
+				c.buf.WriteString("<!--" + string(t) + "-->")
+			}
+		case xml.ProcInst:
+			c.buf.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				c.buf.WriteString(" " + string(t.Inst))
+			}
+			c.buf.WriteString("?>")
+		}
+	}
+	c.buf.WriteString("</" + qname + ">")
+}
+
+func escapeText(b *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '\r':
+			b.WriteString("&#xD;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(b *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '"':
+			b.WriteString("&quot;")
+		case '\t':
+			b.WriteString("&#x9;")
+		case '\n':
+			b.WriteString("&#xA;")
+		case '\r':
+			b.WriteString("&#xD;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package xmldsig verifies and creates enveloped XML signatures (XMLDSig), as
+// used by ETSI TS 119 612 trusted lists.
+//
+// Only what signed trusted lists need is supported: enveloped signatures over
+// the whole document or over elements referenced by ID, Canonical XML 1.0 and
+// Exclusive XML Canonicalization 1.0, SHA-1/SHA-2 digests, and RSA (PKCS#1
+// v1.5 or PSS) and ECDSA signatures. DTDs are rejected.
+package xmldsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1" // digest algorithms of older signatures
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Namespace and algorithm identifiers
+const (
+	Namespace = "http://www.w3.org/2000/09/xmldsig#"
+
+	AlgC14N                = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	AlgC14NWithComments    = AlgC14N + "#WithComments"
+	AlgC14N11              = "http://www.w3.org/2006/12/xml-c14n11"
+	AlgC14N11WithComments  = AlgC14N11 + "#WithComments"
+	AlgExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	AlgExcC14NWithComments = AlgExcC14N + "WithComments"
+	AlgEnvelopedSignature  = Namespace + "enveloped-signature"
+
+	AlgSHA256      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	AlgRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	AlgECDSASHA384 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384"
+)
+
+var (
+	// ErrNoSignature is returned for documents without an enveloped signature.
+	ErrNoSignature = errors.New("xmldsig: document is not signed")
+	// ErrInvalidSignature is returned when a digest or the signature value doesn't match.
+	ErrInvalidSignature = errors.New("xmldsig: invalid signature")
+	// ErrUntrustedSigner is returned when none of the expected certificates made the signature.
+	ErrUntrustedSigner = errors.New("xmldsig: not signed by an expected certificate")
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	Namespace + "sha1": crypto.SHA1,
+	AlgSHA256:          crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+type signatureAlgorithm struct {
+	hash crypto.Hash
+	key  string // rsa, rsa-pss or ecdsa
+}
+
+var signatureAlgorithms = map[string]signatureAlgorithm{
+	Namespace + "rsa-sha1": {crypto.SHA1, "rsa"},
+	AlgRSASHA256:           {crypto.SHA256, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":      {crypto.SHA384, "rsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":      {crypto.SHA512, "rsa"},
+	"http://www.w3.org/2007/05/xmldsig-more#sha256-rsa-MGF1": {crypto.SHA256, "rsa-pss"},
+	"http://www.w3.org/2007/05/xmldsig-more#sha384-rsa-MGF1": {crypto.SHA384, "rsa-pss"},
+	"http://www.w3.org/2007/05/xmldsig-more#sha512-rsa-MGF1": {crypto.SHA512, "rsa-pss"},
+	AlgECDSASHA256: {crypto.SHA256, "ecdsa"},
+	AlgECDSASHA384: {crypto.SHA384, "ecdsa"},
+	"http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512": {crypto.SHA512, "ecdsa"},
+}
+
+// Verify checks the enveloped signature of doc and returns the certificate of
+// its KeyInfo that made it. Whether that certificate is trusted is up to the
+// caller; see VerifyWith.
+func Verify(doc []byte) (*x509.Certificate, error) {
+	return verify(doc, nil)
+}
+
+// VerifyWith checks that doc carries a valid enveloped signature made by one of
+// signers and returns that signer.
+func VerifyWith(doc []byte, signers []*x509.Certificate) (*x509.Certificate, error) {
+	if len(signers) == 0 {
+		return nil, ErrUntrustedSigner
+	}
+	return verify(doc, signers)
+}
+
+func verify(data []byte, signers []*x509.Certificate) (*x509.Certificate, error) {
+	doc, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	sig := doc.root.child(Namespace, "Signature")
+	if sig == nil {
+		return nil, ErrNoSignature
+	}
+	signedInfo := sig.child(Namespace, "SignedInfo")
+	if signedInfo == nil {
+		return nil, errors.New("xmldsig: missing SignedInfo")
+	}
+
+	// The document itself must be covered, not only e.g. XAdES properties
+	coversDocument := false
+	refs := signedInfo.childrenNamed(Namespace, "Reference")
+	for _, ref := range refs {
+		target, err := verifyReference(doc, sig, ref)
+		if err != nil {
+			return nil, err
+		}
+		coversDocument = coversDocument || target == doc.root
+	}
+	if !coversDocument {
+		return nil, errors.New("xmldsig: signature doesn't cover the document")
+	}
+
+	c, err := canonicalizerFor(signedInfo.child(Namespace, "CanonicalizationMethod"), true)
+	if err != nil {
+		return nil, err
+	}
+	signed := c.canonicalize(signedInfo)
+
+	method := signedInfo.child(Namespace, "SignatureMethod")
+	if method == nil {
+		return nil, errors.New("xmldsig: missing SignatureMethod")
+	}
+	alg, ok := signatureAlgorithms[method.attr("Algorithm")]
+	if !ok {
+		return nil, fmt.Errorf("xmldsig: unsupported signature algorithm %s", method.attr("Algorithm"))
+	}
+	value := sig.child(Namespace, "SignatureValue")
+	if value == nil {
+		return nil, errors.New("xmldsig: missing SignatureValue")
+	}
+	signature, err := decodeBase64(value.text())
+	if err != nil {
+		return nil, fmt.Errorf("xmldsig: invalid SignatureValue: %w", err)
+	}
+
+	candidates := signers
+	if candidates == nil {
+		if candidates, err = keyInfoCertificates(sig); err != nil {
+			return nil, err
+		}
+	}
+	for _, crt := range candidates {
+		if checkSignature(crt.PublicKey, alg, signed, signature) == nil {
+			return crt, nil
+		}
+	}
+	if signers != nil {
+		return nil, ErrUntrustedSigner
+	}
+	return nil, ErrInvalidSignature
+}
+
+// verifyReference checks the digest of a Reference and returns the element it covers
+func verifyReference(doc *document, sig, ref *element) (*element, error) {
+	uri := ref.attr("URI")
+	var target *element
+	switch {
+	case uri == "":
+		target = doc.root
+	case strings.HasPrefix(uri, "#") && !strings.HasPrefix(uri, "#xpointer("):
+		target = doc.root.findID(uri[1:])
+	}
+	if target == nil {
+		return nil, fmt.Errorf("xmldsig: unsupported or unresolved reference %q", uri)
+	}
+
+	// Without a canonicalization transform the node-set is serialized as Canonical XML 1.0
+	c := &canonicalizer{}
+	if transforms := ref.child(Namespace, "Transforms"); transforms != nil {
+		for _, t := range transforms.childrenNamed(Namespace, "Transform") {
+			if t.attr("Algorithm") == AlgEnvelopedSignature {
+				c.skip = sig
+				continue
+			}
+			tc, err := canonicalizerFor(t, false)
+			if err != nil {
+				return nil, err
+			}
+			tc.skip = c.skip
+			c = tc
+		}
+	}
+
+	method := ref.child(Namespace, "DigestMethod")
+	if method == nil {
+		return nil, errors.New("xmldsig: missing DigestMethod")
+	}
+	hash, ok := digestAlgorithms[method.attr("Algorithm")]
+	if !ok || !hash.Available() {
+		return nil, fmt.Errorf("xmldsig: unsupported digest algorithm %s", method.attr("Algorithm"))
+	}
+	value := ref.child(Namespace, "DigestValue")
+	if value == nil {
+		return nil, errors.New("xmldsig: missing DigestValue")
+	}
+	want, err := decodeBase64(value.text())
+	if err != nil {
+		return nil, fmt.Errorf("xmldsig: invalid DigestValue: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(c.canonicalize(target))
+	if !bytes.Equal(h.Sum(nil), want) {
+		return nil, fmt.Errorf("%w: digest mismatch for reference %q", ErrInvalidSignature, uri)
+	}
+	return target, nil
+}
+
+// canonicalizerFor configures a canonicalizer from a CanonicalizationMethod or
+// Transform element. Comments are kept only where allowed: same-document
+// references always drop them.
+func canonicalizerFor(method *element, allowComments bool) (*canonicalizer, error) {
+	if method == nil {
+		return nil, errors.New("xmldsig: missing CanonicalizationMethod")
+	}
+	alg := method.attr("Algorithm")
+	c := &canonicalizer{}
+	switch alg {
+	case AlgC14N, AlgC14N11:
+	case AlgC14NWithComments, AlgC14N11WithComments:
+		c.comments = allowComments
+	case AlgExcC14N, AlgExcC14NWithComments:
+		c.exclusive = true
+		c.comments = allowComments && alg == AlgExcC14NWithComments
+		if incl := method.child(AlgExcC14N, "InclusiveNamespaces"); incl != nil {
+			c.inclusiveNS = map[string]bool{}
+			for _, p := range strings.Fields(incl.attr("PrefixList")) {
+				if p == "#default" {
+					p = ""
+				}
+				c.inclusiveNS[p] = true
+			}
+		}
+	default:
+		return nil, fmt.Errorf("xmldsig: unsupported transform %s", alg)
+	}
+	return c, nil
+}
+
+// keyInfoCertificates returns the certificates of the KeyInfo of sig
+func keyInfoCertificates(sig *element) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	if keyInfo := sig.child(Namespace, "KeyInfo"); keyInfo != nil {
+		for _, data := range keyInfo.childrenNamed(Namespace, "X509Data") {
+			for _, el := range data.childrenNamed(Namespace, "X509Certificate") {
+				der, err := decodeBase64(el.text())
+				if err != nil {
+					return nil, fmt.Errorf("xmldsig: invalid X509Certificate: %w", err)
+				}
+				crt, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, fmt.Errorf("xmldsig: invalid X509Certificate: %w", err)
+				}
+				certs = append(certs, crt)
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("xmldsig: no certificate in KeyInfo")
+	}
+	return certs, nil
+}
+
+func checkSignature(pub crypto.PublicKey, alg signatureAlgorithm, signed, signature []byte) error {
+	if !alg.hash.Available() {
+		return fmt.Errorf("xmldsig: hash %s unavailable", alg.hash)
+	}
+	h := alg.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		switch alg.key {
+		case "rsa":
+			return rsa.VerifyPKCS1v15(key, alg.hash, digest, signature)
+		case "rsa-pss":
+			return rsa.VerifyPSS(key, alg.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		}
+	case *ecdsa.PublicKey:
+		// XML signatures carry r and s concatenated, not ASN.1
+		if alg.key != "ecdsa" || len(signature)%2 != 0 {
+			break
+		}
+		half := len(signature) / 2
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		if ecdsa.Verify(key, digest, r, s) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// Sign adds an enveloped signature made by signer as the last child of the root
+// element of doc. The signature covers the whole document (exclusive
+// canonicalization, SHA-256) and carries cert in its KeyInfo. RSA and ECDSA
+// P-256/P-384 keys are supported.
+func Sign(doc []byte, signer crypto.Signer, cert *x509.Certificate) ([]byte, error) {
+	parsed, err := parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.root.child(Namespace, "Signature") != nil {
+		return nil, errors.New("xmldsig: document is already signed")
+	}
+
+	var method string
+	hash := crypto.SHA256
+	switch key := signer.Public().(type) {
+	case *rsa.PublicKey:
+		method = AlgRSASHA256
+	case *ecdsa.PublicKey:
+		method = AlgECDSASHA256
+		if key.Curve == elliptic.P384() {
+			method, hash = AlgECDSASHA384, crypto.SHA384
+		}
+	default:
+		return nil, fmt.Errorf("xmldsig: unsupported key type %T", key)
+	}
+
+	digest := crypto.SHA256.New()
+	digest.Write((&canonicalizer{exclusive: true}).canonicalize(parsed.root))
+
+	const placeholder = "<ds:SignatureValue></ds:SignatureValue>"
+	signature := `<ds:Signature xmlns:ds="` + Namespace + `">` +
+		`<ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + AlgExcC14N + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + method + `"></ds:SignatureMethod>` +
+		`<ds:Reference URI="">` +
+		`<ds:Transforms>` +
+		`<ds:Transform Algorithm="` + AlgEnvelopedSignature + `"></ds:Transform>` +
+		`<ds:Transform Algorithm="` + AlgExcC14N + `"></ds:Transform>` +
+		`</ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + AlgSHA256 + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest.Sum(nil)) + `</ds:DigestValue>` +
+		`</ds:Reference>` +
+		`</ds:SignedInfo>` +
+		placeholder +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(cert.Raw) + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo>` +
+		`</ds:Signature>`
+
+	if !bytes.HasPrefix(doc[parsed.rootEnd:], []byte("</")) {
+		return nil, errors.New("xmldsig: root element has no end tag")
+	}
+	signedDoc := make([]byte, 0, len(doc)+len(signature)+512)
+	signedDoc = append(signedDoc, doc[:parsed.rootEnd]...)
+	signedDoc = append(signedDoc, signature...)
+	signedDoc = append(signedDoc, doc[parsed.rootEnd:]...)
+
+	// SignedInfo is canonicalized in the context it ends up in
+	withSig, err := parse(signedDoc)
+	if err != nil {
+		return nil, err
+	}
+	signedInfo := withSig.root.child(Namespace, "Signature").child(Namespace, "SignedInfo")
+	h := hash.New()
+	h.Write((&canonicalizer{exclusive: true}).canonicalize(signedInfo))
+	value, err := signer.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		if value, err = concatECDSA(value, (key.Curve.Params().BitSize+7)/8); err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.Replace(signedDoc, []byte(placeholder),
+		[]byte("<ds:SignatureValue>"+base64.StdEncoding.EncodeToString(value)+"</ds:SignatureValue>"), 1), nil
+}
+
+// concatECDSA converts an ASN.1 ECDSA signature to r and s concatenated
+func concatECDSA(der []byte, size int) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("xmldsig: invalid ECDSA signature: %w", err)
+	}
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
@@ -0,0 +1,160 @@
+package xmldsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, key crypto.Signer, cn string) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestCanonicalize(t *testing.T) {
+	doc := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<r xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b"><a:e b:z="2" y="1&amp;" x="&quot;"/><e>text &lt; &#xD;</e><!-- inner --></r>`)
+	parsed, err := parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		c    *canonicalizer
+		el   *element
+		want string
+	}{
+		{
+			name: "inclusive document",
+			c:    &canonicalizer{},
+			el:   parsed.root,
+			want: `<r xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b"><a:e x="&quot;" y="1&amp;" b:z="2"></a:e><e>text &lt; &#xD;</e></r>`,
+		},
+		{
+			name: "inclusive with comments",
+			c:    &canonicalizer{comments: true},
+			el:   parsed.root,
+			want: `<r xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b"><a:e x="&quot;" y="1&amp;" b:z="2"></a:e><e>text &lt; &#xD;</e><!-- inner --></r>`,
+		},
+		{
+			name: "inclusive subtree",
+			c:    &canonicalizer{},
+			el:   parsed.root.children[0].(*element),
+			want: `<a:e xmlns="urn:default" xmlns:a="urn:a" xmlns:b="urn:b" x="&quot;" y="1&amp;" b:z="2"></a:e>`,
+		},
+		{
+			name: "exclusive subtree",
+			c:    &canonicalizer{exclusive: true},
+			el:   parsed.root.children[0].(*element),
+			want: `<a:e xmlns:a="urn:a" xmlns:b="urn:b" x="&quot;" y="1&amp;" b:z="2"></a:e>`,
+		},
+		{
+			name: "exclusive default namespace",
+			c:    &canonicalizer{exclusive: true},
+			el:   parsed.root.children[1].(*element),
+			want: `<e xmlns="urn:default">text &lt; &#xD;</e>`,
+		},
+		{
+			name: "exclusive inclusive prefixes",
+			c:    &canonicalizer{exclusive: true, inclusiveNS: map[string]bool{"b": true}},
+			el:   parsed.root.children[1].(*element),
+			want: `<e xmlns="urn:default" xmlns:b="urn:b">text &lt; &#xD;</e>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.c.canonicalize(tt.el)); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	doc := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" xmlns:ns2="http://www.w3.org/2000/09/xmldsig#" Id="tsl">
+  <SchemeInformation>
+    <SchemeTerritory>ZZ</SchemeTerritory>
+  </SchemeInformation>
+</TrustServiceStatusList>
+`)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "rsa": rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			crt := testCertificate(t, key, "Test TL signer")
+			signed, err := Sign(doc, key, crt)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			got, err := Verify(signed)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if !got.Equal(crt) {
+				t.Error("Verify returned another certificate")
+			}
+			if _, err := VerifyWith(signed, []*x509.Certificate{crt}); err != nil {
+				t.Errorf("VerifyWith: %v", err)
+			}
+
+			other := testCertificate(t, rsaKey, "Other")
+			if name == "rsa" {
+				other = testCertificate(t, ecKey, "Other")
+			}
+			if _, err := VerifyWith(signed, []*x509.Certificate{other}); !errors.Is(err, ErrUntrustedSigner) {
+				t.Errorf("VerifyWith(other) = %v, want ErrUntrustedSigner", err)
+			}
+
+			tampered := bytes.Replace(signed, []byte("<SchemeTerritory>ZZ"), []byte("<SchemeTerritory>ZY"), 1)
+			if _, err := Verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify(tampered) = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+
+	if _, err := Verify(doc); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("Verify(unsigned) = %v, want ErrNoSignature", err)
+	}
+}
+
+func TestParseRejectsDTD(t *testing.T) {
+	doc := []byte(`<?xml version="1.0"?><!DOCTYPE r [<!ENTITY e "x">]><r>&e;</r>`)
+	if _, err := parse(doc); err == nil {
+		t.Error("parse accepted a DTD")
+	}
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shurco/gosign/pkg/security/kms"
+)
+
+// encryptedMagic starts every object written by EncryptedStorage
+var encryptedMagic = []byte("GSE1")
+
+// ErrNotEncrypted is returned when reading an object that wasn't written by
+// EncryptedStorage and plaintext objects aren't allowed
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+// EncryptedStorage encrypts objects at rest in any BlobStorage (envelope encryption).
+// Every object gets its own AES-256-GCM data key, wrapped by the KMS and stored in a
+// header in front of the ciphertext:
+//
+//	"GSE1" | len(keyID) uint8 | keyID | len(wrapped) uint16 | wrapped data key | nonce | ciphertext+tag
+//
+// The ciphertext is authenticated together with the storage key, so an object can't
+// be passed off as another. Rewrap rotates the KEK of objects by rewriting their
+// header, the ciphertext is kept as it is.
+type EncryptedStorage struct {
+	inner BlobStorage
+	kms   kms.KMS
+
+	// AllowPlaintext returns objects without the header as they are, so documents
+	// stored before encryption was enabled stay readable
+	AllowPlaintext bool
+}
+
+// NewEncryptedStorage wraps inner so that objects are encrypted with data keys wrapped by k
+func NewEncryptedStorage(inner BlobStorage, k kms.KMS) *EncryptedStorage {
+	return &EncryptedStorage{inner: inner, kms: k}
+}
+
+// envelope is a parsed encrypted object
+type envelope struct {
+	keyID   string
+	wrapped []byte
+	sealed  []byte // nonce || ciphertext+tag
+}
+
+// Upload encrypts the content of reader and uploads it
+func (s *EncryptedStorage) Upload(ctx context.Context, key string, reader io.Reader, metadata *BlobMetadata) error {
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read content: %w", err)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newDataCipher(dataKey)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	keyID, wrapped, err := s.kms.WrapKey(ctx, dataKey)
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	env := envelope{keyID: keyID, wrapped: wrapped, sealed: aead.Seal(nonce, nonce, plaintext, objectAAD(key))}
+	data, err := env.marshal()
+	if err != nil {
+		return err
+	}
+	meta := &BlobMetadata{Size: int64(len(data)), ContentType: "application/octet-stream"}
+	if metadata != nil {
+		meta.Custom = metadata.Custom
+	}
+	return s.inner.Upload(ctx, key, bytes.NewReader(data), meta)
+}
+
+// Download downloads and decrypts an object
+func (s *EncryptedStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, err := ReadAll(ctx, s.inner, key)
+	if err != nil {
+		return nil, err
+	}
+	env, ok, err := parseEnvelope(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if !ok {
+		if !s.AllowPlaintext {
+			return nil, fmt.Errorf("%w: %s", ErrNotEncrypted, key)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	dataKey, err := s.kms.UnwrapKey(ctx, env.keyID, env.wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of %s: %w", key, err)
+	}
+	aead, err := newDataCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	n := aead.NonceSize()
+	if len(env.sealed) < n+aead.Overhead() {
+		return nil, fmt.Errorf("%s: encrypted object truncated", key)
+	}
+	plaintext, err := aead.Open(nil, env.sealed[:n], env.sealed[n:], objectAAD(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// Rewrap re-wraps with the current KEK the data keys of the objects under prefix that
+// were wrapped with another one, without re-encrypting their content. Plaintext objects
+// are left alone. It returns the number of objects rewritten.
+func (s *EncryptedStorage) Rewrap(ctx context.Context, prefix string) (int, error) {
+	keys, err := s.inner.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	current := s.kms.CurrentKeyID()
+	rewrapped := 0
+	for _, key := range keys {
+		data, err := ReadAll(ctx, s.inner, key)
+		if errors.Is(err, ErrNotFound) {
+			continue // removed meanwhile
+		}
+		if err != nil {
+			return rewrapped, err
+		}
+		env, ok, err := parseEnvelope(data)
+		if err != nil {
+			return rewrapped, fmt.Errorf("%s: %w", key, err)
+		}
+		if !ok || env.keyID == current {
+			continue
+		}
+
+		dataKey, err := s.kms.UnwrapKey(ctx, env.keyID, env.wrapped)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to unwrap data key of %s: %w", key, err)
+		}
+		if env.keyID, env.wrapped, err = s.kms.WrapKey(ctx, dataKey); err != nil {
+			return rewrapped, fmt.Errorf("failed to wrap data key of %s: %w", key, err)
+		}
+		out, err := env.marshal()
+		if err != nil {
+			return rewrapped, err
+		}
+		if err := Put(ctx, s.inner, key, out, "application/octet-stream"); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// Delete deletes an object
+func (s *EncryptedStorage) Delete(ctx context.Context, key string) error {
+	return s.inner.Delete(ctx, key)
+}
+
+// GetURL returns the signed URL of a local storage, whose files are served (decrypted)
+// by the application; other storages only hold ciphertext and give no URL
+func (s *EncryptedStorage) GetURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	return s.GetDownloadURL(ctx, key, expiration, "")
+}
+
+// GetDownloadURL is GetURL answering with the given Content-Disposition
+func (s *EncryptedStorage) GetDownloadURL(ctx context.Context, key string, expiration time.Duration, disposition string) (string, error) {
+	if local, ok := s.inner.(*LocalStorage); ok {
+		return local.GetDownloadURL(ctx, key, expiration, disposition)
+	}
+	return "", ErrDirectURL
+}
+
+// List lists objects with prefix
+func (s *EncryptedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.inner.List(ctx, prefix)
+}
+
+// Exists checks if an object exists
+func (s *EncryptedStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return s.inner.Exists(ctx, key)
+}
+
+// GetMetadata returns the metadata of the stored object; its size is that of the ciphertext
+func (s *EncryptedStorage) GetMetadata(ctx context.Context, key string) (*BlobMetadata, error) {
+	return s.inner.GetMetadata(ctx, key)
+}
+
+func newDataCipher(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// objectAAD binds a ciphertext to the key it is stored under
+func objectAAD(key string) []byte {
+	return append(append([]byte{}, encryptedMagic...), key...)
+}
+
+func (e envelope) marshal() ([]byte, error) {
+	if len(e.keyID) == 0 || len(e.keyID) > 255 || len(e.wrapped) > 0xffff {
+		return nil, fmt.Errorf("invalid wrapped data key")
+	}
+	out := make([]byte, 0, len(encryptedMagic)+1+len(e.keyID)+2+len(e.wrapped)+len(e.sealed))
+	out = append(out, encryptedMagic...)
+	out = append(out, byte(len(e.keyID)))
+	out = append(out, e.keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(e.wrapped)))
+	out = append(out, e.wrapped...)
+	return append(out, e.sealed...), nil
+}
+
+// parseEnvelope splits an encrypted object; ok is false for objects without the header
+func parseEnvelope(data []byte) (env envelope, ok bool, err error) {
+	rest, found := bytes.CutPrefix(data, encryptedMagic)
+	if !found {
+		return envelope{}, false, nil
+	}
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return envelope{}, true, errors.New("encrypted object header truncated")
+	}
+	env.keyID, rest = string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	if len(rest) < 2 {
+		return envelope{}, true, errors.New("encrypted object header truncated")
+	}
+	n := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+n {
+		return envelope{}, true, errors.New("encrypted object header truncated")
+	}
+	env.wrapped, env.sealed = rest[2:2+n], rest[2+n:]
+	return env, true, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shurco/gosign/pkg/security/kms"
+)
+
+func TestEncryptedStorage(t *testing.T) {
+	ctx := context.Background()
+	inner, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kek1, kek2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	keyring, err := kms.NewKeyring("k1", map[string][]byte{"k1": kek1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewEncryptedStorage(inner, keyring)
+
+	content := []byte("%PDF-1.7 signed contract")
+	key := Key(PrefixSigned, "contract.pdf")
+	if err := Put(ctx, s, key, content, "application/pdf"); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+	stored, err := ReadAll(ctx, inner, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, content) {
+		t.Fatal("content stored in clear")
+	}
+	if data, err := ReadAll(ctx, s, key); err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+	if _, err := s.GetURL(ctx, key, 0); !errors.Is(err, ErrDirectURL) {
+		t.Errorf("GetURL() error = %v, want %v", err, ErrDirectURL)
+	}
+
+	t.Run("bound to key", func(t *testing.T) {
+		other := Key(PrefixSigned, "other.pdf")
+		if err := Put(ctx, inner, other, stored, "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadAll(ctx, s, other); err == nil {
+			t.Fatal("decrypted an object copied under another key")
+		}
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		legacy := Key(PrefixUploads, "legacy.pdf")
+		if err := Put(ctx, inner, legacy, content, "application/pdf"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadAll(ctx, s, legacy); !errors.Is(err, ErrNotEncrypted) {
+			t.Fatalf("ReadAll() error = %v, want %v", err, ErrNotEncrypted)
+		}
+		s.AllowPlaintext = true
+		defer func() { s.AllowPlaintext = false }()
+		if data, err := ReadAll(ctx, s, legacy); err != nil || !bytes.Equal(data, content) {
+			t.Fatalf("ReadAll() = %q, %v", data, err)
+		}
+	})
+
+	t.Run("rewrap", func(t *testing.T) {
+		rotated, err := kms.NewKeyring("k2", map[string][]byte{"k1": kek1, "k2": kek2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewEncryptedStorage(inner, rotated)
+		n, err := s.Rewrap(ctx, PrefixSigned)
+		if err != nil || n != 2 { // contract.pdf and its copy
+			t.Fatalf("Rewrap() = %d, %v, want 2 objects", n, err)
+		}
+		if n, _ := s.Rewrap(ctx, PrefixSigned); n != 0 {
+			t.Errorf("second Rewrap() = %d, want 0", n)
+		}
+
+		rewrapped, err := ReadAll(ctx, inner, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, _, _ := parseEnvelope(stored)
+		after, _, _ := parseEnvelope(rewrapped)
+		if after.keyID != "k2" || !bytes.Equal(after.sealed, before.sealed) {
+			t.Fatalf("rewrapped object: key %q, ciphertext kept %v", after.keyID, bytes.Equal(after.sealed, before.sealed))
+		}
+
+		// the old KEK can be dropped once every object is rewrapped
+		current, err := kms.NewKeyring("k2", map[string][]byte{"k2": kek2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := ReadAll(ctx, NewEncryptedStorage(inner, current), key); err != nil || !bytes.Equal(data, content) {
+			t.Fatalf("ReadAll() after rotation = %q, %v", data, err)
+		}
+	})
+}
@@ -11,8 +11,13 @@ import (
 func NewStorage(ctx context.Context, cfg Config) (BlobStorage, error) {
 	switch cfg.Provider {
 	case "local":
-		// Local storage path is next to the executable, not configurable
-		return NewLocalStorage(appdir.LcUploads())
+		// Local storage lives in the data directory next to the executable;
+		// BasePath narrows it (e.g. to lc_uploads for storage tests)
+		basePath := cfg.BasePath
+		if basePath == "" {
+			basePath = appdir.DataDir()
+		}
+		return NewLocalStorage(basePath)
 
 	case "s3":
 		s3cfg := S3Config{
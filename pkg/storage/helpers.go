@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+)
+
+// Key joins a prefix and path elements into a storage key
+func Key(prefix string, elem ...string) string {
+	return path.Join(append([]string{prefix}, elem...)...)
+}
+
+// BlobKey returns the key of a content-addressed file by its hex SHA-256
+func BlobKey(checksum string) string {
+	return Key(PrefixBlobs, checksum[:2], checksum)
+}
+
+// ReadAll downloads the content of a key
+func ReadAll(ctx context.Context, s BlobStorage, key string) ([]byte, error) {
+	r, err := s.Download(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Put uploads data under a key
+func Put(ctx context.Context, s BlobStorage, key string, data []byte, contentType string) error {
+	return s.Upload(ctx, key, bytes.NewReader(data), &BlobMetadata{Size: int64(len(data)), ContentType: contentType})
+}
@@ -2,10 +2,27 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
 	"time"
 )
 
+// ErrNotFound is returned (wrapped) when a key doesn't exist
+var ErrNotFound = errors.New("file not found")
+
+// ErrDirectURL is returned by GetURL when a storage can't give out URLs of its files
+var ErrDirectURL = errors.New("no direct URL for this storage")
+
+// Key prefixes of the documents kept in the blob storage. They match the local
+// data directories, so a LocalStorage rooted at the data directory serves files
+// written before documents went through the blob storage.
+const (
+	PrefixPages   = "lc_pages"   // template pages and previews: {attachment_id}/0.pdf, 0.jpg, p/0.jpg
+	PrefixSigned  = "lc_signed"  // original, completed and certificate PDFs of submissions
+	PrefixUploads = "lc_uploads" // uploaded and signed files of the sign endpoint
+	PrefixBlobs   = "lc_blobs"   // content-addressed files: {sha256[:2]}/{sha256}
+)
+
 // BlobMetadata contains blob object metadata
 type BlobMetadata struct {
 	Size        int64
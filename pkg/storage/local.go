@@ -15,6 +15,9 @@ import (
 // LocalStorage implements storage on local file system
 type LocalStorage struct {
 	basePath string
+
+	// URLs signs the download URLs returned by GetURL; without it files have no URL
+	URLs *URLSigner
 }
 
 // NewLocalStorage creates a new local storage
@@ -31,9 +34,10 @@ func NewLocalStorage(basePath string) (*LocalStorage, error) {
 
 // getFullPath returns the full path to file
 func (s *LocalStorage) getFullPath(key string) string {
-	// Clean key from potentially dangerous characters
-	cleanKey := filepath.Clean(key)
-	cleanKey = strings.TrimPrefix(cleanKey, "/")
+	// Clean key from potentially dangerous characters; rooting it first keeps
+	// ".." from leaving basePath
+	cleanKey := filepath.Clean("/" + filepath.FromSlash(key))
+	cleanKey = strings.TrimPrefix(cleanKey, string(filepath.Separator))
 	return filepath.Join(s.basePath, cleanKey)
 }
 
@@ -47,15 +51,25 @@ func (s *LocalStorage) Upload(ctx context.Context, key string, reader io.Reader,
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Create file
-	file, err := os.Create(fullPath)
+	// Write to a temporary file and rename it, so readers never see a partial file
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*")
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
 
 	// Copy data
 	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(file.Name(), fullPath); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -69,7 +83,7 @@ func (s *LocalStorage) Download(ctx context.Context, key string) (io.ReadCloser,
 	file, err := os.Open(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
@@ -88,17 +102,23 @@ func (s *LocalStorage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-// GetURL returns local path to file
+// GetURL returns an expiring signed URL to download a file through the application
 func (s *LocalStorage) GetURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
-	fullPath := s.getFullPath(key)
+	return s.GetDownloadURL(ctx, key, expiration, "")
+}
+
+// GetDownloadURL returns an expiring signed URL answering with the given Content-Disposition
+func (s *LocalStorage) GetDownloadURL(ctx context.Context, key string, expiration time.Duration, disposition string) (string, error) {
+	if s.URLs == nil {
+		return "", ErrDirectURL
+	}
 
 	// Check file exists
-	if !utils.IsFile(fullPath) {
-		return "", fmt.Errorf("file not found: %s", key)
+	if !utils.IsFile(s.getFullPath(key)) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
 
-	// For local storage return relative path
-	return fmt.Sprintf("/uploads/%s", key), nil
+	return s.URLs.Sign(key, expiration, disposition), nil
 }
 
 // List returns list of files with prefix
@@ -143,7 +163,7 @@ func (s *LocalStorage) GetMetadata(ctx context.Context, key string) (*BlobMetada
 	info, err := os.Stat(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to get file info: %w", err)
 	}
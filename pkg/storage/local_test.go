@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	base := filepath.Join(dir, "data")
+	s, err := NewLocalStorage(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key(PrefixPages, "att-1", "0.pdf")
+	if err := Put(ctx, s, key, []byte("%PDF-1.7"), "application/pdf"); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "lc_pages", "att-1", "0.pdf")); err != nil {
+		t.Fatalf("file not written under the prefix directory: %v", err)
+	}
+	data, err := ReadAll(ctx, s, key)
+	if err != nil || string(data) != "%PDF-1.7" {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+
+	t.Run("overwrite", func(t *testing.T) {
+		if err := Put(ctx, s, key, []byte("%PDF-2.0"), "application/pdf"); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := ReadAll(ctx, s, key); string(data) != "%PDF-2.0" {
+			t.Fatalf("ReadAll() = %q", data)
+		}
+		entries, _ := os.ReadDir(filepath.Join(base, "lc_pages", "att-1"))
+		if len(entries) != 1 {
+			t.Errorf("temporary files left: %v", entries)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := ReadAll(ctx, s, Key(PrefixSigned, "missing.pdf")); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ReadAll() error = %v, want %v", err, ErrNotFound)
+		}
+		if _, err := s.GetMetadata(ctx, Key(PrefixSigned, "missing.pdf")); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetMetadata() error = %v, want %v", err, ErrNotFound)
+		}
+	})
+
+	t.Run("traversal", func(t *testing.T) {
+		if err := Put(ctx, s, "../../escaped.txt", []byte("x"), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "escaped.txt")); err == nil {
+			t.Fatal("key escaped the base path")
+		}
+		if _, err := os.Stat(filepath.Join(base, "escaped.txt")); err != nil {
+			t.Fatalf("key not kept under the base path: %v", err)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Migrator copies the objects of a storage to another one (e.g. from the local disk to S3).
+// Objects are streamed and hashed on the way; every copied object is appended to a journal,
+// so an interrupted migration resumes where it stopped.
+type Migrator struct {
+	From, To    BlobStorage
+	Concurrency int    // objects copied at once, 1 when unset
+	Journal     string // resume journal (JSON lines); "" copies everything again
+
+	mu      sync.Mutex
+	journal *os.File
+}
+
+// MigratedObject is an object copied by a migration, as recorded in the journal
+type MigratedObject struct {
+	Key    string `json:"key"`             // key in the source storage
+	NewKey string `json:"new_key"`         // key in the destination storage
+	Size   int64  `json:"size"`            // bytes
+	SHA256 string `json:"sha256"`          // hex
+	Error  string `json:"error,omitempty"` // set on failures, never journaled
+}
+
+// MigrationResult sums a migration up
+type MigrationResult struct {
+	Copied  []MigratedObject // copied by this run
+	Skipped []MigratedObject // already copied by an earlier run
+	Failed  []MigratedObject
+	Bytes   int64
+}
+
+// Renamed returns the objects whose key changed, by this run or an earlier one: old key to new key
+func (r *MigrationResult) Renamed() map[string]string {
+	renamed := map[string]string{}
+	for _, o := range append(append([]MigratedObject{}, r.Skipped...), r.Copied...) {
+		if o.NewKey != o.Key {
+			renamed[o.Key] = o.NewKey
+		}
+	}
+	return renamed
+}
+
+// MigrationKey returns the key an object is stored under in the destination: keys are
+// normalized to slash-separated clean paths (local storages list them with the separator
+// of the OS)
+func MigrationKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(key)), "/")
+}
+
+// Migrate copies the objects under prefixes that the journal doesn't list yet. An object
+// that fails is reported in the result and the others are still copied.
+func (m *Migrator) Migrate(ctx context.Context, prefixes []string) (*MigrationResult, error) {
+	done, err := m.openJournal()
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeJournal()
+
+	keys, err := listAll(ctx, m.From, prefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{}
+	var mu sync.Mutex
+	m.each(ctx, keys, func(key string) {
+		if prev, ok := done[key]; ok {
+			if meta, err := m.To.GetMetadata(ctx, prev.NewKey); err == nil && meta.Size == prev.Size {
+				mu.Lock()
+				result.Skipped = append(result.Skipped, prev)
+				mu.Unlock()
+				return
+			}
+		}
+
+		obj, err := m.copy(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			return // removed meanwhile (or a temporary file of an upload)
+		}
+		if err == nil {
+			err = m.record(obj)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			obj.Error = err.Error()
+			result.Failed = append(result.Failed, obj)
+			return
+		}
+		result.Copied = append(result.Copied, obj)
+		result.Bytes += obj.Size
+	})
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	sort.Slice(result.Copied, func(i, j int) bool { return result.Copied[i].Key < result.Copied[j].Key })
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].Key < result.Failed[j].Key })
+	return result, nil
+}
+
+// Verify compares the size and SHA-256 of every object under prefixes with its copy and
+// returns the objects that differ or are missing
+func (m *Migrator) Verify(ctx context.Context, prefixes []string) ([]MigratedObject, error) {
+	keys, err := listAll(ctx, m.From, prefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu         sync.Mutex
+		mismatches []MigratedObject
+	)
+	m.each(ctx, keys, func(key string) {
+		obj := MigratedObject{Key: key, NewKey: MigrationKey(key)}
+		src, srcErr := hashObject(ctx, m.From, key)
+		dst, dstErr := hashObject(ctx, m.To, obj.NewKey)
+		switch {
+		case errors.Is(srcErr, ErrNotFound):
+			return // removed meanwhile
+		case srcErr != nil:
+			obj.Error = srcErr.Error()
+		case dstErr != nil:
+			obj.Error = dstErr.Error()
+		case src.Size != dst.Size:
+			obj.Error = fmt.Sprintf("size %d, copy has %d", src.Size, dst.Size)
+		case src.SHA256 != dst.SHA256:
+			obj.Error = fmt.Sprintf("sha256 %s, copy has %s", src.SHA256, dst.SHA256)
+		default:
+			return
+		}
+		obj.Size, obj.SHA256 = src.Size, src.SHA256
+		mu.Lock()
+		mismatches = append(mismatches, obj)
+		mu.Unlock()
+	})
+	if err := ctx.Err(); err != nil {
+		return mismatches, err
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Key < mismatches[j].Key })
+	return mismatches, nil
+}
+
+// copy streams an object to the destination, hashing it on the way
+func (m *Migrator) copy(ctx context.Context, key string) (MigratedObject, error) {
+	obj := MigratedObject{Key: key, NewKey: MigrationKey(key)}
+	meta, err := m.From.GetMetadata(ctx, key)
+	if err != nil {
+		return obj, err
+	}
+	r, err := m.From.Download(ctx, key)
+	if err != nil {
+		return obj, err
+	}
+	defer r.Close()
+
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(obj.NewKey))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, h)}
+	if err := m.To.Upload(ctx, obj.NewKey, counter, &BlobMetadata{Size: meta.Size, ContentType: contentType}); err != nil {
+		return obj, err
+	}
+	obj.Size, obj.SHA256 = counter.n, hex.EncodeToString(h.Sum(nil))
+
+	copied, err := m.To.GetMetadata(ctx, obj.NewKey)
+	if err != nil {
+		return obj, fmt.Errorf("copy not found: %w", err)
+	}
+	if copied.Size != obj.Size {
+		return obj, fmt.Errorf("copied %d bytes, destination has %d", obj.Size, copied.Size)
+	}
+	return obj, nil
+}
+
+// each calls fn for every key with the concurrency of the migrator, until ctx is done
+func (m *Migrator) each(ctx context.Context, keys []string, fn func(key string)) {
+	workers := m.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range ch {
+				fn(key)
+			}
+		}()
+	}
+	for _, key := range keys {
+		select {
+		case ch <- key:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// openJournal loads the objects copied by earlier runs and opens the journal for appending
+func (m *Migrator) openJournal() (map[string]MigratedObject, error) {
+	done := map[string]MigratedObject{}
+	if m.Journal == "" {
+		return done, nil
+	}
+	f, err := os.OpenFile(m.Journal, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var obj MigratedObject
+		if json.Unmarshal(scanner.Bytes(), &obj) == nil && obj.Key != "" {
+			done[obj.Key] = obj
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	m.journal = f
+	return done, nil
+}
+
+func (m *Migrator) record(obj MigratedObject) error {
+	if m.journal == nil {
+		return nil
+	}
+	line, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = m.journal.Write(append(line, '\n'))
+	return err
+}
+
+func (m *Migrator) closeJournal() {
+	if m.journal != nil {
+		m.journal.Close()
+		m.journal = nil
+	}
+}
+
+// listAll lists the keys under every prefix
+func listAll(ctx context.Context, s BlobStorage, prefixes []string) ([]string, error) {
+	var keys []string
+	for _, prefix := range prefixes {
+		list, err := s.List(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		keys = append(keys, list...)
+	}
+	return keys, nil
+}
+
+// hashObject streams an object to compute its size and SHA-256
+func hashObject(ctx context.Context, s BlobStorage, key string) (MigratedObject, error) {
+	r, err := s.Download(ctx, key)
+	if err != nil {
+		return MigratedObject{}, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return MigratedObject{}, err
+	}
+	return MigratedObject{Key: key, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
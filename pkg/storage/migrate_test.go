@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	from, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		Key(PrefixPages, "att-1", "0.pdf"):              "%PDF page",
+		Key(PrefixPages, "att-1", "p", "0.jpg"):         "jpeg",
+		Key(PrefixSigned, "submission_1_completed.pdf"): "%PDF completed",
+		Key(PrefixBlobs, "ab", "abcdef"):                "blob",
+	}
+	for key, content := range files {
+		if err := Put(ctx, from, key, []byte(content), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Put(ctx, from, "assets/font.ttf", []byte("not a document"), ""); err != nil {
+		t.Fatal(err)
+	}
+	prefixes := []string{PrefixPages, PrefixSigned, PrefixUploads, PrefixBlobs}
+	journal := filepath.Join(t.TempDir(), "migrate.journal")
+
+	m := &Migrator{From: from, To: to, Concurrency: 3, Journal: journal}
+	result, err := m.Migrate(ctx, prefixes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Copied) != len(files) || len(result.Failed) != 0 || len(result.Renamed()) != 0 {
+		t.Fatalf("Migrate() = %+v", result)
+	}
+	for key, content := range files {
+		if data, err := ReadAll(ctx, to, key); err != nil || string(data) != content {
+			t.Errorf("copy of %s = %q, %v", key, data, err)
+		}
+	}
+	if exists, _ := to.Exists(ctx, "assets/font.ttf"); exists {
+		t.Error("copied a file outside the prefixes")
+	}
+	if mismatches, err := m.Verify(ctx, prefixes); err != nil || len(mismatches) != 0 {
+		t.Fatalf("Verify() = %+v, %v", mismatches, err)
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		newKey := Key(PrefixUploads, "late.pdf")
+		if err := Put(ctx, from, newKey, []byte("%PDF late"), ""); err != nil {
+			t.Fatal(err)
+		}
+		result, err := (&Migrator{From: from, To: to, Journal: journal}).Migrate(ctx, prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Copied) != 1 || result.Copied[0].Key != newKey || len(result.Skipped) != len(files) {
+			t.Fatalf("resumed Migrate() = %+v", result)
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		corrupted := Key(PrefixPages, "att-1", "0.pdf")
+		if err := Put(ctx, to, corrupted, []byte("%PDF pagf"), ""); err != nil {
+			t.Fatal(err)
+		}
+		missing := Key(PrefixBlobs, "ab", "abcdef")
+		if err := to.Delete(ctx, missing); err != nil {
+			t.Fatal(err)
+		}
+		mismatches, err := m.Verify(ctx, prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mismatches) != 2 || mismatches[0].Key != missing || mismatches[1].Key != corrupted {
+			t.Fatalf("Verify() = %+v, want %s and %s", mismatches, missing, corrupted)
+		}
+	})
+}
+
+func TestMigrationKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"lc_pages/a/0.pdf":    "lc_pages/a/0.pdf",
+		"lc_pages//a/./0.pdf": "lc_pages/a/0.pdf",
+		"/lc_signed/x.pdf":    "lc_signed/x.pdf",
+	} {
+		if got := MigrationKey(key); got != want {
+			t.Errorf("MigrationKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
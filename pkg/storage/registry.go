@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ConfigLoader returns the storage configuration of an organization, or nil
+// when the organization uses the default storage
+type ConfigLoader func(ctx context.Context, organizationID string) (*Config, error)
+
+// Registry resolves the blob storage holding the documents of an organization.
+// Organizations without a configuration of their own, and documents outside any
+// organization, use the default storage.
+//
+// The configuration is loaded on every resolution, so a change made on one node
+// is picked up by every node; clients are reused while it stays the same.
+type Registry struct {
+	def  BlobStorage
+	load ConfigLoader
+
+	// Decorate, when set, wraps every storage opened for an organization
+	// (e.g. with encryption); the default storage is given already wrapped
+	Decorate func(BlobStorage) BlobStorage
+
+	mu      sync.Mutex
+	clients map[string]registryClient
+}
+
+type registryClient struct {
+	cfg     Config
+	storage BlobStorage
+}
+
+// NewRegistry creates a registry over the default storage; load may be nil
+func NewRegistry(def BlobStorage, load ConfigLoader) *Registry {
+	return &Registry{def: def, load: load, clients: map[string]registryClient{}}
+}
+
+// Default returns the default storage
+func (r *Registry) Default() BlobStorage {
+	return r.def
+}
+
+// ForOrganization returns the storage of an organization; "" is the default storage
+func (r *Registry) ForOrganization(ctx context.Context, organizationID string) (BlobStorage, error) {
+	if organizationID == "" || r.load == nil {
+		return r.def, nil
+	}
+
+	cfg, err := r.load(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage of organization %s: %w", organizationID, err)
+	}
+	if cfg == nil {
+		r.mu.Lock()
+		delete(r.clients, organizationID)
+		r.mu.Unlock()
+		return r.def, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.clients[organizationID]; ok && reflect.DeepEqual(c.cfg, *cfg) {
+		return c.storage, nil
+	}
+	s, err := NewStorage(ctx, *cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage of organization %s: %w", organizationID, err)
+	}
+	if r.Decorate != nil {
+		s = r.Decorate(s)
+	}
+	r.clients[organizationID] = registryClient{cfg: *cfg, storage: s}
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	def, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := map[string]*Config{
+		"org-eu":  {Provider: "s3", Bucket: "eu-docs", Region: "eu-central-1", Endpoint: "http://minio:9000"},
+		"org-bad": {Provider: "ftp"},
+	}
+	loads := 0
+	r := NewRegistry(def, func(_ context.Context, organizationID string) (*Config, error) {
+		loads++
+		if organizationID == "org-down" {
+			return nil, errors.New("database down")
+		}
+		return configs[organizationID], nil
+	})
+
+	for _, orgID := range []string{"", "org-default"} {
+		if s, err := r.ForOrganization(ctx, orgID); err != nil || s != BlobStorage(def) {
+			t.Errorf("ForOrganization(%q) = %v, %v, want the default storage", orgID, s, err)
+		}
+	}
+
+	eu, err := r.ForOrganization(ctx, "org-eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := eu.(*S3Storage); !ok {
+		t.Fatalf("ForOrganization(org-eu) = %T, want *S3Storage", eu)
+	}
+	if again, _ := r.ForOrganization(ctx, "org-eu"); again != eu {
+		t.Error("client not reused for an unchanged configuration")
+	}
+
+	configs["org-eu"] = &Config{Provider: "s3", Bucket: "eu-docs-2", Region: "eu-central-1", Endpoint: "http://minio:9000"}
+	if changed, _ := r.ForOrganization(ctx, "org-eu"); changed == eu {
+		t.Error("client reused after the configuration changed")
+	}
+
+	delete(configs, "org-eu")
+	if s, _ := r.ForOrganization(ctx, "org-eu"); s != BlobStorage(def) {
+		t.Error("removed configuration still resolves to its bucket")
+	}
+
+	if _, err := r.ForOrganization(ctx, "org-bad"); err == nil {
+		t.Error("unsupported provider resolved")
+	}
+	if _, err := r.ForOrganization(ctx, "org-down"); err == nil {
+		t.Error("load error not reported")
+	}
+	if loads != 7 {
+		t.Errorf("loads = %d, want 7 (the default scope is never loaded)", loads)
+	}
+}
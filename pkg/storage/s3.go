@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -28,8 +30,14 @@ type S3Config struct {
 
 // NewS3Storage creates new S3 storage
 func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
-	// Determine endpoint
+	// Determine endpoint; a scheme in it ("http://minio:9000") decides SSL
 	endpoint := cfg.Endpoint
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		endpoint, cfg.UseSSL = rest, true
+	} else if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		endpoint = rest
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
 	if endpoint == "" {
 		// Default AWS S3 endpoint based on region
 		if cfg.Region == "" {
@@ -64,12 +72,16 @@ func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
 // Upload uploads file to S3
 func (s *S3Storage) Upload(ctx context.Context, key string, reader io.Reader, metadata *BlobMetadata) error {
 	opts := minio.PutObjectOptions{}
+	size := int64(-1)
 
 	if metadata != nil && metadata.ContentType != "" {
 		opts.ContentType = metadata.ContentType
 	}
+	if metadata != nil && metadata.Size > 0 {
+		size = metadata.Size
+	}
 
-	_, err := s.client.PutObject(ctx, s.bucket, key, reader, -1, opts)
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, size, opts)
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -84,6 +96,14 @@ func (s *S3Storage) Download(ctx context.Context, key string) (io.ReadCloser, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to download from S3: %w", err)
 	}
+	// Stat sends the request, so a missing key is reported here
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to download from S3: %w", err)
+	}
 
 	return obj, nil
 }
@@ -100,12 +120,21 @@ func (s *S3Storage) Delete(ctx context.Context, key string) error {
 
 // GetURL returns presigned URL for file access
 func (s *S3Storage) GetURL(ctx context.Context, key string, expiration time.Duration) (string, error) {
-	url, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiration, nil)
+	return s.GetDownloadURL(ctx, key, expiration, "")
+}
+
+// GetDownloadURL returns presigned URL answering with the given Content-Disposition
+func (s *S3Storage) GetDownloadURL(ctx context.Context, key string, expiration time.Duration, disposition string) (string, error) {
+	var params url.Values
+	if disposition != "" {
+		params = url.Values{"response-content-disposition": {disposition}}
+	}
+	presigned, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiration, params)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
 
-	return url.String(), nil
+	return presigned.String(), nil
 }
 
 // List returns list of files with prefix
@@ -132,8 +161,7 @@ func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
 	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
 	if err != nil {
 		// Check if error is "not found"
-		errResp := minio.ToErrorResponse(err)
-		if errResp.Code == "NoSuchKey" || errResp.Code == "NotFound" {
+		if isNotFound(err) {
 			return false, nil
 		}
 		return false, fmt.Errorf("failed to check S3 object: %w", err)
@@ -146,6 +174,9 @@ func (s *S3Storage) Exists(ctx context.Context, key string) (bool, error) {
 func (s *S3Storage) GetMetadata(ctx context.Context, key string) (*BlobMetadata, error) {
 	objInfo, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
 	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
 		return nil, fmt.Errorf("failed to get S3 metadata: %w", err)
 	}
 
@@ -159,3 +190,9 @@ func (s *S3Storage) GetMetadata(ctx context.Context, key string) (*BlobMetadata,
 	return metadata, nil
 }
 
+
+// isNotFound reports whether an S3 error is a missing key
+func isNotFound(err error) bool {
+	errResp := minio.ToErrorResponse(err)
+	return errResp.Code == "NoSuchKey" || errResp.Code == "NotFound"
+}
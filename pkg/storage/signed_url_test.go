@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	u := NewURLSigner([]byte("secret"), "https://sign.example.com/drive/files/")
+	u.now = func() time.Time { return now }
+
+	key := Key(PrefixSigned, "submission 1", "contract.pdf")
+	disposition := `attachment; filename="contract.pdf"`
+	raw := u.Sign(key, time.Hour, disposition)
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, "https://sign.example.com/drive/files/lc_signed/submission%201/contract.pdf?") {
+		t.Fatalf("Sign() = %s", raw)
+	}
+	q := parsed.Query()
+	verify := func(key, disposition string) error {
+		return u.Verify(key, q.Get("expires"), disposition, q.Get("signature"))
+	}
+
+	if err := verify(key, disposition); err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if err := verify(Key(PrefixSigned, "other.pdf"), disposition); !errors.Is(err, ErrURLSignature) {
+		t.Errorf("Verify(other key) error = %v, want %v", err, ErrURLSignature)
+	}
+	if err := verify(key, "inline"); !errors.Is(err, ErrURLSignature) {
+		t.Errorf("Verify(other disposition) error = %v, want %v", err, ErrURLSignature)
+	}
+	if err := u.Verify(key, q.Get("expires")+"0", disposition, q.Get("signature")); !errors.Is(err, ErrURLSignature) {
+		t.Errorf("Verify(extended expiry) error = %v, want %v", err, ErrURLSignature)
+	}
+
+	now = now.Add(time.Hour)
+	if err := verify(key, disposition); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("Verify() after expiry error = %v, want %v", err, ErrURLExpired)
+	}
+}
+
+func TestURLSigner_Token(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	u := NewURLSigner([]byte("secret"), "/drive/files")
+	u.now = func() time.Time { return now }
+
+	prefix := Key(PrefixPages, "attachment-1")
+	token := u.Token(prefix, time.Hour)
+	if err := u.VerifyToken(prefix, token); err != nil {
+		t.Fatalf("VerifyToken() error: %v", err)
+	}
+	for name, tt := range map[string]struct{ prefix, token string }{
+		"other prefix": {Key(PrefixPages, "attachment-2"), token},
+		"no signature": {prefix, strings.Split(token, ".")[0]},
+		// a file URL of the prefix doesn't grant the directory
+		"file signature": {prefix, strings.Split(token, ".")[0] + "." + u.signature(prefix, strings.Split(token, ".")[0], "")},
+	} {
+		if err := u.VerifyToken(tt.prefix, tt.token); !errors.Is(err, ErrURLSignature) {
+			t.Errorf("%s: VerifyToken() error = %v, want %v", name, err, ErrURLSignature)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if err := u.VerifyToken(prefix, token); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("VerifyToken() after expiry error = %v, want %v", err, ErrURLExpired)
+	}
+}
+
+func TestLocalStorage_GetURL(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key(PrefixUploads, "file.pdf")
+	if err := Put(ctx, s, key, []byte("%PDF"), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetURL(ctx, key, time.Minute); !errors.Is(err, ErrDirectURL) {
+		t.Fatalf("GetURL() without signer error = %v, want %v", err, ErrDirectURL)
+	}
+
+	s.URLs = NewURLSigner([]byte("secret"), "/drive/files")
+	raw, err := DownloadURL(ctx, s, key, time.Minute, "inline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(raw)
+	q := parsed.Query()
+	if parsed.Path != "/drive/files/lc_uploads/file.pdf" || q.Get("disposition") != "inline" {
+		t.Fatalf("DownloadURL() = %s", raw)
+	}
+	if err := s.URLs.Verify(key, q.Get("expires"), q.Get("disposition"), q.Get("signature")); err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if _, err := s.GetURL(ctx, Key(PrefixUploads, "missing.pdf"), time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetURL(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -1,6 +1,8 @@
 package webutil
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v3"
 )
 
@@ -23,3 +25,10 @@ func Response(c fiber.Ctx, code int, message string, data any) error {
 
 	return c.Status(code).JSON(data)
 }
+
+// Attachment sends data as a file download named filename.
+func Attachment(c fiber.Ctx, data []byte, filename, contentType string) error {
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Send(data)
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is attempted before it is dead
+	MaxAttempts = 12
+	// ResponseExcerptSize is how much of the response body an attempt keeps
+	ResponseExcerptSize = 1024
+	// responseDrainSize is how much more of the response body is read so the connection can
+	// be reused; the connection of a longer response is closed instead
+	responseDrainSize = 64 << 10
+
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = 6 * time.Hour
+)
+
+// Attempt is the outcome of a single delivery attempt
+type Attempt struct {
+	StatusCode int    // 0 when no response was received
+	Body       string // excerpt of the response body
+	Latency    time.Duration
+	Err        error // nil when the endpoint answered 2xx
+}
+
+// Backoff returns the delay before the next attempt of a delivery attempted attempts
+// times: 30s, 1m, 2m, 4m… up to 6h (the 12 attempts span about 15 hours)
+func Backoff(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// Deliver posts an event payload to a webhook once. The request is signed as specified by
+// Standard Webhooks with every active secret of the webhook, eventID being the webhook-id
+// the endpoint can ignore redeliveries by; X-Webhook-Signature keeps the HMAC of the body
+// alone for endpoints verifying deliveries the former way.
+func (d *Dispatcher) Deliver(ctx context.Context, webhook *models.Webhook, eventID string, payload []byte) *Attempt {
+	header := http.Header{}
+	if err := SignHeaders(header, eventID, time.Now(), payload, webhook.SigningSecrets(time.Now())...); err != nil {
+		return &Attempt{Err: err}
+	}
+	header.Set("X-Webhook-Signature", generateSignature(payload, webhook.Secret))
+	return d.post(ctx, webhook.URL, payload, header)
+}
+
+// post executes the HTTP request of an attempt
+func (d *Dispatcher) post(ctx context.Context, url string, payload []byte, header http.Header) *Attempt {
+	attempt := &Attempt{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Err = fmt.Errorf("failed to create request: %w", err)
+		return attempt
+	}
+	for name := range header {
+		req.Header.Set(name, header.Get(name))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goSign-Webhook/1.0")
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Latency = time.Since(start)
+		attempt.Err = fmt.Errorf("failed to send request: %w", err)
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, ResponseExcerptSize))
+	// Closing a body that wasn't read to the end drops the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, responseDrainSize))
+	attempt.Latency = time.Since(start)
+	attempt.StatusCode = resp.StatusCode
+	attempt.Body = excerpt(body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Err = fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, attempt.Body)
+	}
+	return attempt
+}
+
+// excerpt returns body as text that can be stored: valid UTF-8 without NUL bytes
+func excerpt(body []byte) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+}
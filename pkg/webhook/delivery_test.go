@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shurco/gosign/internal/models"
+)
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, d := range want {
+		if got := Backoff(i + 1); got != d {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, d)
+		}
+	}
+	if got := Backoff(MaxAttempts); got != 6*time.Hour {
+		t.Errorf("Backoff(%d) = %v, want the 6h cap", MaxAttempts, got)
+	}
+	if got := Backoff(1000); got != 6*time.Hour {
+		t.Errorf("Backoff(1000) = %v, want the 6h cap", got)
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	payload := []byte(`{"type":"submission.completed"}`)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(payload) {
+			t.Errorf("body = %s", body)
+		}
+		if got := r.Header.Get(HeaderID); got != "event-1" {
+			t.Errorf("webhook-id = %q", got)
+		}
+		for _, secret := range []string{"secret", "previous"} {
+			if err := verifierOf(t, secret).Verify(body, r.Header); err != nil {
+				t.Errorf("signature of %s: %v", secret, err)
+			}
+		}
+		if err := verifierOf(t, "expired").Verify(body, r.Header); err == nil {
+			t.Error("delivery signed with an expired secret")
+		}
+		if !VerifySignature(body, r.Header.Get("X-Webhook-Signature"), "secret") {
+			t.Error("legacy signature doesn't verify")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(strings.Repeat("x", 2*ResponseExcerptSize)))
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(0, 5*time.Second)
+	hook := &models.Webhook{URL: srv.URL, Secret: "secret", Enabled: true, PreviousSecrets: []models.WebhookSecret{
+		{Secret: "previous", ExpiresAt: time.Now().Add(time.Hour)},
+		{Secret: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+	}}
+
+	attempt := d.Deliver(context.Background(), hook, "event-1", payload)
+	if attempt.Err != nil || attempt.StatusCode != http.StatusOK {
+		t.Fatalf("Deliver = %d, %v", attempt.StatusCode, attempt.Err)
+	}
+	if len(attempt.Body) != ResponseExcerptSize {
+		t.Errorf("body excerpt has %d bytes, want %d", len(attempt.Body), ResponseExcerptSize)
+	}
+	if attempt.Latency <= 0 {
+		t.Error("latency not measured")
+	}
+
+	status = http.StatusServiceUnavailable
+	if attempt := d.Deliver(context.Background(), hook, "event-1", payload); attempt.Err == nil || attempt.StatusCode != status {
+		t.Fatalf("Deliver = %d, %v, want a failed attempt with the status", attempt.StatusCode, attempt.Err)
+	}
+
+	srv.Close()
+	if attempt := d.Deliver(context.Background(), hook, "event-1", payload); attempt.Err == nil || attempt.StatusCode != 0 {
+		t.Fatalf("Deliver = %d, %v, want a failed attempt without response", attempt.StatusCode, attempt.Err)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if got := excerpt([]byte("ok\x00\xff")); got != "ok�" {
+		t.Errorf("excerpt = %q", got)
+	}
+}
+
+func verifierOf(t *testing.T, secret string) *Verifier {
+	v, err := NewVerifier(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDispatcher_DeliverLargeResponse(t *testing.T) {
+	// An endpoint streaming an endless body must not hold the attempt
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := []byte(strings.Repeat("x", 32<<10))
+		for r.Context().Err() == nil {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(0, 5*time.Second)
+	hook := &models.Webhook{URL: srv.URL, Secret: "secret", Enabled: true}
+	attempt := d.Deliver(context.Background(), hook, "event-1", []byte(`{}`))
+	if attempt.Err != nil || attempt.StatusCode != http.StatusOK {
+		t.Fatalf("Deliver = %d, %v", attempt.StatusCode, attempt.Err)
+	}
+	if len(attempt.Body) != ResponseExcerptSize {
+		t.Errorf("body excerpt has %d bytes, want %d", len(attempt.Body), ResponseExcerptSize)
+	}
+	if attempt.Latency > 3*time.Second {
+		t.Errorf("attempt took %v reading the response", attempt.Latency)
+	}
+}
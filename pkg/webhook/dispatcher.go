@@ -1,18 +1,17 @@
 package webhook
 
 import (
-	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"slices"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/shurco/gosign/internal/models"
 )
@@ -53,8 +52,8 @@ func (d *Dispatcher) Send(ctx context.Context, webhook *models.Webhook, event *m
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	// Generate signature
-	signature := d.generateSignature(payload, webhook.Secret)
+	// Every attempt carries the same message ID
+	messageID := uuid.NewString()
 
 	// Send with retry
 	var lastErr error
@@ -69,7 +68,7 @@ func (d *Dispatcher) Send(ctx context.Context, webhook *models.Webhook, event *m
 			}
 		}
 
-		if err := d.sendRequest(ctx, webhook.URL, payload, signature); err != nil {
+		if err := d.Deliver(ctx, webhook, messageID, payload).Err; err != nil {
 			lastErr = err
 			log.Warn().
 				Err(err).
@@ -92,41 +91,6 @@ func (d *Dispatcher) Send(ctx context.Context, webhook *models.Webhook, event *m
 	return fmt.Errorf("failed after %d retries: %w", d.maxRetries, lastErr)
 }
 
-// sendRequest executes HTTP request
-func (d *Dispatcher) sendRequest(ctx context.Context, url string, payload []byte, signature string) error {
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Webhook-Signature", signature)
-	req.Header.Set("User-Agent", "goSign-Webhook/1.0")
-
-	resp, err := d.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response (for logging)
-	body, _ := io.ReadAll(resp.Body)
-
-	// Check status code
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return nil
-}
-
-// generateSignature generates HMAC-SHA256 signature
-func (d *Dispatcher) generateSignature(payload []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	return hex.EncodeToString(h.Sum(nil))
-}
-
 // isSubscribed checks if webhook is subscribed to event
 func (d *Dispatcher) isSubscribed(webhook *models.Webhook, eventType string) bool {
 	return slices.Contains(webhook.Events, eventType) || slices.Contains(webhook.Events, "*")
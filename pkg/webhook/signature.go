@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a delivery signed as specified by Standard Webhooks (https://www.standardwebhooks.com)
+const (
+	HeaderID        = "webhook-id"        // message ID, the same for every attempt and redelivery of an event
+	HeaderTimestamp = "webhook-timestamp" // Unix seconds of the attempt
+	HeaderSignature = "webhook-signature" // space-separated "v1,<base64 HMAC-SHA256>", one per active secret
+
+	// SecretPrefix starts the secrets generated by NewSecret; the rest is the base64 key
+	SecretPrefix = "whsec_"
+
+	// DefaultTolerance is how far the timestamp of a delivery may be from the clock of the verifier
+	DefaultTolerance = 5 * time.Minute
+
+	signatureVersion = "v1"
+)
+
+var (
+	ErrMissingHeaders   = errors.New("webhook: missing signature headers")
+	ErrInvalidTimestamp = errors.New("webhook: invalid timestamp")
+	ErrTimestampTooOld  = errors.New("webhook: timestamp too old")
+	ErrTimestampTooNew  = errors.New("webhook: timestamp too new")
+	ErrNoMatchingSecret = errors.New("webhook: no matching signature")
+)
+
+// NewSecret generates a signing secret: "whsec_" and 32 random bytes in base64
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// secretKey returns the HMAC key of a secret: the base64 key of a "whsec_" secret, the
+// bytes of any other (secrets set before signatures were versioned)
+func secretKey(secret string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(secret, SecretPrefix)
+	if !ok {
+		return []byte(secret), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: invalid secret: %w", err)
+	}
+	return key, nil
+}
+
+// Sign returns the value of the webhook-signature header of a message: a v1 signature of
+// "{id}.{timestamp}.{payload}" for each secret, so receivers verify with any of them
+// while a secret is rotated
+func Sign(id string, timestamp time.Time, payload []byte, secrets ...string) (string, error) {
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		key, err := secretKey(secret)
+		if err != nil {
+			return "", err
+		}
+		signatures = append(signatures, signatureVersion+","+sign(key, id, timestamp.Unix(), payload))
+	}
+	return strings.Join(signatures, " "), nil
+}
+
+// SignHeaders sets the webhook-id, webhook-timestamp and webhook-signature headers of a message
+func SignHeaders(header http.Header, id string, timestamp time.Time, payload []byte, secrets ...string) error {
+	signature, err := Sign(id, timestamp, payload, secrets...)
+	if err != nil {
+		return err
+	}
+	header.Set(HeaderID, id)
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HeaderSignature, signature)
+	return nil
+}
+
+func sign(key []byte, id string, timestamp int64, payload []byte) string {
+	h := hmac.New(sha256.New, key)
+	fmt.Fprintf(h, "%s.%d.", id, timestamp)
+	h.Write(payload)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verifier verifies deliveries received by a webhook endpoint:
+//
+//	verifier, err := webhook.NewVerifier(os.Getenv("WEBHOOK_SECRET"))
+//	...
+//	body, _ := io.ReadAll(r.Body)
+//	if err := verifier.Verify(body, r.Header); err != nil {
+//		http.Error(w, err.Error(), http.StatusUnauthorized)
+//		return
+//	}
+//
+// A delivery is accepted when a signature matches one of the secrets (pass the old and
+// the new secret while rotating) and its timestamp is within Tolerance of the clock,
+// which rejects replays of old deliveries. Receivers should also ignore webhook-ids
+// they have already processed, as a delivery may be retried or redelivered.
+type Verifier struct {
+	Tolerance time.Duration // DefaultTolerance when zero
+
+	keys [][]byte
+	now  func() time.Time
+}
+
+// NewVerifier creates a verifier accepting the signatures of any of secrets
+func NewVerifier(secrets ...string) (*Verifier, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("webhook: no secret")
+	}
+	v := &Verifier{now: time.Now}
+	for _, secret := range secrets {
+		key, err := secretKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, key)
+	}
+	return v, nil
+}
+
+// Verify checks the signature headers of a delivery against its raw body
+func (v *Verifier) Verify(payload []byte, header http.Header) error {
+	id, ts, signatures := header.Get(HeaderID), header.Get(HeaderTimestamp), header.Get(HeaderSignature)
+	if id == "" || ts == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	now := v.now()
+	switch sent := time.Unix(timestamp, 0); {
+	case now.Sub(sent) > tolerance:
+		return ErrTimestampTooOld
+	case sent.Sub(now) > tolerance:
+		return ErrTimestampTooNew
+	}
+
+	for _, key := range v.keys {
+		expected := sign(key, id, timestamp, payload)
+		for _, versioned := range strings.Fields(signatures) {
+			version, signature, ok := strings.Cut(versioned, ",")
+			if ok && version == signatureVersion && hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return ErrNoMatchingSecret
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vector of the Standard Webhooks specification
+func TestSign_StandardWebhooksVector(t *testing.T) {
+	const (
+		secret  = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+		id      = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+		payload = `{"test": 2432232314}`
+		want    = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+	)
+	got, err := Sign(id, time.Unix(1614265330, 0), []byte(payload), secret)
+	if err != nil || got != want {
+		t.Fatalf("Sign = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	oldSecret, _ := NewSecret()
+	newSecret, _ := NewSecret()
+	if !strings.HasPrefix(newSecret, SecretPrefix) || oldSecret == newSecret {
+		t.Fatalf("NewSecret = %q", newSecret)
+	}
+	now := time.Unix(1760000000, 0)
+	payload := []byte(`{"type":"submission.completed"}`)
+
+	signed := func(ts time.Time, secrets ...string) http.Header {
+		header := http.Header{}
+		if err := SignHeaders(header, "event-1", ts, payload, secrets...); err != nil {
+			t.Fatal(err)
+		}
+		return header
+	}
+
+	// during a rotation deliveries carry both signatures
+	rotating := signed(now, newSecret, oldSecret)
+	if n := len(strings.Fields(rotating.Get(HeaderSignature))); n != 2 {
+		t.Fatalf("%d signatures, want 2", n)
+	}
+
+	tests := []struct {
+		name    string
+		secrets []string
+		header  http.Header
+		payload []byte
+		want    error
+	}{
+		{"current secret", []string{newSecret}, signed(now, newSecret), payload, nil},
+		{"old secret while rotating", []string{oldSecret}, rotating, payload, nil},
+		{"receiver rotating", []string{newSecret, oldSecret}, signed(now, oldSecret), payload, nil},
+		{"legacy secret", []string{"plain secret"}, signed(now, "plain secret"), payload, nil},
+		{"within tolerance", []string{newSecret}, signed(now.Add(-4*time.Minute), newSecret), payload, nil},
+		{"other secret", []string{oldSecret}, signed(now, newSecret), payload, ErrNoMatchingSecret},
+		{"tampered payload", []string{newSecret}, signed(now, newSecret), []byte(`{"type":"submission.created"}`), ErrNoMatchingSecret},
+		{"replayed", []string{newSecret}, signed(now.Add(-time.Hour), newSecret), payload, ErrTimestampTooOld},
+		{"from the future", []string{newSecret}, signed(now.Add(time.Hour), newSecret), payload, ErrTimestampTooNew},
+		{"no headers", []string{newSecret}, http.Header{}, payload, ErrMissingHeaders},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(tt.secrets...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.now = func() time.Time { return now }
+			if err := v.Verify(tt.payload, tt.header); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// a re-signed timestamp doesn't match the signature
+	header := signed(now, newSecret)
+	header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix()+1, 10))
+	v, _ := NewVerifier(newSecret)
+	v.now = func() time.Time { return now }
+	if err := v.Verify(payload, header); !errors.Is(err, ErrNoMatchingSecret) {
+		t.Fatalf("Verify with a changed timestamp = %v", err)
+	}
+
+	v.Tolerance = 2 * time.Hour
+	if err := v.Verify(payload, signed(now.Add(-time.Hour), newSecret)); err != nil {
+		t.Fatalf("Verify with a larger tolerance = %v", err)
+	}
+}
+
+func TestNewVerifier_InvalidSecret(t *testing.T) {
+	if _, err := NewVerifier(); err == nil {
+		t.Error("NewVerifier without secrets succeeded")
+	}
+	if _, err := NewVerifier(SecretPrefix + "not base64!"); err == nil {
+		t.Error("NewVerifier with an invalid secret succeeded")
+	}
+	if _, err := secretKey(SecretPrefix + base64.StdEncoding.EncodeToString([]byte("key"))); err != nil {
+		t.Error(err)
+	}
+}
@@ -331,6 +331,8 @@ export interface Sign {
   error?: string;
   file_name?: string;
   file_name_signed?: string;
+  url?: string;
+  url_signed?: string;
 }
 
 //////////
@@ -675,6 +677,7 @@ export interface TrustedIssuer {
   valid: boolean;
   list: string;
   name: string;
+  location?: string;
 }
 /**
  * TimeStamp is ...